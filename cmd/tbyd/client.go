@@ -16,6 +16,8 @@ type apiClient struct {
 	baseURL    string
 	token      string
 	httpClient *http.Client
+	persona    string // sent as X-TBYD-Persona when set
+	project    string // sent as X-TBYD-Project when set
 }
 
 var newAPIClient = func() (*apiClient, error) {
@@ -51,6 +53,13 @@ func (c *apiClient) do(ctx context.Context, method, path string, body any) (*htt
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-TBYD-Source", "cli")
+	if c.persona != "" {
+		req.Header.Set("X-TBYD-Persona", c.persona)
+	}
+	if c.project != "" {
+		req.Header.Set("X-TBYD-Project", c.project)
+	}
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
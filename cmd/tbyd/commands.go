@@ -1,17 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/kalambet/tbyd/internal/cache"
 	"github.com/kalambet/tbyd/internal/config"
+	"github.com/kalambet/tbyd/internal/profile"
 )
 
 // --- ingest ---
@@ -73,10 +79,14 @@ Examples:
 			}
 		}
 
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
+		project, _ := cmd.Flags().GetString("project")
+		if client.project, err = projectSelector(project); err != nil {
+			return err
+		}
 
 		resp, err := client.post(cmd.Context(), "/ingest", req)
 		if err != nil {
@@ -99,6 +109,22 @@ func init() {
 	ingestCmd.Flags().String("file", "", "file path to ingest")
 	ingestCmd.Flags().String("title", "", "title for the document")
 	ingestCmd.Flags().String("tags", "", "comma-separated tags")
+	ingestCmd.Flags().String("persona", "", "ingest into this persona's knowledge-base partition, if it has one")
+	ingestCmd.Flags().String("project", "", "project name or directory to tag the document with (default: the current directory's project)")
+}
+
+// projectSelector returns the X-TBYD-Project value for a --project flag: a
+// project name as given, a directory made absolute, or the current working
+// directory when the flag is empty. A directory outside every project root
+// selects no project.
+func projectSelector(flag string) (string, error) {
+	if flag == "" {
+		return os.Getwd()
+	}
+	if flag == "." || flag == ".." || strings.ContainsRune(flag, filepath.Separator) {
+		return filepath.Abs(flag)
+	}
+	return flag, nil
 }
 
 // --- profile ---
@@ -108,11 +134,21 @@ var profileCmd = &cobra.Command{
 	Short: "Manage user profile",
 }
 
+// newPersonaClient returns an API client scoped to the command's --persona flag.
+func newPersonaClient(cmd *cobra.Command) (*apiClient, error) {
+	client, err := newAPIClient()
+	if err != nil {
+		return nil, err
+	}
+	client.persona, _ = cmd.Flags().GetString("persona")
+	return client, nil
+}
+
 var profileShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current profile as JSON",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -140,7 +176,7 @@ var profileSetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		key, value := args[0], args[1]
 
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -170,7 +206,7 @@ var profileEditCmd = &cobra.Command{
 			editor = "vi"
 		}
 
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -238,371 +274,1631 @@ var profileEditCmd = &cobra.Command{
 	},
 }
 
-func init() {
-	profileCmd.AddCommand(profileShowCmd)
-	profileCmd.AddCommand(profileSetCmd)
-	profileCmd.AddCommand(profileEditCmd)
+type profileChange struct {
+	Key string `json:"key"`
+	Op  string `json:"op"`
+	Old string `json:"old"`
+	New string `json:"new"`
 }
 
-// --- recall ---
+func printProfileChanges(changes []profileChange) {
+	for _, c := range changes {
+		switch c.Op {
+		case "add":
+			fmt.Printf("  %s %s = %s\n", colorize(colorGreen, "+"), c.Key, c.New)
+		case "delete":
+			fmt.Printf("  %s %s (was %s)\n", colorize(colorRed, "-"), c.Key, c.Old)
+		default:
+			fmt.Printf("  %s %s: %s -> %s\n", colorize(colorYellow, "~"), c.Key, c.Old, c.New)
+		}
+	}
+}
 
-var recallCmd = &cobra.Command{
-	Use:   "recall <query>",
-	Short: "Semantic search over the knowledge base",
-	Args:  cobra.MinimumNArgs(1),
+var profileHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List profile versions, newest first",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		query := strings.Join(args, " ")
 		limit, _ := cmd.Flags().GetInt("limit")
 
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		path := fmt.Sprintf("/recall?q=%s&limit=%d", url.QueryEscape(query), limit)
-		resp, err := client.get(cmd.Context(), path)
+		resp, err := client.get(cmd.Context(), fmt.Sprintf("/profile/history?limit=%d", limit))
 		if err != nil {
 			return err
 		}
 
-		var results []struct {
-			ID         string  `json:"id"`
-			SourceID   string  `json:"source_id"`
-			SourceType string  `json:"source_type"`
-			Text       string  `json:"text"`
-			Score      float32 `json:"score"`
-			Tags       string  `json:"tags"`
+		var versions []struct {
+			Version   int64           `json:"version"`
+			CreatedAt string          `json:"created_at"`
+			Source    string          `json:"source"`
+			Summary   string          `json:"summary"`
+			Changes   []profileChange `json:"changes"`
 		}
-		if err := decodeJSON(resp, &results); err != nil {
+		if err := decodeJSON(resp, &versions); err != nil {
 			return err
 		}
 
-		if len(results) == 0 {
-			fmt.Println("No results found.")
+		if len(versions) == 0 {
+			fmt.Println("No profile history.")
 			return nil
 		}
 
-		for i, r := range results {
-			fmt.Printf("\n%s [score: %.3f]\n", colorize(colorBold, fmt.Sprintf("Result %d", i+1)), r.Score)
-			var tags []string
-			if r.Tags != "" {
-				if json.Unmarshal([]byte(r.Tags), &tags) == nil && len(tags) > 0 {
-					fmt.Printf("  Tags: %s\n", strings.Join(tags, ", "))
-				}
-			}
-			text := r.Text
-			if len(text) > 500 {
-				text = text[:500] + "..."
-			}
-			fmt.Printf("  %s\n", text)
+		for _, v := range versions {
+			fmt.Printf("%s  %s  %-8s  %s\n",
+				colorize(colorCyan, fmt.Sprintf("v%d", v.Version)),
+				v.CreatedAt,
+				v.Source,
+				v.Summary,
+			)
+			printProfileChanges(v.Changes)
 		}
 		return nil
 	},
 }
 
-func init() {
-	recallCmd.Flags().Int("limit", 5, "maximum number of results")
-}
-
-// --- interactions ---
-
-var interactionsCmd = &cobra.Command{
-	Use:   "interactions",
-	Short: "Manage interaction history",
-}
-
-var interactionsListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List recent interactions",
+var profileDiffCmd = &cobra.Command{
+	Use:   "diff <v1> <v2>",
+	Short: "Show how the profile changed between two versions",
+	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		limit, _ := cmd.Flags().GetInt("limit")
-
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		path := fmt.Sprintf("/interactions?limit=%d", limit)
-		resp, err := client.get(cmd.Context(), path)
+		q := url.Values{"from": {args[0]}, "to": {args[1]}}
+		resp, err := client.get(cmd.Context(), "/profile/diff?"+q.Encode())
 		if err != nil {
 			return err
 		}
 
-		var interactions []struct {
-			ID            string `json:"id"`
-			CreatedAt     string `json:"created_at"`
-			UserQuery     string `json:"user_query"`
-			Status        string `json:"status"`
-			FeedbackScore int    `json:"feedback_score"`
+		var result struct {
+			Changes []profileChange `json:"changes"`
 		}
-		if err := decodeJSON(resp, &interactions); err != nil {
+		if err := decodeJSON(resp, &result); err != nil {
 			return err
 		}
 
-		if len(interactions) == 0 {
-			fmt.Println("No interactions found.")
+		if len(result.Changes) == 0 {
+			fmt.Println("No differences.")
 			return nil
 		}
-
-		for _, ix := range interactions {
-			query := ix.UserQuery
-			if len(query) > 80 {
-				query = query[:80] + "..."
-			}
-			var rating string
-			switch ix.FeedbackScore {
-			case 1:
-				rating = colorize(colorGreen, "+1")
-			case -1:
-				rating = colorize(colorRed, "-1")
-			default:
-				rating = "  "
-			}
-			fmt.Printf("%s  %s  %s  %s\n",
-				colorize(colorCyan, ix.ID[:8]),
-				ix.CreatedAt,
-				rating,
-				query,
-			)
-		}
+		printProfileChanges(result.Changes)
 		return nil
 	},
 }
 
-var interactionsShowCmd = &cobra.Command{
-	Use:   "show <id>",
-	Short: "Show a single interaction",
+var profileRollbackCmd = &cobra.Command{
+	Use:   "rollback <version>",
+	Short: "Restore the profile as of a version (recorded as a new version)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.get(cmd.Context(), "/interactions/"+args[0])
+		resp, err := client.post(cmd.Context(), "/profile/rollback/"+url.PathEscape(args[0]), nil)
 		if err != nil {
 			return err
 		}
 
-		var interaction any
-		if err := decodeJSON(resp, &interaction); err != nil {
+		var result struct {
+			Version int64 `json:"version"`
+		}
+		if err := decodeJSON(resp, &result); err != nil {
 			return err
 		}
 
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		return enc.Encode(interaction)
+		printSuccess("Rolled back to v%s (now v%d)", args[0], result.Version)
+		return nil
 	},
 }
 
-var interactionsRateCmd = &cobra.Command{
-	Use:   "rate <id>",
-	Short: "Rate an interaction as positive or negative",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		id := args[0]
-		positive, _ := cmd.Flags().GetBool("positive")
-		negative, _ := cmd.Flags().GetBool("negative")
-		note, _ := cmd.Flags().GetString("notes")
-
-		if positive == negative {
-			return fmt.Errorf("exactly one of --positive or --negative is required")
-		}
+var profileExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the profile and pending deltas as portable JSON",
+	Long: `Export the profile, and for the default persona its pending deltas, as a
+JSON document that tbyd profile import accepts on any machine. The document
+format is described by the JSON Schema served at GET /profile/schema.
 
-		score := 1
-		if negative {
-			score = -1
-		}
+Examples:
+  tbyd profile export > profile.json
+  tbyd profile export --output team-baseline.json --persona work`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
 
-		client, err := newAPIClient()
+		client, err := newPersonaClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		body := map[string]any{"score": score}
-		if note != "" {
-			body["notes"] = note
-		}
-
-		resp, err := client.post(cmd.Context(), "/interactions/"+url.PathEscape(id)+"/feedback", body)
+		resp, err := client.get(cmd.Context(), "/profile/export")
 		if err != nil {
 			return err
 		}
-
-		if err := decodeJSON(resp, &map[string]string{}); err != nil {
-    		return err
+		var doc json.RawMessage
+		if err := decodeJSON(resp, &doc); err != nil {
+			return err
 		}
 
-		if score == 1 {
-			printSuccess("Rated interaction %s as positive", id)
-		} else {
-			printSuccess("Rated interaction %s as negative", id)
+		if output == "" || output == "-" {
+			fmt.Println(string(doc))
+			return nil
+		}
+		// The profile is personal data; keep the file private.
+		if err := os.WriteFile(output, append(doc, '\n'), 0600); err != nil {
+			return fmt.Errorf("writing %s: %w", output, err)
 		}
+		printSuccess("Exported profile to %s", output)
 		return nil
 	},
 }
 
-func init() {
-	interactionsRateCmd.Flags().Bool("positive", false, "rate as positive (+1)")
-	interactionsRateCmd.Flags().Bool("negative", false, "rate as negative (-1)")
-	interactionsRateCmd.Flags().String("notes", "", "optional notes about the rating")
-	interactionsListCmd.Flags().Int("limit", 20, "maximum number of interactions to list")
-	interactionsCmd.AddCommand(interactionsListCmd)
-	interactionsCmd.AddCommand(interactionsShowCmd)
-	interactionsCmd.AddCommand(interactionsRateCmd)
-}
-
-// --- data ---
-
-var dataCmd = &cobra.Command{
-	Use:   "data",
-	Short: "Export or purge stored data",
-}
+var profileImportCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Import a profile exported with tbyd profile export",
+	Long: `Import a profile document. By default the import is merged into the current
+profile: its non-empty fields win and lists are combined. With --replace the
+profile becomes exactly the imported one. Pending deltas in the document are
+queued for review. --dry-run shows the changes without applying them.
 
-var dataExportCmd = &cobra.Command{
-	Use:   "export",
-	Short: "Export all stored data as JSONL",
+Examples:
+  tbyd profile import profile.json --dry-run
+  tbyd profile import team-baseline.json --replace`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		output, _ := cmd.Flags().GetString("output")
+		replace, _ := cmd.Flags().GetBool("replace")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
-		client, err := newAPIClient()
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("reading %s: %w", args[0], err)
 		}
-
-		var writer *os.File
-		if output != "" {
-			f, err := os.Create(output)
-			if err != nil {
-				return fmt.Errorf("creating output file: %w", err)
-			}
-			defer f.Close()
-			writer = f
-		} else {
-			writer = os.Stdout
+		if !json.Valid(data) {
+			return fmt.Errorf("%s is not valid JSON", args[0])
 		}
 
-		ctx := cmd.Context()
-		enc := json.NewEncoder(writer)
-
-		if err := exportPagedData(ctx, client, enc, "/context-docs", "context_doc"); err != nil {
-			return err
-		}
-		if err := exportPagedData(ctx, client, enc, "/interactions", "interaction"); err != nil {
+		client, err := newPersonaClient(cmd)
+		if err != nil {
 			return err
 		}
 
-		if output != "" {
-			printSuccess("Data exported to %s", output)
+		q := url.Values{"mode": {"merge"}}
+		if replace {
+			q.Set("mode", "replace")
 		}
-		return nil
-	},
-}
-
-func exportPagedData(ctx context.Context, client *apiClient, enc *json.Encoder, path, recordType string) error {
-	offset := 0
-	for {
-		resp, err := client.get(ctx, fmt.Sprintf("%s?limit=100&offset=%d", path, offset))
+		if dryRun {
+			q.Set("dry_run", "true")
+		}
+		resp, err := client.post(cmd.Context(), "/profile/import?"+q.Encode(), json.RawMessage(data))
 		if err != nil {
 			return err
 		}
-		var items []any
-		if err := decodeJSON(resp, &items); err != nil {
+
+		var result struct {
+			Changes       []profileChange `json:"changes"`
+			PendingDeltas int             `json:"pending_deltas"`
+		}
+		if err := decodeJSON(resp, &result); err != nil {
 			return err
 		}
-		if len(items) == 0 {
-			break
+
+		if len(result.Changes) == 0 {
+			fmt.Println("No profile changes.")
+		} else {
+			printProfileChanges(result.Changes)
 		}
-		for _, item := range items {
-			record := map[string]any{"type": recordType, "data": item}
-			if err := enc.Encode(record); err != nil {
-				return fmt.Errorf("encoding %s: %w", recordType, err)
-			}
+		if dryRun {
+			fmt.Printf("Dry run: %d change(s), %d pending delta(s) would be queued. Nothing was applied.\n",
+				len(result.Changes), result.PendingDeltas)
+			return nil
 		}
-		offset += len(items)
-	}
-	return nil
+		printSuccess("Imported profile: %d change(s), %d pending delta(s) queued", len(result.Changes), result.PendingDeltas)
+		return nil
+	},
 }
 
-var dataPurgeCmd = &cobra.Command{
-	Use:   "purge",
-	Short: "Delete all stored data",
+var profileReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Step through pending profile deltas item by item",
+	Long: `Review the default persona's pending profile deltas one item at a time.
+For each proposed change, answer a to accept it, e to accept an edited
+version, or s to skip it; q stops reviewing and leaves the remaining deltas
+pending. A delta is accepted with the items you kept, or rejected when you
+skip them all.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		confirm, _ := cmd.Flags().GetBool("confirm")
-		if !confirm {
-			printWarning("This will delete ALL stored data. Use --confirm to proceed.")
-			return nil
-		}
-
 		client, err := newAPIClient()
 		if err != nil {
 			return err
 		}
 
-		ctx := cmd.Context()
-		var failures int
-
-		printStep("Deleting context docs...")
-		n, err := purgeEndpoint(ctx, client, "/context-docs")
-		failures += n
+		resp, err := client.get(cmd.Context(), "/profile/pending-deltas")
 		if err != nil {
 			return err
 		}
-
-		printStep("Deleting interactions...")
-		n, err = purgeEndpoint(ctx, client, "/interactions")
-		failures += n
-		if err != nil {
+		var pending []struct {
+			ID            string          `json:"id"`
+			DeltaJSON     string          `json:"delta_json"`
+			Description   string          `json:"description"`
+			Source        string          `json:"source"`
+			CreatedAt     string          `json:"created_at"`
+			Evidence      json.RawMessage `json:"evidence"`
+			EvidenceLinks []string        `json:"evidence_links"`
+		}
+		if err := decodeJSON(resp, &pending); err != nil {
 			return err
 		}
+		if len(pending) == 0 {
+			fmt.Println("No pending deltas.")
+			return nil
+		}
 
-		if failures > 0 {
-			printError("%d deletion(s) failed", failures)
+		in := bufio.NewReader(cmd.InOrStdin())
+		out := cmd.OutOrStdout()
+		for n, d := range pending {
+			var delta profile.ProfileDelta
+			if err := json.Unmarshal([]byte(d.DeltaJSON), &delta); err != nil {
+				printWarning("Skipping delta %s: %v", d.ID, err)
+				continue
+			}
+			fmt.Fprintf(out, "\n%s  %s  %s\n%s\n",
+				colorize(colorCyan, fmt.Sprintf("[%d/%d]", n+1, len(pending))), d.Source, d.CreatedAt, d.Description)
+			printDeltaEvidence(out, d.Evidence, d.EvidenceLinks)
+
+			kept, quit, err := reviewDelta(in, out, delta)
+			if err != nil {
+				return err
+			}
+			if quit {
+				fmt.Fprintln(out, "Stopped; remaining deltas are still pending.")
+				return nil
+			}
+
+			path := "/profile/pending-deltas/" + url.PathEscape(d.ID)
+			if kept.IsEmpty() {
+				resp, err := client.post(cmd.Context(), path+"/reject", nil)
+				if err != nil {
+					return err
+				}
+				if err := decodeJSON(resp, &map[string]string{}); err != nil {
+					return err
+				}
+				printSuccess("Rejected delta %s", d.ID)
+				continue
+			}
+			resp, err := client.post(cmd.Context(), path+"/accept", map[string]any{"delta": kept})
+			if err != nil {
+				return err
+			}
+			if err := decodeJSON(resp, &map[string]string{}); err != nil {
+				return err
+			}
+			printSuccess("Accepted delta %s", d.ID)
+		}
+		return nil
+	},
+}
+
+// reviewDelta asks about each change in delta in turn and returns the
+// changes the user kept, edited as requested. quit is true when the user
+// stopped reviewing; the returned delta is then meaningless.
+func reviewDelta(in *bufio.Reader, out io.Writer, delta profile.ProfileDelta) (kept profile.ProfileDelta, quit bool, err error) {
+	// ask prompts for one item and returns its (possibly edited) value and
+	// whether to keep it.
+	ask := func(label, value string) (string, bool, error) {
+		for {
+			fmt.Fprintf(out, "  %s %s\n  [a]ccept, [e]dit, [s]kip, [q]uit? ", label, value)
+			answer, err := readLine(in)
+			if err == io.EOF {
+				// Input ran out: stop as if the user quit.
+				quit = true
+				return "", false, nil
+			}
+			if err != nil {
+				return "", false, err
+			}
+			switch strings.ToLower(answer) {
+			case "a", "accept":
+				return value, true, nil
+			case "s", "skip":
+				return "", false, nil
+			case "q", "quit":
+				quit = true
+				return "", false, nil
+			case "e", "edit":
+				fmt.Fprintf(out, "  new value: ")
+				edited, err := readLine(in)
+				if err != nil {
+					return "", false, err
+				}
+				if edited == "" {
+					fmt.Fprintln(out, "  empty value; skipping")
+					return "", false, nil
+				}
+				return edited, true, nil
+			}
+		}
+	}
+
+	list := func(label string, items []string) ([]string, error) {
+		var keep []string
+		for _, v := range items {
+			if quit {
+				break
+			}
+			got, ok, err := ask(label, v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				keep = append(keep, got)
+			}
+		}
+		return keep, nil
+	}
+
+	if kept.AddPreferences, err = list(colorize(colorGreen, "+ preference:"), delta.AddPreferences); err != nil {
+		return kept, false, err
+	}
+	if kept.RemovePreferences, err = list(colorize(colorRed, "- preference:"), delta.RemovePreferences); err != nil {
+		return kept, false, err
+	}
+	if kept.RemoveOpinions, err = list(colorize(colorRed, "- opinion:"), delta.RemoveOpinions); err != nil {
+		return kept, false, err
+	}
+	if kept.PromoteInterests, err = list(colorize(colorGreen, "↑ interest:"), delta.PromoteInterests); err != nil {
+		return kept, false, err
+	}
+	if kept.DecayInterests, err = list(colorize(colorYellow, "↓ interest:"), delta.DecayInterests); err != nil {
+		return kept, false, err
+	}
+
+	keys := make([]string, 0, len(delta.UpdateFields))
+	for k := range delta.UpdateFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if quit {
+			break
+		}
+		v, ok, err := ask(colorize(colorYellow, "~ "+k+":"), delta.UpdateFields[k])
+		if err != nil {
+			return kept, false, err
+		}
+		if ok {
+			if kept.UpdateFields == nil {
+				kept.UpdateFields = make(map[string]string)
+			}
+			kept.UpdateFields[k] = v
+		}
+	}
+	return kept, quit, nil
+}
+
+// readLine reads one trimmed line from in. A final line without a newline is
+// returned as is; io.EOF is only reported when nothing was read.
+func readLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// printDeltaEvidence shows what a pending delta is based on: links to the
+// cited interactions and documents when there are any, otherwise the raw
+// evidence record.
+func printDeltaEvidence(out io.Writer, evidence json.RawMessage, links []string) {
+	if len(links) > 0 {
+		fmt.Fprintln(out, "Evidence:")
+		for _, l := range links {
+			fmt.Fprintf(out, "  %s\n", l)
+		}
+		return
+	}
+	if len(evidence) > 0 {
+		fmt.Fprintf(out, "Evidence: %s\n", evidence)
+	}
+}
+
+var profileDecisionsCmd = &cobra.Command{
+	Use:   "decisions",
+	Short: "List automatic decisions on pending profile deltas, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), fmt.Sprintf("/profile/delta-decisions?limit=%d", limit))
+		if err != nil {
+			return err
+		}
+
+		var decisions []struct {
+			ID          string  `json:"id"`
+			DeltaID     string  `json:"delta_id"`
+			Action      string  `json:"action"`
+			Rule        string  `json:"rule"`
+			AppliedJSON string  `json:"applied_json"`
+			CreatedAt   string  `json:"created_at"`
+			UndoneAt    *string `json:"undone_at"`
+		}
+		if err := decodeJSON(resp, &decisions); err != nil {
+			return err
+		}
+
+		if len(decisions) == 0 {
+			fmt.Println("No automatic decisions.")
+			return nil
+		}
+
+		for _, d := range decisions {
+			status := ""
+			if d.UndoneAt != nil {
+				status = colorize(colorYellow, " (undone)")
+			}
+			fmt.Printf("%s  %s  %-8s  %s  delta %s%s\n",
+				colorize(colorCyan, d.ID), d.CreatedAt, d.Action, d.Rule, d.DeltaID, status)
+			var applied profile.ProfileDelta
+			if d.AppliedJSON != "" && json.Unmarshal([]byte(d.AppliedJSON), &applied) == nil {
+				for _, p := range applied.AddPreferences {
+					fmt.Printf("  %s %s\n", colorize(colorGreen, "+"), p)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var profileUndoCmd = &cobra.Command{
+	Use:   "undo <decision-id>",
+	Short: "Undo an automatic decision and return its delta to review",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/profile/delta-decisions/"+url.PathEscape(args[0])+"/undo", nil)
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+			return err
+		}
+
+		printSuccess("Undid decision %s; its delta is pending review again", args[0])
+		return nil
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileShowCmd)
+	profileCmd.AddCommand(profileSetCmd)
+	profileCmd.AddCommand(profileEditCmd)
+	profileCmd.AddCommand(profileHistoryCmd)
+	profileCmd.AddCommand(profileDiffCmd)
+	profileCmd.AddCommand(profileRollbackCmd)
+	profileCmd.AddCommand(profileExportCmd)
+	profileCmd.AddCommand(profileImportCmd)
+	profileCmd.AddCommand(profileReviewCmd)
+	profileCmd.AddCommand(profileDecisionsCmd)
+	profileCmd.AddCommand(profileUndoCmd)
+
+	profileCmd.PersistentFlags().String("persona", "", "persona whose profile to manage (default persona if empty)")
+	profileHistoryCmd.Flags().Int("limit", 20, "maximum number of versions to list")
+	profileDecisionsCmd.Flags().Int("limit", 20, "maximum number of decisions to list")
+	profileExportCmd.Flags().StringP("output", "o", "", "write the export to a file instead of stdout")
+	profileImportCmd.Flags().Bool("replace", false, "replace the profile instead of merging into it")
+	profileImportCmd.Flags().Bool("dry-run", false, "show the changes without applying them")
+}
+
+// --- recall ---
+
+var recallCmd = &cobra.Command{
+	Use:   "recall <query>",
+	Short: "Semantic search over the knowledge base",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := strings.Join(args, " ")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		path := fmt.Sprintf("/recall?q=%s&limit=%d", url.QueryEscape(query), limit)
+		resp, err := client.get(cmd.Context(), path)
+		if err != nil {
+			return err
+		}
+
+		var results []struct {
+			ID         string  `json:"id"`
+			SourceID   string  `json:"source_id"`
+			SourceType string  `json:"source_type"`
+			Text       string  `json:"text"`
+			Score      float32 `json:"score"`
+			Tags       string  `json:"tags"`
+		}
+		if err := decodeJSON(resp, &results); err != nil {
+			return err
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No results found.")
+			return nil
+		}
+
+		for i, r := range results {
+			fmt.Printf("\n%s [score: %.3f]\n", colorize(colorBold, fmt.Sprintf("Result %d", i+1)), r.Score)
+			var tags []string
+			if r.Tags != "" {
+				if json.Unmarshal([]byte(r.Tags), &tags) == nil && len(tags) > 0 {
+					fmt.Printf("  Tags: %s\n", strings.Join(tags, ", "))
+				}
+			}
+			text := r.Text
+			if len(text) > 500 {
+				text = text[:500] + "..."
+			}
+			fmt.Printf("  %s\n", text)
+		}
+		return nil
+	},
+}
+
+func init() {
+	recallCmd.Flags().Int("limit", 5, "maximum number of results")
+}
+
+// --- interactions ---
+
+var interactionsCmd = &cobra.Command{
+	Use:   "interactions",
+	Short: "Manage interaction history",
+}
+
+var interactionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent interactions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		path := fmt.Sprintf("/interactions?limit=%d", limit)
+		resp, err := client.get(cmd.Context(), path)
+		if err != nil {
+			return err
+		}
+
+		var interactions []struct {
+			ID            string `json:"id"`
+			CreatedAt     string `json:"created_at"`
+			UserQuery     string `json:"user_query"`
+			Status        string `json:"status"`
+			FeedbackScore int    `json:"feedback_score"`
+		}
+		if err := decodeJSON(resp, &interactions); err != nil {
+			return err
+		}
+
+		if len(interactions) == 0 {
+			fmt.Println("No interactions found.")
+			return nil
+		}
+
+		for _, ix := range interactions {
+			query := ix.UserQuery
+			if len(query) > 80 {
+				query = query[:80] + "..."
+			}
+			var rating string
+			switch ix.FeedbackScore {
+			case 1:
+				rating = colorize(colorGreen, "+1")
+			case -1:
+				rating = colorize(colorRed, "-1")
+			default:
+				rating = "  "
+			}
+			fmt.Printf("%s  %s  %s  %s\n",
+				colorize(colorCyan, ix.ID[:8]),
+				ix.CreatedAt,
+				rating,
+				query,
+			)
+		}
+		return nil
+	},
+}
+
+var interactionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a single interaction",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), "/interactions/"+args[0])
+		if err != nil {
+			return err
+		}
+
+		var interaction any
+		if err := decodeJSON(resp, &interaction); err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(interaction)
+	},
+}
+
+var interactionsRateCmd = &cobra.Command{
+	Use:   "rate <id>",
+	Short: "Rate an interaction as positive or negative",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		positive, _ := cmd.Flags().GetBool("positive")
+		negative, _ := cmd.Flags().GetBool("negative")
+		note, _ := cmd.Flags().GetString("notes")
+
+		if positive == negative {
+			return fmt.Errorf("exactly one of --positive or --negative is required")
+		}
+
+		score := 1
+		if negative {
+			score = -1
+		}
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		body := map[string]any{"score": score}
+		if note != "" {
+			body["notes"] = note
+		}
+
+		resp, err := client.post(cmd.Context(), "/interactions/"+url.PathEscape(id)+"/feedback", body)
+		if err != nil {
+			return err
+		}
+
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+    		return err
+		}
+
+		if score == 1 {
+			printSuccess("Rated interaction %s as positive", id)
+		} else {
+			printSuccess("Rated interaction %s as negative", id)
+		}
+		return nil
+	},
+}
+
+func init() {
+	interactionsRateCmd.Flags().Bool("positive", false, "rate as positive (+1)")
+	interactionsRateCmd.Flags().Bool("negative", false, "rate as negative (-1)")
+	interactionsRateCmd.Flags().String("notes", "", "optional notes about the rating")
+	interactionsListCmd.Flags().Int("limit", 20, "maximum number of interactions to list")
+	interactionsCmd.AddCommand(interactionsListCmd)
+	interactionsCmd.AddCommand(interactionsShowCmd)
+	interactionsCmd.AddCommand(interactionsRateCmd)
+}
+
+// --- data ---
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Export or purge stored data",
+}
+
+var dataExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all stored data as JSONL",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		var writer *os.File
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("creating output file: %w", err)
+			}
+			defer f.Close()
+			writer = f
+		} else {
+			writer = os.Stdout
+		}
+
+		ctx := cmd.Context()
+		enc := json.NewEncoder(writer)
+
+		if err := exportPagedData(ctx, client, enc, "/context-docs", "context_doc"); err != nil {
+			return err
+		}
+		if err := exportPagedData(ctx, client, enc, "/interactions", "interaction"); err != nil {
+			return err
+		}
+
+		if output != "" {
+			printSuccess("Data exported to %s", output)
+		}
+		return nil
+	},
+}
+
+func exportPagedData(ctx context.Context, client *apiClient, enc *json.Encoder, path, recordType string) error {
+	offset := 0
+	for {
+		resp, err := client.get(ctx, fmt.Sprintf("%s?limit=100&offset=%d", path, offset))
+		if err != nil {
+			return err
+		}
+		var items []any
+		if err := decodeJSON(resp, &items); err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			record := map[string]any{"type": recordType, "data": item}
+			if err := enc.Encode(record); err != nil {
+				return fmt.Errorf("encoding %s: %w", recordType, err)
+			}
+		}
+		offset += len(items)
+	}
+	return nil
+}
+
+var dataPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete all stored data",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		confirm, _ := cmd.Flags().GetBool("confirm")
+		if !confirm {
+			printWarning("This will delete ALL stored data. Use --confirm to proceed.")
+			return nil
+		}
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		var failures int
+
+		printStep("Deleting context docs...")
+		n, err := purgeEndpoint(ctx, client, "/context-docs")
+		failures += n
+		if err != nil {
+			return err
+		}
+
+		printStep("Deleting interactions...")
+		n, err = purgeEndpoint(ctx, client, "/interactions")
+		failures += n
+		if err != nil {
+			return err
+		}
+
+		if failures > 0 {
+			printError("%d deletion(s) failed", failures)
 			return fmt.Errorf("%d deletion(s) failed during purge", failures)
 		}
 
-		printSuccess("All data purged")
+		printSuccess("All data purged")
+		return nil
+	},
+}
+
+func purgeEndpoint(ctx context.Context, client *apiClient, path string) (failures int, _ error) {
+	for {
+		resp, err := client.get(ctx, path+"?limit=100")
+		if err != nil {
+			return failures, err
+		}
+		var items []struct {
+			ID string `json:"id"`
+		}
+		if err := decodeJSON(resp, &items); err != nil {
+			return failures, err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			delResp, err := client.delete(ctx, path+"/"+item.ID)
+			if err != nil {
+				printError("Failed to delete %s: %v", item.ID, err)
+				failures++
+				continue
+			}
+			delResp.Body.Close()
+			if delResp.StatusCode >= 400 {
+				printError("Failed to delete %s: HTTP %d", item.ID, delResp.StatusCode)
+				failures++
+			}
+		}
+	}
+	return failures, nil
+}
+
+func init() {
+	dataExportCmd.Flags().String("output", "", "output file path (default: stdout)")
+	dataPurgeCmd.Flags().Bool("confirm", false, "confirm data purge")
+	dataCmd.AddCommand(dataExportCmd)
+	dataCmd.AddCommand(dataPurgeCmd)
+}
+
+// --- retrieval ---
+
+var retrievalCmd = &cobra.Command{
+	Use:   "retrieval",
+	Short: "Inspect and tune retrieval",
+}
+
+var retrievalWeightsCmd = &cobra.Command{
+	Use:   "weights",
+	Short: "Show learned hybrid search ratios per intent type",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), "/retrieval/weights")
+		if err != nil {
+			return err
+		}
+
+		var weights []struct {
+			IntentType    string  `json:"intent_type"`
+			VectorRatio   float64 `json:"vector_ratio"`
+			FeedbackCount int     `json:"feedback_count"`
+			UpdatedAt     string  `json:"updated_at"`
+			Legs          map[string]struct {
+				Positive int `json:"positive"`
+				Negative int `json:"negative"`
+			} `json:"legs"`
+		}
+		if err := decodeJSON(resp, &weights); err != nil {
+			return err
+		}
+
+		if len(weights) == 0 {
+			fmt.Println("No hybrid weights learned yet.")
+			return nil
+		}
+
+		for _, w := range weights {
+			fmt.Printf("%s  vector %.2f / keyword %.2f  (%d ratings, updated %s)\n",
+				colorize(colorCyan, fmt.Sprintf("%-16s", w.IntentType)),
+				w.VectorRatio, 1-w.VectorRatio, w.FeedbackCount, w.UpdatedAt)
+			legs := make([]string, 0, len(w.Legs))
+			for leg := range w.Legs {
+				legs = append(legs, leg)
+			}
+			sort.Strings(legs)
+			for _, leg := range legs {
+				st := w.Legs[leg]
+				fmt.Printf("    %-10s %s %s\n", leg,
+					colorize(colorGreen, fmt.Sprintf("+%d", st.Positive)),
+					colorize(colorRed, fmt.Sprintf("-%d", st.Negative)))
+			}
+		}
+		return nil
+	},
+}
+
+var retrievalWeightsResetCmd = &cobra.Command{
+	Use:   "reset [intent-type]",
+	Short: "Forget learned hybrid ratios (all intent types if none given)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		path := "/retrieval/weights"
+		if len(args) == 1 {
+			path += "/" + url.PathEscape(args[0])
+		}
+		resp, err := client.delete(cmd.Context(), path)
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			printSuccess("Reset hybrid weights for %s", args[0])
+		} else {
+			printSuccess("Reset hybrid weights for all intent types")
+		}
+		return nil
+	},
+}
+
+func init() {
+	retrievalWeightsCmd.AddCommand(retrievalWeightsResetCmd)
+	retrievalCmd.AddCommand(retrievalWeightsCmd)
+}
+
+// --- cache ---
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and flush the enrichment cache",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache hit rates, similarity distribution, memory, and evictions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), "/cache/stats")
+		if err != nil {
+			return err
+		}
+
+		var stats cache.Stats
+		if err := decodeJSON(resp, &stats); err != nil {
+			return err
+		}
+
+		if !stats.Enabled {
+			fmt.Println("Cache is disabled.")
+			return nil
+		}
+
+		for _, level := range []struct {
+			name string
+			s    cache.LevelStats
+		}{{"L1 exact", stats.Exact}, {"L2 semantic", stats.Semantic}} {
+			fmt.Printf("%s  %s hits / %s misses  (%.1f%%, %d entries)\n",
+				colorize(colorCyan, fmt.Sprintf("%-12s", level.name)),
+				colorize(colorGreen, fmt.Sprint(level.s.Hits)),
+				colorize(colorRed, fmt.Sprint(level.s.Misses)),
+				level.s.HitRate*100, level.s.Entries)
+		}
+
+		fmt.Printf("\nL2 hit similarity (threshold %.2f):\n", stats.SemanticThreshold)
+		for _, b := range stats.SimilarityHistogram {
+			fmt.Printf("    %.2f-%.2f  %d\n", b.Min, b.Max, b.Count)
+		}
+
+		e := stats.Evictions
+		fmt.Printf("\nEvictions: %d (expired %d, capacity %d, invalidated %d)\n",
+			e.Total, e.Expired, e.Capacity, e.Invalidated)
+		fmt.Printf("Memory:    ~%.1f MB\n", float64(stats.MemoryBytes)/(1<<20))
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Drop every cached enrichment",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/cache/invalidate", nil)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Evicted int `json:"evicted"`
+		}
+		if err := decodeJSON(resp, &result); err != nil {
+			return err
+		}
+
+		printSuccess("Cleared cache (%d entries)", result.Evicted)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+// --- persona ---
+
+var personaCmd = &cobra.Command{
+	Use:   "persona",
+	Short: "Manage named personas and how requests select them",
+}
+
+var personaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List personas with their selectors",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), "/personas")
+		if err != nil {
+			return err
+		}
+
+		var personas []struct {
+			Name        string   `json:"name"`
+			Description string   `json:"description"`
+			Partitioned bool     `json:"partitioned"`
+			Clients     []string `json:"clients"`
+			Models      []string `json:"models"`
+			Tokens      int      `json:"tokens"`
+		}
+		if err := decodeJSON(resp, &personas); err != nil {
+			return err
+		}
+
+		for _, p := range personas {
+			name := p.Name
+			if p.Partitioned {
+				name += " (own knowledge base)"
+			}
+			fmt.Println(colorize(colorCyan, name))
+			if p.Description != "" {
+				fmt.Printf("  %s\n", p.Description)
+			}
+			if len(p.Clients) > 0 {
+				fmt.Printf("  clients: %s\n", strings.Join(p.Clients, ", "))
+			}
+			if len(p.Models) > 0 {
+				fmt.Printf("  models:  %s\n", strings.Join(p.Models, ", "))
+			}
+			if p.Tokens > 0 {
+				fmt.Printf("  tokens:  %d\n", p.Tokens)
+			}
+		}
+		return nil
+	},
+}
+
+var personaCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a persona",
+	Long: `Create a persona with its own profile.
+
+Requests select a persona by the X-TBYD-Persona header, an API token issued
+with --token, the X-TBYD-Client or MCP client name (--client), or the requested
+model (--model). Unmatched requests use the default persona.
+
+Examples:
+  tbyd persona create work --client cursor --model openai/gpt-4o
+  tbyd persona create personal --partitioned --token`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		description, _ := cmd.Flags().GetString("description")
+		partitioned, _ := cmd.Flags().GetBool("partitioned")
+		clients, _ := cmd.Flags().GetStringSlice("client")
+		models, _ := cmd.Flags().GetStringSlice("model")
+		issueToken, _ := cmd.Flags().GetBool("token")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/personas", map[string]any{
+			"name":        args[0],
+			"description": description,
+			"partitioned": partitioned,
+			"clients":     clients,
+			"models":      models,
+			"issue_token": issueToken,
+		})
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Token string `json:"token"`
+		}
+		if err := decodeJSON(resp, &result); err != nil {
+			return err
+		}
+
+		printSuccess("Created persona %s", args[0])
+		if result.Token != "" {
+			fmt.Printf("API token (shown once): %s\n", colorize(colorYellow, result.Token))
+		}
 		return nil
 	},
 }
 
-func purgeEndpoint(ctx context.Context, client *apiClient, path string) (failures int, _ error) {
-	for {
-		resp, err := client.get(ctx, path+"?limit=100")
+var personaDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a persona with its profile, history and partitioned documents",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
 		if err != nil {
-			return failures, err
+			return err
 		}
-		var items []struct {
-			ID string `json:"id"`
+
+		resp, err := client.delete(cmd.Context(), "/personas/"+url.PathEscape(args[0]))
+		if err != nil {
+			return err
 		}
-		if err := decodeJSON(resp, &items); err != nil {
-			return failures, err
+		var result map[string]string
+		if err := decodeJSON(resp, &result); err != nil {
+			return err
 		}
-		if len(items) == 0 {
-			break
+
+		printSuccess("Deleted persona %s", args[0])
+		return nil
+	},
+}
+
+func init() {
+	personaCmd.AddCommand(personaListCmd)
+	personaCmd.AddCommand(personaCreateCmd)
+	personaCmd.AddCommand(personaDeleteCmd)
+
+	personaCreateCmd.Flags().String("description", "", "what the persona is for")
+	personaCreateCmd.Flags().Bool("partitioned", false, "give the persona its own knowledge-base partition")
+	personaCreateCmd.Flags().StringSlice("client", nil, "client names that select the persona (repeatable)")
+	personaCreateCmd.Flags().StringSlice("model", nil, "requested models that select the persona (repeatable)")
+	personaCreateCmd.Flags().Bool("token", false, "issue an API token that selects the persona")
+}
+
+// --- project ---
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage project workspaces",
+}
+
+var projectListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List projects with their roots and stack",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
 		}
-		for _, item := range items {
-			delResp, err := client.delete(ctx, path+"/"+item.ID)
+
+		resp, err := client.get(cmd.Context(), "/projects")
+		if err != nil {
+			return err
+		}
+
+		var projects []struct {
+			Name        string   `json:"name"`
+			Description string   `json:"description"`
+			Roots       []string `json:"roots"`
+			Stack       []string `json:"stack"`
+		}
+		if err := decodeJSON(resp, &projects); err != nil {
+			return err
+		}
+
+		if len(projects) == 0 {
+			fmt.Println("No projects.")
+			return nil
+		}
+		for _, p := range projects {
+			fmt.Println(colorize(colorCyan, p.Name))
+			if p.Description != "" {
+				fmt.Printf("  %s\n", p.Description)
+			}
+			for _, root := range p.Roots {
+				fmt.Printf("  root:  %s\n", root)
+			}
+			if len(p.Stack) > 0 {
+				fmt.Printf("  stack: %s\n", strings.Join(p.Stack, ", "))
+			}
+		}
+		return nil
+	},
+}
+
+var projectCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a project",
+	Long: `Create a project workspace.
+
+Requests and MCP clients working under one of the project's roots select it,
+as does the X-TBYD-Project header. Its documents are preferred in retrieval
+while it is active; with retrieval.project_mode=filter, other projects'
+documents are left out entirely.
+
+Examples:
+  tbyd project create tbyd --root ~/src/tbyd --stack go,sqlite
+  tbyd project create site --root . --description "personal website"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		description, _ := cmd.Flags().GetString("description")
+		roots, _ := cmd.Flags().GetStringSlice("root")
+		stack, _ := cmd.Flags().GetStringSlice("stack")
+
+		for i, root := range roots {
+			abs, err := filepath.Abs(root)
 			if err != nil {
-				printError("Failed to delete %s: %v", item.ID, err)
-				failures++
-				continue
+				return fmt.Errorf("resolving root %q: %w", root, err)
 			}
-			delResp.Body.Close()
-			if delResp.StatusCode >= 400 {
-				printError("Failed to delete %s: HTTP %d", item.ID, delResp.StatusCode)
-				failures++
+			roots[i] = abs
+		}
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/projects", map[string]any{
+			"name":        args[0],
+			"description": description,
+			"roots":       roots,
+			"stack":       stack,
+		})
+		if err != nil {
+			return err
+		}
+		var result map[string]string
+		if err := decodeJSON(resp, &result); err != nil {
+			return err
+		}
+
+		printSuccess("Created project %s", args[0])
+		return nil
+	},
+}
+
+var projectDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a project; its documents are kept without a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.delete(cmd.Context(), "/projects/"+url.PathEscape(args[0]))
+		if err != nil {
+			return err
+		}
+		var result map[string]string
+		if err := decodeJSON(resp, &result); err != nil {
+			return err
+		}
+
+		printSuccess("Deleted project %s", args[0])
+		return nil
+	},
+}
+
+func init() {
+	projectCmd.AddCommand(projectListCmd)
+	projectCmd.AddCommand(projectCreateCmd)
+	projectCmd.AddCommand(projectDeleteCmd)
+
+	projectCreateCmd.Flags().String("description", "", "what the project is")
+	projectCreateCmd.Flags().StringSlice("root", nil, "directory that belongs to the project (repeatable)")
+	projectCreateCmd.Flags().StringSlice("stack", nil, "technologies the project uses (comma-separated)")
+}
+
+// --- jobs ---
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Inspect and manage background jobs",
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List background jobs, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobType, _ := cmd.Flags().GetString("type")
+		status, _ := cmd.Flags().GetString("status")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Set("limit", fmt.Sprint(limit))
+		if jobType != "" {
+			q.Set("type", jobType)
+		}
+		if status != "" {
+			q.Set("status", status)
+		}
+		resp, err := client.get(cmd.Context(), "/jobs?"+q.Encode())
+		if err != nil {
+			return err
+		}
+
+		var jobs []struct {
+			ID          string `json:"id"`
+			Type        string `json:"type"`
+			Status      string `json:"status"`
+			Attempts    int    `json:"attempts"`
+			MaxAttempts int    `json:"max_attempts"`
+			RunAfter    string `json:"run_after"`
+			CreatedAt   string `json:"created_at"`
+			LastError   string `json:"last_error"`
+		}
+		if err := decodeJSON(resp, &jobs); err != nil {
+			return err
+		}
+
+		if len(jobs) == 0 {
+			fmt.Println("No jobs.")
+			return nil
+		}
+		for _, j := range jobs {
+			st := j.Status
+			switch j.Status {
+			case "failed":
+				st = colorize(colorRed, st)
+			case "running":
+				st = colorize(colorYellow, st)
+			case "completed":
+				st = colorize(colorGreen, st)
+			}
+			fmt.Printf("%s  %-22s  %s  attempts %d/%d  created %s\n",
+				colorize(colorCyan, j.ID), j.Type, st, j.Attempts, j.MaxAttempts, j.CreatedAt)
+			if j.Status == "pending" && j.Attempts > 0 {
+				fmt.Printf("  next attempt: %s\n", j.RunAfter)
+			}
+			if j.LastError != "" {
+				fmt.Printf("  last error: %s\n", j.LastError)
 			}
 		}
-	}
-	return failures, nil
+		return nil
+	},
+}
+
+var jobsRetryCmd = &cobra.Command{
+	Use:   "retry <job-id>",
+	Short: "Run a failed or pending job now with fresh attempts",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/jobs/"+url.PathEscape(args[0])+"/retry", nil)
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+			return err
+		}
+
+		printSuccess("Job %s queued to run again", args[0])
+		return nil
+	},
+}
+
+var jobsDeleteCmd = &cobra.Command{
+	Use:   "delete <job-id>",
+	Short: "Delete a job that is not running",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.delete(cmd.Context(), "/jobs/"+url.PathEscape(args[0]))
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+			return err
+		}
+
+		printSuccess("Deleted job %s", args[0])
+		return nil
+	},
+}
+
+var jobsRunCmd = &cobra.Command{
+	Use:   "run <type>",
+	Short: "Start a scheduled job now",
+	Long: `Start a scheduled job now instead of waiting for its schedule.
+
+Examples:
+  tbyd jobs run nightly_synthesis
+  tbyd jobs run deep_enrich`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.post(cmd.Context(), "/jobs/run/"+url.PathEscape(args[0]), nil)
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(resp, &map[string]string{}); err != nil {
+			return err
+		}
+
+		printSuccess("Started %s", args[0])
+		return nil
+	},
 }
 
 func init() {
-	dataExportCmd.Flags().String("output", "", "output file path (default: stdout)")
-	dataPurgeCmd.Flags().Bool("confirm", false, "confirm data purge")
-	dataCmd.AddCommand(dataExportCmd)
-	dataCmd.AddCommand(dataPurgeCmd)
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsRetryCmd)
+	jobsCmd.AddCommand(jobsDeleteCmd)
+	jobsCmd.AddCommand(jobsRunCmd)
+
+	jobsListCmd.Flags().String("type", "", "only list jobs of this type")
+	jobsListCmd.Flags().String("status", "", "only list jobs with this status (pending, running, completed, failed)")
+	jobsListCmd.Flags().Int("limit", 20, "maximum number of jobs to list")
+}
+
+// --- facts ---
+
+var factsCmd = &cobra.Command{
+	Use:   "facts",
+	Short: "Review facts and decisions extracted from conversations",
+}
+
+var factsReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Step through extracted facts waiting for review",
+	Long: `Review facts and decisions extracted from past conversations one at a
+time. Answer a to save a fact to the knowledge base, e to save an edited
+version, r to reject it, or s to skip it for now; q stops reviewing and
+leaves the remaining facts pending.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.get(cmd.Context(), "/facts/pending")
+		if err != nil {
+			return err
+		}
+		var pending []struct {
+			ID              string  `json:"id"`
+			Kind            string  `json:"kind"`
+			Statement       string  `json:"statement"`
+			Confidence      float64 `json:"confidence"`
+			CreatedAt       string  `json:"created_at"`
+			InteractionLink string  `json:"interaction_link"`
+		}
+		if err := decodeJSON(resp, &pending); err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending facts.")
+			return nil
+		}
+
+		in := bufio.NewReader(cmd.InOrStdin())
+		out := cmd.OutOrStdout()
+	facts:
+		for n, f := range pending {
+			fmt.Fprintf(out, "\n%s  %s  confidence %.2f  from %s\n  %s\n",
+				colorize(colorCyan, fmt.Sprintf("[%d/%d]", n+1, len(pending))), f.Kind, f.Confidence, f.InteractionLink, f.Statement)
+
+			path := "/facts/pending/" + url.PathEscape(f.ID)
+			for {
+				fmt.Fprintf(out, "  [a]ccept, [e]dit, [r]eject, [s]kip, [q]uit? ")
+				answer, err := readLine(in)
+				if err == io.EOF {
+					// Input ran out: stop as if the user quit.
+					answer = "q"
+				} else if err != nil {
+					return err
+				}
+
+				var body any
+				switch strings.ToLower(answer) {
+				case "a", "accept":
+				case "e", "edit":
+					fmt.Fprintf(out, "  new statement: ")
+					edited, err := readLine(in)
+					if err != nil && err != io.EOF {
+						return err
+					}
+					if edited == "" {
+						fmt.Fprintln(out, "  empty statement; skipping")
+						continue facts
+					}
+					body = map[string]string{"statement": edited}
+				case "r", "reject":
+					resp, err := client.post(cmd.Context(), path+"/reject", nil)
+					if err != nil {
+						return err
+					}
+					if err := decodeJSON(resp, &map[string]string{}); err != nil {
+						return err
+					}
+					printSuccess("Rejected fact %s", f.ID)
+					continue facts
+				case "s", "skip":
+					continue facts
+				case "q", "quit":
+					fmt.Fprintln(out, "Stopped; remaining facts are still pending.")
+					return nil
+				default:
+					continue
+				}
+
+				resp, err := client.post(cmd.Context(), path+"/accept", body)
+				if err != nil {
+					return err
+				}
+				var accepted struct {
+					DocID string `json:"doc_id"`
+				}
+				if err := decodeJSON(resp, &accepted); err != nil {
+					return err
+				}
+				printSuccess("Saved fact %s as context doc %s", f.ID, accepted.DocID)
+				continue facts
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	factsCmd.AddCommand(factsReviewCmd)
 }
 
 // --- config ---
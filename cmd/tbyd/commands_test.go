@@ -8,17 +8,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/spf13/pflag"
+
 	"github.com/kalambet/tbyd/internal/config"
 )
 
 type recordedRequest struct {
-	Method string
-	Path   string
-	Body   string
-	Auth   string
+	Method  string
+	Path    string
+	Body    string
+	Auth    string
+	Source  string
+	Persona string
+	Project string
 }
 
 type testServer struct {
@@ -35,10 +43,13 @@ func newTestServer(t *testing.T, responses map[string]string) *testServer {
 		body.ReadFrom(r.Body)
 
 		ts.requests = append(ts.requests, recordedRequest{
-			Method: r.Method,
-			Path:   r.URL.RequestURI(),
-			Body:   body.String(),
-			Auth:   r.Header.Get("Authorization"),
+			Method:  r.Method,
+			Path:    r.URL.RequestURI(),
+			Body:    body.String(),
+			Auth:    r.Header.Get("Authorization"),
+			Source:  r.Header.Get("X-TBYD-Source"),
+			Persona: r.Header.Get("X-TBYD-Persona"),
+			Project: r.Header.Get("X-TBYD-Project"),
 		})
 
 		key := r.Method + " " + r.URL.Path
@@ -470,6 +481,344 @@ func TestPurgeEndpoint_CollectsFailures(t *testing.T) {
 	}
 }
 
+func TestRetrievalWeightsReset(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		path string
+	}{
+		{"all", []string{"retrieval", "weights", "reset"}, "/retrieval/weights"},
+		{"one intent", []string{"retrieval", "weights", "reset", "recall"}, "/retrieval/weights/recall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, map[string]string{
+				"DELETE " + tt.path: `{"status":"reset"}`,
+			})
+
+			original := newAPIClient
+			newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+			t.Cleanup(func() { newAPIClient = original })
+
+			defer rootCmd.SetArgs(nil)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ts.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(ts.requests))
+			}
+			if r := ts.requests[0]; r.Method != "DELETE" || r.Path != tt.path {
+				t.Errorf("request = %s %s, want DELETE %s", r.Method, r.Path, tt.path)
+			}
+		})
+	}
+}
+
+func TestCacheCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		method string
+		path   string
+		resp   string
+	}{
+		{"stats", []string{"cache", "stats"}, "GET", "/cache/stats",
+			`{"enabled":true,"exact":{"entries":2,"hits":5,"misses":3,"hit_rate":0.625},` +
+				`"semantic":{"hits":1,"misses":2},"similarity_histogram":[{"min":0.9,"max":1,"count":1}],` +
+				`"evictions":{"total":4,"expired":4},"memory_bytes":2048}`},
+		{"clear", []string{"cache", "clear"}, "POST", "/cache/invalidate", `{"status":"invalidated","evicted":7}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, map[string]string{tt.method + " " + tt.path: tt.resp})
+
+			original := newAPIClient
+			newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+			t.Cleanup(func() { newAPIClient = original })
+
+			defer rootCmd.SetArgs(nil)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ts.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(ts.requests))
+			}
+			if r := ts.requests[0]; r.Method != tt.method || r.Path != tt.path {
+				t.Errorf("request = %s %s, want %s %s", r.Method, r.Path, tt.method, tt.path)
+			}
+		})
+	}
+}
+
+func TestProfileHistoryCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		method string
+		path   string
+		resp   string
+	}{
+		{"history", []string{"profile", "history", "--limit", "5"}, "GET", "/profile/history?limit=5",
+			`[{"version":2,"created_at":"2026-01-02T00:00:00Z","source":"cli","summary":"set communication.tone",` +
+				`"changes":[{"key":"communication.tone","op":"update","old":"direct","new":"formal"}]}]`},
+		{"diff", []string{"profile", "diff", "1", "2"}, "GET", "/profile/diff?from=1&to=2",
+			`{"from":1,"to":2,"changes":[{"key":"identity.role","op":"add","new":"engineer"}]}`},
+		{"rollback", []string{"profile", "rollback", "1"}, "POST", "/profile/rollback/1", `{"status":"rolled_back","version":3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, map[string]string{tt.method + " " + strings.SplitN(tt.path, "?", 2)[0]: tt.resp})
+
+			original := newAPIClient
+			newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+			t.Cleanup(func() { newAPIClient = original })
+
+			defer rootCmd.SetArgs(nil)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ts.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(ts.requests))
+			}
+			r := ts.requests[0]
+			if r.Method != tt.method || r.Path != tt.path {
+				t.Errorf("request = %s %s, want %s %s", r.Method, r.Path, tt.method, tt.path)
+			}
+			if r.Source != "cli" {
+				t.Errorf("X-TBYD-Source = %q, want cli", r.Source)
+			}
+		})
+	}
+}
+
+func TestPersonaCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		method  string
+		path    string
+		resp    string
+		body    string // substring expected in the request body
+		persona string // expected X-TBYD-Persona header
+	}{
+		{"list", []string{"persona", "list"}, "GET", "/personas",
+			`[{"name":"default","partitioned":false,"clients":null,"models":null,"tokens":0},` +
+				`{"name":"work","partitioned":true,"clients":["cursor"],"models":[],"tokens":1}]`, "", ""},
+		{"create", []string{"persona", "create", "work", "--client", "cursor", "--partitioned", "--token"}, "POST", "/personas",
+			`{"name":"work","status":"created","token":"tbyd-abc"}`, `"clients":["cursor"]`, ""},
+		{"delete", []string{"persona", "delete", "work"}, "DELETE", "/personas/work", `{"status":"deleted"}`, "", ""},
+		{"profile show as persona", []string{"profile", "show", "--persona", "work"}, "GET", "/profile", `{}`, "", "work"},
+		{"ingest as persona", []string{"ingest", "--text", "notes", "--persona", "work"}, "POST", "/ingest",
+			`{"id":"doc-1","status":"queued"}`, `"content":"notes"`, "work"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, map[string]string{tt.method + " " + tt.path: tt.resp})
+
+			original := newAPIClient
+			newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+			t.Cleanup(func() {
+				newAPIClient = original
+				profileCmd.PersistentFlags().Set("persona", "")
+				ingestCmd.Flags().Set("persona", "")
+				ingestCmd.Flags().Set("text", "")
+			})
+
+			defer rootCmd.SetArgs(nil)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ts.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(ts.requests))
+			}
+			r := ts.requests[0]
+			if r.Method != tt.method || r.Path != tt.path {
+				t.Errorf("request = %s %s, want %s %s", r.Method, r.Path, tt.method, tt.path)
+			}
+			if !strings.Contains(r.Body, tt.body) {
+				t.Errorf("body = %s, want it to contain %s", r.Body, tt.body)
+			}
+			if r.Persona != tt.persona {
+				t.Errorf("X-TBYD-Persona = %q, want %q", r.Persona, tt.persona)
+			}
+		})
+	}
+}
+
+func TestProjectCommands(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		method  string
+		path    string
+		resp    string
+		body    string // substring expected in the request body
+		project string // expected X-TBYD-Project header
+	}{
+		{"list", []string{"project", "list"}, "GET", "/projects",
+			`[{"name":"tbyd","description":"","roots":["/src/tbyd"],"stack":["go"]}]`, "", ""},
+		{"create", []string{"project", "create", "tbyd", "--root", "/src/tbyd", "--stack", "go,sqlite"}, "POST", "/projects",
+			`{"name":"tbyd","status":"created"}`, `"roots":["/src/tbyd"],"stack":["go","sqlite"]`, ""},
+		{"create relative root", []string{"project", "create", "here", "--root", "."}, "POST", "/projects",
+			`{"name":"here","status":"created"}`, `"roots":[` + strconv.Quote(cwd) + `]`, ""},
+		{"delete", []string{"project", "delete", "tbyd"}, "DELETE", "/projects/tbyd", `{"status":"deleted"}`, "", ""},
+		{"ingest in working directory", []string{"ingest", "--text", "notes"}, "POST", "/ingest",
+			`{"id":"doc-1","status":"queued"}`, `"content":"notes"`, cwd},
+		{"ingest into named project", []string{"ingest", "--text", "notes", "--project", "tbyd"}, "POST", "/ingest",
+			`{"id":"doc-1","status":"queued"}`, `"content":"notes"`, "tbyd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, map[string]string{tt.method + " " + tt.path: tt.resp})
+
+			original := newAPIClient
+			newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+			t.Cleanup(func() {
+				newAPIClient = original
+				for _, name := range []string{"root", "stack"} {
+					projectCreateCmd.Flags().Lookup(name).Value.(pflag.SliceValue).Replace(nil)
+				}
+				ingestCmd.Flags().Set("project", "")
+				ingestCmd.Flags().Set("text", "")
+			})
+
+			defer rootCmd.SetArgs(nil)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ts.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(ts.requests))
+			}
+			r := ts.requests[0]
+			if r.Method != tt.method || r.Path != tt.path {
+				t.Errorf("request = %s %s, want %s %s", r.Method, r.Path, tt.method, tt.path)
+			}
+			if !strings.Contains(r.Body, tt.body) {
+				t.Errorf("body = %s, want it to contain %s", r.Body, tt.body)
+			}
+			if r.Project != tt.project {
+				t.Errorf("X-TBYD-Project = %q, want %q", r.Project, tt.project)
+			}
+		})
+	}
+}
+
+func TestProfileExportImportCommands(t *testing.T) {
+	const doc = `{"$schema":"https://github.com/kalambet/tbyd/schemas/profile-export.v1.json","version":1,"profile":{"identity":{"role":"engineer"}}}`
+	ts := newTestServer(t, map[string]string{
+		"GET /profile/export":  doc,
+		"POST /profile/import": `{"mode":"replace","dry_run":true,"changes":[{"key":"identity.role","op":"add","new":"engineer"}],"pending_deltas":0}`,
+	})
+	original := newAPIClient
+	newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+	t.Cleanup(func() {
+		newAPIClient = original
+		profileExportCmd.Flags().Set("output", "")
+		profileImportCmd.Flags().Set("replace", "false")
+		profileImportCmd.Flags().Set("dry-run", "false")
+	})
+
+	path := filepath.Join(t.TempDir(), "profile.json")
+	defer rootCmd.SetArgs(nil)
+	rootCmd.SetArgs([]string{"profile", "export", "--output", path})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(written)) != doc {
+		t.Fatalf("exported file = %q, %v; want the server's document", written, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("export file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	rootCmd.SetArgs([]string{"profile", "import", path, "--replace", "--dry-run"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(ts.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(ts.requests))
+	}
+	r := ts.requests[1]
+	if r.Method != "POST" || r.Path != "/profile/import?dry_run=true&mode=replace" {
+		t.Errorf("request = %s %s, want POST /profile/import?dry_run=true&mode=replace", r.Method, r.Path)
+	}
+	if r.Body != doc {
+		t.Errorf("import body = %s, want the file's document", r.Body)
+	}
+}
+
+func TestProfileReviewCommand(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"GET /profile/pending-deltas": `[
+			{"id":"d1","delta_json":"{\"AddPreferences\":[\"concise\",\"emoji\"],\"UpdateFields\":{\"communication.tone\":\"casual\"}}","description":"mixed","source":"nightly_synthesis",
+			 "evidence":{"interaction_ids":["ix-1"]},"evidence_links":["/interactions/ix-1"]},
+			{"id":"d2","delta_json":"{\"RemovePreferences\":[\"examples\"]}","description":"drop","source":"nightly_synthesis"},
+			{"id":"d3","delta_json":"{\"AddPreferences\":[\"tables\"]}","description":"later","source":"nightly_synthesis"}
+		]`,
+		"POST /profile/pending-deltas/d1/accept": `{"status":"accepted"}`,
+		"POST /profile/pending-deltas/d2/reject": `{"status":"rejected"}`,
+	})
+	original := newAPIClient
+	newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+	t.Cleanup(func() { newAPIClient = original })
+
+	// d1: accept "concise", skip "emoji", edit the tone; d2: skip its only
+	// item, rejecting it; d3: quit.
+	rootCmd.SetIn(strings.NewReader("a\ns\ne\ndirect\ns\nq\n"))
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+	}()
+	rootCmd.SetArgs([]string{"profile", "review"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("review: %v", err)
+	}
+
+	if len(ts.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d: %+v", len(ts.requests), ts.requests)
+	}
+	accept := ts.requests[1]
+	if accept.Path != "/profile/pending-deltas/d1/accept" {
+		t.Fatalf("second request = %s, want the d1 accept", accept.Path)
+	}
+	var body struct {
+		Delta struct {
+			AddPreferences []string
+			UpdateFields   map[string]string
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal([]byte(accept.Body), &body); err != nil {
+		t.Fatalf("accept body %q: %v", accept.Body, err)
+	}
+	if len(body.Delta.AddPreferences) != 1 || body.Delta.AddPreferences[0] != "concise" || body.Delta.UpdateFields["communication.tone"] != "direct" {
+		t.Errorf("accepted delta = %+v, want concise and tone=direct", body.Delta)
+	}
+	if ts.requests[2].Path != "/profile/pending-deltas/d2/reject" {
+		t.Errorf("third request = %s, want the d2 reject", ts.requests[2].Path)
+	}
+	if !strings.Contains(out.String(), "/interactions/ix-1") {
+		t.Errorf("output does not link the cited interaction:\n%s", out.String())
+	}
+}
+
 func TestCountLabel(t *testing.T) {
 	tests := []struct {
 		count, limit int
@@ -487,3 +836,40 @@ func TestCountLabel(t *testing.T) {
 		}
 	}
 }
+
+func TestJobsCommands(t *testing.T) {
+	ts := newTestServer(t, map[string]string{
+		"GET /jobs":                  `[{"id":"j1","type":"nightly_synthesis","status":"failed","attempts":3,"max_attempts":3,"last_error":"model unavailable"}]`,
+		"POST /jobs/j1/retry":        `{"status":"pending"}`,
+		"POST /jobs/run/deep_enrich": `{"status":"started","type":"deep_enrich"}`,
+	})
+	original := newAPIClient
+	newAPIClient = func() (*apiClient, error) { return ts.client(), nil }
+	t.Cleanup(func() { newAPIClient = original })
+	defer rootCmd.SetArgs(nil)
+
+	for _, args := range [][]string{
+		{"jobs", "list", "--status", "failed", "--type", "nightly_synthesis"},
+		{"jobs", "retry", "j1"},
+		{"jobs", "run", "deep_enrich"},
+	} {
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	want := []string{
+		"GET /jobs?limit=20&status=failed&type=nightly_synthesis",
+		"POST /jobs/j1/retry",
+		"POST /jobs/run/deep_enrich",
+	}
+	if len(ts.requests) != len(want) {
+		t.Fatalf("requests = %+v", ts.requests)
+	}
+	for i, w := range want {
+		if got := ts.requests[i].Method + " " + ts.requests[i].Path; got != w {
+			t.Errorf("request %d = %s, want %s", i, got, w)
+		}
+	}
+}
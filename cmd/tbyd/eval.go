@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kalambet/tbyd/internal/config"
+	"github.com/kalambet/tbyd/internal/engine"
+	"github.com/kalambet/tbyd/internal/eval"
+	"github.com/kalambet/tbyd/internal/retrieval"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate local pipeline quality",
+}
+
+var evalRetrievalCmd = &cobra.Command{
+	Use:   "retrieval <golden-set.yaml|golden-set.jsonl>",
+	Short: "Score retrieval strategies against a golden query set",
+	Long: `Runs each query in the golden set against a snapshot of the knowledge base
+and reports recall@k, MRR, nDCG@k, and latency for every search strategy.
+
+Base strategies search without query expansion so they measure retrieval
+alone. Pass --expand to add +expand variants that search with the LLM query
+expander, regardless of whether expansion is enabled for live traffic.
+
+Use --format json --no-timing to produce output that is byte-identical across
+runs over the same data, suitable for committing and diffing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, _ := cmd.Flags().GetInt("k")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		noRerank, _ := cmd.Flags().GetBool("no-rerank")
+		noTiming, _ := cmd.Flags().GetBool("no-timing")
+		only, _ := cmd.Flags().GetString("strategies")
+		expand, _ := cmd.Flags().GetBool("expand")
+
+		if format != "table" && format != "json" {
+			return fmt.Errorf("invalid --format %q (want table or json)", format)
+		}
+
+		queries, err := eval.LoadGoldenSet(args[0])
+		if err != nil {
+			return err
+		}
+
+		strategies, err := selectStrategies(eval.DefaultStrategies(!noRerank, expand), only)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if k <= 0 {
+			k = cfg.Retrieval.TopK
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		eng, err := engine.Detect(engine.DetectConfig{OllamaBaseURL: cfg.Ollama.BaseURL})
+		if err != nil {
+			return fmt.Errorf("detecting inference engine: %w", err)
+		}
+		if !eng.IsRunning(ctx) {
+			return fmt.Errorf("inference engine is not running at %s", cfg.Ollama.BaseURL)
+		}
+
+		store, cleanup, err := openSnapshot(ctx, cfg.Storage.DataDir)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		embedder := retrieval.NewEmbedder(eng, cfg.Ollama.EmbedModel)
+		vectors := retrieval.NewSQLiteStore(store.DB())
+		retriever := retrieval.NewRetriever(embedder, vectors)
+
+		// Rerank variants always rerank, regardless of whether reranking is
+		// enabled for live traffic.
+		rerankCfg := cfg
+		rerankCfg.Enrichment.RerankingEnabled = true
+		reranker := newReranker(rerankCfg, eng)
+
+		printStep("Evaluating %d queries across %d strategies (k=%d)", len(queries), len(strategies), k)
+		runner := eval.NewRunner(retriever, reranker, k)
+		if expand {
+			expandCfg := cfg
+			expandCfg.Enrichment.ExpansionEnabled = true
+			runner.SetExpandingSearcher(newRetriever(expandCfg, eng, embedder, vectors))
+		}
+		report, err := runner.Run(ctx, queries, strategies)
+		if err != nil {
+			return err
+		}
+		if noTiming {
+			report.StripTiming()
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("creating output file: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if format == "json" {
+			err = report.WriteJSON(w)
+		} else {
+			err = report.WriteTable(w)
+		}
+		if err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		if output != "" {
+			printSuccess("Report written to %s", output)
+		}
+		return nil
+	},
+}
+
+// selectStrategies filters all by a comma-separated list of strategy names.
+// An empty list keeps every strategy.
+func selectStrategies(all []eval.Strategy, only string) ([]eval.Strategy, error) {
+	if strings.TrimSpace(only) == "" {
+		return all, nil
+	}
+	byName := make(map[string]eval.Strategy, len(all))
+	names := make([]string, 0, len(all))
+	for _, s := range all {
+		byName[s.Name] = s
+		names = append(names, s.Name)
+	}
+
+	var out []eval.Strategy
+	for _, name := range strings.Split(only, ",") {
+		name = strings.TrimSpace(name)
+		s, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// openSnapshot copies the live database into a temporary directory and opens
+// the copy, so evaluation sees a fixed view of the store and never writes to
+// the live database: the live file is opened read-only and only the copy is
+// migrated. The returned cleanup closes the copy and removes it.
+func openSnapshot(ctx context.Context, dataDir string) (*storage.Store, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "tbyd-eval-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	if err := storage.SnapshotReadOnly(ctx, dataDir, filepath.Join(tmpDir, "tbyd.db")); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, nil, err
+	}
+
+	snap, err := storage.Open(tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	return snap, func() {
+		snap.Close()
+		os.RemoveAll(tmpDir)
+	}, nil
+}
+
+func init() {
+	evalRetrievalCmd.Flags().Int("k", 0, "number of results to score (default: retrieval.top_k)")
+	evalRetrievalCmd.Flags().String("format", "table", "output format: table or json")
+	evalRetrievalCmd.Flags().String("output", "", "output file path (default: stdout)")
+	evalRetrievalCmd.Flags().Bool("no-rerank", false, "skip the reranked strategy variants")
+	evalRetrievalCmd.Flags().Bool("expand", false, "add +expand variants that search with query expansion")
+	evalRetrievalCmd.Flags().Bool("no-timing", false, "omit latency so reports are identical across runs")
+	evalRetrievalCmd.Flags().String("strategies", "", "comma-separated strategy names to run (default: all)")
+	evalCmd.AddCommand(evalRetrievalCmd)
+}
@@ -31,7 +31,14 @@ func init() {
 	rootCmd.AddCommand(recallCmd)
 	rootCmd.AddCommand(interactionsCmd)
 	rootCmd.AddCommand(dataCmd)
+	rootCmd.AddCommand(retrievalCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(personaCmd)
+	rootCmd.AddCommand(projectCmd)
+	rootCmd.AddCommand(jobsCmd)
+	rootCmd.AddCommand(factsCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(evalCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
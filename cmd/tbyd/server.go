@@ -31,6 +31,7 @@ import (
 	"github.com/kalambet/tbyd/internal/proxy"
 	"github.com/kalambet/tbyd/internal/reranking"
 	"github.com/kalambet/tbyd/internal/retrieval"
+	"github.com/kalambet/tbyd/internal/scheduler"
 	"github.com/kalambet/tbyd/internal/storage"
 	"github.com/kalambet/tbyd/internal/synthesis"
 )
@@ -146,7 +147,8 @@ func runServer() error {
 
 	// Build enrichment pipeline.
 	ollamaEngine := eng
-	profileMgr := profile.NewManager(store)
+	personas := profile.NewRegistry(func(persona string) profile.ProfileStore { return store.PersonaProfile(persona) })
+	profileMgr := personas.Default()
 	calibrationProvider := func() profile.CalibrationContext {
 		cal, err := profileMgr.GetCalibrationContext()
 		if err != nil {
@@ -158,21 +160,19 @@ func runServer() error {
 	extractor := intent.NewExtractor(engine.ChatAdapter(ollamaEngine), cfg.Ollama.FastModel, calibrationProvider)
 	embedder := retrieval.NewEmbedder(ollamaEngine, cfg.Ollama.EmbedModel)
 	vectorStore := retrieval.NewSQLiteStore(store.DB())
-	retriever := retrieval.NewRetriever(embedder, vectorStore)
-	comp := composer.New(0)
-	rerankTimeout, err := time.ParseDuration(cfg.Enrichment.RerankingTimeout)
-	if err != nil {
-		slog.Warn("invalid reranking timeout, using default 5s", "value", cfg.Enrichment.RerankingTimeout, "error", err)
-		rerankTimeout = 5 * time.Second
+	retriever := newRetriever(cfg, ollamaEngine, embedder, vectorStore)
+	retriever.SetGraph(store, cfg.Retrieval.GraphNeighbours)
+
+	// Learn the per-intent vector/keyword ratio from feedback. Left as a nil
+	// interface when disabled so the API reports the feature as unavailable.
+	var hybridTuner api.HybridTuner
+	if cfg.Retrieval.AdaptiveHybridEnabled {
+		tuner := retrieval.NewHybridTuner(store.DB(), cfg.Retrieval.HybridRatioMin, cfg.Retrieval.HybridRatioMax)
+		retriever.SetHybridRatioSource(tuner)
+		hybridTuner = tuner
 	}
-	reranker := reranking.NewReranker(
-		ollamaEngine,
-		cfg.Ollama.FastModel,
-		cfg.Enrichment.RerankingEnabled,
-		rerankTimeout,
-		cfg.Enrichment.RerankingThreshold,
-		cfg.Retrieval.TopK,
-	)
+	comp := composer.New(0)
+	reranker := newReranker(cfg, ollamaEngine)
 
 	// Build query cache. Uses the same embedder as retrieval so that cosine
 	// similarity scores are comparable between cache lookups and retrieval.
@@ -193,14 +193,38 @@ func runServer() error {
 		exactTTL,
 		semanticTTL,
 	)
+	if cfg.Enrichment.CacheEnabled && cfg.Enrichment.CachePersistEnabled {
+		persistInterval, err := time.ParseDuration(cfg.Enrichment.CachePersistInterval)
+		if err != nil {
+			slog.Warn("invalid cache persist interval, using default 5m", "value", cfg.Enrichment.CachePersistInterval, "error", err)
+			persistInterval = 5 * time.Minute
+		}
+		queryCache.SetPersister(qcache.NewSQLitePersister(store.DB()), cfg.Ollama.EmbedModel, persistInterval)
+		if n, err := queryCache.Load(ctx); err != nil {
+			slog.Warn("failed to load persisted query cache, starting cold", "error", err)
+		} else {
+			slog.Info("query cache restored", "entries", n)
+		}
+	} else if err := qcache.NewSQLitePersister(store.DB()).Clear(ctx); err != nil {
+		// A snapshot left from when persistence was on was never invalidated
+		// by deletes since; drop it so re-enabling cannot restore it.
+		slog.Warn("failed to clear stale persisted query cache", "error", err)
+	}
+	// Stop saves the cache when persistence is enabled, so it must run before
+	// the store is closed (defers run in reverse order).
 	defer queryCache.Stop()
 
 	enricher := pipeline.NewEnricher(extractor, retriever, profileMgr, comp, reranker, cfg.Retrieval.TopK, queryCache)
-
-	// Wire cache invalidation: profile updates invalidate ALL cached enrichments
-	// (not topic-selective) because profile fields like tone, role, and detail
-	// level affect every enriched response, not just topic-specific ones.
-	profileMgr.OnInvalidate(func() { queryCache.Invalidate() })
+	enricher.SetPersonas(personas)
+	enricher.SetProjectOnly(cfg.Retrieval.ProjectMode == "filter")
+
+	// Wire cache invalidation: a profile update invalidates ALL of that
+	// persona's cached enrichments (not topic-selective) because profile fields
+	// like tone, role, and detail level affect every enriched response, not
+	// just topic-specific ones. Other personas' entries are unaffected.
+	personas.OnInvalidate(func(persona string, version int64) {
+		queryCache.InvalidateProfile(pipeline.CacheScope(persona), version)
+	})
 
 	// Retrieve API token for bearer auth on management endpoints.
 	apiToken, err := config.GetAPIToken(config.NewKeychain())
@@ -210,6 +234,7 @@ func runServer() error {
 
 	// Build ingest worker with optional interaction summarizer.
 	worker := ingest.NewWorker(store, embedder, vectorStore, 500*time.Millisecond)
+	worker.SetCache(queryCache)
 	enqueueSummarize := false
 	summarizeModel := cfg.Ollama.DeepModel
 	if summarizeModel == "" {
@@ -229,6 +254,10 @@ func runServer() error {
 	feedbackWorker := synthesis.NewFeedbackWorker(store, prefExtractor, profileMgr, 500*time.Millisecond)
 	go feedbackWorker.Run(ctx)
 
+	// Periodic jobs registered by the workers below are enqueued by the
+	// scheduler; see scheduler.Register.
+	sched := newScheduler(store, cfg.Scheduler)
+
 	// Build and start nightly profile synthesis worker.
 	nightlyModel := cfg.Ollama.DeepModel
 	if nightlyModel == "" {
@@ -236,7 +265,42 @@ func runServer() error {
 	}
 	nightlySynth := synthesis.NewNightlySynthesizer(store, engine.ChatAdapter(ollamaEngine), nightlyModel)
 	go nightlySynth.ProcessJobs(ctx, 30*time.Second)
-	go nightlySynth.Schedule(ctx, 24*time.Hour)
+
+	// Build and start the interest promotion/decay review.
+	interestReviewer := synthesis.NewInterestReviewer(store, profileMgr)
+	go interestReviewer.ProcessJobs(ctx, 30*time.Second)
+
+	// Build and start preference deduplication and contradiction checks. The
+	// API also enqueues a pass whenever a pending delta is accepted.
+	reconciler := synthesis.NewPreferenceReconciler(store, profileMgr, embedder, engine.ChatAdapter(ollamaEngine), cfg.Ollama.FastModel)
+	go reconciler.ProcessJobs(ctx, 30*time.Second)
+
+	// Build and start the delta policy engine. The feedback worker also
+	// enqueues a pass whenever it proposes new preferences.
+	deltaPolicy := synthesis.DeltaPolicy{
+		AutoAccept:    cfg.Profile.AutoAcceptEnabled,
+		MinCount:      cfg.Profile.AutoAcceptMinCount,
+		MinConfidence: cfg.Profile.AutoAcceptMinConfidence,
+		ExpireAfter:   time.Duration(cfg.Profile.DeltaExpiryDays) * 24 * time.Hour,
+	}
+	policyEngine := synthesis.NewPolicyEngine(store, profileMgr, deltaPolicy)
+	go policyEngine.ProcessJobs(ctx, 5*time.Second)
+
+	// Build and start episodic memory consolidation. Passes are no-ops
+	// unless enabled, so scheduled jobs never pile up in the queue.
+	consolidator := synthesis.NewMemoryConsolidator(store, engine.ChatAdapter(ollamaEngine), nightlyModel, synthesis.ConsolidationPolicy{
+		Enabled:    cfg.Enrichment.ConsolidationEnabled,
+		MinAge:     time.Duration(cfg.Enrichment.ConsolidationMinAgeDays) * 24 * time.Hour,
+		Window:     time.Duration(cfg.Enrichment.ConsolidationWindowDays) * 24 * time.Hour,
+		MinCluster: cfg.Enrichment.ConsolidationMinCluster,
+	})
+	go consolidator.ProcessJobs(ctx, 30*time.Second)
+
+	// Build and start fact extraction from completed interactions. Facts wait
+	// for review; see the /facts/pending API. Passes are no-ops unless enabled.
+	factExtractor := synthesis.NewFactExtractor(store, engine.ChatAdapter(ollamaEngine), nightlyModel,
+		cfg.Enrichment.FactMinConfidence, cfg.Enrichment.FactExtractionEnabled)
+	go factExtractor.ProcessJobs(ctx, 30*time.Second)
 
 	// Build and start deep enrichment worker (if enabled).
 	if cfg.Enrichment.DeepEnabled {
@@ -246,31 +310,68 @@ func runServer() error {
 		}
 		deepEnricher := synthesis.NewDeepEnricher(engine.ChatAdapter(ollamaEngine), deepModel)
 		deepBatcher := synthesis.NewBatcher(synthesis.DefaultContextWindowTokens)
-		deepIdle := synthesis.NewIdleDetector(cfg.Enrichment.DeepIdleCPUMaxPct, cfg.Enrichment.DeepIdleMemMinGB)
+		deepIdle := synthesis.NewIdleDetector(cfg.Enrichment.DeepIdleCPUMaxPct, cfg.Enrichment.DeepIdleMemMinGB, cfg.Enrichment.DeepIdleSkipOnBattery)
 		deepWorker := synthesis.NewDeepEnrichmentWorker(store, deepEnricher, deepBatcher, deepIdle, cfg.Enrichment.DeepBatchClaimLimit)
-		scheduledHour := 2 // default
-		if h, err := parseScheduleHour(cfg.Enrichment.DeepSchedule); err == nil {
-			scheduledHour = h
-		} else {
-			slog.Info("invalid deep enrichment schedule, defaulting to 02:00 UTC",
+		deepSpec, err := deepScheduleSpec(cfg.Enrichment.DeepSchedule)
+		if err != nil {
+			slog.Info("invalid deep enrichment schedule, defaulting to 02:00",
 				"schedule", cfg.Enrichment.DeepSchedule, "error", err)
+			deepSpec = "0 2 * * *"
 		}
-		go deepWorker.Schedule(ctx, 5*time.Minute, scheduledHour)
+		if err := sched.AddFunc("deep_enrich", deepSpec, deepWorker.Run); err != nil {
+			slog.Warn("deep enrichment schedule rejected, running only when idle", "error", err)
+		}
+		go deepWorker.RunWhenIdle(ctx, 5*time.Minute)
 		slog.Info("deep enrichment worker started", "model", deepModel, "schedule", cfg.Enrichment.DeepSchedule)
 	}
 
+	go func() {
+		if err := sched.Run(ctx); err != nil {
+			slog.Error("scheduler stopped", "error", err)
+		}
+	}()
+
+	// Full response cache for opted-in clients; nil when no client opted in.
+	var responseCache *qcache.ResponseCache
+	if strings.TrimSpace(cfg.Proxy.ResponseCacheClients) != "" {
+		responseTTL, err := time.ParseDuration(cfg.Proxy.ResponseCacheTTL)
+		if err != nil || responseTTL <= 0 {
+			slog.Warn("invalid response cache TTL, using default 10m", "value", cfg.Proxy.ResponseCacheTTL, "error", err)
+			responseTTL = 10 * time.Minute
+		}
+		responseCache = qcache.NewResponseCache(cfg.Proxy.ResponseCacheClients, responseTTL)
+		slog.Info("response cache enabled", "clients", cfg.Proxy.ResponseCacheClients, "ttl", responseTTL)
+	}
+
+	// Model router for "tbyd/auto" requests; invalid intent rules are dropped
+	// rather than failing startup.
+	router, err := proxy.NewRouter(cfg.Proxy.DefaultModel, cfg.Proxy.IntentModels)
+	if err != nil {
+		slog.Warn("invalid intent routing rules, routing by preference and default only", "value", cfg.Proxy.IntentModels, "error", err)
+		router, _ = proxy.NewRouter(cfg.Proxy.DefaultModel, "")
+	}
+
 	// Build HTTP handler and server.
 	proxyClient := proxy.NewClient(cfg.Proxy.OpenRouterAPIKey)
 	onboarding := api.NewOnboardingNotifier(&serverOnboardingConfig{cfg: cfg})
-	openaiHandler, waitSaveLoop := api.NewOpenAIHandler(ctx, proxyClient, enricher, store, cfg.Storage.SaveInteractions, enqueueSummarize, onboarding)
+	openaiHandler, waitSaveLoop := api.NewOpenAIHandler(ctx, proxyClient, enricher, store, cfg.Storage.SaveInteractions, enqueueSummarize, onboarding, api.OpenAIOptions{
+		Responses: responseCache,
+		Personas:  store,
+		Projects:  store,
+		Router:    router,
+	})
 	appHandler := api.NewAppHandler(api.AppDeps{
 		Store:             store,
 		Profile:           profileMgr,
+		Personas:          personas,
 		Token:             apiToken,
 		HTTPClient:        &http.Client{Timeout: 15 * time.Second},
 		Vectors:           vectorStore,
 		Retriever:         retriever,
 		DeepEnrichEnabled: cfg.Enrichment.DeepEnabled,
+		Tuner:             hybridTuner,
+		Cache:             queryCache,
+		Jobs:              sched,
 	})
 
 	// Compose top-level router: OpenAI-compat routes + management/ingest routes.
@@ -301,6 +402,9 @@ func runServer() error {
 		Engine:            mcpEngine,
 		DeepModel:         cfg.Ollama.DeepModel,
 		DeepEnrichEnabled: cfg.Enrichment.DeepEnabled,
+		Tuner:             hybridTuner,
+		Personas:          personas,
+		ProjectOnly:       cfg.Retrieval.ProjectMode == "filter",
 	})
 	stdioSrv := server.NewStdioServer(mcpSrv)
 	go func() {
@@ -514,19 +618,45 @@ func (s *serverOnboardingConfig) MarkOnboardingShown() error {
 	return config.SetKey("storage.onboarding_shown", "true")
 }
 
-// parseScheduleHour parses a schedule string like "2:00" or "14:30" and returns
-// the hour component (0–23). Only hour-level granularity is used; if minutes
-// are non-zero, a warning is emitted. Returns an error if the format is unrecognised.
-func parseScheduleHour(schedule string) (int, error) {
+// deepScheduleSpec converts a daily schedule string like "2:00" or "14:30"
+// into the equivalent cron expression. Returns an error if the format is
+// unrecognised.
+func deepScheduleSpec(schedule string) (string, error) {
 	t, err := time.Parse("15:04", schedule)
 	if err != nil {
-		return 0, fmt.Errorf("invalid schedule format %q (expected HH:MM): %w", schedule, err)
+		return "", fmt.Errorf("invalid schedule format %q (expected HH:MM): %w", schedule, err)
 	}
-	if t.Minute() != 0 {
-		slog.Warn("deep enrichment schedule minutes ignored; only hour-level granularity is supported",
-			"schedule", schedule, "effective_hour", t.Hour())
+	return fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour()), nil
+}
+
+// newScheduler builds the background job scheduler from cfg. Invalid
+// settings are logged and fall back to their defaults.
+func newScheduler(store *storage.Store, cfg config.SchedulerConfig) *scheduler.Scheduler {
+	opts := scheduler.Options{Location: time.Local}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			slog.Warn("invalid scheduler timezone, using local time", "value", cfg.Timezone, "error", err)
+		} else {
+			opts.Location = loc
+		}
 	}
-	return t.Hour(), nil
+	if cfg.Jitter != "" {
+		jitter, err := time.ParseDuration(cfg.Jitter)
+		if err != nil || jitter < 0 {
+			slog.Warn("invalid scheduler jitter, running without jitter", "value", cfg.Jitter, "error", err)
+		} else {
+			opts.Jitter = jitter
+		}
+	}
+	overrides, err := scheduler.ParseOverrides(cfg.Schedules)
+	if err != nil {
+		slog.Warn("invalid schedule overrides, using default schedules", "value", cfg.Schedules, "error", err)
+	} else {
+		opts.Overrides = overrides
+	}
+
+	return scheduler.New(store, opts)
 }
 
 // engineChatAdapter wraps engine.Engine to satisfy ingest.ChatEngine.
@@ -551,3 +681,58 @@ func (a *engineChatAdapter) Chat(ctx context.Context, model string, messages []i
 
 	return a.eng.Chat(ctx, model, engineMsgs, nil)
 }
+
+// newRetriever builds the retriever from config, attaching the query expander
+// when expansion is enabled.
+func newRetriever(cfg config.Config, eng engine.Engine, embedder *retrieval.Embedder, vectors retrieval.VectorStore) *retrieval.Retriever {
+	retriever := retrieval.NewRetriever(embedder, vectors)
+	if cfg.Enrichment.ExpansionEnabled {
+		expansionBudget, err := time.ParseDuration(cfg.Enrichment.ExpansionBudget)
+		if err != nil {
+			slog.Warn("invalid expansion budget, using default 800ms", "value", cfg.Enrichment.ExpansionBudget, "error", err)
+			expansionBudget = 800 * time.Millisecond
+		}
+		retriever.SetExpander(retrieval.NewLLMExpander(eng, cfg.Ollama.FastModel, expansionBudget, cfg.Enrichment.ExpansionParaphrases), expansionBudget)
+	}
+	return retriever
+}
+
+// newReranker builds the reranker selected by enrichment.reranking_backend.
+func newReranker(cfg config.Config, eng engine.Engine) reranking.Reranker {
+	rerankTimeout, err := time.ParseDuration(cfg.Enrichment.RerankingTimeout)
+	if err != nil {
+		slog.Warn("invalid reranking timeout, using default 5s", "value", cfg.Enrichment.RerankingTimeout, "error", err)
+		rerankTimeout = 5 * time.Second
+	}
+	if !cfg.Enrichment.RerankingEnabled {
+		return &reranking.NoOpReranker{}
+	}
+
+	switch cfg.Enrichment.RerankingBackend {
+	case "cross_encoder":
+		slog.Info("cross-encoder reranker enabled", "url", cfg.Enrichment.RerankingURL)
+		return reranking.NewCrossEncoderReranker(
+			cfg.Enrichment.RerankingURL,
+			cfg.Enrichment.RerankingModel,
+			rerankTimeout,
+			cfg.Enrichment.RerankingThreshold,
+			cfg.Enrichment.RerankingRawLogits,
+		)
+	case "listwise":
+		return reranking.NewListwiseReranker(
+			eng,
+			cfg.Ollama.FastModel,
+			rerankTimeout,
+			cfg.Enrichment.RerankingThreshold,
+		)
+	}
+	// "llm"; config loading rejects any other backend.
+	return reranking.NewReranker(
+		eng,
+		cfg.Ollama.FastModel,
+		cfg.Enrichment.RerankingEnabled,
+		rerankTimeout,
+		cfg.Enrichment.RerankingThreshold,
+		cfg.Retrieval.TopK,
+	)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kalambet/tbyd/internal/cache"
+)
+
+// QueryCache is the enrichment cache as seen by the management API.
+// *cache.QueryCache satisfies it.
+type QueryCache interface {
+	// InvalidateBySources evicts entries that used any of the given vector
+	// IDs or drew context from any of the given doc or interaction IDs.
+	InvalidateBySources(ids []string)
+	// Invalidate evicts every entry and returns how many were removed.
+	Invalidate() int
+	Stats() cache.Stats
+}
+
+func handleCacheStats(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Cache == nil {
+			httpError(w, http.StatusNotImplemented, "api_error", "query cache is not available")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deps.Cache.Stats())
+	}
+}
+
+// handleInvalidateCache drops every cached enrichment. Counters are kept so
+// that hit rates stay comparable across manual flushes.
+func handleInvalidateCache(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Cache == nil {
+			httpError(w, http.StatusNotImplemented, "api_error", "query cache is not available")
+			return
+		}
+		evicted := deps.Cache.Invalidate()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  "invalidated",
+			"evicted": evicted,
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/cache"
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func setupCacheHandler(t *testing.T) (http.Handler, *cache.QueryCache) {
+	t.Helper()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	qc := cache.NewQueryCache(constEmbedder{}, true, 0.99, time.Hour, time.Hour)
+	t.Cleanup(qc.Stop)
+
+	h := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    profile.NewManager(store),
+		Token:      testToken,
+		HTTPClient: http.DefaultClient,
+		Cache:      qc,
+	})
+	return h, qc
+}
+
+func TestCacheStats(t *testing.T) {
+	h, qc := setupCacheHandler(t)
+	ctx := context.Background()
+	qc.Set(ctx, "what is go", []float32{1, 0, 0, 0}, cache.CachedEnrichment{Metadata: "m"})
+	qc.Get(ctx, "what is go")
+	qc.Get(ctx, "something else")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/cache/stats", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var stats cache.Stats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding stats: %v", err)
+	}
+	if stats.Exact.Hits != 1 || stats.Exact.Misses != 1 {
+		t.Errorf("exact hits/misses = %d/%d, want 1/1", stats.Exact.Hits, stats.Exact.Misses)
+	}
+	if stats.Exact.Entries != 1 || stats.MemoryBytes == 0 {
+		t.Errorf("entries = %d, memory = %d; want 1 entry and nonzero memory", stats.Exact.Entries, stats.MemoryBytes)
+	}
+	if len(stats.SimilarityHistogram) == 0 {
+		t.Error("similarity histogram is empty")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	h, qc := setupCacheHandler(t)
+	ctx := context.Background()
+	qc.Set(ctx, "what is go", []float32{1, 0, 0, 0}, cache.CachedEnrichment{Metadata: "m"})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/cache/invalidate", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp struct {
+		Status  string `json:"status"`
+		Evicted int    `json:"evicted"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Status != "invalidated" || resp.Evicted != 2 {
+		t.Errorf("response = %+v, want invalidated with 2 evicted (exact and semantic)", resp)
+	}
+	if r := qc.Get(ctx, "what is go"); r.Hit {
+		t.Error("cache still served an entry after invalidation")
+	}
+}
+
+func TestCacheEndpoints_NotAvailable(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	for _, req := range []*http.Request{
+		authReq(http.MethodGet, "/cache/stats", "", testToken),
+		authReq(http.MethodPost, "/cache/invalidate", "", testToken),
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("%s %s status = %d, want %d", req.Method, req.URL.Path, rr.Code, http.StatusNotImplemented)
+		}
+	}
+}
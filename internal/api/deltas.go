@@ -1,16 +1,55 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/kalambet/tbyd/internal/profile"
 	"github.com/kalambet/tbyd/internal/storage"
+	"github.com/kalambet/tbyd/internal/synthesis"
 )
 
+// PendingDeltaResponse is a pending delta as listed by GET
+// /profile/pending-deltas, with its evidence decoded and, where the evidence
+// cites stored items, API paths to them so a reviewer can inspect them first.
+type PendingDeltaResponse struct {
+	storage.PendingProfileDelta
+	Evidence      json.RawMessage `json:"evidence,omitempty"`
+	EvidenceLinks []string        `json:"evidence_links,omitempty"`
+}
+
+func newPendingDeltaResponse(d storage.PendingProfileDelta) PendingDeltaResponse {
+	resp := PendingDeltaResponse{PendingProfileDelta: d}
+	if d.EvidenceJSON == "" || !json.Valid([]byte(d.EvidenceJSON)) {
+		return resp
+	}
+	resp.Evidence = json.RawMessage(d.EvidenceJSON)
+
+	// Only nightly synthesis evidence cites interactions and documents; the
+	// other sources record aggregate counts.
+	var cited synthesis.NightlyEvidence
+	if err := json.Unmarshal([]byte(d.EvidenceJSON), &cited); err != nil {
+		return resp
+	}
+	for _, id := range cited.InteractionIDs {
+		resp.EvidenceLinks = append(resp.EvidenceLinks, "/interactions/"+url.PathEscape(id))
+	}
+	for _, id := range cited.DocIDs {
+		resp.EvidenceLinks = append(resp.EvidenceLinks, "/context-docs/"+url.PathEscape(id))
+	}
+	return resp
+}
+
 func handleGetPendingDeltas(deps AppDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		deltas, err := deps.Store.ListPendingDeltas()
@@ -18,20 +57,134 @@ func handleGetPendingDeltas(deps AppDeps) http.HandlerFunc {
 			httpError(w, http.StatusInternalServerError, "api_error", "failed to list pending deltas: %v", err)
 			return
 		}
-		if deltas == nil {
-			deltas = []storage.PendingProfileDelta{}
+		resp := make([]PendingDeltaResponse, 0, len(deltas))
+		for _, d := range deltas {
+			resp = append(resp, newPendingDeltaResponse(d))
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(deltas)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// AcceptDeltaRequest is the optional body of POST
+// /profile/pending-deltas/{id}/accept. Without a body the pending delta is
+// applied as proposed; with one, Delta is applied instead, so a client can
+// accept a subset of the proposed entries or edited versions of them.
+type AcceptDeltaRequest struct {
+	Delta *profile.ProfileDelta `json:"delta"`
+}
+
+// validateEditedDelta checks that edited only drops or rewords entries of
+// proposed. Only AddPreferences and UpdateFields values may be reworded:
+// AddPreferences may hold at most as many entries as proposed, and fields may
+// only be set if proposed sets them and PATCH /profile accepts them. Every
+// other list must be a subset of the proposed one, matched
+// case-insensitively, so an edit cannot remove or move entries the reviewer
+// was never shown.
+func validateEditedDelta(proposed, edited profile.ProfileDelta) error {
+	keys := make([]string, 0, len(edited.UpdateFields))
+	for key := range edited.UpdateFields {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := profileKeyAllowlist[key]; !ok {
+			return fmt.Errorf("unrecognised profile key %q", key)
+		}
+		if _, ok := proposed.UpdateFields[key]; !ok {
+			return fmt.Errorf("field %q is not in the proposed delta", key)
+		}
+	}
+
+	if len(edited.AddPreferences) > len(proposed.AddPreferences) {
+		return fmt.Errorf("AddPreferences has %d entries but the proposed delta has %d",
+			len(edited.AddPreferences), len(proposed.AddPreferences))
+	}
+
+	for _, l := range []struct {
+		name             string
+		proposed, edited []string
+	}{
+		{"RemovePreferences", proposed.RemovePreferences, edited.RemovePreferences},
+		{"RemoveOpinions", proposed.RemoveOpinions, edited.RemoveOpinions},
+		{"PromoteInterests", proposed.PromoteInterests, edited.PromoteInterests},
+		{"DecayInterests", proposed.DecayInterests, edited.DecayInterests},
+	} {
+		allowed := make(map[string]bool, len(l.proposed))
+		for _, entry := range l.proposed {
+			allowed[strings.ToLower(entry)] = true
+		}
+		for _, entry := range l.edited {
+			if !allowed[strings.ToLower(entry)] {
+				return fmt.Errorf("%s entry %q is not in the proposed delta", l.name, entry)
+			}
+		}
+	}
+	return nil
 }
 
 func handleAcceptDelta(deps AppDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 
-		// Atomically mark as accepted first to prevent TOCTOU races.
-		if err := deps.Store.ReviewDelta(id, true); err != nil {
+		// Parse the optional edited delta before reviewing, so a bad body
+		// leaves the delta pending.
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "reading request body: %v", err)
+			return
+		}
+		var edited *profile.ProfileDelta
+		if len(bytes.TrimSpace(body)) > 0 {
+			var req AcceptDeltaRequest
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&req); err != nil {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: %v", err)
+				return
+			}
+			if req.Delta == nil || req.Delta.IsEmpty() {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "delta accepts no changes; reject it instead")
+				return
+			}
+			edited = req.Delta
+		}
+
+		delta, err := deps.Store.GetPendingDelta(id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "pending delta not found")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get pending delta: %v", err)
+			return
+		}
+		if delta.Accepted != nil {
+			httpError(w, http.StatusConflict, "conflict", "delta has already been reviewed")
+			return
+		}
+		var profileDelta profile.ProfileDelta
+		if err := json.Unmarshal([]byte(delta.DeltaJSON), &profileDelta); err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to parse delta JSON: %v", err)
+			return
+		}
+		if edited != nil {
+			if err := validateEditedDelta(profileDelta, *edited); err != nil {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "%v", err)
+				return
+			}
+			profileDelta = *edited
+		}
+		appliedJSON, err := json.Marshal(profileDelta)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to marshal delta: %v", err)
+			return
+		}
+
+		// Atomically mark as accepted, recording what is applied, before
+		// applying it to prevent TOCTOU races.
+		if err := deps.Store.AcceptDelta(id, string(appliedJSON)); err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				httpError(w, http.StatusNotFound, "not_found", "pending delta not found")
 				return
@@ -44,8 +197,8 @@ func handleAcceptDelta(deps AppDeps) http.HandlerFunc {
 			return
 		}
 
-		// rollback unreview helper — on any failure after ReviewDelta, reset so
-		// the delta reappears in the pending list and the user can retry.
+		// rollback unreview helper — on any failure after AcceptDelta, reset
+		// so the delta reappears in the pending list and the user can retry.
 		rollback := func(opErr error) {
 			if rbErr := deps.Store.UnreviewDelta(id); rbErr != nil {
 				slog.Error("failed to roll back delta review",
@@ -53,25 +206,21 @@ func handleAcceptDelta(deps AppDeps) http.HandlerFunc {
 			}
 		}
 
-		// Now fetch the delta to apply it.
-		delta, err := deps.Store.GetPendingDelta(id)
-		if err != nil {
+		if err := deps.Profile.ApplyDelta(deltaSource(delta.Source), profileDelta); err != nil {
 			rollback(err)
-			httpError(w, http.StatusInternalServerError, "api_error", "failed to get pending delta: %v", err)
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to apply delta: %v", err)
 			return
 		}
 
-		var profileDelta profile.ProfileDelta
-		if err := json.Unmarshal([]byte(delta.DeltaJSON), &profileDelta); err != nil {
-			rollback(err)
-			httpError(w, http.StatusInternalServerError, "api_error", "failed to parse delta JSON: %v", err)
-			return
+		// Look for duplicates and contradictions the accepted delta may have
+		// introduced. Non-fatal: the delta is already applied.
+		job := storage.Job{
+			ID:          uuid.New().String(),
+			Type:        synthesis.PreferenceReconcileJobType,
+			PayloadJSON: "{}",
 		}
-
-		if err := deps.Profile.ApplyDelta(profileDelta); err != nil {
-			rollback(err)
-			httpError(w, http.StatusInternalServerError, "api_error", "failed to apply delta: %v", err)
-			return
+		if err := deps.Store.EnqueueJob(r.Context(), job); err != nil {
+			slog.Warn("failed to enqueue preference reconciliation", "delta_id", id, "error", err)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -100,3 +249,83 @@ func handleRejectDelta(deps AppDeps) http.HandlerFunc {
 		json.NewEncoder(w).Encode(map[string]string{"status": "rejected"})
 	}
 }
+
+func handleListDeltaDecisions(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseIntParam(r, "limit", 20, 100)
+		offset := parseIntParam(r, "offset", 0, 0)
+
+		decisions, err := deps.Store.ListDeltaDecisions(limit, offset)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list delta decisions: %v", err)
+			return
+		}
+		if decisions == nil {
+			decisions = []storage.DeltaDecision{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(decisions)
+	}
+}
+
+// handleUndoDeltaDecision reverts an automatic decision: an accepted delta's
+// additions are removed from the profile, and the delta returns to the
+// pending list for manual review. The policy engine leaves it alone after.
+func handleUndoDeltaDecision(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		// Atomically mark as undone first so concurrent undos cannot both
+		// revert the profile.
+		if err := deps.Store.MarkDecisionUndone(id); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "delta decision not found")
+				return
+			}
+			if errors.Is(err, storage.ErrAlreadyUndone) {
+				httpError(w, http.StatusConflict, "conflict", "decision has already been undone")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to mark decision as undone: %v", err)
+			return
+		}
+
+		rollback := func(opErr error) {
+			if rbErr := deps.Store.ClearDecisionUndone(id); rbErr != nil {
+				slog.Error("failed to roll back decision undo",
+					"decision_id", id, "op_error", opErr, "rollback_error", rbErr)
+			}
+		}
+
+		decision, err := deps.Store.GetDeltaDecision(id)
+		if err != nil {
+			rollback(err)
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get delta decision: %v", err)
+			return
+		}
+
+		if decision.Action == synthesis.DecisionAccepted && decision.AppliedJSON != "" {
+			var applied profile.ProfileDelta
+			if err := json.Unmarshal([]byte(decision.AppliedJSON), &applied); err != nil {
+				rollback(err)
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to parse applied delta: %v", err)
+				return
+			}
+			inverse := profile.ProfileDelta{RemovePreferences: applied.AddPreferences}
+			if err := deps.Profile.ApplyDelta(profile.SourceAPI, inverse); err != nil {
+				rollback(err)
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to revert delta: %v", err)
+				return
+			}
+		}
+
+		if err := deps.Store.UnreviewDelta(decision.DeltaID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			// The profile change is already reverted; the delta just stays
+			// out of the pending list.
+			slog.Warn("failed to return delta to pending", "delta_id", decision.DeltaID, "error", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "undone"})
+	}
+}
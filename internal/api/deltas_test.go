@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/kalambet/tbyd/internal/profile"
 	"github.com/kalambet/tbyd/internal/storage"
+	"github.com/kalambet/tbyd/internal/synthesis"
 )
 
 // savePendingDelta is a test helper that inserts a pending delta and fails on error.
@@ -71,6 +74,42 @@ func TestGetPendingDeltas_ReturnsList(t *testing.T) {
 	}
 }
 
+func TestGetPendingDeltas_LinksEvidence(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	if err := store.SavePendingDelta(storage.PendingProfileDelta{
+		ID:           "delta-evidence-1",
+		DeltaJSON:    `{"AddPreferences":["concise responses"]}`,
+		Description:  "brevity",
+		Source:       "nightly_synthesis",
+		CreatedAt:    time.Now().UTC(),
+		EvidenceJSON: `{"interaction_ids":["ix-1"],"doc_ids":["doc-1"],"signal_patterns":["concise"]}`,
+	}); err != nil {
+		t.Fatalf("SavePendingDelta: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/profile/pending-deltas", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var result []PendingDeltaResponse
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("got %d deltas, want 1", len(result))
+	}
+	want := []string{"/interactions/ix-1", "/context-docs/doc-1"}
+	if !reflect.DeepEqual(result[0].EvidenceLinks, want) {
+		t.Errorf("EvidenceLinks = %v, want %v", result[0].EvidenceLinks, want)
+	}
+	var ev synthesis.NightlyEvidence
+	if err := json.Unmarshal(result[0].Evidence, &ev); err != nil || !reflect.DeepEqual(ev.SignalPatterns, []string{"concise"}) {
+		t.Errorf("Evidence = %s (%v)", result[0].Evidence, err)
+	}
+}
+
 func TestAcceptDelta(t *testing.T) {
 	h, store := setupAppHandler(t, testToken)
 	delta := savePendingDelta(t, store, "delta-accept-1", "nightly_synthesis")
@@ -102,6 +141,132 @@ func TestAcceptDelta(t *testing.T) {
 	}
 }
 
+func TestAcceptDelta_EnqueuesReconciliation(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	delta := savePendingDelta(t, store, "delta-reconcile-1", "nightly_synthesis")
+
+	rr := httptest.NewRecorder()
+	req := authReq(http.MethodPost, "/profile/pending-deltas/"+delta.ID+"/accept", "", testToken)
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	job, err := store.ClaimNextJob([]string{synthesis.PreferenceReconcileJobType})
+	if err != nil {
+		t.Fatalf("ClaimNextJob: %v", err)
+	}
+	if job == nil {
+		t.Error("accepting a delta did not enqueue a preference_reconcile job")
+	}
+}
+
+func TestAcceptDelta_EditedSubset(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	delta := storage.PendingProfileDelta{
+		ID:          "delta-partial-1",
+		DeltaJSON:   `{"AddPreferences":["concise responses","lots of emoji"],"UpdateFields":{"communication.tone":"casual"}}`,
+		Description: "mixed suggestions",
+		Source:      "nightly_synthesis",
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := store.SavePendingDelta(delta); err != nil {
+		t.Fatalf("SavePendingDelta: %v", err)
+	}
+
+	body := `{"delta":{"AddPreferences":["very concise responses"]}}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/pending-deltas/"+delta.ID+"/accept", body, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	keys, err := store.GetAllProfileKeys()
+	if err != nil {
+		t.Fatalf("GetAllProfileKeys: %v", err)
+	}
+	if keys["preferences"] != `["very concise responses"]` {
+		t.Errorf("preferences = %s, want only the edited entry", keys["preferences"])
+	}
+	if _, ok := keys["communication.tone"]; ok {
+		t.Error("skipped UpdateFields entry was applied")
+	}
+
+	stored, err := store.GetPendingDelta(delta.ID)
+	if err != nil {
+		t.Fatalf("GetPendingDelta: %v", err)
+	}
+	var applied profile.ProfileDelta
+	if err := json.Unmarshal([]byte(stored.AppliedJSON), &applied); err != nil {
+		t.Fatalf("applied_json = %q: %v", stored.AppliedJSON, err)
+	}
+	if !reflect.DeepEqual(applied.AddPreferences, []string{"very concise responses"}) || len(applied.UpdateFields) != 0 {
+		t.Errorf("applied = %+v, want the edited delta", applied)
+	}
+}
+
+func TestAcceptDelta_EditedRemovalMustBeProposed(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	delta := storage.PendingProfileDelta{
+		ID:          "delta-remove-1",
+		DeltaJSON:   `{"RemovePreferences":["verbose answers"],"DecayInterests":["Rust"]}`,
+		Description: "stale preferences",
+		Source:      "nightly_synthesis",
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := store.SavePendingDelta(delta); err != nil {
+		t.Fatalf("SavePendingDelta: %v", err)
+	}
+
+	for name, body := range map[string]string{
+		"removal not proposed": `{"delta":{"RemovePreferences":["concise responses"]}}`,
+		"decay not proposed":   `{"delta":{"DecayInterests":["Go"]}}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/pending-deltas/"+delta.ID+"/accept", body, testToken))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", name, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	// Entries are matched case-insensitively.
+	body := `{"delta":{"RemovePreferences":["Verbose Answers"]}}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/pending-deltas/"+delta.ID+"/accept", body, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+}
+
+func TestAcceptDelta_InvalidBodyLeavesPending(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	delta := savePendingDelta(t, store, "delta-badbody-1", "nightly_synthesis")
+
+	for name, body := range map[string]string{
+		"empty delta":        `{"delta":{}}`,
+		"unknown field":      `{"delta":{"AddPreferences":["x"]},"apply_all":true}`,
+		"malformed":          `{"delta":`,
+		"disallowed key":     `{"delta":{"UpdateFields":{"persona.token":"x"}}}`,
+		"field not proposed": `{"delta":{"UpdateFields":{"communication.tone":"casual"}}}`,
+		"extra entry":        `{"delta":{"AddPreferences":["concise responses","x"]}}`,
+		"list not proposed":  `{"delta":{"RemovePreferences":["concise responses"]}}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/pending-deltas/"+delta.ID+"/accept", body, testToken))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", name, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	stored, err := store.GetPendingDelta(delta.ID)
+	if err != nil {
+		t.Fatalf("GetPendingDelta: %v", err)
+	}
+	if stored.Accepted != nil {
+		t.Errorf("stored.Accepted = %v, want still pending", *stored.Accepted)
+	}
+}
+
 func TestRejectDelta(t *testing.T) {
 	h, store := setupAppHandler(t, testToken)
 	delta := savePendingDelta(t, store, "delta-reject-1", "nightly_synthesis")
@@ -190,3 +355,56 @@ func TestRejectedDeltaNotReapplied(t *testing.T) {
 		t.Errorf("accept-after-reject status = %d, want %d (conflict)", rr.Code, http.StatusConflict)
 	}
 }
+
+func TestUndoDeltaDecision_RevertsAndReturnsToPending(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	delta := savePendingDelta(t, store, "delta-auto-1", "feedback_aggregation")
+	if err := store.ReviewDelta(delta.ID, true); err != nil {
+		t.Fatalf("ReviewDelta: %v", err)
+	}
+	if _, err := store.CommitProfileChange("test", "set preferences", map[string]string{"preferences": `["uses Go","prefers tables"]`}, nil); err != nil {
+		t.Fatalf("CommitProfileChange: %v", err)
+	}
+	if err := store.SaveDeltaDecision(storage.DeltaDecision{
+		ID:          "decision-1",
+		DeltaID:     delta.ID,
+		Action:      synthesis.DecisionAccepted,
+		Rule:        synthesis.RuleFeedbackAdditions,
+		AppliedJSON: `{"AddPreferences":["prefers tables"]}`,
+		CreatedAt:   time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("SaveDeltaDecision: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/delta-decisions/decision-1/undo", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	keys, err := store.GetAllProfileKeys()
+	if err != nil {
+		t.Fatalf("GetAllProfileKeys: %v", err)
+	}
+	if keys["preferences"] != `["uses Go"]` {
+		t.Errorf("preferences = %s, want the auto-accepted entry removed", keys["preferences"])
+	}
+	stored, err := store.GetPendingDelta(delta.ID)
+	if err != nil {
+		t.Fatalf("GetPendingDelta: %v", err)
+	}
+	if stored.Accepted != nil {
+		t.Errorf("Accepted = %v, want the delta pending again", *stored.Accepted)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/delta-decisions/decision-1/undo", "", testToken))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("second undo status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/delta-decisions/missing/undo", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing undo status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kalambet/tbyd/internal/storage"
+	"github.com/kalambet/tbyd/internal/synthesis"
+)
+
+// PendingFactResponse is a pending fact as listed by GET /facts/pending, with
+// an API path to the interaction it was extracted from so a reviewer can
+// check it in context first.
+type PendingFactResponse struct {
+	storage.PendingFact
+	InteractionLink string `json:"interaction_link"`
+}
+
+func handleListPendingFacts(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseIntParam(r, "limit", 50, 200)
+		offset := parseIntParam(r, "offset", 0, 0)
+
+		facts, err := deps.Store.ListPendingFacts(limit, offset)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list pending facts: %v", err)
+			return
+		}
+		resp := make([]PendingFactResponse, 0, len(facts))
+		for _, f := range facts {
+			resp = append(resp, PendingFactResponse{
+				PendingFact:     f,
+				InteractionLink: "/interactions/" + url.PathEscape(f.InteractionID),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// AcceptFactRequest is the optional body of POST /facts/pending/{id}/accept.
+// Without a body the fact is saved as extracted; with one, Statement replaces
+// the extracted wording.
+type AcceptFactRequest struct {
+	Statement string `json:"statement"`
+}
+
+func handleAcceptFact(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "reading request body: %v", err)
+			return
+		}
+		var edited string
+		if len(bytes.TrimSpace(body)) > 0 {
+			var req AcceptFactRequest
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&req); err != nil {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: %v", err)
+				return
+			}
+			if edited = strings.TrimSpace(req.Statement); edited == "" {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "statement is empty; reject the fact instead")
+				return
+			}
+		}
+
+		fact, err := deps.Store.GetPendingFact(id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "pending fact not found")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get pending fact: %v", err)
+			return
+		}
+		if fact.Accepted != nil {
+			httpError(w, http.StatusConflict, "conflict", "fact has already been reviewed")
+			return
+		}
+		if edited != "" {
+			fact.Statement = edited
+		}
+
+		doc, err := synthesis.FactDoc(fact, time.Now().UTC())
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to build fact document: %v", err)
+			return
+		}
+		// Marks the fact accepted and saves its doc atomically, so a
+		// concurrent review cannot save it twice.
+		if err := deps.Store.AcceptFact(id, fact.Statement, doc); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "pending fact not found")
+				return
+			}
+			if errors.Is(err, storage.ErrAlreadyReviewed) {
+				httpError(w, http.StatusConflict, "conflict", "fact has already been reviewed")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to accept fact: %v", err)
+			return
+		}
+
+		if err := enqueueIngestJob(r.Context(), deps.Store, "ingest_enrich", doc.ID, 3); err != nil {
+			// The fact is saved; without a vector it is only missing from
+			// retrieval until re-ingested.
+			slog.Warn("failed to enqueue enrichment for accepted fact", "fact_id", id, "doc_id", doc.ID, "error", err)
+		}
+		if deps.DeepEnrichEnabled {
+			if err := enqueueIngestJob(r.Context(), deps.Store, "ingest_deep_enrich", doc.ID, 3); err != nil {
+				slog.Warn("failed to enqueue deep enrichment for accepted fact", "fact_id", id, "doc_id", doc.ID, "error", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "accepted", "doc_id": doc.ID})
+	}
+}
+
+func handleRejectFact(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		if err := deps.Store.RejectFact(id); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "pending fact not found")
+				return
+			}
+			if errors.Is(err, storage.ErrAlreadyReviewed) {
+				httpError(w, http.StatusConflict, "conflict", "fact has already been reviewed")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to mark fact as rejected: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "rejected"})
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+	"github.com/kalambet/tbyd/internal/synthesis"
+)
+
+// savePendingFact is a test helper that queues one extracted fact from a
+// new interaction and fails on error.
+func savePendingFact(t *testing.T, store *storage.Store, id, statement string) storage.PendingFact {
+	t.Helper()
+	fact := storage.PendingFact{
+		ID:         id,
+		Kind:       synthesis.FactKindDecision,
+		Statement:  statement,
+		Confidence: 0.8,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if _, err := store.SaveExtractedFacts("ix-"+id, []storage.PendingFact{fact}); err != nil {
+		t.Fatalf("SaveExtractedFacts(%q): %v", id, err)
+	}
+	fact.InteractionID = "ix-" + id
+	return fact
+}
+
+func TestListPendingFacts(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/facts/pending", "", testToken))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("empty list: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	savePendingFact(t, store, "fact-1", "Billing uses Postgres.")
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/facts/pending", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var result []PendingFactResponse
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(result) != 1 || result[0].Statement != "Billing uses Postgres." || result[0].InteractionLink != "/interactions/ix-fact-1" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestAcceptFact(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	fact := savePendingFact(t, store, "fact-accept-1", "Billing uses Postgres.")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/"+fact.ID+"/accept", `{"statement":"Billing uses Postgres 16."}`, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp map[string]string
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["status"] != "accepted" || resp["doc_id"] == "" {
+		t.Fatalf("response = %v", resp)
+	}
+
+	doc, err := store.GetContextDoc(resp["doc_id"])
+	if err != nil {
+		t.Fatalf("GetContextDoc: %v", err)
+	}
+	if doc.Source != synthesis.ExtractedFactSource || !strings.HasPrefix(doc.Content, "Billing uses Postgres 16.") {
+		t.Errorf("doc = %+v", doc)
+	}
+	if !strings.Contains(doc.Metadata, `"interaction_id":"ix-fact-accept-1"`) {
+		t.Errorf("doc metadata = %s, want the source interaction", doc.Metadata)
+	}
+	stored, err := store.GetPendingFact(fact.ID)
+	if err != nil {
+		t.Fatalf("GetPendingFact: %v", err)
+	}
+	if stored.Accepted == nil || !*stored.Accepted || stored.DocID != doc.ID {
+		t.Errorf("stored = %+v", stored)
+	}
+	job, err := store.ClaimNextJob([]string{"ingest_enrich"})
+	if err != nil {
+		t.Fatalf("ClaimNextJob: %v", err)
+	}
+	if job == nil || !strings.Contains(job.PayloadJSON, doc.ID) {
+		t.Errorf("accepting a fact did not enqueue ingest_enrich for its doc: %+v", job)
+	}
+
+	// Accept again — should return 409.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/"+fact.ID+"/accept", "", testToken))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("second accept status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestAcceptFact_InvalidBodyLeavesPending(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	fact := savePendingFact(t, store, "fact-badbody-1", "Billing uses Postgres.")
+
+	for name, body := range map[string]string{
+		"empty statement": `{"statement":"  "}`,
+		"unknown field":   `{"statement":"x","kind":"fact"}`,
+		"malformed":       `{"statement":`,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/"+fact.ID+"/accept", body, testToken))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", name, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	stored, err := store.GetPendingFact(fact.ID)
+	if err != nil {
+		t.Fatalf("GetPendingFact: %v", err)
+	}
+	if stored.Accepted != nil {
+		t.Errorf("stored.Accepted = %v, want still pending", *stored.Accepted)
+	}
+}
+
+func TestRejectFact(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	fact := savePendingFact(t, store, "fact-reject-1", "Billing uses Postgres.")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/"+fact.ID+"/reject", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	stored, err := store.GetPendingFact(fact.ID)
+	if err != nil {
+		t.Fatalf("GetPendingFact: %v", err)
+	}
+	if stored.Accepted == nil || *stored.Accepted || stored.DocID != "" {
+		t.Errorf("stored = %+v, want rejected without a doc", stored)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/"+fact.ID+"/accept", "", testToken))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("accepting a rejected fact: status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/facts/pending/missing/reject", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("rejecting a missing fact: status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
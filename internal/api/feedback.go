@@ -40,7 +40,7 @@ func handleFeedback(deps AppDeps) http.HandlerFunc {
 			return
 		}
 
-		if err := saveFeedback(r.Context(), deps.Store, id, req.Score, req.Notes); err != nil {
+		if err := saveFeedback(r.Context(), deps.Store, deps.Tuner, id, req.Score, req.Notes); err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				httpError(w, http.StatusNotFound, "not_found", "interaction not found")
 				return
@@ -61,17 +61,25 @@ func handleFeedback(deps AppDeps) http.HandlerFunc {
 var errNotesTooLong = fmt.Errorf("notes exceed maximum length")
 
 // saveFeedback validates notes length, persists the feedback, enqueues
-// a feedback_extract job, and adjusts quality scores for the retrieved chunks.
+// a feedback_extract job, adjusts quality scores for the retrieved chunks, and
+// (when tuner is non-nil) feeds the change in rating and the retrieval legs of
+// hybrid searches into the hybrid ratio tuner.
 // Shared by the HTTP handler and MCP tool.
 //
 // Note: feedback persistence, job enqueue, and quality adjustment are not
 // transactional. A crash between steps may leave quality scores slightly off.
 // This is acceptable for a best-effort quality signal.
-func saveFeedback(ctx context.Context, store *storage.Store, id string, score int, notes string) error {
+func saveFeedback(ctx context.Context, store *storage.Store, tuner HybridTuner, id string, score int, notes string) error {
 	if len(notes) > maxFeedbackNotesLength {
 		return errNotesTooLong
 	}
 
+	// Load the interaction first so the tuner sees the rating being replaced.
+	interaction, err := store.GetInteraction(id)
+	if err != nil {
+		return err
+	}
+
 	if err := store.UpdateFeedback(id, score, notes); err != nil {
 		return err
 	}
@@ -82,13 +90,6 @@ func saveFeedback(ctx context.Context, store *storage.Store, id string, score in
 	}
 
 	// Adjust quality scores for any vector chunks used in this interaction.
-	interaction, err := store.GetInteraction(id)
-	if err != nil {
-		slog.Warn("feedback: could not load interaction for quality adjustment",
-			"interaction_id", id, "error", err)
-		return nil
-	}
-
 	var vectorIDs []string
 	if interaction.VectorIDs != "" && interaction.VectorIDs != "[]" {
 		if err := json.Unmarshal([]byte(interaction.VectorIDs), &vectorIDs); err != nil {
@@ -109,6 +110,21 @@ func saveFeedback(ctx context.Context, store *storage.Store, id string, score in
 		}
 	}
 
+	// Only hybrid searches use the learned ratio, so only they teach it.
+	if tuner != nil && interaction.SearchStrategy == "hybrid" && interaction.IntentType != "" &&
+		interaction.RetrievalLegs != "" && interaction.RetrievalLegs != "{}" {
+		var legs map[string][]string
+		if err := json.Unmarshal([]byte(interaction.RetrievalLegs), &legs); err != nil {
+			slog.Warn("feedback: could not parse retrieval_legs JSON",
+				"interaction_id", id, "error", err)
+			return nil
+		}
+		if err := tuner.RecordFeedback(ctx, interaction.IntentType, legs, interaction.FeedbackScore, score); err != nil {
+			slog.Error("feedback: failed to update hybrid weights",
+				"interaction_id", id, "intent_type", interaction.IntentType, "error", err)
+		}
+	}
+
 	return nil
 }
 
@@ -9,6 +9,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/retrieval"
 	"github.com/kalambet/tbyd/internal/storage"
 )
 
@@ -303,3 +305,138 @@ func TestFeedback_NoVectorIDs_SkipsAdjustment(t *testing.T) {
 		t.Errorf("quality_score = %f, want 1.0 (should be unaffected when vector_ids is empty)", qs)
 	}
 }
+
+func TestFeedback_UpdatesHybridWeights(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	tuner := retrieval.NewHybridTuner(store.DB(), 0.3, 0.9)
+	h := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    profile.NewManager(store),
+		Token:      testToken,
+		HTTPClient: http.DefaultClient,
+		Tuner:      tuner,
+	})
+
+	err = store.SaveInteraction(context.Background(), storage.Interaction{
+		ID:             "ix-legs",
+		CreatedAt:      time.Now().UTC(),
+		UserQuery:      "test query",
+		Status:         "completed",
+		VectorIDs:      `["vec-a"]`,
+		IntentType:     "recall",
+		RetrievalLegs:  `{"vec-a":["vector"]}`,
+		SearchStrategy: "hybrid",
+	})
+	if err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/interactions/ix-legs/feedback", `{"score":1}`, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("feedback status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if _, ok := tuner.Ratio("recall"); !ok {
+		t.Fatal("no learned ratio for recall after feedback")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/retrieval/weights", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var weights []retrieval.HybridWeight
+	if err := json.NewDecoder(rr.Body).Decode(&weights); err != nil {
+		t.Fatalf("decoding weights: %v", err)
+	}
+	if len(weights) != 1 || weights[0].IntentType != "recall" || weights[0].Legs["vector"].Positive != 1 {
+		t.Errorf("weights = %+v, want one recall entry with a positive vector leg", weights)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodDelete, "/retrieval/weights/recall", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("reset status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if _, ok := tuner.Ratio("recall"); ok {
+		t.Error("recall still has a learned ratio after reset")
+	}
+}
+
+// recordingTuner records the feedback passed to RecordFeedback.
+type recordingTuner struct {
+	HybridTuner
+	calls [][2]int // previous, score
+}
+
+func (r *recordingTuner) RecordFeedback(_ context.Context, _ string, _ map[string][]string, previous, score int) error {
+	r.calls = append(r.calls, [2]int{previous, score})
+	return nil
+}
+
+func TestFeedback_HybridTunerSeesOnlyHybridRatingChanges(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	tuner := &recordingTuner{}
+	h := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    profile.NewManager(store),
+		Token:      testToken,
+		HTTPClient: http.DefaultClient,
+		Tuner:      tuner,
+	})
+
+	for id, strategy := range map[string]string{"ix-hybrid": "hybrid", "ix-vector": "vector_only", "ix-keyword": "keyword_heavy"} {
+		err := store.SaveInteraction(context.Background(), storage.Interaction{
+			ID:             id,
+			CreatedAt:      time.Now().UTC(),
+			Status:         "completed",
+			IntentType:     "recall",
+			RetrievalLegs:  `{"vec-a":["vector"]}`,
+			SearchStrategy: strategy,
+		})
+		if err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+
+	rate := func(id, score string) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, authReq(http.MethodPost, "/interactions/"+id+"/feedback", `{"score":`+score+`}`, testToken))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("feedback status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+	rate("ix-vector", "1")
+	rate("ix-keyword", "-1")
+	rate("ix-hybrid", "1")
+	rate("ix-hybrid", "-1")
+
+	want := [][2]int{{0, 1}, {1, -1}}
+	if len(tuner.calls) != len(want) {
+		t.Fatalf("tuner calls = %v, want %v (hybrid only)", tuner.calls, want)
+	}
+	for i := range want {
+		if tuner.calls[i] != want[i] {
+			t.Errorf("call %d = %v, want previous/score %v", i, tuner.calls[i], want[i])
+		}
+	}
+}
+
+func TestHybridWeights_NotEnabled(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/retrieval/weights", "", testToken))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusNotImplemented)
+	}
+}
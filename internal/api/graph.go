@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// handleListEntities lists knowledge-graph entities, most mentioned first.
+// q matches any part of a name or alias; kind restricts the entity kind.
+func handleListEntities(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseIntParam(r, "limit", 20, 100)
+		offset := parseIntParam(r, "offset", 0, 0)
+		kind := r.URL.Query().Get("kind")
+		if kind != "" && !slices.Contains(storage.EntityKinds, kind) {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "kind must be one of %s", strings.Join(storage.EntityKinds, ", "))
+			return
+		}
+
+		entities, err := deps.Store.ListEntities(storage.GraphScope{}, r.URL.Query().Get("q"), kind, limit, offset)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list entities: %v", err)
+			return
+		}
+		if entities == nil {
+			entities = []storage.Entity{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entities)
+	}
+}
+
+// handleGetEntity returns an entity with its aliases, relations and the docs
+// that mention it.
+func handleGetEntity(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		entity, err := deps.Store.GetEntity(storage.GraphScope{}, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			httpError(w, http.StatusNotFound, "not_found", "entity not found")
+			return
+		}
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get entity: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entity)
+	}
+}
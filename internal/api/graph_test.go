@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func TestGraph_ListAndGetEntities(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+
+	for _, id := range []string{"d1", "d2"} {
+		if err := store.SaveContextDoc(storage.ContextDoc{ID: id, Title: id, Content: "c", Source: "test", Tags: "[]"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.ReplaceDocGraph("d1", storage.DocGraph{
+		Entities:  []storage.DocEntity{{Name: "Alice", Kind: "person"}, {Name: "tbyd", Kind: "project"}},
+		Relations: []storage.DocRelation{{Source: "Alice", Relation: "works_on", Target: "tbyd"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReplaceDocGraph("d2", storage.DocGraph{
+		Entities: []storage.DocEntity{{Name: "tbyd", Kind: "project"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/graph/entities", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var entities []storage.Entity
+	if err := json.Unmarshal(rr.Body.Bytes(), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 || entities[0].Name != "tbyd" || entities[0].Mentions != 2 {
+		t.Fatalf("entities = %+v, want tbyd first with 2 mentions", entities)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/graph/entities?kind=person&q=ali", "", testToken))
+	entities = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 || entities[0].Name != "Alice" {
+		t.Errorf("filtered entities = %+v, want Alice", entities)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/graph/entities?kind=animal", "", testToken))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid kind status = %d, want 400", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/graph/entities/"+entities[0].ID, "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var detail storage.EntityDetail
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Relations) != 1 || detail.Relations[0].Direction != "out" || detail.Relations[0].OtherName != "tbyd" {
+		t.Errorf("relations = %+v, want works_on tbyd", detail.Relations)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/graph/entities/missing", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing entity status = %d, want 404", rr.Code)
+	}
+}
@@ -69,9 +69,13 @@ type AppDeps struct {
 	Profile           *profile.Manager
 	Token             string
 	HTTPClient        *http.Client
-	Vectors           VectorDeleter // optional; if nil, vector cleanup is skipped on delete
-	Retriever         Retriever     // optional; if nil, /recall returns 501
-	DeepEnrichEnabled bool          // when true, also enqueue an ingest_deep_enrich job on ingest
+	Vectors           VectorDeleter     // optional; if nil, vector cleanup is skipped on delete
+	Retriever         Retriever         // optional; if nil, /recall returns 501
+	DeepEnrichEnabled bool              // when true, also enqueue an ingest_deep_enrich job on ingest
+	Tuner             HybridTuner       // optional; if nil, feedback does not adapt hybrid ratios and /retrieval/weights returns 501
+	Cache             QueryCache        // optional; if nil, deletes do not invalidate cached enrichments and /cache returns 501
+	Personas          *profile.Registry // optional; if nil, only the default persona's profile is served
+	Jobs              JobRunner         // optional; if nil, POST /jobs/run/{type} returns 501
 }
 
 // Retriever abstracts semantic search for the management API layer.
@@ -87,16 +91,46 @@ func NewAppHandler(deps AppDeps) http.Handler {
 	r.Get("/profile", handleGetProfile(deps))
 	r.Patch("/profile", handlePatchProfile(deps))
 	r.Delete("/profile/{field}", handleDeleteProfileField(deps))
+	r.Get("/profile/history", handleProfileHistory(deps))
+	r.Get("/profile/diff", handleProfileDiff(deps))
+	r.Post("/profile/rollback/{version}", handleProfileRollback(deps))
+	r.Get("/profile/export", handleProfileExport(deps))
+	r.Get("/profile/schema", handleProfileSchema(deps))
+	r.Post("/profile/import", handleProfileImport(deps))
 	r.Get("/interactions", handleListInteractions(deps))
 	r.Get("/interactions/{id}", handleGetInteraction(deps))
 	r.Delete("/interactions/{id}", handleDeleteInteraction(deps))
 	r.Post("/interactions/{id}/feedback", handleFeedback(deps))
 	r.Get("/context-docs", handleListContextDocs(deps))
+	r.Get("/context-docs/{id}", handleGetContextDoc(deps))
+	r.Get("/context-docs/{id}/sources", handleGetContextDocSources(deps))
 	r.Delete("/context-docs/{id}", handleDeleteContextDoc(deps))
 	r.Get("/recall", handleRecall(deps))
 	r.Get("/profile/pending-deltas", handleGetPendingDeltas(deps))
 	r.Post("/profile/pending-deltas/{id}/accept", handleAcceptDelta(deps))
 	r.Post("/profile/pending-deltas/{id}/reject", handleRejectDelta(deps))
+	r.Get("/profile/delta-decisions", handleListDeltaDecisions(deps))
+	r.Post("/profile/delta-decisions/{id}/undo", handleUndoDeltaDecision(deps))
+	r.Get("/facts/pending", handleListPendingFacts(deps))
+	r.Post("/facts/pending/{id}/accept", handleAcceptFact(deps))
+	r.Post("/facts/pending/{id}/reject", handleRejectFact(deps))
+	r.Get("/retrieval/weights", handleListHybridWeights(deps))
+	r.Delete("/retrieval/weights", handleResetHybridWeights(deps))
+	r.Delete("/retrieval/weights/{intent_type}", handleResetHybridWeights(deps))
+	r.Get("/cache/stats", handleCacheStats(deps))
+	r.Post("/cache/invalidate", handleInvalidateCache(deps))
+	r.Get("/personas", handleListPersonas(deps))
+	r.Post("/personas", handleCreatePersona(deps))
+	r.Delete("/personas/{name}", handleDeletePersona(deps))
+	r.Get("/projects", handleListProjects(deps))
+	r.Post("/projects", handleCreateProject(deps))
+	r.Delete("/projects/{name}", handleDeleteProject(deps))
+	r.Get("/jobs", handleListJobs(deps))
+	r.Post("/jobs/{id}/retry", handleRetryJob(deps))
+	r.Delete("/jobs/{id}", handleDeleteJob(deps))
+	r.Post("/jobs/run/{type}", handleRunJob(deps))
+	r.Get("/graph/entities", handleListEntities(deps))
+	r.Get("/graph/entities/{id}", handleGetEntity(deps))
 
 	return r
 }
@@ -124,6 +158,16 @@ func handleIngest(deps AppDeps) http.HandlerFunc {
 			req.Type = "text"
 		}
 
+		// Documents ingested as a partitioned persona are only retrieved for it.
+		_, persona, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		project, ok := projectFor(deps, w, r)
+		if !ok {
+			return
+		}
+
 		var resolvedContent string
 		switch {
 		case req.Type == "url" && req.URL != "":
@@ -208,6 +252,8 @@ func handleIngest(deps AppDeps) http.HandlerFunc {
 			Tags:      tagsJSON,
 			CreatedAt: time.Now().UTC(),
 			Metadata:  metadataJSON,
+			Partition: persona.Partition(),
+			Project:   project,
 		}
 		if err := deps.Store.SaveContextDoc(doc); err != nil {
 			httpError(w, http.StatusInternalServerError, "api_error", "failed to save document: %v", err)
@@ -253,7 +299,11 @@ func handleIngest(deps AppDeps) http.HandlerFunc {
 
 func handleGetProfile(deps AppDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		p, err := deps.Profile.GetProfile()
+		mgr, _, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		p, err := mgr.GetProfile()
 		if err != nil {
 			httpError(w, http.StatusInternalServerError, "api_error", "failed to get profile: %v", err)
 			return
@@ -287,8 +337,12 @@ func handlePatchProfile(deps AppDeps) http.HandlerFunc {
 			}
 		}
 
+		mgr, _, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
 		for key, value := range fields {
-			if err := deps.Profile.SetField(key, value); err != nil {
+			if err := mgr.SetField(profileSource(r), key, value); err != nil {
 				httpError(w, http.StatusInternalServerError, "api_error", "failed to set field %q: %v", key, err)
 				return
 			}
@@ -315,7 +369,11 @@ func handleDeleteProfileField(deps AppDeps) http.HandlerFunc {
 			return
 		}
 
-		if err := deps.Profile.DeleteField(field); err != nil {
+		mgr, _, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		if err := mgr.DeleteField(profileSource(r), field); err != nil {
 			if errors.Is(err, profile.ErrFieldNotFound) {
 				httpError(w, http.StatusNotFound, "not_found", "profile field %q not found", field)
 				return
@@ -382,6 +440,18 @@ func handleDeleteInteraction(deps AppDeps) http.HandlerFunc {
 			return
 		}
 
+		// Consolidated memories summarise the interaction too, so they go
+		// with it. Deleting one releases its other sources, which a later
+		// consolidation pass folds into a new memory without this one.
+		evicted, err := deleteConsolidatedMemories(deps, id)
+		if err != nil {
+			if deps.Cache != nil && len(evicted) > 0 {
+				deps.Cache.InvalidateBySources(evicted)
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete consolidated memories: %v", err)
+			return
+		}
+
 		if deps.Vectors != nil {
 			deleteVectorIDs(deps.Vectors, interaction.VectorIDs)
 		}
@@ -390,26 +460,69 @@ func handleDeleteInteraction(deps AppDeps) http.HandlerFunc {
 			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete interaction: %v", err)
 			return
 		}
+		if deps.Cache != nil {
+			evicted = append(evicted, parseVectorIDs(interaction.VectorIDs)...)
+			deps.Cache.InvalidateBySources(append(evicted, id))
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
 	}
 }
 
+// deleteConsolidatedMemories deletes the consolidated memory docs that
+// interactionID was folded into, with their vectors. It returns the doc and
+// vector IDs removed, for cache invalidation.
+func deleteConsolidatedMemories(deps AppDeps, interactionID string) ([]string, error) {
+	docIDs, err := deps.Store.ListConsolidatedMemoryIDs(interactionID)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, docID := range docIDs {
+		doc, err := deps.Store.GetContextDoc(docID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if deps.Vectors != nil && doc.VectorID != "" {
+			if err := deps.Vectors.Delete("context_vectors", doc.VectorID); err != nil {
+				slog.Warn("failed to delete vector for consolidated memory", "doc_id", docID, "vector_id", doc.VectorID, "error", err)
+			}
+		}
+		if err := deps.Store.DeleteContextDoc(docID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return removed, err
+		}
+		removed = append(removed, docID)
+		if doc.VectorID != "" {
+			removed = append(removed, doc.VectorID)
+		}
+	}
+	return removed, nil
+}
+
 // deleteVectorIDs parses a JSON array of vector IDs and deletes each from the vector store.
 func deleteVectorIDs(vectors VectorDeleter, vectorIDsJSON string) {
-	var ids []string
-	if err := json.Unmarshal([]byte(vectorIDsJSON), &ids); err != nil {
-		slog.Warn("failed to parse vector_ids JSON", "raw", vectorIDsJSON, "error", err)
-		return
-	}
-	for _, vid := range ids {
+	for _, vid := range parseVectorIDs(vectorIDsJSON) {
 		if err := vectors.Delete("context_vectors", vid); err != nil {
 			slog.Warn("failed to delete vector", "vector_id", vid, "error", err)
 		}
 	}
 }
 
+// parseVectorIDs parses an interaction's vector_ids JSON array, logging and
+// returning nil if it is malformed.
+func parseVectorIDs(vectorIDsJSON string) []string {
+	var ids []string
+	if err := json.Unmarshal([]byte(vectorIDsJSON), &ids); err != nil {
+		slog.Warn("failed to parse vector_ids JSON", "raw", vectorIDsJSON, "error", err)
+		return nil
+	}
+	return ids
+}
+
 func handleListContextDocs(deps AppDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		limit := parseIntParam(r, "limit", 20, 100)
@@ -430,6 +543,50 @@ func handleListContextDocs(deps AppDeps) http.HandlerFunc {
 	}
 }
 
+func handleGetContextDoc(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		doc, err := deps.Store.GetContextDoc(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			httpError(w, http.StatusNotFound, "not_found", "context doc not found")
+			return
+		}
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get context doc: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// handleGetContextDocSources returns the interaction summaries consolidated
+// into a memory doc; other docs have none.
+func handleGetContextDocSources(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		if _, err := deps.Store.GetContextDoc(id); errors.Is(err, storage.ErrNotFound) {
+			httpError(w, http.StatusNotFound, "not_found", "context doc not found")
+			return
+		} else if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get context doc: %v", err)
+			return
+		}
+
+		sources, err := deps.Store.GetConsolidationSources(id)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get sources: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sources)
+	}
+}
+
 func handleDeleteContextDoc(deps AppDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
@@ -454,6 +611,13 @@ func handleDeleteContextDoc(deps AppDeps) http.HandlerFunc {
 			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete context doc: %v", err)
 			return
 		}
+		if deps.Cache != nil {
+			ids := []string{id}
+			if doc.VectorID != "" {
+				ids = append(ids, doc.VectorID)
+			}
+			deps.Cache.InvalidateBySources(ids)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
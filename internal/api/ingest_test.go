@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,7 +13,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kalambet/tbyd/internal/cache"
 	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/retrieval"
 	"github.com/kalambet/tbyd/internal/storage"
 )
 
@@ -369,6 +372,250 @@ func TestDeleteContextDoc_NotFound(t *testing.T) {
 	}
 }
 
+func TestGetContextDoc(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	doc := storage.ContextDoc{ID: "doc-get-1", Title: "Notes", Content: "content", Source: "test", Tags: "[]", CreatedAt: time.Now().UTC()}
+	if err := store.SaveContextDoc(doc); err != nil {
+		t.Fatalf("SaveContextDoc: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/context-docs/doc-get-1", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	var got storage.ContextDoc
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID != doc.ID || got.Title != "Notes" {
+		t.Errorf("doc = %+v", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/context-docs/missing", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing doc status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetContextDocSources(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	ctx := context.Background()
+
+	created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if err := store.SaveInteraction(ctx, storage.Interaction{
+		ID: "ix-src-1", CreatedAt: created, UserQuery: "how do I wrap errors?", Status: "completed", VectorIDs: "[]",
+	}); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if _, err := store.DB().Exec(`INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at)
+		VALUES ('v-src-1', 'ix-src-1', 'interaction', 'asked about error wrapping', x'', ?)`, created.Format(time.RFC3339)); err != nil {
+		t.Fatalf("insert vector: %v", err)
+	}
+	mems, err := store.ListEpisodicMemories(time.Now(), 10)
+	if err != nil {
+		t.Fatalf("ListEpisodicMemories: %v", err)
+	}
+	doc := storage.ContextDoc{ID: "mem-1", Title: "Go errors", Content: "memory", Source: "memory_consolidation", Tags: "[]", CreatedAt: time.Now().UTC()}
+	if err := store.SaveConsolidatedMemory(doc, mems, 0.25); err != nil {
+		t.Fatalf("SaveConsolidatedMemory: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/context-docs/mem-1/sources", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var sources []storage.ConsolidationSource
+	if err := json.NewDecoder(rr.Body).Decode(&sources); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(sources) != 1 || sources[0].InteractionID != "ix-src-1" || sources[0].Summary != "asked about error wrapping" {
+		t.Errorf("sources = %+v", sources)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/context-docs/missing/sources", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing doc status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+// TestDeleteInteraction_DeletesConsolidatedMemory verifies that deleting an
+// interaction deletes the consolidated memory that summarises it, with its
+// vector, and releases the memory's other sources at their prior weight.
+func TestDeleteInteraction_DeletesConsolidatedMemory(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	for _, id := range []string{"ix-1", "ix-2"} {
+		if err := store.SaveInteraction(ctx, storage.Interaction{
+			ID: id, CreatedAt: created, UserQuery: "how do I wrap errors?", Status: "completed", VectorIDs: `["v-` + id + `"]`,
+		}); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+		if _, err := store.DB().Exec(`INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at)
+			VALUES (?, ?, 'interaction', 'asked about error wrapping', x'', ?)`, "v-"+id, id, created.Format(time.RFC3339)); err != nil {
+			t.Fatalf("insert vector: %v", err)
+		}
+	}
+	mems, err := store.ListEpisodicMemories(time.Now(), 10)
+	if err != nil {
+		t.Fatalf("ListEpisodicMemories: %v", err)
+	}
+	doc := storage.ContextDoc{ID: "mem-1", Title: "Go errors", Content: "memory", Source: "memory_consolidation", Tags: "[]", CreatedAt: time.Now().UTC()}
+	if err := store.SaveConsolidatedMemory(doc, mems, 0.25); err != nil {
+		t.Fatalf("SaveConsolidatedMemory: %v", err)
+	}
+	if _, err := store.DB().Exec(`INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at)
+		VALUES ('v-mem-1', 'mem-1', 'context_doc', 'memory', x'', ?)`, created.Format(time.RFC3339)); err != nil {
+		t.Fatalf("insert memory vector: %v", err)
+	}
+	if err := store.UpdateContextDocVectorID("mem-1", "v-mem-1"); err != nil {
+		t.Fatalf("UpdateContextDocVectorID: %v", err)
+	}
+
+	qc := cache.NewQueryCache(constEmbedder{}, true, 0.99, time.Hour, time.Hour)
+	t.Cleanup(qc.Stop)
+	qc.Set(ctx, "how do I wrap errors", []float32{1, 0, 0, 0}, cache.CachedEnrichment{
+		Metadata: "memory", ChunkIDs: []string{"v-mem-1"}, SourceIDs: []string{"mem-1"},
+	})
+
+	h := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    profile.NewManager(store),
+		Token:      testToken,
+		HTTPClient: http.DefaultClient,
+		Vectors:    retrieval.NewSQLiteStore(store.DB()),
+		Cache:      qc,
+	})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodDelete, "/interactions/ix-1", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+
+	if _, err := store.GetContextDoc("mem-1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetContextDoc(mem-1) error = %v, want ErrNotFound", err)
+	}
+	var vectors int
+	store.DB().QueryRow(`SELECT COUNT(*) FROM context_vectors WHERE id = 'v-mem-1'`).Scan(&vectors)
+	if vectors != 0 {
+		t.Error("consolidated memory vector was not deleted")
+	}
+	var quality float64
+	if err := store.DB().QueryRow(`SELECT quality_score FROM context_vectors WHERE id = 'v-ix-2'`).Scan(&quality); err != nil || quality != 1 {
+		t.Errorf("remaining source quality = %v, %v; want its prior weight 1", quality, err)
+	}
+	if r := qc.Get(ctx, "how do I wrap errors"); r.Hit {
+		t.Error("cache served an enrichment built from the deleted memory")
+	}
+}
+
+type constEmbedder struct{}
+
+func (constEmbedder) Embed(context.Context, string) ([]float32, error) {
+	return []float32{0, 0, 0, 1}, nil
+}
+
+// TestDelete_NeverServesDeletedContentFromCache verifies that deleting a
+// context doc or an interaction evicts the cached enrichments built from it,
+// both in memory and in the persisted snapshot, while unrelated entries stay.
+func TestDelete_NeverServesDeletedContentFromCache(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		seed    func(*testing.T, *storage.Store)
+		path    string
+		derived cache.CachedEnrichment // built from the content being deleted
+	}{
+		{
+			name: "context doc",
+			seed: func(t *testing.T, store *storage.Store) {
+				doc := storage.ContextDoc{ID: "doc-1", Title: "t", Content: "secret", Source: "cli", Tags: "[]", CreatedAt: time.Now().UTC()}
+				if err := store.SaveContextDoc(doc); err != nil {
+					t.Fatalf("SaveContextDoc: %v", err)
+				}
+				if err := store.UpdateContextDocVectorID("doc-1", "vec-doc"); err != nil {
+					t.Fatalf("UpdateContextDocVectorID: %v", err)
+				}
+			},
+			path:    "/context-docs/doc-1",
+			derived: cache.CachedEnrichment{Metadata: "secret", ChunkIDs: []string{"vec-doc"}, SourceIDs: []string{"doc-1"}},
+		},
+		{
+			name: "interaction",
+			seed: func(t *testing.T, store *storage.Store) {
+				ix := storage.Interaction{ID: "ix-1", CreatedAt: time.Now().UTC(), UserQuery: "q", Status: "completed", VectorIDs: `["vec-ix"]`}
+				if err := store.SaveInteraction(context.Background(), ix); err != nil {
+					t.Fatalf("SaveInteraction: %v", err)
+				}
+			},
+			path:    "/interactions/ix-1",
+			derived: cache.CachedEnrichment{Metadata: "secret", ChunkIDs: []string{"vec-ix"}, SourceIDs: []string{"ix-1"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.Open(":memory:")
+			if err != nil {
+				t.Fatalf("Open(:memory:) failed: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			tc.seed(t, store)
+
+			persister := cache.NewSQLitePersister(store.DB())
+			newCache := func() *cache.QueryCache {
+				// Orthogonal embeddings per query keep L2 from cross-matching.
+				qc := cache.NewQueryCache(constEmbedder{}, true, 0.99, time.Hour, time.Hour)
+				qc.SetPersister(persister, "m", 0)
+				return qc
+			}
+			qc := newCache()
+			t.Cleanup(qc.Stop)
+			qc.Set(ctx, "what is the secret", []float32{1, 0, 0, 0}, tc.derived)
+			qc.Set(ctx, "unrelated question", []float32{0, 1, 0, 0}, cache.CachedEnrichment{
+				Metadata: "other", ChunkIDs: []string{"vec-other"}, SourceIDs: []string{"doc-other"},
+			})
+			if err := qc.Save(ctx); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			h := NewAppHandler(AppDeps{
+				Store:      store,
+				Profile:    profile.NewManager(store),
+				Token:      testToken,
+				HTTPClient: http.DefaultClient,
+				Cache:      qc,
+			})
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, authReq(http.MethodDelete, tc.path, "", testToken))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("delete status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+			}
+
+			// Without Stop: a crash right after the delete must not resurrect it.
+			restarted := newCache()
+			if _, err := restarted.Load(ctx); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			for name, c := range map[string]*cache.QueryCache{"live": qc, "restarted": restarted} {
+				if r := c.Get(ctx, "what is the secret"); r.Hit {
+					t.Errorf("%s cache served an enrichment built from deleted content", name)
+				}
+				if r := c.Get(ctx, "unrelated question"); !r.Hit {
+					t.Errorf("%s cache lost an unrelated entry", name)
+				}
+			}
+		})
+	}
+}
+
 // TestExtractTextFromPDF validates PDF text extraction at the function level.
 func TestExtractTextFromPDF(t *testing.T) {
 	data, err := base64.StdEncoding.DecodeString(testPDFB64)
@@ -71,7 +71,7 @@ func TestSaveInteraction_OptInEnabled(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi there"}]}`
 	rr := httptest.NewRecorder()
@@ -138,7 +138,7 @@ func TestSaveInteraction_OptInDisabled(t *testing.T) {
 	})
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, saver, false, false, nil) // disabled
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, saver, false, false, nil, OpenAIOptions{}) // disabled
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -171,7 +171,7 @@ func TestSaveInteraction_NilSaver(t *testing.T) {
 		fmt.Fprint(w, respJSON)
 	})
 
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, true, false, nil) // enabled but no saver
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, true, false, nil, OpenAIOptions{}) // enabled but no saver
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -199,7 +199,7 @@ func TestSaveInteraction_Streaming(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"stream me"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -262,7 +262,7 @@ func TestSaveInteraction_ErrorDoesNotBlockResponse(t *testing.T) {
 			return fmt.Errorf("database error")
 		},
 	}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -341,7 +341,7 @@ func TestSaveInteraction_QueuedImmediately(t *testing.T) {
 			return nil
 		},
 	}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -394,7 +394,7 @@ func TestSaveInteraction_ChannelFull(t *testing.T) {
 		},
 	}
 
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 	reqBody := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 
@@ -499,7 +499,7 @@ func TestOnboardingPrompt_ShownOnce(t *testing.T) {
 		onboardingShown:     false,
 	}
 	freshNotifier := NewOnboardingNotifier(freshCfg)
-	_, _ = NewOpenAIHandler(context.Background(), c, nil, nil, false, false, freshNotifier)
+	_, _ = NewOpenAIHandler(context.Background(), c, nil, nil, false, false, freshNotifier, OpenAIOptions{})
 
 	if freshCfg.markShownCalled != 1 {
 		t.Errorf("after NewOpenAIHandler: MarkOnboardingShown called %d times, want 1", freshCfg.markShownCalled)
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kalambet/tbyd/internal/scheduler"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// JobRunner starts scheduled background work on demand.
+// *scheduler.Scheduler satisfies it.
+type JobRunner interface {
+	Trigger(name string) error
+	Names() []string
+}
+
+var jobStatuses = map[string]bool{
+	"pending":   true,
+	"running":   true,
+	"completed": true,
+	"failed":    true,
+}
+
+func handleListJobs(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseIntParam(r, "limit", 20, 100)
+		offset := parseIntParam(r, "offset", 0, 0)
+		jobType := r.URL.Query().Get("type")
+		status := r.URL.Query().Get("status")
+		if status != "" && !jobStatuses[status] {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "status must be one of pending, running, completed, failed")
+			return
+		}
+
+		jobs, err := deps.Store.ListJobs(jobType, status, limit, offset)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list jobs: %v", err)
+			return
+		}
+		if jobs == nil {
+			jobs = []storage.Job{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+	}
+}
+
+func handleRetryJob(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		if err := deps.Store.RetryJob(id); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "job not found")
+				return
+			}
+			if errors.Is(err, storage.ErrJobRunning) {
+				httpError(w, http.StatusConflict, "conflict", "job is running")
+				return
+			}
+			if errors.Is(err, storage.ErrJobNotFailed) {
+				httpError(w, http.StatusConflict, "conflict", "%v", err)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to retry job: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "pending"})
+	}
+}
+
+func handleDeleteJob(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		if err := deps.Store.DeleteJob(id); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "job not found")
+				return
+			}
+			if errors.Is(err, storage.ErrJobRunning) {
+				httpError(w, http.StatusConflict, "conflict", "job is running")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete job: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+	}
+}
+
+// handleRunJob starts a scheduled job, such as nightly_synthesis or
+// deep_enrich, immediately. The run continues after the response.
+func handleRunJob(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Jobs == nil {
+			httpError(w, http.StatusNotImplemented, "api_error", "the scheduler is not running")
+			return
+		}
+		jobType := chi.URLParam(r, "type")
+
+		if err := deps.Jobs.Trigger(jobType); err != nil {
+			switch {
+			case errors.Is(err, scheduler.ErrUnknown):
+				httpError(w, http.StatusNotFound, "not_found", "unknown job type %q; runnable: %s",
+					jobType, strings.Join(deps.Jobs.Names(), ", "))
+			case errors.Is(err, scheduler.ErrBusy):
+				httpError(w, http.StatusConflict, "conflict", "%s is already running", jobType)
+			case errors.Is(err, scheduler.ErrNotRunning):
+				httpError(w, http.StatusServiceUnavailable, "api_error", "the scheduler is not running")
+			default:
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to start %s: %v", jobType, err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "started", "type": jobType})
+	}
+}
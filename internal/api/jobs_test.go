@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/scheduler"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type fakeJobRunner struct {
+	busy      bool
+	triggered []string
+}
+
+func (f *fakeJobRunner) Trigger(name string) error {
+	if name != "nightly_synthesis" {
+		return fmt.Errorf("%w: %s", scheduler.ErrUnknown, name)
+	}
+	if f.busy {
+		return scheduler.ErrBusy
+	}
+	f.triggered = append(f.triggered, name)
+	return nil
+}
+
+func (f *fakeJobRunner) Names() []string { return []string{"nightly_synthesis"} }
+
+func TestJobs_ListRetryDelete(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	ctx := context.Background()
+
+	for _, j := range []storage.Job{
+		{ID: "j-fail", Type: "nightly_synthesis", PayloadJSON: "{}", MaxAttempts: 1},
+		{ID: "j-other", Type: "summarize", PayloadJSON: "{}"},
+	} {
+		if err := store.EnqueueJob(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claimed, err := store.ClaimNextJob([]string{"nightly_synthesis"})
+	if err != nil || claimed == nil {
+		t.Fatalf("ClaimNextJob = %v, %v", claimed, err)
+	}
+	if err := store.FailJob("j-fail", "model unavailable"); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/jobs?status=failed", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var jobs []storage.Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "j-fail" || jobs[0].LastError != "model unavailable" || jobs[0].Attempts != 1 {
+		t.Fatalf("failed jobs = %+v", jobs)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/jobs?status=stuck", "", testToken))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid status filter = %d, want 400", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/j-fail/retry", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("retry status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	jobs, err = store.ListJobs("nightly_synthesis", "pending", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Attempts != 0 || jobs[0].LastError != "" {
+		t.Fatalf("after retry = %+v, want pending with no attempts or error", jobs)
+	}
+
+	// Only failed jobs can be retried.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/j-fail/retry", "", testToken))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("retry of pending job = %d, want 409", rr.Code)
+	}
+
+	// A running job can be neither retried nor deleted.
+	if _, err := store.ClaimNextJob([]string{"summarize"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*http.Request{
+		authReq(http.MethodPost, "/jobs/j-other/retry", "", testToken),
+		authReq(http.MethodDelete, "/jobs/j-other", "", testToken),
+	} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("%s %s on running job = %d, want 409", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodDelete, "/jobs/j-fail", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodDelete, "/jobs/j-fail", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", rr.Code)
+	}
+}
+
+func TestJobs_Run(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	runner := &fakeJobRunner{}
+	h := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    profile.NewManager(store),
+		Token:      testToken,
+		HTTPClient: http.DefaultClient,
+		Jobs:       runner,
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/run/nightly_synthesis", "", testToken))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("run status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	if len(runner.triggered) != 1 {
+		t.Errorf("triggered = %v, want nightly_synthesis", runner.triggered)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/run/bogus", "", testToken))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown type = %d, want 404", rr.Code)
+	}
+
+	runner.busy = true
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/run/nightly_synthesis", "", testToken))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("busy run = %d, want 409", rr.Code)
+	}
+}
+
+func TestJobs_RunWithoutScheduler(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/jobs/run/nightly_synthesis", "", testToken))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rr.Code)
+	}
+}
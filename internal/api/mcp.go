@@ -17,6 +17,7 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
+	"github.com/kalambet/tbyd/internal/pipeline"
 	"github.com/kalambet/tbyd/internal/profile"
 	"github.com/kalambet/tbyd/internal/retrieval"
 	"github.com/kalambet/tbyd/internal/storage"
@@ -25,6 +26,7 @@ import (
 // MCPRetriever abstracts semantic search for the MCP layer.
 type MCPRetriever interface {
 	Retrieve(ctx context.Context, query string, topK int) ([]retrieval.ContextChunk, error)
+	RetrieveIn(ctx context.Context, query string, topK int, scope retrieval.Scope) ([]retrieval.ContextChunk, error)
 }
 
 // MCPEngine abstracts local LLM calls for summarization.
@@ -66,9 +68,18 @@ type MCPDeps struct {
 	Store             *storage.Store
 	Profile           *profile.Manager
 	Retriever         MCPRetriever
-	Engine            MCPEngine // optional; if nil, summarize_session returns an error
-	DeepModel         string    // model name for summarization
-	DeepEnrichEnabled bool      // when true, also enqueue an ingest_deep_enrich job on ingest
+	Engine            MCPEngine   // optional; if nil, summarize_session returns an error
+	DeepModel         string      // model name for summarization
+	DeepEnrichEnabled bool        // when true, also enqueue an ingest_deep_enrich job on ingest
+	Tuner             HybridTuner // optional; if nil, feedback does not adapt hybrid ratios
+	// Personas is optional; when set, each MCP client acts as the persona its
+	// client name selects, falling back to the default persona.
+	Personas *profile.Registry
+	// ProjectOnly makes recall for an active project drop other projects'
+	// documents instead of down-ranking them.
+	ProjectOnly bool
+
+	roots *mcpRoots // per-session client roots; set by NewMCPServer
 }
 
 // NewMCPServer creates an MCP server with all tbyd tools and resources registered.
@@ -82,6 +93,11 @@ func NewMCPServer(deps MCPDeps) *server.MCPServer {
 		server.WithRecovery(),
 	)
 
+	deps.roots = newMCPRoots()
+	s.AddNotificationHandler(mcp.MethodNotificationRootsListChanged, func(ctx context.Context, _ mcp.JSONRPCNotification) {
+		deps.roots.forget(ctx)
+	})
+
 	// Tools
 	s.AddTool(
 		mcp.NewTool("add_context",
@@ -89,6 +105,7 @@ func NewMCPServer(deps MCPDeps) *server.MCPServer {
 			mcp.WithString("title", mcp.Description("Title for the context entry")),
 			mcp.WithString("content", mcp.Description("The text content to store"), mcp.Required()),
 			mcp.WithArray("tags", mcp.Description("Optional tags for categorization")),
+			mcp.WithString("project", mcp.Description("Project name or working directory; defaults to the project containing the client's roots")),
 		),
 		mcpAddContext(deps),
 	)
@@ -98,6 +115,7 @@ func NewMCPServer(deps MCPDeps) *server.MCPServer {
 			mcp.WithDescription("Semantically search the local knowledge base and return relevant context chunks."),
 			mcp.WithString("query", mcp.Description("Search query"), mcp.Required()),
 			mcp.WithNumber("limit", mcp.Description("Maximum number of results (default 5)")),
+			mcp.WithString("project", mcp.Description("Project name or working directory to prefer; defaults to the project containing the client's roots")),
 		),
 		mcpRecall(deps),
 	)
@@ -129,6 +147,14 @@ func NewMCPServer(deps MCPDeps) *server.MCPServer {
 		mcpSummarizeSession(deps),
 	)
 
+	s.AddTool(
+		mcp.NewTool("explore_entity",
+			mcp.WithDescription("Look up a person, project, technology or other entity in the knowledge graph built from stored context, with its aliases, related entities and the documents that mention it."),
+			mcp.WithString("name", mcp.Description("Entity name or alias"), mcp.Required()),
+		),
+		mcpExploreEntity(deps),
+	)
+
 	// Resources
 	s.AddResource(
 		mcp.NewResource(
@@ -217,6 +243,12 @@ func mcpAddContext(deps MCPDeps) server.ToolHandlerFunc {
 			tagsJSON = string(b)
 		}
 
+		project, err := mcpProject(ctx, deps, req)
+		if err != nil {
+			return mcpError(fmt.Sprintf("failed to resolve project: %v", err)), nil
+		}
+
+		_, persona := mcpPersona(ctx, deps)
 		doc := storage.ContextDoc{
 			ID:        docID,
 			Title:     title,
@@ -224,6 +256,10 @@ func mcpAddContext(deps MCPDeps) server.ToolHandlerFunc {
 			Source:    "mcp",
 			Tags:      tagsJSON,
 			CreatedAt: time.Now().UTC(),
+			Project:   project,
+		}
+		if persona != nil {
+			doc.Partition = persona.Partition()
 		}
 		if err := deps.Store.SaveContextDoc(doc); err != nil {
 			return mcpError(fmt.Sprintf("failed to save: %v", err)), nil
@@ -259,7 +295,23 @@ func mcpRecall(deps MCPDeps) server.ToolHandlerFunc {
 			limit = 50
 		}
 
-		chunks, err := deps.Retriever.Retrieve(ctx, query, limit)
+		project, err := mcpProject(ctx, deps, req)
+		if err != nil {
+			return mcpError(fmt.Sprintf("failed to resolve project: %v", err)), nil
+		}
+
+		var scope retrieval.Scope
+		if _, persona := mcpPersona(ctx, deps); persona != nil {
+			scope = retrieval.PartitionScope(persona.Partition())
+		}
+		scope = scope.WithProject(project, deps.ProjectOnly)
+
+		var chunks []retrieval.ContextChunk
+		if scope != (retrieval.Scope{}) {
+			chunks, err = deps.Retriever.RetrieveIn(ctx, query, limit, scope)
+		} else {
+			chunks, err = deps.Retriever.Retrieve(ctx, query, limit)
+		}
 		if err != nil {
 			return mcpError(fmt.Sprintf("recall failed: %v", err)), nil
 		}
@@ -275,6 +327,7 @@ func mcpRecall(deps MCPDeps) server.ToolHandlerFunc {
 			Text       string  `json:"text"`
 			Score      float32 `json:"score"`
 			Tags       string  `json:"tags,omitempty"`
+			Project    string  `json:"project,omitempty"`
 		}
 
 		results := make([]chunkResult, len(chunks))
@@ -286,6 +339,7 @@ func mcpRecall(deps MCPDeps) server.ToolHandlerFunc {
 				Text:       c.Text,
 				Score:      c.Score,
 				Tags:       c.Tags,
+				Project:    c.Project,
 			}
 		}
 
@@ -309,7 +363,8 @@ func mcpSetPreference(deps MCPDeps) server.ToolHandlerFunc {
 			return mcpError("value is required"), nil
 		}
 
-		if err := deps.Profile.SetField(key, value); err != nil {
+		mgr, _ := mcpPersona(ctx, deps)
+		if err := mgr.SetField(profile.SourceMCP, key, value); err != nil {
 			return mcpError(fmt.Sprintf("failed to set preference: %v", err)), nil
 		}
 
@@ -367,6 +422,14 @@ func mcpSummarizeSession(deps MCPDeps) server.ToolHandlerFunc {
 			Tags:      `["session_summary"]`,
 			CreatedAt: now,
 		}
+		if _, persona := mcpPersona(ctx, deps); persona != nil {
+			doc.Partition = persona.Partition()
+		}
+		if project, err := mcpProject(ctx, deps, req); err == nil {
+			doc.Project = project
+		} else {
+			slog.Warn("mcp: failed to resolve project for session summary", "error", err)
+		}
 		if err := deps.Store.SaveContextDoc(doc); err != nil {
 			return mcpError(fmt.Sprintf("summary generated but failed to save: %v", err)), nil
 		}
@@ -410,7 +473,7 @@ func mcpRateResponse(deps MCPDeps) server.ToolHandlerFunc {
 
 		notes := req.GetString("notes", "")
 
-		if err := saveFeedback(ctx, deps.Store, interactionID, score, notes); err != nil {
+		if err := saveFeedback(ctx, deps.Store, deps.Tuner, interactionID, score, notes); err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				return mcpError(fmt.Sprintf("interaction %s not found", interactionID)), nil
 			}
@@ -424,9 +487,38 @@ func mcpRateResponse(deps MCPDeps) server.ToolHandlerFunc {
 	}
 }
 
+func mcpExploreEntity(deps MCPDeps) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := req.RequireString("name")
+		if err != nil || strings.TrimSpace(name) == "" {
+			return mcpError("name is required"), nil
+		}
+
+		// Like recall, only what the persona's partition says is visible.
+		var scope storage.GraphScope
+		if _, persona := mcpPersona(ctx, deps); persona != nil {
+			scope = storage.GraphScope{Partitioned: true, Partition: persona.Partition()}
+		}
+		entity, err := deps.Store.FindEntity(scope, name)
+		if errors.Is(err, storage.ErrNotFound) {
+			return mcpError(fmt.Sprintf("no entity named %q in the knowledge graph", name)), nil
+		}
+		if err != nil {
+			return mcpError(fmt.Sprintf("failed to look up entity: %v", err)), nil
+		}
+
+		b, err := json.Marshal(entity)
+		if err != nil {
+			return mcpError(fmt.Sprintf("failed to marshal entity: %v", err)), nil
+		}
+		return mcpText(string(b)), nil
+	}
+}
+
 func mcpResourceProfile(deps MCPDeps) server.ResourceHandlerFunc {
 	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		p, err := deps.Profile.GetProfile()
+		mgr, _ := mcpPersona(ctx, deps)
+		p, err := mgr.GetProfile()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get profile: %w", err)
 		}
@@ -488,6 +580,33 @@ func mcpResourceRecent(deps MCPDeps) server.ResourceHandlerFunc {
 	}
 }
 
+// mcpPersona returns the profile manager and persona for the calling MCP
+// client, selected by the client name it sent during initialization. Without
+// a persona registry every client uses the default profile and the returned
+// persona is nil, leaving recall unscoped.
+func mcpPersona(ctx context.Context, deps MCPDeps) (*profile.Manager, *pipeline.Persona) {
+	if deps.Personas == nil {
+		return deps.Profile, nil
+	}
+	var client string
+	if s, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo); ok {
+		client = s.GetClientInfo().Name
+	}
+	name, err := deps.Store.ResolvePersona(storage.SelectorClient, client)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.Warn("mcp: failed to resolve persona, using default", "client", client, "error", err)
+		}
+		return deps.Profile, &pipeline.Persona{Name: storage.DefaultPersona}
+	}
+	p, err := deps.Store.GetPersona(name)
+	if err != nil {
+		slog.Warn("mcp: failed to load persona, using default", "persona", name, "error", err)
+		return deps.Profile, &pipeline.Persona{Name: storage.DefaultPersona}
+	}
+	return deps.Personas.For(p.Name), &pipeline.Persona{Name: p.Name, Partitioned: p.Partitioned}
+}
+
 func enqueueIngestJob(ctx context.Context, store *storage.Store, jobType, docID string, maxAttempts int) error {
 	payload, err := json.Marshal(map[string]string{"context_doc_id": docID})
 	if err != nil {
@@ -28,12 +28,18 @@ import (
 type mockMCPRetriever struct {
 	chunks []retrieval.ContextChunk
 	err    error
+	scopes []retrieval.Scope // scope of each RetrieveIn call
 }
 
 func (m *mockMCPRetriever) Retrieve(_ context.Context, _ string, _ int) ([]retrieval.ContextChunk, error) {
 	return m.chunks, m.err
 }
 
+func (m *mockMCPRetriever) RetrieveIn(_ context.Context, _ string, _ int, scope retrieval.Scope) ([]retrieval.ContextChunk, error) {
+	m.scopes = append(m.scopes, scope)
+	return m.chunks, m.err
+}
+
 type mockMCPEngine struct {
 	response string
 	err      error
@@ -52,23 +58,6 @@ func newMockProfileStore() *mockProfileStore {
 	return &mockProfileStore{data: make(map[string]string)}
 }
 
-func (m *mockProfileStore) SetProfileKey(key, value string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.data[key] = value
-	return nil
-}
-
-func (m *mockProfileStore) GetProfileKey(key string) (string, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	v, ok := m.data[key]
-	if !ok {
-		return "", nil
-	}
-	return v, nil
-}
-
 func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -79,14 +68,23 @@ func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
 	return cp, nil
 }
 
-func (m *mockProfileStore) DeleteProfileKey(key string) error {
+func (m *mockProfileStore) CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.data[key]; !ok {
-		return errors.New("not found")
+	for _, k := range del {
+		if _, ok := m.data[k]; !ok {
+			return 0, errors.New("not found")
+		}
+		delete(m.data, k)
 	}
-	delete(m.data, key)
-	return nil
+	for k, v := range set {
+		m.data[k] = v
+	}
+	return 1, nil
+}
+
+func (m *mockProfileStore) GetProfileSnapshot(version int64) (map[string]string, error) {
+	return nil, errors.New("not found")
 }
 
 // --- helpers ---
@@ -279,7 +277,7 @@ func TestMCPResource_Profile(t *testing.T) {
 	deps, _ := newTestMCPDeps(t)
 
 	// Set a profile field first.
-	deps.Profile.SetField("identity.role", "engineer")
+	deps.Profile.SetField(profile.SourceMCP, "identity.role", "engineer")
 
 	handler := mcpResourceProfile(deps)
 	req := makeReadResourceRequest("user://profile")
@@ -640,6 +638,51 @@ func TestMCPTool_RateResponse_NotFound(t *testing.T) {
 	}
 }
 
+func TestMCPTool_ExploreEntity(t *testing.T) {
+	deps, store := newTestMCPDeps(t)
+
+	if err := store.SaveContextDoc(storage.ContextDoc{ID: "doc-kg", Title: "t", Content: "c", Source: "test", Tags: "[]"}); err != nil {
+		t.Fatalf("SaveContextDoc: %v", err)
+	}
+	err := store.ReplaceDocGraph("doc-kg", storage.DocGraph{
+		Entities:  []storage.DocEntity{{Name: "Alice", Kind: "person"}, {Name: "tbyd", Kind: "project"}},
+		Relations: []storage.DocRelation{{Source: "Alice", Relation: "works_on", Target: "tbyd"}},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocGraph: %v", err)
+	}
+
+	handler := mcpExploreEntity(deps)
+	result, err := handler(context.Background(), makeCallToolRequest("explore_entity", map[string]interface{}{"name": "alice"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected error result: %s", toolText(t, result))
+	}
+	var entity storage.EntityDetail
+	if err := json.Unmarshal([]byte(toolText(t, result)), &entity); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if entity.Name != "Alice" || entity.Kind != "person" {
+		t.Errorf("entity = %+v, want Alice (person)", entity.Entity)
+	}
+	if len(entity.Relations) != 1 || entity.Relations[0].OtherName != "tbyd" || entity.Relations[0].Relation != "works_on" {
+		t.Errorf("relations = %+v, want works_on tbyd", entity.Relations)
+	}
+	if len(entity.DocIDs) != 1 || entity.DocIDs[0] != "doc-kg" {
+		t.Errorf("doc IDs = %v, want [doc-kg]", entity.DocIDs)
+	}
+
+	result, err = handler(context.Background(), makeCallToolRequest("explore_entity", map[string]interface{}{"name": "Bob"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(toolText(t, result), "Bob") {
+		t.Errorf("unknown entity result = %+v, want an error naming it", result)
+	}
+}
+
 func TestPrintMCPSetupSnippet(t *testing.T) {
 	const token = "my-secret-token"
 	const port = 4001
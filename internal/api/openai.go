@@ -2,8 +2,10 @@ package api
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,6 +18,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
+	"github.com/kalambet/tbyd/internal/cache"
 	"github.com/kalambet/tbyd/internal/pipeline"
 	"github.com/kalambet/tbyd/internal/proxy"
 	"github.com/kalambet/tbyd/internal/storage"
@@ -25,6 +28,21 @@ const maxRequestBodySize = 1 << 20        // 1MB
 const maxCaptureBytesStreaming = 1 << 20  // 1MB cap for accumulated streaming content
 const maxResponseBodyBytes = 10 << 20    // 10MB cap for non-streaming response body
 
+// Response cache request and response headers.
+const (
+	headerClient        = "X-TBYD-Client"         // names the calling client for per-client opt-in
+	headerCacheTTL      = "X-TBYD-Cache-TTL"      // optional per-request TTL, e.g. "1h"
+	headerResponseCache = "X-TBYD-Response-Cache" // "hit" or "miss" when the client has opted in
+	headerCachedFrom    = "X-TBYD-Cached-From"    // interaction whose response was replayed
+)
+
+// Model routing response headers, set on every proxied chat completion when
+// a router is configured.
+const (
+	headerRouteModel  = "X-TBYD-Route-Model"  // upstream model the request was sent to
+	headerRouteReason = "X-TBYD-Route-Reason" // why: "requested", "intent=<type>", "preference" or "default"
+)
+
 // InteractionSaver persists interactions and enqueues summarization jobs.
 // All methods accept a context so that implementations can use context-aware
 // database calls (e.g. ExecContext), enabling graceful cancellation on shutdown.
@@ -40,8 +58,15 @@ type interactionRecord struct {
 	EnrichedPrompt string
 	Model          string
 	CloudResponse  string
-	Status         string   // "completed" or "aborted"
+	Status         string   // "completed", "aborted", or "cached"
 	ChunksUsed     []string // vector IDs used during enrichment; empty when enrichment is skipped
+	IntentType     string
+	Topics         []string            // intent topics extracted during enrichment
+	ChunkLegs      map[string][]string // vector ID -> retrieval legs that surfaced it
+	SearchStrategy string
+	CachedFrom     string // source interaction when the response was replayed from the response cache
+	Persona        string
+	Partition      string // knowledge-base partition the interaction's summary belongs to
 }
 
 // interactionSaveLoop drains the save channel until ctx is cancelled,
@@ -71,6 +96,29 @@ func interactionSaveLoop(ctx context.Context, saver InteractionSaver, ch <-chan
 	}
 }
 
+// OpenAIOptions holds the optional dependencies of NewOpenAIHandler.
+type OpenAIOptions struct {
+	// Responses, when set, answers requests from opted-in clients from cached
+	// upstream responses when the enriched request matches.
+	Responses *cache.ResponseCache
+
+	// Personas, when set, enriches each request as the persona selected by
+	// the X-TBYD-Persona header, bearer token, X-TBYD-Client name or
+	// requested model. When nil, every request uses the default persona.
+	Personas PersonaResolver
+
+	// Projects, when set, lets the X-TBYD-Project header (a project name or
+	// working directory) select the project whose documents are preferred,
+	// and rejects an unknown project name. When nil, requests that set the
+	// header are rejected.
+	Projects ProjectResolver
+
+	// Router, when set, sends requests for proxy.AutoModel to the model
+	// chosen from the extracted intent, the profile's cloud model preference
+	// or the default model, and adds proxy.AutoModel to /v1/models.
+	Router *proxy.Router
+}
+
 // NewOpenAIHandler returns an http.Handler implementing the OpenAI-compatible
 // REST API and a cleanup function the caller must invoke after the HTTP server
 // has stopped accepting requests. The cleanup function blocks until the
@@ -90,7 +138,9 @@ func interactionSaveLoop(ctx context.Context, saver InteractionSaver, ch <-chan
 // is called once during handler setup. The sync.Once inside the notifier
 // ensures the check-and-print logic runs at most once per process lifetime,
 // making it safe even if the handler were created multiple times.
-func NewOpenAIHandler(appCtx context.Context, p *proxy.Client, enricher *pipeline.Enricher, saver InteractionSaver, saveInteractions bool, enqueueSummarize bool, onboarding *OnboardingNotifier) (http.Handler, func()) {
+//
+// opts holds the optional dependencies; the zero value disables them all.
+func NewOpenAIHandler(appCtx context.Context, p *proxy.Client, enricher *pipeline.Enricher, saver InteractionSaver, saveInteractions bool, enqueueSummarize bool, onboarding *OnboardingNotifier, opts OpenAIOptions) (http.Handler, func()) {
 	r := chi.NewRouter()
 
 	// Call onboarding once at setup time, not on every request.
@@ -116,8 +166,8 @@ func NewOpenAIHandler(appCtx context.Context, p *proxy.Client, enricher *pipelin
 	}
 
 	r.Get("/health", handleHealth(&droppedInteractions))
-	r.Get("/v1/models", handleModels(p))
-	r.Post("/v1/chat/completions", handleChatCompletions(p, enricher, saveCh, &droppedInteractions))
+	r.Get("/v1/models", handleModels(p, opts.Router))
+	r.Post("/v1/chat/completions", handleChatCompletions(p, enricher, opts.Responses, opts.Personas, opts.Projects, opts.Router, saveCh, &droppedInteractions))
 
 	return r, cleanup
 }
@@ -132,13 +182,16 @@ func handleHealth(droppedInteractions *atomic.Int64) http.HandlerFunc {
 	}
 }
 
-func handleModels(p *proxy.Client) http.HandlerFunc {
+func handleModels(p *proxy.Client, router *proxy.Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		models, err := p.ListModels(r.Context())
 		if err != nil {
 			httpError(w, http.StatusBadGateway, "api_error", "failed to list models: %v", err)
 			return
 		}
+		if router != nil {
+			models = append([]proxy.Model{{ID: proxy.AutoModel, Object: "model", OwnedBy: "tbyd"}}, models...)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(proxy.ModelList{
@@ -148,7 +201,7 @@ func handleModels(p *proxy.Client) http.HandlerFunc {
 	}
 }
 
-func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh chan<- interactionRecord, droppedInteractions *atomic.Int64) http.HandlerFunc {
+func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, responses *cache.ResponseCache, personas PersonaResolver, projects ProjectResolver, router *proxy.Router, saveCh chan<- interactionRecord, droppedInteractions *atomic.Int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		defer r.Body.Close()
@@ -164,6 +217,41 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 			return
 		}
 
+		persona, err := resolvePersona(personas, r, req.Model)
+		if err != nil {
+			if errors.Is(err, errUnknownPersona) {
+				httpError(w, http.StatusNotFound, "not_found", "%v", err)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to resolve persona: %v", err)
+			return
+		}
+		if projects == nil && strings.TrimSpace(r.Header.Get(headerProject)) != "" {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "%s is not supported: projects are not configured", headerProject)
+			return
+		}
+		persona.Project, err = resolveProject(projects, r.Header.Get(headerProject))
+		if err != nil {
+			if errors.Is(err, errUnknownProject) {
+				httpError(w, http.StatusNotFound, "not_found", "%v", err)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to resolve project: %v", err)
+			return
+		}
+
+		client := r.Header.Get(headerClient)
+		useResponseCache := responses.Enabled(client)
+		var responseTTL time.Duration
+		if v := r.Header.Get(headerCacheTTL); v != "" && useResponseCache {
+			ttl, err := time.ParseDuration(v)
+			if err != nil || ttl <= 0 {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "%s must be a positive duration, got %q", headerCacheTTL, v)
+				return
+			}
+			responseTTL = ttl
+		}
+
 		// Generate interaction ID early so it can be surfaced in the response.
 		// Only generate when save is enabled — if interactions are not being saved,
 		// there is no ID to surface and no feedback to give.
@@ -172,15 +260,26 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 			interactionID = uuid.New().String()
 		}
 
-		// Capture original user query before enrichment.
+		// Capture original user query and model before enrichment.
 		userQuery := extractLastUserMessage(req.Messages)
+		requestedModel := req.Model
 
 		// Enrich if enricher is available.
 		var chunksUsed []string
+		var intentType string
+		var topics []string
+		var chunkLegs map[string][]string
+		var searchStrategy string
+		var modelPreference string
 		if enricher != nil {
-			enriched, meta := enricher.Enrich(r.Context(), req)
+			enriched, meta := enricher.EnrichAs(r.Context(), persona, req)
 			req = enriched
 			chunksUsed = meta.ChunksUsed
+			intentType = meta.IntentType
+			topics = meta.Topics
+			chunkLegs = meta.ChunkLegs
+			searchStrategy = meta.SearchStrategy
+			modelPreference = meta.ModelPreference
 			slog.Debug("request enriched",
 				"intent_extracted", meta.IntentExtracted,
 				"chunks_used", len(meta.ChunksUsed),
@@ -188,6 +287,15 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 			)
 		}
 
+		// Route before the response cache lookup: the key covers the model.
+		if router != nil {
+			route := router.Route(requestedModel, intentType, modelPreference)
+			req.Model = route.Model
+			w.Header().Set(headerRouteModel, route.Model)
+			w.Header().Set(headerRouteReason, route.Explain())
+			slog.Debug("request routed", "requested", requestedModel, "model", route.Model, "reason", route.Explain())
+		}
+
 		// Always capture the final forwarded messages for interaction storage,
 		// whether enriched or original (passthrough mode).
 		var enrichedPrompt string
@@ -195,24 +303,62 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 			enrichedPrompt = string(b)
 		}
 
-		rc, err := p.Chat(r.Context(), req)
-		if err != nil {
-			httpError(w, http.StatusBadGateway, "api_error", "upstream error: %v", err)
-			return
+		// The response cache is keyed on the enriched request, so a profile or
+		// context change that alters enrichment naturally misses.
+		var responseKey string
+		var cached cache.CachedResponse
+		var cacheHit bool
+		if useResponseCache {
+			responseKey = cache.ResponseKey(client, req)
+			if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+				cached, cacheHit = responses.Get(responseKey)
+			}
+		}
+
+		var src io.Reader
+		if cacheHit {
+			src = bytes.NewReader(cached.Body)
+			w.Header().Set(headerResponseCache, "hit")
+			if cached.InteractionID != "" {
+				w.Header().Set(headerCachedFrom, cached.InteractionID)
+			}
+			slog.Debug("response cache hit", "client", client, "cached_from", cached.InteractionID)
+		} else {
+			rc, err := p.Chat(r.Context(), req)
+			if err != nil {
+				httpError(w, http.StatusBadGateway, "api_error", "upstream error: %v", err)
+				return
+			}
+			defer rc.Close()
+			src = rc
+			if useResponseCache {
+				w.Header().Set(headerResponseCache, "miss")
+			}
 		}
-		defer rc.Close()
 
 		var responseBody string
 		var upstreamModel string
 		status := "completed"
 		if req.Stream {
+			// Capture the raw upstream stream for the response cache. The
+			// tbyd-metadata event is written separately and is not captured,
+			// so a replay carries its own interaction ID.
+			var raw *cappedBuffer
+			if useResponseCache && !cacheHit {
+				raw = &cappedBuffer{max: maxCaptureBytesStreaming}
+				src = io.TeeReader(src, raw)
+			}
 			var streamOK bool
-			responseBody, upstreamModel, streamOK = streamResponseCapture(w, rc, interactionID)
+			responseBody, upstreamModel, streamOK = streamResponseCapture(w, src, interactionID)
 			if !streamOK {
 				status = "aborted"
+			} else if raw != nil && !raw.overflow {
+				responses.Set(responseKey, cache.CachedResponse{
+					Body: raw.Bytes(), Stream: true, Model: upstreamModel, InteractionID: interactionID,
+				}, responseTTL)
 			}
 		} else {
-			body, err := io.ReadAll(io.LimitReader(rc, maxResponseBodyBytes))
+			body, err := io.ReadAll(io.LimitReader(src, maxResponseBodyBytes))
 			if err != nil {
 				httpError(w, http.StatusBadGateway, "api_error", "reading upstream response: %v", err)
 				return
@@ -232,6 +378,17 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 			if json.Unmarshal(body, &respObj) == nil && respObj.Model != "" {
 				upstreamModel = respObj.Model
 			}
+			if useResponseCache && !cacheHit && isCompletion(body) {
+				responses.Set(responseKey, cache.CachedResponse{
+					Body: body, Model: upstreamModel, InteractionID: interactionID,
+				}, responseTTL)
+			}
+		}
+
+		var cachedFrom string
+		if cacheHit {
+			status = "cached"
+			cachedFrom = cached.InteractionID
 		}
 
 		// Prefer upstream model (what was actually used) over request model.
@@ -250,6 +407,13 @@ func handleChatCompletions(p *proxy.Client, enricher *pipeline.Enricher, saveCh
 				CloudResponse:  responseBody,
 				Status:         status,
 				ChunksUsed:     chunksUsed,
+				IntentType:     intentType,
+				Topics:         topics,
+				ChunkLegs:      chunkLegs,
+				SearchStrategy: searchStrategy,
+				CachedFrom:     cachedFrom,
+				Persona:        persona.Name,
+				Partition:      persona.Partition(),
 			}
 			select {
 			case saveCh <- rec:
@@ -322,6 +486,24 @@ func doSaveInteraction(ctx context.Context, saver InteractionSaver, rec interact
 		}
 	}
 
+	retrievalLegsJSON := "{}"
+	if len(rec.ChunkLegs) > 0 {
+		if b, err := json.Marshal(rec.ChunkLegs); err == nil {
+			retrievalLegsJSON = string(b)
+		} else {
+			slog.Error("failed to marshal retrieval legs", "error", err, "interaction_id", interactionID)
+		}
+	}
+
+	topicsJSON := "[]"
+	if len(rec.Topics) > 0 {
+		if b, err := json.Marshal(rec.Topics); err == nil {
+			topicsJSON = string(b)
+		} else {
+			slog.Error("failed to marshal topics", "error", err, "interaction_id", interactionID)
+		}
+	}
+
 	interaction := storage.Interaction{
 		ID:             interactionID,
 		CreatedAt:      time.Now().UTC(),
@@ -331,6 +513,13 @@ func doSaveInteraction(ctx context.Context, saver InteractionSaver, rec interact
 		CloudResponse:  rec.CloudResponse,
 		Status:         status,
 		VectorIDs:      vectorIDsJSON,
+		IntentType:     rec.IntentType,
+		Topics:         topicsJSON,
+		RetrievalLegs:  retrievalLegsJSON,
+		SearchStrategy: rec.SearchStrategy,
+		CachedFrom:     rec.CachedFrom,
+		Persona:        rec.Persona,
+		Partition:      rec.Partition,
 	}
 
 	if err := saver.SaveInteraction(ctx, interaction); err != nil {
@@ -475,6 +664,34 @@ func streamResponseCapture(w http.ResponseWriter, rc io.Reader, interactionID st
 	return string(synth), streamModel, streamDone
 }
 
+// cappedBuffer accumulates up to max bytes and records whether more were
+// offered. Writes never fail, so it can sit behind an io.TeeReader without
+// interrupting the stream it copies.
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.max {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// isCompletion reports whether body is a chat completion with at least one
+// choice, as opposed to an error object returned with a 200 status.
+func isCompletion(body []byte) bool {
+	var resp struct {
+		Choices []json.RawMessage `json:"choices"`
+		Error   json.RawMessage   `json:"error"`
+	}
+	return json.Unmarshal(body, &resp) == nil && len(resp.Choices) > 0 && len(resp.Error) == 0
+}
+
 func hasMessages(raw json.RawMessage) bool {
 	if len(raw) == 0 {
 		return false
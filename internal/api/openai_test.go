@@ -13,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/kalambet/tbyd/internal/proxy"
+	"github.com/kalambet/tbyd/internal/storage"
 )
 
 // mockUpstream returns an httptest.Server that mimics a subset of the OpenRouter API.
@@ -26,7 +27,7 @@ func mockUpstream(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *pr
 
 func TestHealth(t *testing.T) {
 	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
@@ -53,7 +54,7 @@ func TestChatCompletions_Streaming(t *testing.T) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		fmt.Fprint(w, sseData)
 	})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -82,7 +83,7 @@ func TestChatCompletions_NonStreaming(t *testing.T) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprint(w, respJSON)
 	})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":false}`
 	rr := httptest.NewRecorder()
@@ -105,7 +106,7 @@ func TestChatCompletions_NonStreaming(t *testing.T) {
 
 func TestChatCompletions_InvalidBody(t *testing.T) {
 	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{invalid"))
@@ -118,7 +119,7 @@ func TestChatCompletions_InvalidBody(t *testing.T) {
 
 func TestChatCompletions_MissingMessages(t *testing.T) {
 	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"test","messages":[]}`))
@@ -135,7 +136,7 @@ func TestChatCompletions_UpstreamError(t *testing.T) {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, `{"error":{"message":"internal failure","type":"server_error"}}`)
 	})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -174,7 +175,7 @@ func TestChatCompletions_StreamingMidStreamError(t *testing.T) {
 		conn, _, _ := hj.Hijack()
 		conn.Close()
 	})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -196,7 +197,7 @@ func TestChatCompletions_StreamingMidStreamError(t *testing.T) {
 
 func TestBindsToLoopback(t *testing.T) {
 	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
-	handler, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	handler, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	srv := &http.Server{
 		Addr:    "127.0.0.1:0",
@@ -230,7 +231,7 @@ func TestModels(t *testing.T) {
 		}
 		json.NewEncoder(w).Encode(list)
 	})
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
@@ -266,7 +267,7 @@ func TestChatCompletions_NonStreaming_SurfacesInteractionID(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -296,7 +297,7 @@ func TestChatCompletions_NonStreaming_NoIDWhenSaveDisabled(t *testing.T) {
 		fmt.Fprint(w, respJSON)
 	})
 
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 	rr := httptest.NewRecorder()
@@ -328,7 +329,7 @@ func TestChatCompletions_Streaming_SurfacesInteractionID(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -380,7 +381,7 @@ func TestChatCompletions_Streaming_NoMetadataWhenSaveDisabled(t *testing.T) {
 		fmt.Fprint(w, sseData)
 	})
 
-	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil)
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -411,7 +412,7 @@ func TestChatCompletions_InteractionID_MatchesSavedRecord(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"match me"}]}`
 	rr := httptest.NewRecorder()
@@ -465,7 +466,7 @@ func TestChatCompletions_Streaming_MetadataEventFormat(t *testing.T) {
 	t.Cleanup(cancel)
 
 	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 	rr := httptest.NewRecorder()
@@ -532,7 +533,7 @@ func TestChatCompletions_InteractionID_IsUUID(t *testing.T) {
 		t.Cleanup(cancel)
 
 		saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-		h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+		h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 		body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
 		rr := httptest.NewRecorder()
@@ -560,7 +561,7 @@ func TestChatCompletions_InteractionID_IsUUID(t *testing.T) {
 		t.Cleanup(cancel)
 
 		saver := &mockInteractionSaver{saveDone: make(chan struct{}, 1)}
-		h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil)
+		h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{})
 
 		body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
 		rr := httptest.NewRecorder()
@@ -593,3 +594,101 @@ func TestChatCompletions_InteractionID_IsUUID(t *testing.T) {
 }
 
 
+
+func TestChatCompletions_RoutesAutoModel(t *testing.T) {
+	var upstreamModel string
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models":
+			json.NewEncoder(w).Encode(proxy.ModelList{Object: "list", Data: []proxy.Model{{ID: "openai/gpt-4o", Object: "model"}}})
+		default:
+			var req proxy.ChatRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			upstreamModel = req.Model
+			fmt.Fprint(w, `{"id":"gen-1","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+		}
+	})
+	router, err := proxy.NewRouter("anthropic/claude-opus-4", "recall=openai/gpt-4o-mini")
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{Router: router})
+
+	tests := []struct {
+		model      string
+		wantModel  string
+		wantReason string
+	}{
+		// Without an enricher there is no intent or preference to route by.
+		{proxy.AutoModel, "anthropic/claude-opus-4", "default"},
+		{"openai/gpt-4o", "openai/gpt-4o", "requested"},
+	}
+	for _, tt := range tests {
+		body := `{"model":"` + tt.model + `","messages":[{"role":"user","content":"hi"}]}`
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", tt.model, rr.Code, rr.Body.String())
+		}
+		if upstreamModel != tt.wantModel {
+			t.Errorf("%s: upstream model = %q, want %q", tt.model, upstreamModel, tt.wantModel)
+		}
+		if got := rr.Header().Get(headerRouteModel); got != tt.wantModel {
+			t.Errorf("%s: %s = %q, want %q", tt.model, headerRouteModel, got, tt.wantModel)
+		}
+		if got := rr.Header().Get(headerRouteReason); got != tt.wantReason {
+			t.Errorf("%s: %s = %q, want %q", tt.model, headerRouteReason, got, tt.wantReason)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	var list proxy.ModelList
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Data) != 2 || list.Data[0].ID != proxy.AutoModel {
+		t.Errorf("models = %+v, want %s listed first", list.Data, proxy.AutoModel)
+	}
+}
+
+func TestChatCompletions_ProjectHeader(t *testing.T) {
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"gen-1","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	})
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.CreateProject(storage.Project{Name: "tbyd", Roots: []string{"/src/tbyd"}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	withProjects, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{Projects: store})
+	withoutProjects, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{})
+
+	tests := []struct {
+		name    string
+		h       http.Handler
+		project string
+		want    int
+	}{
+		{"known project", withProjects, "tbyd", http.StatusOK},
+		{"path outside every root", withProjects, "/home/me", http.StatusOK},
+		{"unknown project", withProjects, "nope", http.StatusNotFound},
+		{"no project resolver", withoutProjects, "tbyd", http.StatusBadRequest},
+		{"no header", withoutProjects, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"test","messages":[{"role":"user","content":"hi"}]}`))
+		if tt.project != "" {
+			req.Header.Set(headerProject, tt.project)
+		}
+		rr := httptest.NewRecorder()
+		tt.h.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d; body = %s", tt.name, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kalambet/tbyd/internal/pipeline"
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// headerPersona selects a persona explicitly, overriding token, client and
+// model selectors.
+const headerPersona = "X-TBYD-Persona"
+
+// personaNameRe restricts persona names to values that are safe as cache
+// scopes and knowledge-base partition names.
+var personaNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// PersonaResolver maps request selectors to personas. Implemented by
+// storage.Store.
+type PersonaResolver interface {
+	ResolvePersona(kind, value string) (string, error)
+	GetPersona(name string) (storage.Persona, error)
+}
+
+// errUnknownPersona is returned when the persona header names a persona that
+// does not exist.
+var errUnknownPersona = errors.New("unknown persona")
+
+// resolvePersona picks the persona for a proxied request: the persona header,
+// then the bearer token, then the X-TBYD-Client name, then the requested
+// model, falling back to the default persona. A nil resolver always selects
+// the default persona.
+func resolvePersona(rs PersonaResolver, r *http.Request, model string) (pipeline.Persona, error) {
+	if rs == nil {
+		return pipeline.Persona{}, nil
+	}
+	name := strings.TrimSpace(r.Header.Get(headerPersona))
+	if name == "" {
+		var token string
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = auth[len("Bearer "):]
+		}
+		selectors := []struct{ kind, value string }{
+			{storage.SelectorToken, token},
+			{storage.SelectorClient, r.Header.Get(headerClient)},
+			{storage.SelectorModel, model},
+		}
+		for _, sel := range selectors {
+			p, err := rs.ResolvePersona(sel.kind, sel.value)
+			if err == nil {
+				name = p
+				break
+			}
+			if !errors.Is(err, storage.ErrNotFound) {
+				return pipeline.Persona{}, err
+			}
+		}
+	}
+	if name == "" || name == storage.DefaultPersona {
+		return pipeline.Persona{Name: storage.DefaultPersona}, nil
+	}
+	p, err := rs.GetPersona(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return pipeline.Persona{}, fmt.Errorf("%w %q", errUnknownPersona, name)
+	}
+	if err != nil {
+		return pipeline.Persona{}, err
+	}
+	return pipeline.Persona{Name: p.Name, Partitioned: p.Partitioned}, nil
+}
+
+// profileFor returns the profile manager and persona selected by the persona
+// header on a management request. It writes an error response and returns
+// false when the persona does not exist or personas are not configured.
+func profileFor(deps AppDeps, w http.ResponseWriter, r *http.Request) (*profile.Manager, pipeline.Persona, bool) {
+	name := strings.TrimSpace(r.Header.Get(headerPersona))
+	if name == "" || name == storage.DefaultPersona {
+		return deps.Profile, pipeline.Persona{Name: storage.DefaultPersona}, true
+	}
+	if deps.Personas == nil {
+		httpError(w, http.StatusNotImplemented, "not_implemented", "personas are not configured")
+		return nil, pipeline.Persona{}, false
+	}
+	p, err := deps.Store.GetPersona(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			httpError(w, http.StatusNotFound, "not_found", "persona %q not found", name)
+			return nil, pipeline.Persona{}, false
+		}
+		httpError(w, http.StatusInternalServerError, "api_error", "failed to get persona: %v", err)
+		return nil, pipeline.Persona{}, false
+	}
+	return deps.Personas.For(p.Name), pipeline.Persona{Name: p.Name, Partitioned: p.Partitioned}, true
+}
+
+// CreatePersonaRequest is the body of POST /personas.
+type CreatePersonaRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Partitioned bool     `json:"partitioned"`
+	Clients     []string `json:"clients"`
+	Models      []string `json:"models"`
+	IssueToken  bool     `json:"issue_token"` // generate an API token that selects the persona
+}
+
+func handleListPersonas(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		personas, err := deps.Store.ListPersonas()
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list personas: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(personas)
+	}
+}
+
+func handleCreatePersona(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		var req CreatePersonaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: %v", err)
+			return
+		}
+		if !personaNameRe.MatchString(req.Name) {
+			httpError(w, http.StatusBadRequest, "invalid_request_error",
+				"name must be 1-64 lowercase letters, digits, '_' or '-', starting with a letter or digit")
+			return
+		}
+
+		p := storage.Persona{
+			Name:        req.Name,
+			Description: req.Description,
+			Partitioned: req.Partitioned,
+			Clients:     req.Clients,
+			Models:      req.Models,
+		}
+		if err := deps.Store.CreatePersona(p); err != nil {
+			if errors.Is(err, storage.ErrConflict) {
+				httpError(w, http.StatusConflict, "conflict", "%v", err)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to create persona: %v", err)
+			return
+		}
+
+		resp := map[string]any{"name": p.Name, "status": "created"}
+		if req.IssueToken {
+			token, err := newPersonaToken()
+			if err != nil {
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to generate token: %v", err)
+				return
+			}
+			if err := deps.Store.AddPersonaToken(p.Name, token); err != nil {
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to store token: %v", err)
+				return
+			}
+			// Only the hash is stored; this is the one time the token is shown.
+			resp["token"] = token
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func handleDeletePersona(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == storage.DefaultPersona {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "the %s persona cannot be deleted", storage.DefaultPersona)
+			return
+		}
+		removed, err := deps.Store.DeletePersona(name)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "persona %q not found", name)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete persona: %v", err)
+			return
+		}
+		if deps.Personas != nil {
+			deps.Personas.Reset(name)
+		}
+		if deps.Cache != nil && len(removed) > 0 {
+			deps.Cache.InvalidateBySources(removed)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+	}
+}
+
+// newPersonaToken returns a random API token for a persona.
+func newPersonaToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "tbyd-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/retrieval"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func setupPersonaAppHandler(t *testing.T, token string) (http.Handler, *storage.Store) {
+	t.Helper()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	personas := profile.NewRegistry(func(persona string) profile.ProfileStore { return store.PersonaProfile(persona) })
+	handler := NewAppHandler(AppDeps{
+		Store:      store,
+		Profile:    personas.Default(),
+		Personas:   personas,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	})
+	return handler, store
+}
+
+func TestPersonaEndpoints(t *testing.T) {
+	const token = "test-token"
+	h, store := setupPersonaAppHandler(t, token)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	asPersona := func(req *http.Request, persona string) *http.Request {
+		req.Header.Set(headerPersona, persona)
+		return req
+	}
+
+	w := serve(authReq("POST", "/personas", `{"name":"work","partitioned":true,"clients":["cursor"],"issue_token":true}`, token))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Token == "" {
+		t.Fatal("create with issue_token returned no token")
+	}
+	if got, err := store.ResolvePersona(storage.SelectorToken, created.Token); err != nil || got != "work" {
+		t.Errorf("issued token resolves to %q, %v; want work", got, err)
+	}
+
+	for _, body := range []string{`{"name":"work"}`, `{"name":"Bad Name"}`} {
+		w = serve(authReq("POST", "/personas", body, token))
+		if w.Code != http.StatusConflict && w.Code != http.StatusBadRequest {
+			t.Errorf("create %s: status = %d, want 409 or 400", body, w.Code)
+		}
+	}
+
+	w = serve(authReq("GET", "/personas", "", token))
+	var list []storage.Persona
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 2 || list[1].Name != "work" || list[1].Tokens != 1 {
+		t.Errorf("list = %+v, want default and work with one token", list)
+	}
+
+	// Profile endpoints are scoped by the persona header.
+	w = serve(asPersona(authReq("PATCH", "/profile", `{"identity.role":"staff engineer"}`, token), "work"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch work profile: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	w = serve(authReq("GET", "/profile", "", token))
+	if strings.Contains(w.Body.String(), "staff engineer") {
+		t.Error("default profile shows the work persona's role")
+	}
+	w = serve(asPersona(authReq("GET", "/profile", "", token), "work"))
+	if !strings.Contains(w.Body.String(), "staff engineer") {
+		t.Errorf("work profile = %s, want the work role", w.Body.String())
+	}
+	w = serve(asPersona(authReq("GET", "/profile/history", "", token), "work"))
+	var versions []storage.ProfileVersion
+	json.NewDecoder(w.Body).Decode(&versions)
+	if len(versions) != 1 {
+		t.Errorf("work history has %d versions, want 1", len(versions))
+	}
+	w = serve(asPersona(authReq("GET", "/profile", "", token), "nobody"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown persona: status = %d, want 404", w.Code)
+	}
+
+	// Documents ingested as a partitioned persona land in its partition.
+	w = serve(asPersona(authReq("POST", "/ingest", `{"source":"cli","content":"work notes"}`, token), "work"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("ingest: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var ingested map[string]string
+	json.NewDecoder(w.Body).Decode(&ingested)
+	doc, err := store.GetContextDoc(ingested["id"])
+	if err != nil || doc.Partition != "work" {
+		t.Errorf("ingested doc partition = %q, %v; want work", doc.Partition, err)
+	}
+
+	w = serve(authReq("DELETE", "/personas/default", "", token))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("delete default: status = %d, want 400", w.Code)
+	}
+	w = serve(authReq("DELETE", "/personas/work", "", token))
+	if w.Code != http.StatusOK {
+		t.Errorf("delete work: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	w = serve(authReq("DELETE", "/personas/work", "", token))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete: status = %d, want 404", w.Code)
+	}
+}
+
+func TestResolvePersona(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for _, p := range []storage.Persona{
+		{Name: "by-token", Partitioned: true},
+		{Name: "by-client", Clients: []string{"cursor"}},
+		{Name: "by-model", Models: []string{"openai/gpt-4o"}},
+	} {
+		if err := store.CreatePersona(p); err != nil {
+			t.Fatalf("CreatePersona(%s): %v", p.Name, err)
+		}
+	}
+	if err := store.AddPersonaToken("by-token", "tok"); err != nil {
+		t.Fatalf("AddPersonaToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		model   string
+		want    string
+		wantErr error
+	}{
+		{"header wins", map[string]string{headerPersona: "by-model", "Authorization": "Bearer tok"}, "", "by-model", nil},
+		{"token before client", map[string]string{"Authorization": "Bearer tok", headerClient: "cursor"}, "", "by-token", nil},
+		{"client before model", map[string]string{headerClient: "Cursor"}, "openai/gpt-4o", "by-client", nil},
+		{"model", nil, "openai/gpt-4o", "by-model", nil},
+		{"unmatched", map[string]string{"Authorization": "Bearer sk-other"}, "other", storage.DefaultPersona, nil},
+		{"unknown header", map[string]string{headerPersona: "nobody"}, "", "", errUnknownPersona},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			got, err := resolvePersona(store, r, tt.model)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got.Name != tt.want {
+				t.Errorf("persona = %q, want %q", got.Name, tt.want)
+			}
+			if tt.want == "by-token" && got.Partition() != "by-token" {
+				t.Errorf("partition = %q, want by-token", got.Partition())
+			}
+		})
+	}
+
+	if got, err := resolvePersona(nil, httptest.NewRequest("GET", "/", nil), "m"); err != nil || got.Name != "" {
+		t.Errorf("nil resolver = %+v, %v; want the zero persona", got, err)
+	}
+}
+
+func TestMCPPersonaByClientName(t *testing.T) {
+	const token = "test-mcp-token"
+	deps, store := newTestMCPDeps(t)
+	deps.Personas = profile.NewRegistry(func(persona string) profile.ProfileStore { return store.PersonaProfile(persona) })
+	retriever := &mockMCPRetriever{}
+	deps.Retriever = retriever
+
+	// newMCPClient initializes as "test-client".
+	if err := store.CreatePersona(storage.Persona{Name: "work", Partitioned: true, Clients: []string{"test-client"}}); err != nil {
+		t.Fatalf("CreatePersona: %v", err)
+	}
+
+	ts := httptest.NewServer(NewMCPHTTPHandler(NewMCPServer(deps), token))
+	t.Cleanup(ts.Close)
+	c := newMCPClient(t, ts.URL, token)
+	ctx := context.Background()
+
+	call := func(name string, args map[string]interface{}) {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Name = name
+		req.Params.Arguments = args
+		res, err := c.CallTool(ctx, req)
+		if err != nil || res.IsError {
+			t.Fatalf("%s: err = %v, result = %+v", name, err, res)
+		}
+	}
+
+	call("set_preference", map[string]interface{}{"key": "communication.tone", "value": "terse"})
+	if keys, _ := store.PersonaProfile("work").GetAllProfileKeys(); keys["communication.tone"] != "terse" {
+		t.Errorf("work profile = %v, want the preference set by its client", keys)
+	}
+	if keys, _ := store.GetAllProfileKeys(); len(keys) != 0 {
+		t.Errorf("default profile = %v, want it untouched", keys)
+	}
+
+	call("recall", map[string]interface{}{"query": "notes"})
+	if len(retriever.scopes) != 1 || retriever.scopes[0] != retrieval.PartitionScope("work") {
+		t.Errorf("recall scopes = %+v, want the work partition", retriever.scopes)
+	}
+
+	call("add_context", map[string]interface{}{"content": "work notes"})
+	docs, err := store.ListContextDocs(10)
+	if err != nil || len(docs) != 1 || docs[0].Partition != "work" {
+		t.Errorf("added doc = %+v, %v; want it in the work partition", docs, err)
+	}
+}
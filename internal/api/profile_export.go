@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// ProfileImportResult is the response of POST /profile/import.
+type ProfileImportResult struct {
+	Mode          profile.ImportMode    `json:"mode"`
+	DryRun        bool                  `json:"dry_run"`
+	Changes       []profile.FieldChange `json:"changes"`
+	PendingDeltas int                   `json:"pending_deltas"` // deltas added (or, in a dry run, that would be) to the review queue
+}
+
+// handleProfileExport returns the persona's profile as an export document.
+// Pending deltas apply to the default persona's profile, so only its export
+// carries them.
+func handleProfileExport(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mgr, persona, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		p, err := mgr.GetProfile()
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to get profile: %v", err)
+			return
+		}
+
+		var deltas []profile.ExportedDelta
+		if persona.Name == storage.DefaultPersona {
+			pending, err := deps.Store.ListPendingDeltas()
+			if err != nil {
+				httpError(w, http.StatusInternalServerError, "api_error", "failed to list pending deltas: %v", err)
+				return
+			}
+			for _, d := range pending {
+				var delta profile.ProfileDelta
+				if err := json.Unmarshal([]byte(d.DeltaJSON), &delta); err != nil {
+					slog.Warn("profile export: skipping malformed pending delta", "id", d.ID, "error", err)
+					continue
+				}
+				deltas = append(deltas, profile.ExportedDelta{
+					Description: d.Description,
+					Source:      d.Source,
+					CreatedAt:   d.CreatedAt,
+					Delta:       delta,
+				})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(profile.NewExport(persona.Name, p, deltas, time.Now()))
+	}
+}
+
+func handleProfileSchema(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(profile.ExportSchema)
+	}
+}
+
+// handleProfileImport applies an export document to the persona's profile.
+// ?mode=merge (default) or replace; ?dry_run=true returns the diff without
+// writing anything.
+func handleProfileImport(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := profile.ImportMode(r.URL.Query().Get("mode"))
+		if mode == "" {
+			mode = profile.ImportMerge
+		}
+		if mode != profile.ImportMerge && mode != profile.ImportReplace {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "mode must be %q or %q", profile.ImportMerge, profile.ImportReplace)
+			return
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "reading request body: %v", err)
+			return
+		}
+		doc, err := profile.ParseExport(body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "%v", err)
+			return
+		}
+		if err := validateImport(doc); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "%v", err)
+			return
+		}
+
+		mgr, persona, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		if len(doc.PendingDeltas) > 0 && persona.Name != storage.DefaultPersona {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "pending deltas can only be imported into the default persona")
+			return
+		}
+
+		// Deltas are queued before the profile is written, so a failed import
+		// never leaves the profile changed. Queued deltas only await review,
+		// and retrying the import skips those already pending.
+		deltas, err := newPendingDeltas(deps.Store, doc.PendingDeltas)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to import pending deltas: %v", err)
+			return
+		}
+		if !dryRun {
+			for _, d := range deltas {
+				if err := deps.Store.SavePendingDelta(d); err != nil {
+					httpError(w, http.StatusInternalServerError, "api_error", "failed to import pending deltas: %v", err)
+					return
+				}
+			}
+		}
+
+		changes, err := mgr.Import(profileSource(r), doc.Profile, mode, dryRun)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to import profile: %v", err)
+			return
+		}
+		added := len(deltas)
+
+		if changes == nil {
+			changes = []profile.FieldChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ProfileImportResult{Mode: mode, DryRun: dryRun, Changes: changes, PendingDeltas: added})
+	}
+}
+
+// validateImport checks every profile key the document would write, and every
+// key its pending deltas would set, against profileKeyAllowlist.
+func validateImport(doc profile.Export) error {
+	keys := make([]string, 0, len(profileKeyAllowlist))
+	for key := range profile.StorageKeys(doc.Profile) {
+		keys = append(keys, key)
+	}
+	for _, d := range doc.PendingDeltas {
+		for key := range d.Delta.UpdateFields {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := profileKeyAllowlist[key]; !ok {
+			return fmt.Errorf("%w: unrecognised profile key %q", profile.ErrInvalidExport, key)
+		}
+	}
+	return nil
+}
+
+// newPendingDeltas builds the pending deltas an import queues for review,
+// skipping any already pending with the same content. Nothing is saved.
+// Queued deltas are stamped with the import time and their source is tagged
+// "import:<source>", so they neither look older than they are nor count as
+// pending output of the job that originally proposed them.
+func newPendingDeltas(store *storage.Store, deltas []profile.ExportedDelta) ([]storage.PendingProfileDelta, error) {
+	if len(deltas) == 0 {
+		return nil, nil
+	}
+	pending, err := store.ListPendingDeltas()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(pending))
+	for _, d := range pending {
+		seen[d.DeltaJSON] = true
+	}
+
+	now := time.Now().UTC()
+	var out []storage.PendingProfileDelta
+	for _, d := range deltas {
+		deltaJSON, err := json.Marshal(d.Delta)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling delta: %w", err)
+		}
+		if seen[string(deltaJSON)] {
+			continue
+		}
+		seen[string(deltaJSON)] = true
+		source := "import"
+		if d.Source != "" {
+			source += ":" + d.Source
+		}
+		out = append(out, storage.PendingProfileDelta{
+			ID:          uuid.New().String(),
+			DeltaJSON:   string(deltaJSON),
+			Description: d.Description,
+			Source:      source,
+			CreatedAt:   now,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// headerSource lets first-party clients identify themselves so that profile
+// history can tell CLI edits from other API calls.
+const headerSource = "X-TBYD-Source"
+
+// profileSource returns the history source for a profile change made through
+// the management API.
+func profileSource(r *http.Request) profile.Source {
+	if r.Header.Get(headerSource) == string(profile.SourceCLI) {
+		return profile.SourceCLI
+	}
+	return profile.SourceAPI
+}
+
+// deltaSource maps a pending delta's origin to the history source recorded
+// when it is accepted.
+func deltaSource(origin string) profile.Source {
+	switch origin {
+	case "nightly_synthesis", "interest_review", "preference_reconcile":
+		return profile.SourceNightly
+	case "feedback_aggregation":
+		return profile.SourceFeedback
+	default:
+		return profile.SourceAPI
+	}
+}
+
+func handleProfileHistory(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseIntParam(r, "limit", 20, 100)
+		offset := parseIntParam(r, "offset", 0, 0)
+
+		_, persona, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		versions, err := deps.Store.PersonaProfile(persona.Name).ListProfileVersions(limit, offset)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list profile versions: %v", err)
+			return
+		}
+		if versions == nil {
+			versions = []storage.ProfileVersion{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+	}
+}
+
+// handleProfileDiff compares two versions given as ?from= and ?to=.
+func handleProfileDiff(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		if err != nil || from < 0 {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "from must be a profile version number")
+			return
+		}
+		to, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		if err != nil || to < 0 {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "to must be a profile version number")
+			return
+		}
+
+		_, persona, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		changes, err := deps.Store.PersonaProfile(persona.Name).DiffProfileVersions(from, to)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "profile version not found")
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to diff profile versions: %v", err)
+			return
+		}
+		if changes == nil {
+			changes = []storage.ProfileFieldChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"from": from, "to": to, "changes": changes})
+	}
+}
+
+func handleProfileRollback(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+		if err != nil || version < 0 {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "version must be a profile version number")
+			return
+		}
+
+		mgr, _, ok := profileFor(deps, w, r)
+		if !ok {
+			return
+		}
+		newVersion, err := mgr.Rollback(profileSource(r), version)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "profile version %d not found", version)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to roll back profile: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"status": "rolled_back", "version": newVersion})
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
 )
 
 func TestGetProfile_ReturnsJSON(t *testing.T) {
@@ -326,3 +328,193 @@ func TestDeleteProfileField_InvalidChars(t *testing.T) {
 		}
 	}
 }
+
+func TestProfileHistory_RecordsSource(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	req := authReq(http.MethodPatch, "/profile", `{"communication.tone":"direct"}`, testToken)
+	req.Header.Set(headerSource, "cli")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/profile", `{"communication.tone":"formal"}`, testToken))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/profile/history", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var versions []storage.ProfileVersion
+	if err := json.NewDecoder(rr.Body).Decode(&versions); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if versions[0].Source != "api" || versions[1].Source != "cli" {
+		t.Errorf("sources = %q, %q; want api, cli", versions[0].Source, versions[1].Source)
+	}
+	if c := versions[0].Changes; len(c) != 1 || c[0].Old != "direct" || c[0].New != "formal" {
+		t.Errorf("newest changes = %+v", c)
+	}
+}
+
+func TestProfileDiffAndRollback(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	h.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/profile", `{"communication.tone":"direct"}`, testToken))
+	h.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/profile", `{"identity.role":"engineer"}`, testToken))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/profile/diff?from=1&to=2", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("diff status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var diff struct {
+		Changes []storage.ProfileFieldChange `json:"changes"`
+	}
+	json.NewDecoder(rr.Body).Decode(&diff)
+	if len(diff.Changes) != 1 || diff.Changes[0].Key != "identity.role" || diff.Changes[0].Op != "add" {
+		t.Errorf("diff changes = %+v", diff.Changes)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodPost, "/profile/rollback/1", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("rollback status = %d; body = %s", rr.Code, rr.Body.String())
+	}
+	var rb struct {
+		Version int64 `json:"version"`
+	}
+	json.NewDecoder(rr.Body).Decode(&rb)
+	if rb.Version != 3 {
+		t.Errorf("rollback version = %d, want 3", rb.Version)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, authReq(http.MethodGet, "/profile", "", testToken))
+	var p profile.Profile
+	json.NewDecoder(rr.Body).Decode(&p)
+	if p.Identity.Role != "" || p.Communication.Tone != "direct" {
+		t.Errorf("profile after rollback = role %q tone %q, want no role and tone direct", p.Identity.Role, p.Communication.Tone)
+	}
+}
+
+func TestProfileHistory_Errors(t *testing.T) {
+	h, _ := setupAppHandler(t, testToken)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/profile/diff?from=a&to=1", http.StatusBadRequest},
+		{http.MethodGet, "/profile/diff?from=0&to=9", http.StatusNotFound},
+		{http.MethodPost, "/profile/rollback/x", http.StatusBadRequest},
+		{http.MethodPost, "/profile/rollback/9", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, authReq(tt.method, tt.path, "", testToken))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestProfileExportImport(t *testing.T) {
+	h, store := setupAppHandler(t, testToken)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve(authReq(http.MethodPatch, "/profile", `{"identity.role":"engineer","preferences":["be concise"]}`, testToken))
+	if err := store.SavePendingDelta(storage.PendingProfileDelta{
+		ID: "d1", DeltaJSON: `{"AddPreferences":["use tables"],"RemovePreferences":null,"UpdateFields":null}`,
+		Description: "nightly", Source: "nightly_synthesis", CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("SavePendingDelta: %v", err)
+	}
+
+	rr := serve(authReq(http.MethodGet, "/profile/export", "", testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	exported := rr.Body.String()
+	doc, err := profile.ParseExport([]byte(exported))
+	if err != nil {
+		t.Fatalf("export does not parse: %v", err)
+	}
+	if doc.Profile.Identity.Role != "engineer" || len(doc.PendingDeltas) != 1 {
+		t.Errorf("export = %+v, want the role and one pending delta", doc)
+	}
+
+	rr = serve(authReq(http.MethodGet, "/profile/schema", "", testToken))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), profile.ExportSchemaID) {
+		t.Errorf("schema: status = %d, body = %.80s", rr.Code, rr.Body.String())
+	}
+
+	// A dry-run replace with a changed role reports the diff and writes nothing.
+	changed := strings.Replace(exported, `"role": "engineer"`, `"role": "manager"`, 1)
+	rr = serve(authReq(http.MethodPost, "/profile/import?mode=replace&dry_run=true", changed, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("dry run: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var result ProfileImportResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	if !result.DryRun || len(result.Changes) != 1 || result.Changes[0].New != "manager" || result.PendingDeltas != 0 {
+		t.Errorf("dry run = %+v, want one role change and no new deltas (already pending)", result)
+	}
+	if role, _ := store.GetProfileKey("identity.role"); role != "engineer" {
+		t.Errorf("dry run wrote role %q", role)
+	}
+
+	rr = serve(authReq(http.MethodPost, "/profile/import?mode=replace", changed, testToken))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("import: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if role, _ := store.GetProfileKey("identity.role"); role != "manager" {
+		t.Errorf("role after import = %q, want manager", role)
+	}
+	if pending, _ := store.ListPendingDeltas(); len(pending) != 1 {
+		t.Errorf("pending deltas = %d, want the existing one not duplicated", len(pending))
+	}
+
+	// Imported deltas are queued as new, tagged with where they came from.
+	before := time.Now().UTC().Add(-time.Second)
+	body := `{"$schema":"` + profile.ExportSchemaID + `","version":1,"profile":{},` +
+		`"pending_deltas":[{"description":"old","source":"nightly_synthesis","created_at":"2025-01-02T03:04:05Z",` +
+		`"delta":{"AddPreferences":["use bullet points"]}}]}`
+	if rr := serve(authReq(http.MethodPost, "/profile/import", body, testToken)); rr.Code != http.StatusOK {
+		t.Fatalf("import delta: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	pending, err := store.ListPendingDeltas()
+	if err != nil {
+		t.Fatalf("ListPendingDeltas: %v", err)
+	}
+	var imported *storage.PendingProfileDelta
+	for i := range pending {
+		if pending[i].Description == "old" {
+			imported = &pending[i]
+		}
+	}
+	if imported == nil {
+		t.Fatalf("pending deltas = %+v, want the imported one", pending)
+	}
+	if imported.Source != "import:nightly_synthesis" || imported.CreatedAt.Before(before) {
+		t.Errorf("imported delta = %+v, want source import:nightly_synthesis created now", *imported)
+	}
+
+	for name, body := range map[string]string{
+		"not an export": `{"identity.role":"x"}`,
+		"delta key outside allowlist": `{"$schema":"` + profile.ExportSchemaID + `","version":1,"profile":{},` +
+			`"pending_deltas":[{"delta":{"UpdateFields":{"api_token":"x"}}}]}`,
+	} {
+		if rr := serve(authReq(http.MethodPost, "/profile/import", body, testToken)); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rr.Code)
+		}
+	}
+	if rr := serve(authReq(http.MethodPost, "/profile/import?mode=overwrite", exported, testToken)); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad mode: status = %d, want 400", rr.Code)
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// headerProject selects the active project by name, or by an absolute path
+// (typically the client's working directory) that lies under a project root.
+const headerProject = "X-TBYD-Project"
+
+// ProjectResolver maps project names and working directories to projects.
+// Implemented by storage.Store.
+type ProjectResolver interface {
+	ResolveProjectByPath(path string) (string, error)
+	GetProject(name string) (storage.Project, error)
+}
+
+// errUnknownProject is returned when a project is selected by a name that
+// does not exist.
+var errUnknownProject = errors.New("unknown project")
+
+// resolveProject returns the project selected by value: a project name, or an
+// absolute path resolved against project roots. A path outside every root
+// selects no project; an unknown name is an error.
+func resolveProject(pr ProjectResolver, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || pr == nil {
+		return "", nil
+	}
+	if filepath.IsAbs(value) {
+		name, err := pr.ResolveProjectByPath(value)
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", nil
+		}
+		return name, err
+	}
+	p, err := pr.GetProject(value)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", fmt.Errorf("%w %q", errUnknownProject, value)
+	}
+	if err != nil {
+		return "", err
+	}
+	return p.Name, nil
+}
+
+// projectFor returns the project selected by the project header on a
+// management request. It writes an error response and returns false when the
+// header names an unknown project.
+func projectFor(deps AppDeps, w http.ResponseWriter, r *http.Request) (string, bool) {
+	name, err := resolveProject(deps.Store, r.Header.Get(headerProject))
+	if err != nil {
+		if errors.Is(err, errUnknownProject) {
+			httpError(w, http.StatusNotFound, "not_found", "%v", err)
+			return "", false
+		}
+		httpError(w, http.StatusInternalServerError, "api_error", "failed to resolve project: %v", err)
+		return "", false
+	}
+	return name, true
+}
+
+// CreateProjectRequest is the body of POST /projects.
+type CreateProjectRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roots       []string `json:"roots"`
+	Stack       []string `json:"stack"`
+}
+
+func handleListProjects(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projects, err := deps.Store.ListProjects()
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list projects: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(projects)
+	}
+}
+
+func handleCreateProject(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		var req CreateProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: %v", err)
+			return
+		}
+		// Project names share the persona name rules: they are used as cache
+		// keys and retrieval filter values.
+		if !personaNameRe.MatchString(req.Name) {
+			httpError(w, http.StatusBadRequest, "invalid_request_error",
+				"name must be 1-64 lowercase letters, digits, '_' or '-', starting with a letter or digit")
+			return
+		}
+		for _, root := range req.Roots {
+			if !filepath.IsAbs(root) {
+				httpError(w, http.StatusBadRequest, "invalid_request_error", "root %q must be an absolute path", root)
+				return
+			}
+		}
+
+		p := storage.Project{
+			Name:        req.Name,
+			Description: req.Description,
+			Roots:       req.Roots,
+			Stack:       req.Stack,
+		}
+		if err := deps.Store.CreateProject(p); err != nil {
+			if errors.Is(err, storage.ErrConflict) {
+				httpError(w, http.StatusConflict, "conflict", "%v", err)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to create project: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"name": p.Name, "status": "created"})
+	}
+}
+
+func handleDeleteProject(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if err := deps.Store.DeleteProject(name); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				httpError(w, http.StatusNotFound, "not_found", "project %q not found", name)
+				return
+			}
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to delete project: %v", err)
+			return
+		}
+		// Cached enrichments may have boosted the project's documents.
+		if deps.Cache != nil {
+			deps.Cache.Invalidate()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+	}
+}
+
+// mcpRootsTimeout bounds a roots/list round trip to the MCP client. Clients
+// that declare roots but never answer would otherwise stall every tool call.
+const mcpRootsTimeout = 2 * time.Second
+
+// mcpRoots caches the roots each MCP session reported, keyed by session ID,
+// until the client signals that its roots changed.
+type mcpRoots struct {
+	mu    sync.Mutex
+	roots map[string][]string // session ID -> root paths; nil entry: lookup failed
+}
+
+func newMCPRoots() *mcpRoots {
+	return &mcpRoots{roots: make(map[string][]string)}
+}
+
+// forget drops the cached roots of the session in ctx.
+func (c *mcpRoots) forget(ctx context.Context) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.roots, session.SessionID())
+	c.mu.Unlock()
+}
+
+// paths returns the directory roots the calling client reported, asking it
+// on first use. Clients that did not declare the roots capability report none.
+func (c *mcpRoots) paths(ctx context.Context) []string {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if !ok || session.GetClientCapabilities().Roots == nil {
+		return nil
+	}
+	id := session.SessionID()
+	c.mu.Lock()
+	paths, cached := c.roots[id]
+	c.mu.Unlock()
+	if cached {
+		return paths
+	}
+
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	rctx, cancel := context.WithTimeout(ctx, mcpRootsTimeout)
+	defer cancel()
+	res, err := srv.RequestRoots(rctx, mcp.ListRootsRequest{})
+	if err != nil {
+		slog.Warn("mcp: failed to list client roots", "error", err)
+	} else {
+		for _, root := range res.Roots {
+			if u, err := url.Parse(root.URI); err == nil && u.Scheme == "file" && u.Path != "" {
+				paths = append(paths, u.Path)
+			}
+		}
+	}
+	c.mu.Lock()
+	c.roots[id] = paths
+	c.mu.Unlock()
+	return paths
+}
+
+// mcpProject returns the active project for an MCP tool call: the project
+// argument when given (a name or an absolute path), otherwise the project
+// containing the first of the client's roots that lies under a project root.
+func mcpProject(ctx context.Context, deps MCPDeps, req mcp.CallToolRequest) (string, error) {
+	if arg := req.GetString("project", ""); arg != "" {
+		return resolveProject(deps.Store, arg)
+	}
+	if deps.roots == nil {
+		return "", nil
+	}
+	for _, path := range deps.roots.paths(ctx) {
+		name, err := deps.Store.ResolveProjectByPath(path)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", nil
+}
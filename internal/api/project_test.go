@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/kalambet/tbyd/internal/retrieval"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func TestProjectEndpoints(t *testing.T) {
+	const token = "test-token"
+	h, store := setupAppHandler(t, token)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	inProject := func(req *http.Request, project string) *http.Request {
+		req.Header.Set(headerProject, project)
+		return req
+	}
+
+	w := serve(authReq("POST", "/projects", `{"name":"tbyd","roots":["/src/tbyd"],"stack":["go","sqlite"]}`, token))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	for _, body := range []string{
+		`{"name":"tbyd"}`,                         // duplicate
+		`{"name":"Bad Name"}`,                     // invalid name
+		`{"name":"rel","roots":["src/relative"]}`, // relative root
+	} {
+		w = serve(authReq("POST", "/projects", body, token))
+		if w.Code != http.StatusConflict && w.Code != http.StatusBadRequest {
+			t.Errorf("create %s: status = %d, want 409 or 400", body, w.Code)
+		}
+	}
+
+	w = serve(authReq("GET", "/projects", "", token))
+	var list []storage.Project
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Name != "tbyd" || len(list[0].Stack) != 2 {
+		t.Errorf("list = %+v, want the tbyd project with its stack", list)
+	}
+
+	// Documents are tagged with the project selected by name or by a path
+	// under one of its roots; other paths select no project.
+	for _, tc := range []struct{ header, want string }{
+		{"tbyd", "tbyd"},
+		{"/src/tbyd/internal/api", "tbyd"},
+		{"/home/me", ""},
+	} {
+		w = serve(inProject(authReq("POST", "/ingest", `{"source":"cli","content":"build notes"}`, token), tc.header))
+		if w.Code != http.StatusOK {
+			t.Fatalf("ingest as %s: status = %d, body = %s", tc.header, w.Code, w.Body.String())
+		}
+		var ingested map[string]string
+		json.NewDecoder(w.Body).Decode(&ingested)
+		doc, err := store.GetContextDoc(ingested["id"])
+		if err != nil || doc.Project != tc.want {
+			t.Errorf("ingest as %s: doc project = %q, %v; want %q", tc.header, doc.Project, err, tc.want)
+		}
+	}
+	w = serve(inProject(authReq("POST", "/ingest", `{"source":"cli","content":"notes"}`, token), "nope"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("ingest into unknown project: status = %d, want 404", w.Code)
+	}
+
+	w = serve(authReq("DELETE", "/projects/tbyd", "", token))
+	if w.Code != http.StatusOK {
+		t.Errorf("delete: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	w = serve(authReq("DELETE", "/projects/tbyd", "", token))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete: status = %d, want 404", w.Code)
+	}
+}
+
+func TestResolveProject(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.CreateProject(storage.Project{Name: "tbyd", Roots: []string{"/src/tbyd"}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"", "", nil},
+		{"tbyd", "tbyd", nil},
+		{" /src/tbyd/cmd ", "tbyd", nil},
+		{"/src/other", "", nil},
+		{"other", "", errUnknownProject},
+	}
+	for _, tt := range tests {
+		got, err := resolveProject(store, tt.value)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("resolveProject(%q) = %q, %v; want %q, %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// staticRoots answers roots/list requests with fixed roots.
+type staticRoots []mcp.Root
+
+func (r staticRoots) ListRoots(context.Context, mcp.ListRootsRequest) (*mcp.ListRootsResult, error) {
+	return &mcp.ListRootsResult{Roots: r}, nil
+}
+
+func TestMCPProjectFromRoots(t *testing.T) {
+	const token = "test-mcp-token"
+	deps, store := newTestMCPDeps(t)
+	retriever := &mockMCPRetriever{}
+	deps.Retriever = retriever
+	if err := store.CreateProject(storage.Project{Name: "tbyd", Roots: []string{"/src/tbyd"}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if err := store.CreateProject(storage.Project{Name: "site", Roots: []string{"/src/site"}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	ts := httptest.NewServer(NewMCPHTTPHandler(NewMCPServer(deps), token))
+	t.Cleanup(ts.Close)
+
+	// The client reports its workspace as a root; the server asks for it
+	// over the client's listening stream.
+	trans, err := transport.NewStreamableHTTP(ts.URL,
+		transport.WithHTTPHeaders(map[string]string{"Authorization": "Bearer " + token}),
+		transport.WithContinuousListening(),
+	)
+	if err != nil {
+		t.Fatalf("creating transport: %v", err)
+	}
+	c := mcpclient.NewClient(trans, mcpclient.WithRootsHandler(staticRoots{{URI: "file:///src/tbyd/internal", Name: "tbyd"}}))
+	ctx := context.Background()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("starting MCP client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if _, err := c.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{
+		ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+		ClientInfo:      mcp.Implementation{Name: "roots-client", Version: "1.0.0"},
+	}}); err != nil {
+		t.Fatalf("initializing MCP client: %v", err)
+	}
+
+	call := func(name string, args map[string]interface{}) {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Name = name
+		req.Params.Arguments = args
+		res, err := c.CallTool(ctx, req)
+		if err != nil || res.IsError {
+			t.Fatalf("%s: err = %v, result = %+v", name, err, res)
+		}
+	}
+
+	call("add_context", map[string]interface{}{"content": "run make build"})
+	docs, err := store.ListContextDocs(10)
+	if err != nil || len(docs) != 1 || docs[0].Project != "tbyd" {
+		t.Errorf("added doc = %+v, %v; want it in the tbyd project", docs, err)
+	}
+
+	call("recall", map[string]interface{}{"query": "build"})
+	call("recall", map[string]interface{}{"query": "build", "project": "site"})
+	want := []retrieval.Scope{
+		retrieval.Scope{}.WithProject("tbyd", false),
+		retrieval.Scope{}.WithProject("site", false),
+	}
+	if len(retriever.scopes) != 2 || retriever.scopes[0] != want[0] || retriever.scopes[1] != want[1] {
+		t.Errorf("recall scopes = %+v, want %+v", retriever.scopes, want)
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/cache"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func chatReq(body, client string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	if client != "" {
+		req.Header.Set(headerClient, client)
+	}
+	return req
+}
+
+func waitForSaves(t *testing.T, saver *mockInteractionSaver, n int) []storage.Interaction {
+	t.Helper()
+	for range n {
+		select {
+		case <-saver.saveDone:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %d interaction saves", n)
+		}
+	}
+	return saver.getInteractions()
+}
+
+func TestResponseCache_NonStreamingHit(t *testing.T) {
+	respJSON := `{"id":"gen-1","model":"test","choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`
+	var calls atomic.Int32
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, respJSON)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 2)}
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, true, nil, OpenAIOptions{Responses: cache.NewResponseCache("ci", time.Hour)})
+
+	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"temperature":0}`
+
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, chatReq(body, "ci"))
+	if got := first.Header().Get(headerResponseCache); got != "miss" {
+		t.Errorf("first %s = %q, want miss", headerResponseCache, got)
+	}
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, chatReq(body, "ci"))
+	if second.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", second.Code, http.StatusOK)
+	}
+	if got := second.Header().Get(headerResponseCache); got != "hit" {
+		t.Errorf("second %s = %q, want hit", headerResponseCache, got)
+	}
+	if second.Body.String() != respJSON {
+		t.Errorf("replayed body = %q, want %q", second.Body.String(), respJSON)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("upstream called %d times, want 1", calls.Load())
+	}
+
+	ixs := waitForSaves(t, saver, 2)
+	source, replay := ixs[0], ixs[1]
+	if replay.Status != "cached" || replay.CachedFrom != source.ID {
+		t.Errorf("replay status/cached_from = %q/%q, want cached/%q", replay.Status, replay.CachedFrom, source.ID)
+	}
+	if replay.ID == source.ID {
+		t.Error("replay reused the source interaction ID")
+	}
+	if got := second.Header().Get(headerCachedFrom); got != source.ID {
+		t.Errorf("%s = %q, want %q", headerCachedFrom, got, source.ID)
+	}
+	if got := second.Header().Get("X-TBYD-Interaction-ID"); got != replay.ID {
+		t.Errorf("interaction ID header = %q, want %q", got, replay.ID)
+	}
+	// Only the source interaction is summarized.
+	if jobs := saver.getJobs(); len(jobs) != 1 {
+		t.Errorf("enqueued %d jobs, want 1", len(jobs))
+	}
+}
+
+func TestResponseCache_StreamingReplay(t *testing.T) {
+	sseData := "data: {\"id\":\"gen-1\",\"model\":\"test\",\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"id\":\"gen-1\",\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	var calls atomic.Int32
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseData)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	saver := &mockInteractionSaver{saveDone: make(chan struct{}, 2)}
+	h, _ := NewOpenAIHandler(ctx, c, nil, saver, true, false, nil, OpenAIOptions{Responses: cache.NewResponseCache("*", time.Hour)})
+
+	body := `{"model":"test","messages":[{"role":"user","content":"hi"}],"stream":true}`
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, chatReq(body, "agent"))
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, chatReq(body, "agent"))
+
+	if calls.Load() != 1 {
+		t.Fatalf("upstream called %d times, want 1", calls.Load())
+	}
+	if ct := second.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	replayed := second.Body.String()
+	if !strings.Contains(replayed, `"content":"Hel"`) || !strings.HasSuffix(replayed, "data: [DONE]\n\n") {
+		t.Errorf("replayed stream = %q, want the upstream events ending in [DONE]", replayed)
+	}
+
+	ixs := waitForSaves(t, saver, 2)
+	replay := ixs[1]
+	// The replay carries its own interaction ID, not the source's.
+	if strings.Count(replayed, "tbyd-metadata") != 1 || !strings.Contains(replayed, replay.ID) {
+		t.Errorf("replayed stream has wrong tbyd-metadata: %q", replayed)
+	}
+	if strings.Contains(replayed, ixs[0].ID) {
+		t.Error("replayed stream leaked the source interaction ID")
+	}
+	if replay.Status != "cached" || !strings.Contains(replay.CloudResponse, "Hello") {
+		t.Errorf("replay = status %q response %q, want cached with reassembled content", replay.Status, replay.CloudResponse)
+	}
+}
+
+func TestResponseCache_Bypass(t *testing.T) {
+	respJSON := `{"id":"gen-1","choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`
+	tests := []struct {
+		name      string
+		client    string
+		header    http.Header
+		wantCalls int32
+	}{
+		{"client not opted in", "other", nil, 2},
+		{"no client header", "", nil, 2},
+		{"no-cache request", "ci", http.Header{"Cache-Control": {"no-cache"}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				fmt.Fprint(w, respJSON)
+			})
+			h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{Responses: cache.NewResponseCache("ci", time.Hour)})
+
+			body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
+			var last *httptest.ResponseRecorder
+			for range 2 {
+				req := chatReq(body, tt.client)
+				for k, v := range tt.header {
+					req.Header[k] = v
+				}
+				last = httptest.NewRecorder()
+				h.ServeHTTP(last, req)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("upstream called %d times, want %d", calls.Load(), tt.wantCalls)
+			}
+			if got := last.Header().Get(headerResponseCache); got == "hit" {
+				t.Errorf("%s = hit, want no hit", headerResponseCache)
+			}
+		})
+	}
+}
+
+func TestResponseCache_ErrorBodyNotCached(t *testing.T) {
+	var calls atomic.Int32
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"error":{"message":"provider overloaded"}}`)
+	})
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{Responses: cache.NewResponseCache("ci", time.Hour)})
+
+	body := `{"model":"test","messages":[{"role":"user","content":"hi"}]}`
+	for range 2 {
+		h.ServeHTTP(httptest.NewRecorder(), chatReq(body, "ci"))
+	}
+	if calls.Load() != 2 {
+		t.Errorf("upstream called %d times, want 2 (error bodies must not be cached)", calls.Load())
+	}
+}
+
+func TestResponseCache_InvalidTTLHeader(t *testing.T) {
+	_, c := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upstream called despite an invalid TTL header")
+	})
+	h, _ := NewOpenAIHandler(context.Background(), c, nil, nil, false, false, nil, OpenAIOptions{Responses: cache.NewResponseCache("ci", time.Hour)})
+
+	req := chatReq(`{"model":"test","messages":[{"role":"user","content":"hi"}]}`, "ci")
+	req.Header.Set(headerCacheTTL, "soon")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kalambet/tbyd/internal/retrieval"
+)
+
+// HybridTuner learns per-intent hybrid search ratios from feedback.
+// *retrieval.HybridTuner satisfies it.
+type HybridTuner interface {
+	RecordFeedback(ctx context.Context, intentType string, legs map[string][]string, previous, score int) error
+	List(ctx context.Context) ([]retrieval.HybridWeight, error)
+	Reset(ctx context.Context, intentType string) error
+}
+
+func handleListHybridWeights(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Tuner == nil {
+			httpError(w, http.StatusNotImplemented, "api_error", "adaptive hybrid search is not enabled")
+			return
+		}
+		weights, err := deps.Tuner.List(r.Context())
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to list hybrid weights: %v", err)
+			return
+		}
+		if weights == nil {
+			weights = []retrieval.HybridWeight{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(weights)
+	}
+}
+
+// handleResetHybridWeights resets one intent type when {intent_type} is in the
+// path, or every intent type otherwise.
+func handleResetHybridWeights(deps AppDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Tuner == nil {
+			httpError(w, http.StatusNotImplemented, "api_error", "adaptive hybrid search is not enabled")
+			return
+		}
+		intentType := chi.URLParam(r, "intent_type")
+		if err := deps.Tuner.Reset(r.Context(), intentType); err != nil {
+			httpError(w, http.StatusInternalServerError, "api_error", "failed to reset hybrid weights: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+	}
+}
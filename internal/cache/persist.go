@@ -0,0 +1,435 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// persistSaveTimeout bounds a single periodic or shutdown save.
+const persistSaveTimeout = 10 * time.Second
+
+// Snapshot is a point-in-time copy of the cache contents for persistence.
+// Entries are ordered oldest first so that reloading preserves eviction order.
+type Snapshot struct {
+	EmbedModel    string
+	ProfileFloors map[string]int64 // per-persona profile version floors at save time
+	Exact         []ExactEntry
+	Semantic      []SemanticEntry
+}
+
+// ExactEntry is one L1 entry keyed by its normalized query hash.
+type ExactEntry struct {
+	QueryHash string
+	Result    CachedEnrichment
+}
+
+// Persister stores and retrieves cache snapshots. Metadata is written as JSON
+// and read back as json.RawMessage, since the cache does not know its type.
+type Persister interface {
+	SaveSnapshot(ctx context.Context, snap Snapshot) error
+	// LoadSnapshot returns false if nothing has been saved yet.
+	LoadSnapshot(ctx context.Context) (Snapshot, bool, error)
+	// Clear discards the stored snapshot.
+	Clear(ctx context.Context) error
+}
+
+// SetPersister attaches a persistence backend. embedModel identifies the
+// embedding model behind semantic entries; snapshots saved under a different
+// model are discarded on Load. If interval is positive the cache is saved
+// periodically, and Stop always writes a final snapshot. Must be called before
+// the cache is used concurrently. No-op when the cache is disabled.
+func (qc *QueryCache) SetPersister(p Persister, embedModel string, interval time.Duration) {
+	if !qc.enabled {
+		return
+	}
+	qc.persister = p
+	qc.embedModel = embedModel
+	if interval > 0 {
+		qc.persistDone = make(chan struct{})
+		go qc.persistLoop(interval)
+	}
+}
+
+// Save writes the live, non-expired cache contents to the persister.
+func (qc *QueryCache) Save(ctx context.Context) error {
+	if qc.persister == nil {
+		return nil
+	}
+
+	// Snapshot under saveMu so a save that races Invalidate either sees the
+	// cleared cache or finishes before Invalidate clears the stored copy.
+	qc.saveMu.Lock()
+	defer qc.saveMu.Unlock()
+	snap := qc.snapshot()
+	if err := qc.persister.SaveSnapshot(ctx, snap); err != nil {
+		return fmt.Errorf("saving query cache: %w", err)
+	}
+	slog.Debug("cache: snapshot saved", "exact", len(snap.Exact), "semantic", len(snap.Semantic))
+	return nil
+}
+
+// Load restores a saved snapshot and returns the number of entries loaded.
+// Entries that have expired, that were already stale by profile version when
+// saved, or that were produced under a different embedding model are dropped.
+// Entries already present in memory are kept over their persisted copies.
+func (qc *QueryCache) Load(ctx context.Context) (int, error) {
+	if qc.persister == nil {
+		return 0, nil
+	}
+	snap, ok, err := qc.persister.LoadSnapshot(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("loading query cache: %w", err)
+	}
+	if !ok {
+		return 0, nil
+	}
+	if snap.EmbedModel != qc.embedModel {
+		slog.Info("cache: discarding persisted entries from a different embedding model",
+			"saved_model", snap.EmbedModel, "current_model", qc.embedModel)
+		return 0, nil
+	}
+
+	now := qc.clock.Now()
+
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	// Profile versions are process-local counters that restart from zero, so
+	// surviving entries are rebased onto their persona's current floor: still
+	// valid now, and invalidated by the next profile change like any other entry.
+	var loaded int
+	for _, e := range snap.Exact {
+		if !now.Before(e.Result.CachedAt.Add(qc.exactTTL)) || e.Result.ProfileVersion < snap.ProfileFloors[e.Result.Persona] {
+			continue
+		}
+		key := exactKey(e.Result.Persona, e.Result.Project, e.QueryHash)
+		if _, exists := qc.exactCache[key]; exists {
+			continue
+		}
+		if len(qc.exactCache) >= qc.maxExactSize {
+			qc.evictOldestExact()
+		}
+		e.Result.ProfileVersion = qc.profileFloors[e.Result.Persona]
+		qc.exactCache[key] = e.Result
+		loaded++
+	}
+	for _, e := range snap.Semantic {
+		if !now.Before(e.CachedAt.Add(qc.semanticTTL)) || e.Result.ProfileVersion < snap.ProfileFloors[e.Result.Persona] || len(e.Embedding) == 0 {
+			continue
+		}
+		e.Result.ProfileVersion = qc.profileFloors[e.Result.Persona]
+		qc.semanticCache[qc.semanticWriteIdx] = e
+		qc.semanticWriteIdx = (qc.semanticWriteIdx + 1) % qc.maxSemanticSize
+		if qc.semanticLen < qc.maxSemanticSize {
+			qc.semanticLen++
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// clearPersisted discards the stored snapshot so that invalidated entries are
+// not restored after a restart, even if the process exits before the next
+// save. Errors are logged: the in-memory cache is already cleared.
+func (qc *QueryCache) clearPersisted() {
+	if qc.persister == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), persistSaveTimeout)
+	defer cancel()
+
+	qc.saveMu.Lock()
+	defer qc.saveMu.Unlock()
+	if err := qc.persister.Clear(ctx); err != nil {
+		slog.Warn("cache: failed to clear persisted entries", "error", err)
+	}
+}
+
+// snapshot copies the live entries under the read lock, oldest first.
+func (qc *QueryCache) snapshot() Snapshot {
+	now := qc.clock.Now()
+
+	qc.mu.RLock()
+	defer qc.mu.RUnlock()
+
+	snap := Snapshot{EmbedModel: qc.embedModel, ProfileFloors: make(map[string]int64, len(qc.profileFloors))}
+	for persona, floor := range qc.profileFloors {
+		snap.ProfileFloors[persona] = floor
+	}
+	for key, entry := range qc.exactCache {
+		if now.Before(entry.CachedAt.Add(qc.exactTTL)) && !qc.staleLocked(entry) {
+			hash := strings.TrimPrefix(key, exactKey(entry.Persona, entry.Project, ""))
+			snap.Exact = append(snap.Exact, ExactEntry{QueryHash: hash, Result: entry})
+		}
+	}
+	sort.Slice(snap.Exact, func(i, j int) bool {
+		return snap.Exact[i].Result.CachedAt.Before(snap.Exact[j].Result.CachedAt)
+	})
+
+	// Once the ring buffer has wrapped, the oldest entry sits at the write cursor.
+	start := 0
+	if qc.semanticLen == qc.maxSemanticSize {
+		start = qc.semanticWriteIdx
+	}
+	for i := 0; i < qc.semanticLen; i++ {
+		entry := qc.semanticCache[(start+i)%qc.maxSemanticSize]
+		if now.Before(entry.CachedAt.Add(qc.semanticTTL)) && !qc.staleLocked(entry.Result) {
+			snap.Semantic = append(snap.Semantic, entry)
+		}
+	}
+	return snap
+}
+
+// persistLoop saves the cache every interval until Stop is called.
+func (qc *QueryCache) persistLoop(interval time.Duration) {
+	defer close(qc.persistDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-qc.stopEviction:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), persistSaveTimeout)
+			if err := qc.Save(ctx); err != nil {
+				slog.Warn("cache: periodic save failed", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Compile-time interface check.
+var _ Persister = (*SQLitePersister)(nil)
+
+// SQLitePersister stores cache snapshots in the query_cache_entries and
+// query_cache_state tables.
+type SQLitePersister struct {
+	db *sql.DB
+}
+
+// NewSQLitePersister creates a SQLitePersister over db.
+func NewSQLitePersister(db *sql.DB) *SQLitePersister {
+	return &SQLitePersister{db: db}
+}
+
+// SaveSnapshot replaces the stored snapshot with snap in one transaction.
+func (p *SQLitePersister) SaveSnapshot(ctx context.Context, snap Snapshot) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM query_cache_entries`); err != nil {
+		return fmt.Errorf("clearing cache entries: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO query_cache_entries
+			(level, seq, query_hash, persona, project, cached_at, profile_version, topics, enriched_request, metadata, embedding, chunk_ids, source_ids)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("preparing cache insert: %w", err)
+	}
+	defer stmt.Close()
+
+	insert := func(level string, seq int, hash string, cachedAt time.Time, r CachedEnrichment, embedding []byte) error {
+		req, err := json.Marshal(r.EnrichedRequest)
+		if err != nil {
+			return fmt.Errorf("encoding enriched request: %w", err)
+		}
+		meta, err := json.Marshal(r.Metadata)
+		if err != nil {
+			return fmt.Errorf("encoding metadata: %w", err)
+		}
+		topics, err := json.Marshal(r.Topics)
+		if err != nil {
+			return fmt.Errorf("encoding topics: %w", err)
+		}
+		chunkIDs, err := json.Marshal(nonNil(r.ChunkIDs))
+		if err != nil {
+			return fmt.Errorf("encoding chunk IDs: %w", err)
+		}
+		sourceIDs, err := json.Marshal(nonNil(r.SourceIDs))
+		if err != nil {
+			return fmt.Errorf("encoding source IDs: %w", err)
+		}
+		_, err = stmt.ExecContext(ctx, level, seq, hash, r.Persona, r.Project, cachedAt.UTC().Format(time.RFC3339Nano),
+			r.ProfileVersion, string(topics), string(req), string(meta), embedding, string(chunkIDs), string(sourceIDs))
+		if err != nil {
+			return fmt.Errorf("inserting %s cache entry: %w", level, err)
+		}
+		return nil
+	}
+
+	for i, e := range snap.Exact {
+		if err := insert("exact", i, e.QueryHash, e.Result.CachedAt, e.Result, nil); err != nil {
+			return err
+		}
+	}
+	for i, e := range snap.Semantic {
+		if err := insert("semantic", i, e.QueryHash, e.CachedAt, e.Result, encodeEmbedding(e.Embedding)); err != nil {
+			return err
+		}
+	}
+
+	floors, err := json.Marshal(snap.ProfileFloors)
+	if err != nil {
+		return fmt.Errorf("encoding profile floors: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO query_cache_state (id, embed_model, profile_floors, saved_at)
+		VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			embed_model = excluded.embed_model,
+			profile_floors = excluded.profile_floors,
+			saved_at = excluded.saved_at`,
+		snap.EmbedModel, string(floors), time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("updating cache state: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Clear deletes the stored entries and state in one transaction.
+func (p *SQLitePersister) Clear(ctx context.Context) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM query_cache_entries`); err != nil {
+		return fmt.Errorf("clearing cache entries: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM query_cache_state`); err != nil {
+		return fmt.Errorf("clearing cache state: %w", err)
+	}
+	return tx.Commit()
+}
+
+// LoadSnapshot reads the stored snapshot. Rows that fail to decode are skipped.
+func (p *SQLitePersister) LoadSnapshot(ctx context.Context) (Snapshot, bool, error) {
+	var snap Snapshot
+	var floors string
+	err := p.db.QueryRowContext(ctx,
+		`SELECT embed_model, profile_floors FROM query_cache_state WHERE id = 1`,
+	).Scan(&snap.EmbedModel, &floors)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("reading cache state: %w", err)
+	}
+	if err := json.Unmarshal([]byte(floors), &snap.ProfileFloors); err != nil {
+		return Snapshot{}, false, fmt.Errorf("decoding profile floors: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT level, query_hash, persona, project, cached_at, profile_version, topics, enriched_request, metadata, embedding, chunk_ids, source_ids
+		FROM query_cache_entries ORDER BY level, seq`)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("reading cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var level, hash, persona, project, cachedAt, topics, req, meta, chunkIDs, sourceIDs string
+		var version int64
+		var embedding []byte
+		if err := rows.Scan(&level, &hash, &persona, &project, &cachedAt, &version, &topics, &req, &meta, &embedding, &chunkIDs, &sourceIDs); err != nil {
+			return Snapshot{}, false, fmt.Errorf("scanning cache entry: %w", err)
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, cachedAt)
+		if err != nil {
+			slog.Warn("cache: skipping persisted entry with bad timestamp", "query_hash", hash, "error", err)
+			continue
+		}
+		result := CachedEnrichment{
+			Metadata:       json.RawMessage(meta),
+			CachedAt:       ts,
+			Persona:        persona,
+			Project:        project,
+			ProfileVersion: version,
+		}
+		if err := json.Unmarshal([]byte(req), &result.EnrichedRequest); err != nil {
+			slog.Warn("cache: skipping persisted entry with bad request", "query_hash", hash, "error", err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(topics), &result.Topics); err != nil {
+			slog.Warn("cache: skipping persisted entry with bad topics", "query_hash", hash, "error", err)
+			continue
+		}
+		// An entry whose sources cannot be read could not be evicted when
+		// that content is deleted, so it is dropped rather than restored.
+		if err := json.Unmarshal([]byte(chunkIDs), &result.ChunkIDs); err != nil {
+			slog.Warn("cache: skipping persisted entry with bad chunk IDs", "query_hash", hash, "error", err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(sourceIDs), &result.SourceIDs); err != nil {
+			slog.Warn("cache: skipping persisted entry with bad source IDs", "query_hash", hash, "error", err)
+			continue
+		}
+
+		switch level {
+		case "exact":
+			snap.Exact = append(snap.Exact, ExactEntry{QueryHash: hash, Result: result})
+		case "semantic":
+			vec, err := decodeEmbedding(embedding)
+			if err != nil {
+				slog.Warn("cache: skipping persisted entry with bad embedding", "query_hash", hash, "error", err)
+				continue
+			}
+			snap.Semantic = append(snap.Semantic, SemanticEntry{
+				QueryHash: hash,
+				Embedding: vec,
+				Result:    result,
+				CachedAt:  ts,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Snapshot{}, false, fmt.Errorf("reading cache entries: %w", err)
+	}
+	return snap, true, nil
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// encodeEmbedding packs v as little-endian float32s, matching context_vectors.
+func encodeEmbedding(v []float32) []byte {
+	buf := make([]byte, len(v)*4)
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeEmbedding(b []byte) ([]float32, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("embedding blob length %d is not a multiple of 4", len(b))
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v, nil
+}
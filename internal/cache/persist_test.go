@@ -0,0 +1,337 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+func openPersister(t *testing.T) *SQLitePersister {
+	t.Helper()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open(:memory:) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewSQLitePersister(store.DB())
+}
+
+func unitVec(i int) []float32 {
+	v := make([]float32, 8)
+	v[i%8] = 1
+	return v
+}
+
+// newPersistentCache builds a cache with an attached persister and no
+// periodic save loop.
+func newPersistentCache(p Persister, model string, clock Clock) *QueryCache {
+	qc := NewQueryCacheWithClock(fixedEmbedding(unitVec(0)), true, 0.92, 5*time.Minute, 30*time.Minute, clock)
+	qc.SetPersister(p, model, 0)
+	return qc
+}
+
+func TestPersist_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "nomic-embed-text", clock)
+	src.Set(ctx, "what is my stack", unitVec(0), CachedEnrichment{
+		EnrichedRequest: makeEntry("", nil).EnrichedRequest,
+		Metadata:        map[string]any{"IntentType": "recall"},
+		Topics:          []string{"go"},
+	})
+	if err := src.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	dst := newPersistentCache(p, "nomic-embed-text", clock)
+	n, err := dst.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Load restored %d entries, want 2 (exact + semantic)", n)
+	}
+
+	r := dst.Get(ctx, "What is my   stack")
+	if !r.Hit || r.CacheLevel != "exact" {
+		t.Fatalf("Get after load = hit %v level %q, want exact hit", r.Hit, r.CacheLevel)
+	}
+	if r.Entry.EnrichedRequest.Model != "test" {
+		t.Errorf("EnrichedRequest.Model = %q, want test", r.Entry.EnrichedRequest.Model)
+	}
+	if len(r.Entry.Topics) != 1 || r.Entry.Topics[0] != "go" {
+		t.Errorf("Topics = %v, want [go]", r.Entry.Topics)
+	}
+	raw, ok := r.Entry.Metadata.(json.RawMessage)
+	if !ok {
+		t.Fatalf("Metadata type = %T, want json.RawMessage", r.Entry.Metadata)
+	}
+	var meta map[string]any
+	if err := json.Unmarshal(raw, &meta); err != nil || meta["IntentType"] != "recall" {
+		t.Errorf("Metadata = %s, want IntentType recall", raw)
+	}
+
+	// The semantic entry serves a paraphrase with the same embedding.
+	if r := dst.Get(ctx, "which stack do I use"); !r.Hit || r.CacheLevel != "semantic" {
+		t.Errorf("paraphrase after load = hit %v level %q, want semantic hit", r.Hit, r.CacheLevel)
+	}
+}
+
+func TestPersist_DropsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "m", clock)
+	src.Set(ctx, "q", unitVec(0), makeEntry("r", nil))
+	if err := src.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Past the 5m exact TTL but within the 30m semantic TTL.
+	clock.Advance(10 * time.Minute)
+	dst := newPersistentCache(p, "m", clock)
+	n, err := dst.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Load restored %d entries, want 1 (semantic only)", n)
+	}
+	if len(dst.exactCache) != 0 {
+		t.Errorf("exact cache has %d entries, want 0", len(dst.exactCache))
+	}
+
+	clock.Advance(30 * time.Minute)
+	dst = newPersistentCache(p, "m", clock)
+	if n, _ := dst.Load(ctx); n != 0 {
+		t.Errorf("Load after semantic TTL restored %d entries, want 0", n)
+	}
+}
+
+func TestPersist_DropsOtherEmbeddingModel(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "nomic-embed-text", clock)
+	src.Set(ctx, "q", unitVec(0), makeEntry("r", nil))
+	if err := src.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	dst := newPersistentCache(p, "mxbai-embed-large", clock)
+	n, err := dst.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Load restored %d entries from a different model, want 0", n)
+	}
+}
+
+// stubPersister returns a fixed snapshot.
+type stubPersister struct {
+	snap  Snapshot
+	saved []Snapshot
+}
+
+func (s *stubPersister) SaveSnapshot(_ context.Context, snap Snapshot) error {
+	s.saved = append(s.saved, snap)
+	return nil
+}
+
+func (s *stubPersister) LoadSnapshot(context.Context) (Snapshot, bool, error) {
+	return s.snap, true, nil
+}
+
+func (s *stubPersister) Clear(context.Context) error {
+	s.snap = Snapshot{}
+	return nil
+}
+
+func TestPersist_RespectsProfileFloors(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	clock := &mockClock{now: now}
+
+	entry := func(version int64) CachedEnrichment {
+		e := makeEntry("r", nil)
+		e.CachedAt = now
+		e.ProfileVersion = version
+		return e
+	}
+	p := &stubPersister{snap: Snapshot{
+		EmbedModel:    "m",
+		ProfileFloors: map[string]int64{"": 3},
+		Exact: []ExactEntry{
+			{QueryHash: hashQuery("stale"), Result: entry(2)},
+			{QueryHash: hashQuery("fresh"), Result: entry(3)},
+		},
+		Semantic: []SemanticEntry{
+			{QueryHash: hashQuery("stale"), Embedding: unitVec(1), Result: entry(2), CachedAt: now},
+		},
+	}}
+
+	qc := newPersistentCache(p, "m", clock)
+	n, err := qc.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Load restored %d entries, want 1", n)
+	}
+	if r := qc.Get(ctx, "fresh"); !r.Hit {
+		t.Error("entry at the saved floor was not restored")
+	}
+	if _, ok := qc.exactCache[hashQuery("stale")]; ok {
+		t.Error("entry below the saved floor was restored")
+	}
+
+	// Restored entries are rebased onto this process's version counter so the
+	// next InvalidateProfile rejects them like any other entry.
+	if got := qc.exactCache[hashQuery("fresh")].ProfileVersion; got != qc.profileFloors[""] {
+		t.Errorf("restored ProfileVersion = %d, want rebased to %d", got, qc.profileFloors[""])
+	}
+}
+
+func TestPersist_SnapshotPreservesRingOrder(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	p := &stubPersister{}
+	qc := newPersistentCache(p, "m", clock)
+	qc.maxSemanticSize = 3
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Second)
+		qc.Set(context.Background(), fmt.Sprintf("query %d", i), unitVec(i), makeEntry(fmt.Sprintf("r%d", i), nil))
+	}
+
+	snap := qc.snapshot()
+	if len(snap.Semantic) != 3 {
+		t.Fatalf("snapshot has %d semantic entries, want 3", len(snap.Semantic))
+	}
+	for i, want := range []string{"r2", "r3", "r4"} {
+		if got := snap.Semantic[i].Result.Metadata; got != want {
+			t.Errorf("semantic[%d] = %v, want %s (oldest first)", i, got, want)
+		}
+	}
+	for i := 1; i < len(snap.Exact); i++ {
+		if snap.Exact[i].Result.CachedAt.Before(snap.Exact[i-1].Result.CachedAt) {
+			t.Errorf("exact entries not ordered oldest first at %d", i)
+		}
+	}
+}
+
+func TestPersist_StopSaves(t *testing.T) {
+	p := &stubPersister{}
+	qc := newPersistentCache(p, "m", &mockClock{now: time.Now()})
+	qc.Set(context.Background(), "q", unitVec(0), makeEntry("r", nil))
+
+	qc.Stop()
+	qc.Stop() // second Stop must not save again
+
+	if len(p.saved) != 1 {
+		t.Fatalf("Stop saved %d snapshots, want 1", len(p.saved))
+	}
+	if len(p.saved[0].Exact) != 1 || len(p.saved[0].Semantic) != 1 {
+		t.Errorf("saved snapshot = %d exact, %d semantic; want 1 and 1", len(p.saved[0].Exact), len(p.saved[0].Semantic))
+	}
+}
+
+func TestPersist_InvalidateThenCrash(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "m", clock)
+	src.Set(ctx, "q", unitVec(0), makeEntry("r", nil))
+	if err := src.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Invalidate, then exit without Stop or another Save.
+	src.Invalidate()
+
+	if _, ok, err := p.LoadSnapshot(ctx); err != nil || ok {
+		t.Fatalf("LoadSnapshot after Invalidate = (ok %v, err %v), want nothing stored", ok, err)
+	}
+	dst := newPersistentCache(p, "m", clock)
+	if n, err := dst.Load(ctx); err != nil || n != 0 {
+		t.Fatalf("Load after invalidate-then-crash = (%d, %v), want 0 entries", n, err)
+	}
+	if r := dst.Get(ctx, "q"); r.Hit {
+		t.Error("invalidated entry served after restart")
+	}
+}
+
+func TestPersist_SaveAfterInvalidateStoresNothingStale(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "m", clock)
+	src.Set(ctx, "stale", unitVec(0), makeEntry("r", nil))
+	src.InvalidateProfile("", 1)
+	fresh := makeEntry("r", nil)
+	fresh.ProfileVersion = 1 // built after the profile change
+	src.Set(ctx, "fresh", unitVec(1), fresh)
+	src.Stop()
+
+	dst := newPersistentCache(p, "m", clock)
+	if _, err := dst.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := dst.exactCache[hashQuery("stale")]; ok {
+		t.Error("entry cached before the profile change was restored")
+	}
+	if r := dst.Get(ctx, "fresh"); !r.Hit {
+		t.Error("entry cached after the profile change was not restored")
+	}
+}
+
+func TestPersist_ScopedEvictionSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	p := openPersister(t)
+
+	src := newPersistentCache(p, "m", clock)
+	deleted := makeEntry("deleted", nil)
+	deleted.ChunkIDs, deleted.SourceIDs = []string{"vec-1"}, []string{"doc-1"}
+	kept := makeEntry("kept", nil)
+	kept.ChunkIDs, kept.SourceIDs = []string{"vec-2"}, []string{"doc-2"}
+	src.Set(ctx, "deleted query", unitVec(1), deleted)
+	src.Set(ctx, "kept query", unitVec(2), kept)
+	if err := src.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Evict, then exit without Stop.
+	src.InvalidateBySources([]string{"doc-1"})
+
+	dst := newPersistentCache(p, "m", clock)
+	if _, err := dst.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if r := dst.Get(ctx, "deleted query"); r.Hit {
+		t.Error("entry built from deleted content restored after restart")
+	}
+	r := dst.Get(ctx, "kept query")
+	if !r.Hit {
+		t.Fatal("unrelated entry not restored")
+	}
+	if len(r.Entry.ChunkIDs) != 1 || r.Entry.ChunkIDs[0] != "vec-2" || len(r.Entry.SourceIDs) != 1 || r.Entry.SourceIDs[0] != "doc-2" {
+		t.Errorf("restored refs = %v / %v, want [vec-2] / [doc-2]", r.Entry.ChunkIDs, r.Entry.SourceIDs)
+	}
+
+	// Restored refs still drive eviction.
+	dst.InvalidateBySources([]string{"vec-2"})
+	if r := dst.Get(ctx, "kept query"); r.Hit {
+		t.Error("restored entry not evicted by its chunk ID")
+	}
+}
@@ -24,7 +24,12 @@ type CachedEnrichment struct {
 	Metadata        any       // pipeline.EnrichmentMetadata stored opaquely to avoid import cycle
 	CachedAt        time.Time
 	Topics          []string // intent topics for selective invalidation
-	ProfileVersion  int64    // profile version at enrichment time; entries below cache min are stale
+	ChunkIDs        []string // vector IDs of the context chunks the enrichment used
+	SourceIDs       []string // context docs and interactions those chunks came from
+	Persona         string   // persona the enrichment was built for; "" is the default persona
+	Project         string   // project workspace active for the enrichment; "" is none
+	ProfileVersion  int64    // persona's profile version at enrichment time; entries below its floor are stale
+	Generation      uint64   // cache generation at lookup time (CacheResult.Generation); Set drops older entries
 }
 
 // SemanticEntry pairs a pre-normalized query embedding with its cached result.
@@ -69,10 +74,19 @@ type QueryCache struct {
 	semThreshold     float64
 	exactTTL         time.Duration
 	semanticTTL      time.Duration
-	maxSemanticSize   int
-	maxExactSize      int
-	stopEviction      chan struct{}
-	minProfileVersion int64 // bumped on Invalidate(); rejects entries with older ProfileVersion
+	maxSemanticSize  int
+	maxExactSize     int
+	stopEviction     chan struct{}
+	profileFloors    map[string]int64 // per persona; raised by InvalidateProfile, rejects entries with older ProfileVersion
+	generation       uint64           // bumped by Invalidate and InvalidateBySources; Set rejects entries from older generations
+
+	// Optional persistence; see SetPersister.
+	persister   Persister
+	embedModel  string
+	persistDone chan struct{} // closed when persistLoop exits; nil if no loop
+	saveMu      sync.Mutex    // serializes snapshot writes
+
+	counters cacheCounters // see Stats
 }
 
 // NewQueryCache creates a QueryCache. If enabled is false, Get always misses.
@@ -91,6 +105,7 @@ func NewQueryCache(embedder Embedder, enabled bool, semThreshold float64, exactT
 		maxSemanticSize: defaultMaxSemanticSize,
 		maxExactSize:    defaultMaxExactSize,
 		stopEviction:    make(chan struct{}),
+		profileFloors:   make(map[string]int64),
 	}
 
 	if enabled {
@@ -115,16 +130,30 @@ func NewQueryCacheWithClock(embedder Embedder, enabled bool, semThreshold float6
 		maxSemanticSize: defaultMaxSemanticSize,
 		maxExactSize:    defaultMaxExactSize,
 		stopEviction:    make(chan struct{}),
+		profileFloors:   make(map[string]int64),
 	}
 }
 
-// Stop terminates the background eviction goroutine.
+// Stop terminates the background goroutines and, if a persister is attached,
+// writes a final snapshot.
 func (qc *QueryCache) Stop() {
 	select {
 	case <-qc.stopEviction:
+		return
 	default:
 		close(qc.stopEviction)
 	}
+
+	if qc.persistDone != nil {
+		<-qc.persistDone
+	}
+	if qc.persister != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), persistSaveTimeout)
+		defer cancel()
+		if err := qc.Save(ctx); err != nil {
+			slog.Warn("cache: save on shutdown failed", "error", err)
+		}
+	}
 }
 
 // CacheResult holds the result of a cache lookup.
@@ -133,12 +162,22 @@ type CacheResult struct {
 	Embedding  []float32 // query embedding computed for L2 (reusable on miss)
 	Hit        bool
 	CacheLevel string // "exact" or "semantic"
+	Generation uint64 // cache generation when the lookup started; stamp it on the entry passed to Set
 }
 
-// Get checks the cache for a matching entry. Returns a CacheResult with the
-// cached entry, the query embedding (computed for L2 lookup, reusable by caller
-// on miss), hit status, and cache level.
+// Get checks the cache for a matching entry for the default persona with no
+// active project. Returns a CacheResult with the cached entry, the query
+// embedding (computed for L2 lookup, reusable by caller on miss), hit status,
+// cache level and, on a miss, the generation to stamp on the entry passed to
+// Set.
 func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
+	return qc.GetFor(ctx, "", "", query)
+}
+
+// GetFor is Get for the given persona and project. Entries built for other
+// personas or projects never match, since they embed a different profile or
+// were retrieved with a different project preference.
+func (qc *QueryCache) GetFor(ctx context.Context, persona, project, query string) CacheResult {
 	if !qc.enabled {
 		return CacheResult{}
 	}
@@ -149,12 +188,15 @@ func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
 
 	// L1: exact match.
 	qc.mu.RLock()
-	if entry, ok := qc.exactCache[hash]; ok && now.Before(entry.CachedAt.Add(qc.exactTTL)) && entry.ProfileVersion >= qc.minProfileVersion {
+	gen := qc.generation
+	if entry, ok := qc.exactCache[exactKey(persona, project, hash)]; ok && now.Before(entry.CachedAt.Add(qc.exactTTL)) && !qc.staleLocked(entry) {
 		qc.mu.RUnlock()
+		qc.counters.exactHits.Add(1)
 		slog.Debug("cache: L1 exact hit", "query_hash", hash[:12])
 		return CacheResult{Entry: entry, Hit: true, CacheLevel: "exact"}
 	}
 	qc.mu.RUnlock()
+	qc.counters.exactMisses.Add(1)
 
 	// L2: semantic match — embed the query once.
 	//
@@ -166,7 +208,8 @@ func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
 	embedding, err := qc.embedder.Embed(ctx, query)
 	if err != nil {
 		slog.Warn("cache: embedding for semantic lookup failed", "error", err)
-		return CacheResult{}
+		qc.counters.semanticMisses.Add(1)
+		return CacheResult{Generation: gen}
 	}
 
 	// Normalize query vector once for dot-product scan against pre-normalized
@@ -175,7 +218,8 @@ func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
 	normQuery := unitNormalize(embedding)
 	if normQuery == nil {
 		slog.Warn("cache: zero-norm query embedding, skipping L2")
-		return CacheResult{Embedding: embedding}
+		qc.counters.semanticMisses.Add(1)
+		return CacheResult{Embedding: embedding, Generation: gen}
 	}
 
 	qc.mu.RLock()
@@ -189,7 +233,7 @@ func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
 		if now.After(entry.CachedAt.Add(qc.semanticTTL)) {
 			continue
 		}
-		if entry.Result.ProfileVersion < qc.minProfileVersion {
+		if entry.Result.Persona != persona || entry.Result.Project != project || qc.staleLocked(entry.Result) {
 			continue
 		}
 		sim := dotProduct(normQuery, entry.Embedding)
@@ -200,15 +244,21 @@ func (qc *QueryCache) Get(ctx context.Context, query string) CacheResult {
 	}
 
 	if bestEntry != nil {
+		qc.counters.semanticHits.Add(1)
+		qc.counters.recordSimilarity(bestSim)
 		slog.Debug("cache: L2 semantic hit", "similarity", bestSim, "query_hash", bestEntry.QueryHash[:12])
 		return CacheResult{Entry: bestEntry.Result, Embedding: embedding, Hit: true, CacheLevel: "semantic"}
 	}
 
+	qc.counters.semanticMisses.Add(1)
 	slog.Debug("cache: miss", "query_hash", hash[:12])
-	return CacheResult{Embedding: embedding}
+	return CacheResult{Embedding: embedding, Generation: gen}
 }
 
-// Set stores a result in both exact and semantic caches.
+// Set stores a result in both exact and semantic caches, scoped to
+// result.Persona and result.Project. A result whose Generation predates the
+// last Invalidate or InvalidateBySources is dropped: its enrichment was in
+// flight during the invalidation and may embed content deleted since.
 func (qc *QueryCache) Set(_ context.Context, query string, queryEmbedding []float32, result CachedEnrichment) {
 	if !qc.enabled {
 		return
@@ -223,11 +273,16 @@ func (qc *QueryCache) Set(_ context.Context, query string, queryEmbedding []floa
 	qc.mu.Lock()
 	defer qc.mu.Unlock()
 
+	if result.Generation < qc.generation {
+		slog.Debug("cache: dropping enrichment started before an invalidation", "query_hash", hash[:12])
+		return
+	}
+
 	// Evict oldest exact-cache entry if at capacity.
 	if len(qc.exactCache) >= qc.maxExactSize {
 		qc.evictOldestExact()
 	}
-	qc.exactCache[hash] = result
+	qc.exactCache[exactKey(result.Persona, result.Project, hash)] = result
 
 	if len(queryEmbedding) > 0 {
 		norm := unitNormalize(queryEmbedding)
@@ -235,6 +290,9 @@ func (qc *QueryCache) Set(_ context.Context, query string, queryEmbedding []floa
 			return // zero-norm vector; skip semantic cache
 		}
 		// Ring buffer: overwrite at cursor, advance with wrap.
+		if qc.semanticLen == qc.maxSemanticSize {
+			qc.counters.capacity.Add(1)
+		}
 		qc.semanticCache[qc.semanticWriteIdx] = SemanticEntry{
 			QueryHash: hash,
 			Embedding: norm,
@@ -248,11 +306,13 @@ func (qc *QueryCache) Set(_ context.Context, query string, queryEmbedding []floa
 	}
 }
 
-// Invalidate clears both caches entirely.
-func (qc *QueryCache) Invalidate() {
+// Invalidate clears both caches entirely, including any persisted snapshot,
+// and returns the number of entries removed.
+func (qc *QueryCache) Invalidate() int {
 	qc.mu.Lock()
-	defer qc.mu.Unlock()
-
+	qc.generation++
+	n := len(qc.exactCache) + qc.semanticLen
+	qc.counters.invalidated.Add(int64(n))
 	qc.exactCache = make(map[string]CachedEnrichment)
 	// Zero ring buffer entries for GC; keep pre-allocated backing array.
 	for i := 0; i < qc.semanticLen; i++ {
@@ -260,15 +320,50 @@ func (qc *QueryCache) Invalidate() {
 	}
 	qc.semanticLen = 0
 	qc.semanticWriteIdx = 0
-	qc.minProfileVersion++
+	qc.mu.Unlock()
+
+	qc.clearPersisted()
+	return n
+}
+
+// InvalidateProfile evicts every entry built for persona and rejects entries
+// stamped with a profile version below version, so that an enrichment which
+// started before the profile change cannot be stored and served afterwards.
+// Entries for other personas are unaffected.
+func (qc *QueryCache) InvalidateProfile(persona string, version int64) {
+	qc.mu.Lock()
+	if version > qc.profileFloors[persona] {
+		qc.profileFloors[persona] = version
+	}
+	qc.mu.Unlock()
+
+	n := qc.evictWhere(func(r CachedEnrichment) bool { return r.Persona == persona })
+	qc.persistEviction(n)
+}
+
+// staleLocked reports whether r was built with an outdated profile. Called
+// with qc.mu held.
+func (qc *QueryCache) staleLocked(r CachedEnrichment) bool {
+	return r.ProfileVersion < qc.profileFloors[r.Persona]
+}
+
+// exactKey returns the L1 map key for a query hash. The default persona with
+// no project keeps the bare hash. Persona and project names cannot contain
+// "@" or "/".
+func exactKey(persona, project, hash string) string {
+	if project != "" {
+		persona += "@" + project
+	}
+	if persona == "" {
+		return hash
+	}
+	return persona + "/" + hash
 }
 
 // InvalidateByTopics selectively evicts entries whose intent topics overlap
 // with the given topics, plus entries with no topic metadata (can't prove safe).
-//
-// NOTE: currently unused in production; profile changes use full Invalidate()
-// because profile fields affect all enrichments. Reserved for future use when
-// context-doc updates need topic-scoped cache eviction.
+// The ingest worker calls it with a new doc's tags; profile changes use
+// InvalidateProfile because profile fields affect all of a persona's enrichments.
 func (qc *QueryCache) InvalidateByTopics(topics []string) {
 	if len(topics) == 0 {
 		qc.Invalidate()
@@ -279,32 +374,95 @@ func (qc *QueryCache) InvalidateByTopics(topics []string) {
 	for _, t := range topics {
 		topicSet[strings.ToLower(t)] = struct{}{}
 	}
+	n := qc.evictWhere(func(r CachedEnrichment) bool { return shouldEvict(r.Topics, topicSet) })
+	qc.persistEviction(n)
+}
+
+// InvalidateBySources evicts entries that used any of the given vector IDs or
+// that drew context from any of the given doc or interaction IDs. Called when
+// content is deleted so that enrichments embedding it are never served again.
+func (qc *QueryCache) InvalidateBySources(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	idSet := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		idSet[id] = struct{}{}
+	}
+	// In-flight enrichments cannot be checked against ids until they are
+	// stored, so every one of them is dropped.
+	qc.mu.Lock()
+	qc.generation++
+	qc.mu.Unlock()
+	n := qc.evictWhere(func(r CachedEnrichment) bool {
+		return containsAny(r.ChunkIDs, idSet) || containsAny(r.SourceIDs, idSet)
+	})
+	qc.persistEviction(n)
+}
 
+// evictWhere removes every entry matching evict, counts them as invalidated,
+// and returns how many were removed. The semantic ring buffer is compacted oldest first, so eviction
+// order is preserved.
+func (qc *QueryCache) evictWhere(evict func(CachedEnrichment) bool) int {
 	qc.mu.Lock()
 	defer qc.mu.Unlock()
 
-	// Evict from exact cache.
+	var n int
+	defer func() { qc.counters.invalidated.Add(int64(n)) }()
+
 	for hash, entry := range qc.exactCache {
-		if shouldEvict(entry.Topics, topicSet) {
+		if evict(entry) {
 			delete(qc.exactCache, hash)
+			n++
 		}
 	}
 
-	// Compact ring buffer in-place, removing evicted entries.
-	writePos := 0
+	// Once the ring buffer has wrapped, the oldest entry sits at the write cursor.
+	start := 0
+	if qc.semanticLen == qc.maxSemanticSize {
+		start = qc.semanticWriteIdx
+	}
+	kept := make([]SemanticEntry, 0, qc.semanticLen)
 	for i := 0; i < qc.semanticLen; i++ {
-		if !shouldEvict(qc.semanticCache[i].Result.Topics, topicSet) {
-			if writePos != i {
-				qc.semanticCache[writePos] = qc.semanticCache[i]
-			}
-			writePos++
+		entry := qc.semanticCache[(start+i)%qc.maxSemanticSize]
+		if evict(entry.Result) {
+			n++
+			continue
 		}
+		kept = append(kept, entry)
 	}
-	for i := writePos; i < qc.semanticLen; i++ {
+	if len(kept) == qc.semanticLen {
+		return n
+	}
+	for i := range qc.semanticCache[:qc.semanticLen] {
 		qc.semanticCache[i] = SemanticEntry{}
 	}
-	qc.semanticLen = writePos
-	qc.semanticWriteIdx = writePos % qc.maxSemanticSize
+	copy(qc.semanticCache, kept)
+	qc.semanticLen = len(kept)
+	qc.semanticWriteIdx = len(kept) % qc.maxSemanticSize
+	return n
+}
+
+// persistEviction rewrites the persisted snapshot after a scoped eviction so
+// that evicted entries are not restored after a restart.
+func (qc *QueryCache) persistEviction(evicted int) {
+	if qc.persister == nil || evicted == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), persistSaveTimeout)
+	defer cancel()
+	if err := qc.Save(ctx); err != nil {
+		slog.Warn("cache: failed to persist eviction", "evicted", evicted, "error", err)
+	}
+}
+
+func containsAny(ids []string, set map[string]struct{}) bool {
+	for _, id := range ids {
+		if _, ok := set[id]; ok {
+			return true
+		}
+	}
+	return false
 }
 
 // shouldEvict returns true if the entry should be evicted: either has no topics
@@ -345,6 +503,7 @@ func (qc *QueryCache) evictExpired() {
 	for hash, entry := range qc.exactCache {
 		if now.After(entry.CachedAt.Add(qc.exactTTL)) {
 			delete(qc.exactCache, hash)
+			qc.counters.expired.Add(1)
 		}
 	}
 
@@ -361,6 +520,7 @@ func (qc *QueryCache) evictExpired() {
 	for i := writePos; i < qc.semanticLen; i++ {
 		qc.semanticCache[i] = SemanticEntry{}
 	}
+	qc.counters.expired.Add(int64(qc.semanticLen - writePos))
 	qc.semanticLen = writePos
 	qc.semanticWriteIdx = writePos % qc.maxSemanticSize
 }
@@ -380,6 +540,7 @@ func (qc *QueryCache) evictOldestExact() {
 	}
 	if !first {
 		delete(qc.exactCache, oldestHash)
+		qc.counters.capacity.Add(1)
 	}
 }
 
@@ -181,7 +181,13 @@ func TestInvalidate(t *testing.T) {
 	qc.Set(context.Background(), "query1", vec, makeEntry("r1", nil))
 	qc.Set(context.Background(), "query2", vec, makeEntry("r2", nil))
 
-	qc.Invalidate()
+	before := qc.Stats()
+	if n := qc.Invalidate(); n == 0 || n != before.Exact.Entries+before.Semantic.Entries {
+		t.Errorf("Invalidate() = %d, want the %d cached entries", n, before.Exact.Entries+before.Semantic.Entries)
+	}
+	if n := qc.Invalidate(); n != 0 {
+		t.Errorf("second Invalidate() = %d, want 0", n)
+	}
 
 	r1 := qc.Get(context.Background(), "query1")
 	r2 := qc.Get(context.Background(), "query2")
@@ -238,6 +244,82 @@ func TestInvalidateByTopics_SelectiveEviction(t *testing.T) {
 	}
 }
 
+func TestInvalidateBySources_EvictsEntriesUsingDeletedContent(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	paraphrases := map[string][]float32{"chunk paraphrase": unitVec(0), "source paraphrase": unitVec(1)}
+	emb := &mockEmbedder{embedFn: func(_ context.Context, text string) ([]float32, error) {
+		if v, ok := paraphrases[text]; ok {
+			return v, nil
+		}
+		return unitVec(7), nil
+	}}
+	qc := NewQueryCacheWithClock(emb, true, 0.92, 5*time.Minute, 30*time.Minute, clock)
+
+	byChunk := makeEntry("by chunk", nil)
+	byChunk.ChunkIDs = []string{"vec-1"}
+	bySource := makeEntry("by source", nil)
+	bySource.SourceIDs = []string{"doc-1"}
+	other := makeEntry("other", nil)
+	other.ChunkIDs, other.SourceIDs = []string{"vec-2"}, []string{"doc-2"}
+
+	qc.Set(ctx, "chunk query", unitVec(0), byChunk)
+	qc.Set(ctx, "source query", unitVec(1), bySource)
+	qc.Set(ctx, "other query", unitVec(2), other)
+
+	qc.InvalidateBySources([]string{"doc-1", "vec-1"})
+
+	// Neither L1 nor L2 (a paraphrase) may serve the evicted entries.
+	for _, q := range []string{"chunk query", "source query", "chunk paraphrase", "source paraphrase"} {
+		if r := qc.Get(ctx, q); r.Hit {
+			t.Errorf("%q served after its content was deleted", q)
+		}
+	}
+	if r := qc.Get(ctx, "other query"); !r.Hit {
+		t.Error("unrelated entry was evicted")
+	}
+}
+
+func TestInvalidateBySources_PreservesRingOrder(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	qc := NewQueryCacheWithClock(fixedEmbedding(unitVec(7)), true, 0.92, 5*time.Minute, 30*time.Minute, clock)
+	qc.maxSemanticSize = 4
+	qc.semanticCache = make([]SemanticEntry, 4)
+
+	// Six inserts into a ring of four: r2..r5 remain, oldest at the cursor.
+	for i := 0; i < 6; i++ {
+		clock.Advance(time.Second)
+		e := makeEntry(fmt.Sprintf("r%d", i), nil)
+		e.SourceIDs = []string{fmt.Sprintf("doc-%d", i)}
+		qc.Set(context.Background(), fmt.Sprintf("query %d", i), unitVec(i), e)
+	}
+	qc.InvalidateBySources([]string{"doc-3"})
+
+	snap := qc.snapshot()
+	want := []string{"r2", "r4", "r5"}
+	if len(snap.Semantic) != len(want) {
+		t.Fatalf("got %d semantic entries, want %d", len(snap.Semantic), len(want))
+	}
+	for i, w := range want {
+		if got := snap.Semantic[i].Result.Metadata; got != w {
+			t.Errorf("semantic[%d] = %v, want %s (oldest first)", i, got, w)
+		}
+	}
+
+	// The next insert evicts nothing while there is room, then the oldest.
+	gen := qc.Get(context.Background(), "query 6").Generation
+	for i, name := range []string{"r6", "r7"} {
+		clock.Advance(time.Second)
+		e := makeEntry(name, nil)
+		e.Generation = gen
+		qc.Set(context.Background(), fmt.Sprintf("query %d", 6+i), unitVec((6+i)%7), e)
+	}
+	snap = qc.snapshot()
+	if got := snap.Semantic[0].Result.Metadata; got != "r4" {
+		t.Errorf("oldest after refill = %v, want r4", got)
+	}
+}
+
 func TestInvalidateByTopics_NoMetadataEvicted(t *testing.T) {
 	clock := &mockClock{now: time.Now()}
 	emb := fixedEmbedding(make([]float32, 768))
@@ -457,19 +539,57 @@ func TestProfileVersionRejectsStaleExactEntries(t *testing.T) {
 		t.Fatal("expected cache hit before invalidation")
 	}
 
-	// Invalidate bumps minProfileVersion to 1.
-	qc.Invalidate()
+	// The profile moves to version 1.
+	qc.InvalidateProfile("", 1)
 
 	// Re-store the stale entry (simulates concurrent pipeline finishing after invalidation).
 	qc.Set(context.Background(), "hello world", nil, entry)
 
-	// Get should reject: ProfileVersion=0 < minProfileVersion=1.
+	// Get should reject: ProfileVersion=0 < floor 1.
 	result = qc.Get(context.Background(), "hello world")
 	if result.Hit {
 		t.Error("expected cache miss for stale profile version entry")
 	}
 }
 
+func TestGenerationRejectsEntriesAfterInvalidation(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	emb := fixedEmbedding(make([]float32, 768))
+	qc := NewQueryCacheWithClock(emb, true, 0.92, 5*time.Minute, 30*time.Minute, clock)
+
+	// Store an entry stamped with the generation its lookup started in.
+	entry := makeEntry("stale result", []string{"go"})
+	entry.Generation = qc.Get(context.Background(), "hello world").Generation
+	qc.Set(context.Background(), "hello world", nil, entry)
+
+	// Verify hit before invalidation.
+	result := qc.Get(context.Background(), "hello world")
+	if !result.Hit {
+		t.Fatal("expected cache hit before invalidation")
+	}
+
+	// Invalidate bumps the generation.
+	qc.Invalidate()
+
+	// Re-store the stale entry (simulates concurrent pipeline finishing after invalidation).
+	qc.Set(context.Background(), "hello world", nil, entry)
+
+	// Get should reject: the entry's lookup started before the invalidation.
+	result = qc.Get(context.Background(), "hello world")
+	if result.Hit {
+		t.Error("expected cache miss for entry from before the invalidation")
+	}
+
+	// Deleting a source drops in-flight enrichments the same way.
+	fresh := makeEntry("fresh result", []string{"go"})
+	fresh.Generation = result.Generation
+	qc.InvalidateBySources([]string{"doc-1"})
+	qc.Set(context.Background(), "hello world", nil, fresh)
+	if qc.Get(context.Background(), "hello world").Hit {
+		t.Error("expected cache miss for entry from before InvalidateBySources")
+	}
+}
+
 func TestProfileVersionRejectsStaleSemanticEntries(t *testing.T) {
 	vec := make([]float32, 768)
 	vec[0] = 1.0
@@ -483,8 +603,8 @@ func TestProfileVersionRejectsStaleSemanticEntries(t *testing.T) {
 	entry.ProfileVersion = 0
 	qc.Set(context.Background(), "original query", vec, entry)
 
-	// Invalidate bumps minProfileVersion.
-	qc.Invalidate()
+	// The profile moves to version 1.
+	qc.InvalidateProfile("", 1)
 
 	// Re-store stale entry (concurrent pipeline).
 	qc.Set(context.Background(), "original query", vec, entry)
@@ -525,3 +645,67 @@ func TestEvictExpired(t *testing.T) {
 		t.Errorf("semantic cache has %d entries, want 0", semLen)
 	}
 }
+
+func TestPersonaScoping(t *testing.T) {
+	vec := make([]float32, 768)
+	vec[0] = 1.0
+	ctx := context.Background()
+	qc := NewQueryCacheWithClock(fixedEmbedding(vec), true, 0.92, 5*time.Minute, 30*time.Minute, &mockClock{now: time.Now()})
+
+	work := makeEntry("work result", []string{"go"})
+	work.Persona = "work"
+	qc.Set(ctx, "what is my stack", vec, work)
+	qc.Set(ctx, "what is my stack", vec, makeEntry("default result", []string{"go"}))
+
+	if r := qc.GetFor(ctx, "work", "", "what is my stack"); !r.Hit || r.Entry.Persona != "work" {
+		t.Fatalf("GetFor(work) = hit %v persona %q, want the work entry", r.Hit, r.Entry.Persona)
+	}
+	if r := qc.Get(ctx, "what is my stack"); !r.Hit || r.Entry.Persona != "" {
+		t.Fatalf("Get = hit %v persona %q, want the default entry", r.Hit, r.Entry.Persona)
+	}
+	if r := qc.GetFor(ctx, "personal", "", "what is my stack"); r.Hit {
+		t.Error("GetFor(personal) served another persona's entry")
+	}
+
+	// A profile change for one persona leaves the other's entries alone.
+	qc.InvalidateProfile("work", 1)
+	if r := qc.GetFor(ctx, "work", "", "what is my stack"); r.Hit {
+		t.Error("work entry served after the work profile changed")
+	}
+	if r := qc.Get(ctx, "what is my stack"); !r.Hit {
+		t.Error("default entry evicted by a work profile change")
+	}
+
+	// Invalidate clears entries but does not reject enrichments that start
+	// after it.
+	qc.Invalidate()
+	after := makeEntry("r", nil)
+	after.Generation = qc.Get(ctx, "after").Generation
+	qc.Set(ctx, "after", nil, after)
+	if r := qc.Get(ctx, "after"); !r.Hit {
+		t.Error("entry stored after Invalidate was rejected")
+	}
+}
+
+func TestProjectScoping(t *testing.T) {
+	vec := make([]float32, 768)
+	vec[0] = 1.0
+	ctx := context.Background()
+	qc := NewQueryCacheWithClock(fixedEmbedding(vec), true, 0.92, 5*time.Minute, 30*time.Minute, &mockClock{now: time.Now()})
+
+	alpha := makeEntry("alpha build notes", []string{"build"})
+	alpha.Project = "alpha"
+	qc.Set(ctx, "how do I run the build", vec, alpha)
+
+	if r := qc.GetFor(ctx, "", "alpha", "how do I run the build"); !r.Hit || r.CacheLevel != "exact" {
+		t.Fatalf("GetFor(alpha) = hit %v level %q, want an exact hit", r.Hit, r.CacheLevel)
+	}
+	// Neither level serves the entry to another project or to no project,
+	// even though the semantic scan sees an identical embedding.
+	if r := qc.GetFor(ctx, "", "beta", "how do I run the build"); r.Hit {
+		t.Error("GetFor(beta) served the alpha entry")
+	}
+	if r := qc.Get(ctx, "how do I run the build"); r.Hit {
+		t.Error("Get served a project-scoped entry")
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/proxy"
+)
+
+// defaultMaxResponseBytes caps the total size of cached response bodies.
+// Oldest entries are evicted first once the budget is exceeded.
+const defaultMaxResponseBytes = 64 << 20
+
+// CachedResponse is an upstream chat completion kept for replay.
+type CachedResponse struct {
+	Body          []byte // JSON body, or the raw SSE stream when Stream is true
+	Stream        bool
+	Model         string
+	InteractionID string // interaction that produced the response; empty if not saved
+	CachedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+// ResponseCache holds full upstream responses for opted-in clients, keyed on
+// the final enriched request. Unlike QueryCache it skips the cloud call
+// entirely, so it is only safe for clients that want identical answers to
+// identical requests (CI scripts, agents replaying a prompt).
+type ResponseCache struct {
+	mu       sync.Mutex
+	entries  map[string]CachedResponse
+	bytes    int
+	maxBytes int
+	ttl      time.Duration
+	clients  map[string]struct{}
+	all      bool
+	clock    Clock
+}
+
+// NewResponseCache creates a ResponseCache for the given comma-separated
+// client names ("*" for every client) with a default entry TTL.
+func NewResponseCache(clients string, ttl time.Duration) *ResponseCache {
+	return NewResponseCacheWithClock(clients, ttl, realClock{})
+}
+
+// NewResponseCacheWithClock creates a ResponseCache with a custom clock (for testing).
+func NewResponseCacheWithClock(clients string, ttl time.Duration, clock Clock) *ResponseCache {
+	rc := &ResponseCache{
+		entries:  make(map[string]CachedResponse),
+		maxBytes: defaultMaxResponseBytes,
+		ttl:      ttl,
+		clients:  make(map[string]struct{}),
+		clock:    clock,
+	}
+	for _, c := range strings.Split(clients, ",") {
+		c = strings.TrimSpace(c)
+		switch c {
+		case "":
+		case "*":
+			rc.all = true
+		default:
+			rc.clients[strings.ToLower(c)] = struct{}{}
+		}
+	}
+	return rc
+}
+
+// Enabled reports whether client has opted in to response caching. A nil
+// cache is disabled for every client.
+func (rc *ResponseCache) Enabled(client string) bool {
+	if rc == nil {
+		return false
+	}
+	if rc.all {
+		return true
+	}
+	_, ok := rc.clients[strings.ToLower(strings.TrimSpace(client))]
+	return ok
+}
+
+// Get returns the unexpired response stored under key.
+func (rc *ResponseCache) Get(key string) (CachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	resp, ok := rc.entries[key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+	if !rc.clock.Now().Before(resp.ExpiresAt) {
+		rc.remove(key)
+		return CachedResponse{}, false
+	}
+	return resp, true
+}
+
+// Set stores resp under key for ttl, or for the cache default when ttl is not
+// positive. Responses larger than the whole cache budget are not stored.
+func (rc *ResponseCache) Set(key string, resp CachedResponse, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = rc.ttl
+	}
+	if ttl <= 0 || len(resp.Body) > rc.maxBytes {
+		return
+	}
+	now := rc.clock.Now()
+	resp.CachedAt = now
+	resp.ExpiresAt = now.Add(ttl)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.remove(key)
+	rc.entries[key] = resp
+	rc.bytes += len(resp.Body)
+	if rc.bytes > rc.maxBytes {
+		rc.evict(now)
+	}
+}
+
+// Clear drops every cached response.
+func (rc *ResponseCache) Clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = make(map[string]CachedResponse)
+	rc.bytes = 0
+}
+
+// evict drops expired entries, then the oldest, until the cache is within
+// budget. Called under rc.mu.
+func (rc *ResponseCache) evict(now time.Time) {
+	keys := make([]string, 0, len(rc.entries))
+	for k, e := range rc.entries {
+		if !now.Before(e.ExpiresAt) {
+			rc.remove(k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return rc.entries[keys[i]].CachedAt.Before(rc.entries[keys[j]].CachedAt)
+	})
+	for _, k := range keys {
+		if rc.bytes <= rc.maxBytes {
+			return
+		}
+		rc.remove(k)
+	}
+}
+
+func (rc *ResponseCache) remove(key string) {
+	if e, ok := rc.entries[key]; ok {
+		rc.bytes -= len(e.Body)
+		delete(rc.entries, key)
+	}
+}
+
+// ResponseKey hashes everything that determines an upstream response: the
+// client, model, messages, streaming mode, and every other request parameter
+// (temperature, max_tokens, tools, ...). JSON values are compacted and object
+// keys sorted so that formatting differences do not change the key.
+func ResponseKey(client string, req proxy.ChatRequest) string {
+	params := make(map[string]json.RawMessage, len(req.Extra))
+	for k, v := range req.Extra {
+		params[k] = canonicalJSON(v)
+	}
+	// encoding/json sorts map keys, so the marshaled form is canonical.
+	b, _ := json.Marshal(struct {
+		Client   string                     `json:"client"`
+		Model    string                     `json:"model"`
+		Messages json.RawMessage            `json:"messages"`
+		Stream   bool                       `json:"stream"`
+		Params   map[string]json.RawMessage `json:"params"`
+	}{strings.ToLower(strings.TrimSpace(client)), req.Model, canonicalJSON(req.Messages), req.Stream, params})
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// canonicalJSON re-encodes raw with sorted object keys and no insignificant
+// whitespace. Invalid JSON is returned compacted as far as possible.
+func canonicalJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("null")
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		var buf bytes.Buffer
+		if json.Compact(&buf, raw) != nil {
+			return raw
+		}
+		return buf.Bytes()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return b
+}
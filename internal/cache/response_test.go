@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/proxy"
+)
+
+func chatRequest(t *testing.T, body string) proxy.ChatRequest {
+	t.Helper()
+	var req proxy.ChatRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	return req
+}
+
+func TestResponseKey(t *testing.T) {
+	base := chatRequest(t, `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0.2}`)
+	key := ResponseKey("ci", base)
+
+	same := []string{
+		`{"temperature":0.2,"messages":[ {"content":"hi","role":"user"} ],"model":"m"}`,
+	}
+	for _, body := range same {
+		if got := ResponseKey("CI ", chatRequest(t, body)); got != key {
+			t.Errorf("key for %s differs from an equivalent request", body)
+		}
+	}
+
+	different := map[string]string{
+		"model":       `{"model":"other","messages":[{"role":"user","content":"hi"}],"temperature":0.2}`,
+		"messages":    `{"model":"m","messages":[{"role":"user","content":"hello"}],"temperature":0.2}`,
+		"temperature": `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0.7}`,
+		"max_tokens":  `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0.2,"max_tokens":5}`,
+		"stream":      `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0.2,"stream":true}`,
+	}
+	for name, body := range different {
+		if ResponseKey("ci", chatRequest(t, body)) == key {
+			t.Errorf("changing %s did not change the key", name)
+		}
+	}
+
+	if ResponseKey("agent", base) == key {
+		t.Error("different clients share a key")
+	}
+}
+
+func TestResponseCache_Enabled(t *testing.T) {
+	rc := NewResponseCache(" ci, Agent ", time.Minute)
+	for client, want := range map[string]bool{"ci": true, "agent": true, "AGENT": true, "": false, "other": false} {
+		if got := rc.Enabled(client); got != want {
+			t.Errorf("Enabled(%q) = %v, want %v", client, got, want)
+		}
+	}
+
+	if !NewResponseCache("*", time.Minute).Enabled("anyone") {
+		t.Error(`"*" did not opt in every client`)
+	}
+
+	var nilCache *ResponseCache
+	if nilCache.Enabled("ci") {
+		t.Error("nil cache reported enabled")
+	}
+}
+
+func TestResponseCache_TTL(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	rc := NewResponseCacheWithClock("*", 10*time.Minute, clock)
+
+	rc.Set("default", CachedResponse{Body: []byte("a")}, 0)
+	rc.Set("short", CachedResponse{Body: []byte("b")}, time.Minute)
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := rc.Get("short"); ok {
+		t.Error("entry with a 1m TTL served after 2m")
+	}
+	got, ok := rc.Get("default")
+	if !ok || string(got.Body) != "a" {
+		t.Fatalf("Get(default) = %q, %v; want a, true", got.Body, ok)
+	}
+	if want := got.CachedAt.Add(10 * time.Minute); !got.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, want)
+	}
+
+	clock.Advance(10 * time.Minute)
+	if _, ok := rc.Get("default"); ok {
+		t.Error("entry served past the default TTL")
+	}
+}
+
+func TestResponseCache_EvictsOldestOverBudget(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	rc := NewResponseCacheWithClock("*", time.Hour, clock)
+	rc.maxBytes = 10
+
+	rc.Set("a", CachedResponse{Body: []byte("aaaa")}, 0)
+	clock.Advance(time.Second)
+	rc.Set("b", CachedResponse{Body: []byte("bbbb")}, 0)
+	clock.Advance(time.Second)
+	rc.Set("c", CachedResponse{Body: []byte("cccc")}, 0)
+
+	if _, ok := rc.Get("a"); ok {
+		t.Error("oldest entry kept over budget")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := rc.Get(k); !ok {
+			t.Errorf("entry %s evicted, want kept", k)
+		}
+	}
+
+	rc.Set("huge", CachedResponse{Body: make([]byte, 11)}, 0)
+	if _, ok := rc.Get("huge"); ok {
+		t.Error("response larger than the budget was cached")
+	}
+
+	rc.Clear()
+	if _, ok := rc.Get("b"); ok || rc.bytes != 0 {
+		t.Errorf("Clear left entries (bytes = %d)", rc.bytes)
+	}
+}
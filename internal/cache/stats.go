@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// similarityBounds are the lower bounds of the L2 hit similarity histogram
+// buckets. Hits below the first bound (only possible with a threshold under
+// 0.80) land in the first bucket.
+var similarityBounds = [...]float64{0.80, 0.85, 0.90, 0.92, 0.94, 0.96, 0.98, 0.99}
+
+// Stats is a point-in-time view of cache effectiveness.
+type Stats struct {
+	Enabled bool       `json:"enabled"`
+	Exact   LevelStats `json:"exact"`
+	// Semantic misses count lookups that reached L2 and found nothing above
+	// the similarity threshold, including lookups whose embedding failed.
+	Semantic            LevelStats         `json:"semantic"`
+	SemanticThreshold   float64            `json:"semantic_threshold"`
+	SimilarityHistogram []SimilarityBucket `json:"similarity_histogram"`
+	Evictions           EvictionStats      `json:"evictions"`
+	// MemoryBytes is an estimate of the memory held by cached requests and
+	// embeddings. A request cached at both levels is counted at each; opaque
+	// metadata is not counted.
+	MemoryBytes int64 `json:"memory_bytes"`
+}
+
+// LevelStats counts lookups answered (or not) at one cache level.
+type LevelStats struct {
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// SimilarityBucket counts L2 hits whose similarity fell in [Min, Max).
+// The last bucket includes Max.
+type SimilarityBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// EvictionStats counts entries removed from either level, by cause.
+type EvictionStats struct {
+	Expired     int64 `json:"expired"`
+	Capacity    int64 `json:"capacity"`
+	Invalidated int64 `json:"invalidated"`
+	Total       int64 `json:"total"`
+}
+
+// cacheCounters holds the counters behind Stats. They are updated without
+// holding QueryCache.mu, so every field is atomic.
+type cacheCounters struct {
+	exactHits, exactMisses         atomic.Int64
+	semanticHits, semanticMisses   atomic.Int64
+	similarity                     [len(similarityBounds)]atomic.Int64
+	expired, capacity, invalidated atomic.Int64
+}
+
+func (c *cacheCounters) recordSimilarity(sim float64) {
+	i := len(similarityBounds) - 1
+	for i > 0 && sim < similarityBounds[i] {
+		i--
+	}
+	c.similarity[i].Add(1)
+}
+
+// Stats returns the current counters, entry counts, and memory estimate.
+// Counters are cumulative since the cache was created; Invalidate empties
+// the cache but does not reset them.
+func (qc *QueryCache) Stats() Stats {
+	c := &qc.counters
+	s := Stats{
+		Enabled:           qc.enabled,
+		Exact:             LevelStats{Hits: c.exactHits.Load(), Misses: c.exactMisses.Load()},
+		Semantic:          LevelStats{Hits: c.semanticHits.Load(), Misses: c.semanticMisses.Load()},
+		SemanticThreshold: qc.semThreshold,
+		Evictions: EvictionStats{
+			Expired:     c.expired.Load(),
+			Capacity:    c.capacity.Load(),
+			Invalidated: c.invalidated.Load(),
+		},
+	}
+	s.Exact.HitRate = hitRate(s.Exact.Hits, s.Exact.Misses)
+	s.Semantic.HitRate = hitRate(s.Semantic.Hits, s.Semantic.Misses)
+	s.Evictions.Total = s.Evictions.Expired + s.Evictions.Capacity + s.Evictions.Invalidated
+
+	s.SimilarityHistogram = make([]SimilarityBucket, len(similarityBounds))
+	for i, lo := range similarityBounds {
+		hi := 1.0
+		if i+1 < len(similarityBounds) {
+			hi = similarityBounds[i+1]
+		}
+		s.SimilarityHistogram[i] = SimilarityBucket{Min: lo, Max: hi, Count: c.similarity[i].Load()}
+	}
+
+	qc.mu.RLock()
+	defer qc.mu.RUnlock()
+	s.Exact.Entries = len(qc.exactCache)
+	s.Semantic.Entries = qc.semanticLen
+	for hash, entry := range qc.exactCache {
+		s.MemoryBytes += int64(len(hash)) + entrySize(entry)
+	}
+	for i := 0; i < qc.semanticLen; i++ {
+		e := &qc.semanticCache[i]
+		s.MemoryBytes += int64(len(e.QueryHash)+len(e.Embedding)*4) + entrySize(e.Result)
+	}
+	return s
+}
+
+// entrySize estimates the bytes held by one cached enrichment.
+func entrySize(e CachedEnrichment) int64 {
+	n := int64(unsafe.Sizeof(e))
+	n += int64(len(e.EnrichedRequest.Model) + len(e.EnrichedRequest.Messages))
+	for k, v := range e.EnrichedRequest.Extra {
+		n += int64(len(k) + len(v))
+	}
+	for _, list := range [][]string{e.Topics, e.ChunkIDs, e.SourceIDs} {
+		for _, s := range list {
+			n += int64(len(s)) + int64(unsafe.Sizeof(s))
+		}
+	}
+	return n
+}
+
+func hitRate(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats_CountsHitsAndMissesPerLevel(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	emb := &mockEmbedder{embedFn: func(_ context.Context, text string) ([]float32, error) {
+		switch text {
+		case "how do I write go tests", "writing tests in go":
+			return []float32{1, 0, 0}, nil
+		case "close paraphrase":
+			return []float32{0.95, 0.312, 0}, nil
+		default:
+			return []float32{0, 0, 1}, nil
+		}
+	}}
+	qc := NewQueryCacheWithClock(emb, true, 0.90, 5*time.Minute, 30*time.Minute, clock)
+	ctx := context.Background()
+
+	qc.Set(ctx, "how do I write go tests", []float32{1, 0, 0}, makeEntry("r", []string{"go"}))
+
+	qc.Get(ctx, "how do I write go tests") // L1 hit
+	qc.Get(ctx, "writing tests in go")     // L1 miss, L2 hit at 1.0
+	qc.Get(ctx, "close paraphrase")        // L1 miss, L2 hit at ~0.95
+	qc.Get(ctx, "unrelated")               // L1 miss, L2 miss
+
+	s := qc.Stats()
+	if !s.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if s.Exact.Hits != 1 || s.Exact.Misses != 3 {
+		t.Errorf("exact hits/misses = %d/%d, want 1/3", s.Exact.Hits, s.Exact.Misses)
+	}
+	if s.Semantic.Hits != 2 || s.Semantic.Misses != 1 {
+		t.Errorf("semantic hits/misses = %d/%d, want 2/1", s.Semantic.Hits, s.Semantic.Misses)
+	}
+	if s.Exact.HitRate != 0.25 {
+		t.Errorf("exact hit rate = %g, want 0.25", s.Exact.HitRate)
+	}
+	if s.Exact.Entries != 1 || s.Semantic.Entries != 1 {
+		t.Errorf("entries = %d/%d, want 1/1", s.Exact.Entries, s.Semantic.Entries)
+	}
+
+	counts := make(map[float64]int64)
+	for _, b := range s.SimilarityHistogram {
+		counts[b.Min] = b.Count
+	}
+	if counts[0.99] != 1 {
+		t.Errorf("bucket [0.99,1] = %d, want 1", counts[0.99])
+	}
+	if counts[0.94] != 1 {
+		t.Errorf("bucket [0.94,0.96) = %d, want 1", counts[0.94])
+	}
+	if last := s.SimilarityHistogram[len(s.SimilarityHistogram)-1]; last.Max != 1 {
+		t.Errorf("last bucket max = %g, want 1", last.Max)
+	}
+}
+
+func TestStats_EmbedFailureCountsAsSemanticMiss(t *testing.T) {
+	emb := &mockEmbedder{embedFn: func(context.Context, string) ([]float32, error) {
+		return nil, context.DeadlineExceeded
+	}}
+	qc := NewQueryCacheWithClock(emb, true, 0.9, time.Minute, time.Minute, &mockClock{now: time.Now()})
+
+	qc.Get(context.Background(), "q")
+
+	if s := qc.Stats(); s.Exact.Misses != 1 || s.Semantic.Misses != 1 {
+		t.Errorf("misses = %d/%d, want 1/1", s.Exact.Misses, s.Semantic.Misses)
+	}
+}
+
+func TestStats_CountsEvictionsByCause(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	qc := NewQueryCacheWithClock(fixedEmbedding(unitVec(0)), true, 0.9, 5*time.Minute, 30*time.Minute, clock)
+	qc.maxSemanticSize = 2
+	qc.maxExactSize = 2
+	ctx := context.Background()
+
+	qc.Set(ctx, "a", unitVec(0), makeEntry("a", []string{"go"}))
+	qc.Set(ctx, "b", unitVec(1), makeEntry("b", []string{"go"}))
+	qc.Set(ctx, "c", unitVec(2), makeEntry("c", []string{"rust"})) // evicts one exact and one semantic entry
+
+	s := qc.Stats()
+	if s.Evictions.Capacity != 2 {
+		t.Errorf("capacity evictions = %d, want 2", s.Evictions.Capacity)
+	}
+
+	qc.InvalidateByTopics([]string{"rust"})
+	if s = qc.Stats(); s.Evictions.Invalidated != 2 {
+		t.Errorf("invalidated = %d, want 2 (exact and semantic copies of c)", s.Evictions.Invalidated)
+	}
+
+	clock.Advance(10 * time.Minute) // past exact TTL, within semantic TTL
+	qc.evictExpired()
+	if s = qc.Stats(); s.Evictions.Expired != 1 {
+		t.Errorf("expired = %d, want 1", s.Evictions.Expired)
+	}
+
+	qc.Invalidate()
+	s = qc.Stats()
+	if s.Evictions.Invalidated != 3 {
+		t.Errorf("invalidated = %d, want 3", s.Evictions.Invalidated)
+	}
+	if s.Evictions.Total != s.Evictions.Capacity+s.Evictions.Invalidated+s.Evictions.Expired {
+		t.Errorf("total = %d, want sum of causes", s.Evictions.Total)
+	}
+	if s.Exact.Entries != 0 || s.Semantic.Entries != 0 || s.MemoryBytes != 0 {
+		t.Errorf("after Invalidate: entries %d/%d, memory %d; want all 0",
+			s.Exact.Entries, s.Semantic.Entries, s.MemoryBytes)
+	}
+}
+
+func TestStats_MemoryGrowsWithEntries(t *testing.T) {
+	qc := NewQueryCacheWithClock(fixedEmbedding(unitVec(0)), true, 0.9, time.Minute, time.Minute, &mockClock{now: time.Now()})
+	ctx := context.Background()
+
+	qc.Set(ctx, "a", nil, makeEntry("a", nil))
+	exactOnly := qc.Stats().MemoryBytes
+	if exactOnly <= 0 {
+		t.Fatalf("memory = %d, want > 0", exactOnly)
+	}
+
+	qc.Set(ctx, "b", unitVec(1), makeEntry("b", nil))
+	if got := qc.Stats().MemoryBytes; got <= 2*exactOnly {
+		t.Errorf("memory = %d, want more than two exact entries (%d) once an embedding is cached", got, 2*exactOnly)
+	}
+}
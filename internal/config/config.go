@@ -16,6 +16,8 @@ type Config struct {
 	Log        LogConfig
 	Retrieval  RetrievalConfig
 	Enrichment EnrichmentConfig
+	Profile    ProfileConfig
+	Scheduler  SchedulerConfig
 }
 
 type LogConfig struct {
@@ -42,11 +44,29 @@ type StorageConfig struct {
 
 type ProxyConfig struct {
 	OpenRouterAPIKey string
-	DefaultModel     string
+	DefaultModel     string // model for "tbyd/auto" requests no rule or preference routes
+
+	// IntentModels routes "tbyd/auto" requests by extracted intent type, as
+	// comma-separated intent=model rules, e.g. "recall=openai/gpt-4o-mini".
+	IntentModels string
+
+	// ResponseCacheClients lists the clients, by X-TBYD-Client header, whose
+	// identical requests are answered from cached upstream responses.
+	// Comma-separated; "*" opts in every client. Empty disables the cache.
+	ResponseCacheClients string
+	ResponseCacheTTL     string // duration string, e.g. "10m"
 }
 
 type RetrievalConfig struct {
 	TopK int // default 5
+
+	AdaptiveHybridEnabled bool    // learn the per-intent vector/keyword ratio from feedback
+	HybridRatioMin        float64 // lower bound for a learned vector ratio
+	HybridRatioMax        float64 // upper bound for a learned vector ratio
+
+	ProjectMode string // "boost" (down-rank other projects' docs) or "filter" (drop them)
+
+	GraphNeighbours int // knowledge-graph neighbours of the query's entities also searched; 0 disables
 }
 
 // DefaultSemanticThreshold is the default cosine similarity threshold for L2
@@ -62,17 +82,57 @@ type EnrichmentConfig struct {
 	RerankingEnabled   bool
 	RerankingTimeout   string  // duration string, e.g. "5s"
 	RerankingThreshold float64 // minimum relevance score to keep a chunk
+	RerankingBackend   string  // "llm" (per chunk), "listwise" (one fast-model call), or "cross_encoder" (/rerank endpoint)
+	RerankingURL       string  // base URL of the /rerank endpoint for the cross_encoder backend
+	RerankingModel     string  // optional model name sent to the /rerank endpoint
+	RerankingRawLogits bool    // cross_encoder endpoint returns raw logits (llama.cpp); map them through a sigmoid
+
+	ExpansionEnabled     bool
+	ExpansionBudget      string // duration string, e.g. "800ms"
+	ExpansionParaphrases int    // number of query paraphrases to request
 
 	CacheEnabled           bool
 	CacheSemanticThreshold float64 // cosine similarity threshold for semantic cache hit
 	CacheExactTTL          string  // duration string, e.g. "5m"
 	CacheSemanticTTL       string  // duration string, e.g. "30m"
+	CachePersistEnabled    bool    // save the query cache to SQLite and reload it on start
+	CachePersistInterval   string  // duration string between periodic saves, e.g. "5m"
+
+	DeepEnabled           bool
+	DeepSchedule          string // "HH:MM" e.g. "2:00"
+	DeepIdleCPUMaxPct     int    // max CPU % to consider system idle
+	DeepIdleMemMinGB      int    // min available memory (GB) to consider system idle
+	DeepIdleSkipOnBattery bool   // never consider the system idle while on battery
+	DeepBatchClaimLimit   int    // max jobs to claim per deep enrichment pass
+
+	ConsolidationEnabled    bool // fold old interaction summaries into consolidated memories (opt-in: runs the deep model)
+	ConsolidationMinAgeDays int  // leave summaries of interactions younger than this alone
+	ConsolidationWindowDays int  // group summaries per topic within windows this long
+	ConsolidationMinCluster int  // smallest group of summaries worth consolidating
+
+	FactExtractionEnabled bool    // extract facts and decisions from completed interactions for review (opt-in: runs the deep model)
+	FactMinConfidence     float64 // drop extracted facts the model is less sure of than this
+}
+
+// ProfileConfig holds the policy for deciding pending profile deltas
+// without review. Both auto-accept and expiry are opt-in, so upgrading never
+// changes the profile or drops deltas nobody asked to have decided.
+type ProfileConfig struct {
+	AutoAcceptEnabled       bool    // auto-accept preference additions learned from feedback; off by default
+	AutoAcceptMinCount      int     // positive signals that qualify an addition
+	AutoAcceptMinConfidence float64 // share of positive signals that qualifies an addition
+	DeltaExpiryDays         int     // reject deltas left unreviewed this long; 0 disables
+}
+
+// SchedulerConfig holds the schedules of periodic background jobs.
+type SchedulerConfig struct {
+	Timezone string // IANA name schedules are evaluated in, e.g. "Europe/Berlin"; "" uses the local zone
+	Jitter   string // duration string; maximum random delay added to each run, e.g. "5m"
 
-	DeepEnabled         bool
-	DeepSchedule        string // "HH:MM" e.g. "2:00"
-	DeepIdleCPUMaxPct   int    // max CPU % to consider system idle
-	DeepIdleMemMinGB    int    // min available memory (GB) to consider system idle
-	DeepBatchClaimLimit int    // max jobs to claim per deep enrichment pass
+	// Schedules overrides the default cron schedule of jobs, as
+	// semicolon-separated name=expression rules, e.g.
+	// "nightly_synthesis=0 3 * * *; delta_policy=*/30 * * * *".
+	Schedules string
 }
 
 func defaults() Config {
@@ -92,29 +152,63 @@ func defaults() Config {
 			DataDir: dataDir,
 		},
 		Proxy: ProxyConfig{
-			DefaultModel: "anthropic/claude-opus-4",
+			DefaultModel:     "anthropic/claude-opus-4",
+			ResponseCacheTTL: "10m",
 		},
 		Log: LogConfig{
 			Level: "info",
 		},
 		Retrieval: RetrievalConfig{
 			TopK: 5,
+
+			AdaptiveHybridEnabled: true,
+			HybridRatioMin:        0.3,
+			HybridRatioMax:        0.9,
+
+			ProjectMode: "boost",
+
+			GraphNeighbours: 3,
 		},
 		Enrichment: EnrichmentConfig{
 			RerankingEnabled:   true,
 			RerankingTimeout:   "5s",
 			RerankingThreshold: 0.3,
+			RerankingBackend:   "llm",
+			RerankingURL:       "http://localhost:8080",
+
+			ExpansionEnabled:     false,
+			ExpansionBudget:      "800ms",
+			ExpansionParaphrases: 2,
 
 			CacheEnabled:           true,
 			CacheSemanticThreshold: DefaultSemanticThreshold,
 			CacheExactTTL:          "5m",
 			CacheSemanticTTL:       "30m",
-
-			DeepEnabled:         false,
-			DeepSchedule:        "2:00",
-			DeepIdleCPUMaxPct:   10,
-			DeepIdleMemMinGB:    4,
-			DeepBatchClaimLimit: 5000,
+			CachePersistEnabled:    false,
+			CachePersistInterval:   "5m",
+
+			DeepEnabled:           false,
+			DeepSchedule:          "2:00",
+			DeepIdleCPUMaxPct:     10,
+			DeepIdleMemMinGB:      4,
+			DeepIdleSkipOnBattery: true,
+			DeepBatchClaimLimit:   5000,
+
+			ConsolidationEnabled:    false,
+			ConsolidationMinAgeDays: 30,
+			ConsolidationWindowDays: 7,
+			ConsolidationMinCluster: 3,
+			FactExtractionEnabled:   false,
+			FactMinConfidence:       0.6,
+		},
+		Profile: ProfileConfig{
+			AutoAcceptEnabled:       false,
+			AutoAcceptMinCount:      5,
+			AutoAcceptMinConfidence: 0.9,
+			DeltaExpiryDays:         0,
+		},
+		Scheduler: SchedulerConfig{
+			Jitter: "5m",
 		},
 	}
 }
@@ -155,6 +249,10 @@ func loadWith(b ConfigBackend, kc Keychain) (Config, error) {
 
 	applyEnvOverrides(&cfg)
 
+	if err := validateEnums(cfg); err != nil {
+		return Config{}, err
+	}
+
 	// Try platform keychain for API key if still empty.
 	if cfg.Proxy.OpenRouterAPIKey == "" {
 		if key, err := kc.Get("tbyd", "openrouter_api_key"); err == nil && key != "" {
@@ -360,6 +360,66 @@ func TestIsKeySet_BoolRoundtrip(t *testing.T) {
 	}
 }
 
+// TestSetKey_FloatRoundtrip verifies that float keys set via setKeyWith are
+// read back by loadWith, and that non-numeric values are rejected.
+func TestSetKey_FloatRoundtrip(t *testing.T) {
+	b := newMockBackend()
+
+	if err := setKeyWith(b, "retrieval.hybrid_ratio_min", "0.45"); err != nil {
+		t.Fatalf("setKeyWith error: %v", err)
+	}
+	if err := setKeyWith(b, "retrieval.hybrid_ratio_max", "high"); err == nil {
+		t.Error("setKeyWith accepted a non-numeric float value")
+	}
+
+	kc := newMockKeychain()
+	kc.store["tbyd/openrouter_api_key"] = "test-key"
+	cfg, err := loadWith(b, kc)
+	if err != nil {
+		t.Fatalf("loadWith error: %v", err)
+	}
+	if cfg.Retrieval.HybridRatioMin != 0.45 {
+		t.Errorf("HybridRatioMin = %v, want 0.45", cfg.Retrieval.HybridRatioMin)
+	}
+	if cfg.Retrieval.HybridRatioMax != 0.9 {
+		t.Errorf("HybridRatioMax = %v, want default 0.9", cfg.Retrieval.HybridRatioMax)
+	}
+}
+
+// TestRerankingBackend_RejectsUnknown verifies that an unknown reranking
+// backend is rejected both by setKeyWith and when loaded from the backend or
+// environment.
+func TestRerankingBackend_RejectsUnknown(t *testing.T) {
+	b := newMockBackend()
+	if err := setKeyWith(b, "enrichment.reranking_backend", "listwise"); err != nil {
+		t.Fatalf("setKeyWith(listwise) error: %v", err)
+	}
+	if err := setKeyWith(b, "enrichment.reranking_backend", "cross-encoder"); err == nil {
+		t.Error("setKeyWith accepted an unknown reranking backend")
+	}
+
+	kc := newMockKeychain()
+	kc.store["tbyd/openrouter_api_key"] = "test-key"
+	cfg, err := loadWith(b, kc)
+	if err != nil {
+		t.Fatalf("loadWith error: %v", err)
+	}
+	if cfg.Enrichment.RerankingBackend != "listwise" {
+		t.Errorf("RerankingBackend = %q, want listwise", cfg.Enrichment.RerankingBackend)
+	}
+
+	b.strings["enrichment.reranking_backend"] = "bm25"
+	if _, err := loadWith(b, kc); err == nil || !contains(err.Error(), "enrichment.reranking_backend") {
+		t.Errorf("loadWith with stored unknown backend: err = %v, want error naming the key", err)
+	}
+
+	delete(b.strings, "enrichment.reranking_backend")
+	t.Setenv("TBYD_ENRICHMENT_RERANKING_BACKEND", "colbert")
+	if _, err := loadWith(b, kc); err == nil {
+		t.Error("loadWith accepted an unknown backend from the environment")
+	}
+}
+
 func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
 		if s[i:i+len(substr)] == substr {
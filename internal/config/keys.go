@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type keyType int
@@ -20,6 +22,7 @@ type keySpec struct {
 	typ     keyType
 	env     string
 	secret  bool
+	oneOf   []string // allowed values for a kString key; nil accepts any
 	apply   func(cfg *Config, v any)
 	extract func(cfg Config) any
 }
@@ -81,6 +84,21 @@ var specs = []keySpec{
 		apply:   func(cfg *Config, v any) { cfg.Proxy.DefaultModel = v.(string) },
 		extract: func(cfg Config) any { return cfg.Proxy.DefaultModel },
 	},
+	{
+		key: "proxy.intent_models", typ: kString, env: "TBYD_PROXY_INTENT_MODELS",
+		apply:   func(cfg *Config, v any) { cfg.Proxy.IntentModels = v.(string) },
+		extract: func(cfg Config) any { return cfg.Proxy.IntentModels },
+	},
+	{
+		key: "proxy.response_cache_clients", typ: kString, env: "TBYD_PROXY_RESPONSE_CACHE_CLIENTS",
+		apply:   func(cfg *Config, v any) { cfg.Proxy.ResponseCacheClients = v.(string) },
+		extract: func(cfg Config) any { return cfg.Proxy.ResponseCacheClients },
+	},
+	{
+		key: "proxy.response_cache_ttl", typ: kString, env: "TBYD_PROXY_RESPONSE_CACHE_TTL",
+		apply:   func(cfg *Config, v any) { cfg.Proxy.ResponseCacheTTL = v.(string) },
+		extract: func(cfg Config) any { return cfg.Proxy.ResponseCacheTTL },
+	},
 	{
 		key: "log.level", typ: kString, env: "TBYD_LOG_LEVEL",
 		apply:   func(cfg *Config, v any) { cfg.Log.Level = v.(string) },
@@ -91,6 +109,32 @@ var specs = []keySpec{
 		apply:   func(cfg *Config, v any) { cfg.Retrieval.TopK = v.(int) },
 		extract: func(cfg Config) any { return cfg.Retrieval.TopK },
 	},
+	{
+		key: "retrieval.adaptive_hybrid_enabled", typ: kBool, env: "TBYD_RETRIEVAL_ADAPTIVE_HYBRID_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Retrieval.AdaptiveHybridEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Retrieval.AdaptiveHybridEnabled },
+	},
+	{
+		key: "retrieval.hybrid_ratio_min", typ: kFloat, env: "TBYD_RETRIEVAL_HYBRID_RATIO_MIN",
+		apply:   func(cfg *Config, v any) { cfg.Retrieval.HybridRatioMin = v.(float64) },
+		extract: func(cfg Config) any { return cfg.Retrieval.HybridRatioMin },
+	},
+	{
+		key: "retrieval.hybrid_ratio_max", typ: kFloat, env: "TBYD_RETRIEVAL_HYBRID_RATIO_MAX",
+		apply:   func(cfg *Config, v any) { cfg.Retrieval.HybridRatioMax = v.(float64) },
+		extract: func(cfg Config) any { return cfg.Retrieval.HybridRatioMax },
+	},
+	{
+		key: "retrieval.project_mode", typ: kString, env: "TBYD_RETRIEVAL_PROJECT_MODE",
+		oneOf:   []string{"boost", "filter"},
+		apply:   func(cfg *Config, v any) { cfg.Retrieval.ProjectMode = v.(string) },
+		extract: func(cfg Config) any { return cfg.Retrieval.ProjectMode },
+	},
+	{
+		key: "retrieval.graph_neighbours", typ: kInt, env: "TBYD_RETRIEVAL_GRAPH_NEIGHBOURS",
+		apply:   func(cfg *Config, v any) { cfg.Retrieval.GraphNeighbours = v.(int) },
+		extract: func(cfg Config) any { return cfg.Retrieval.GraphNeighbours },
+	},
 	{
 		key: "enrichment.reranking_enabled", typ: kBool, env: "TBYD_ENRICHMENT_RERANKING_ENABLED",
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingEnabled = v.(bool) },
@@ -106,6 +150,42 @@ var specs = []keySpec{
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingThreshold = v.(float64) },
 		extract: func(cfg Config) any { return cfg.Enrichment.RerankingThreshold },
 	},
+	{
+		key: "enrichment.reranking_backend", typ: kString, env: "TBYD_ENRICHMENT_RERANKING_BACKEND",
+		oneOf:   []string{"llm", "listwise", "cross_encoder"},
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingBackend = v.(string) },
+		extract: func(cfg Config) any { return cfg.Enrichment.RerankingBackend },
+	},
+	{
+		key: "enrichment.reranking_url", typ: kString, env: "TBYD_ENRICHMENT_RERANKING_URL",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingURL = v.(string) },
+		extract: func(cfg Config) any { return cfg.Enrichment.RerankingURL },
+	},
+	{
+		key: "enrichment.reranking_model", typ: kString, env: "TBYD_ENRICHMENT_RERANKING_MODEL",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingModel = v.(string) },
+		extract: func(cfg Config) any { return cfg.Enrichment.RerankingModel },
+	},
+	{
+		key: "enrichment.reranking_raw_logits", typ: kBool, env: "TBYD_ENRICHMENT_RERANKING_RAW_LOGITS",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.RerankingRawLogits = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.RerankingRawLogits },
+	},
+	{
+		key: "enrichment.expansion_enabled", typ: kBool, env: "TBYD_ENRICHMENT_EXPANSION_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ExpansionEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ExpansionEnabled },
+	},
+	{
+		key: "enrichment.expansion_budget", typ: kString, env: "TBYD_ENRICHMENT_EXPANSION_BUDGET",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ExpansionBudget = v.(string) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ExpansionBudget },
+	},
+	{
+		key: "enrichment.expansion_paraphrases", typ: kInt, env: "TBYD_ENRICHMENT_EXPANSION_PARAPHRASES",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ExpansionParaphrases = v.(int) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ExpansionParaphrases },
+	},
 	{
 		key: "enrichment.cache_enabled", typ: kBool, env: "TBYD_ENRICHMENT_CACHE_ENABLED",
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.CacheEnabled = v.(bool) },
@@ -126,6 +206,16 @@ var specs = []keySpec{
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.CacheSemanticTTL = v.(string) },
 		extract: func(cfg Config) any { return cfg.Enrichment.CacheSemanticTTL },
 	},
+	{
+		key: "enrichment.cache_persist_enabled", typ: kBool, env: "TBYD_ENRICHMENT_CACHE_PERSIST_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.CachePersistEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.CachePersistEnabled },
+	},
+	{
+		key: "enrichment.cache_persist_interval", typ: kString, env: "TBYD_ENRICHMENT_CACHE_PERSIST_INTERVAL",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.CachePersistInterval = v.(string) },
+		extract: func(cfg Config) any { return cfg.Enrichment.CachePersistInterval },
+	},
 	{
 		key: "enrichment.deep_enabled", typ: kBool, env: "TBYD_ENRICHMENT_DEEP_ENABLED",
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.DeepEnabled = v.(bool) },
@@ -146,11 +236,81 @@ var specs = []keySpec{
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.DeepIdleMemMinGB = v.(int) },
 		extract: func(cfg Config) any { return cfg.Enrichment.DeepIdleMemMinGB },
 	},
+	{
+		key: "enrichment.deep_idle_skip_on_battery", typ: kBool, env: "TBYD_ENRICHMENT_DEEP_IDLE_SKIP_ON_BATTERY",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.DeepIdleSkipOnBattery = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.DeepIdleSkipOnBattery },
+	},
 	{
 		key: "enrichment.deep_batch_claim_limit", typ: kInt, env: "TBYD_ENRICHMENT_DEEP_BATCH_CLAIM_LIMIT",
 		apply:   func(cfg *Config, v any) { cfg.Enrichment.DeepBatchClaimLimit = v.(int) },
 		extract: func(cfg Config) any { return cfg.Enrichment.DeepBatchClaimLimit },
 	},
+	{
+		key: "enrichment.consolidation_enabled", typ: kBool, env: "TBYD_ENRICHMENT_CONSOLIDATION_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ConsolidationEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ConsolidationEnabled },
+	},
+	{
+		key: "enrichment.consolidation_min_age_days", typ: kInt, env: "TBYD_ENRICHMENT_CONSOLIDATION_MIN_AGE_DAYS",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ConsolidationMinAgeDays = v.(int) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ConsolidationMinAgeDays },
+	},
+	{
+		key: "enrichment.consolidation_window_days", typ: kInt, env: "TBYD_ENRICHMENT_CONSOLIDATION_WINDOW_DAYS",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ConsolidationWindowDays = v.(int) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ConsolidationWindowDays },
+	},
+	{
+		key: "enrichment.consolidation_min_cluster", typ: kInt, env: "TBYD_ENRICHMENT_CONSOLIDATION_MIN_CLUSTER",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.ConsolidationMinCluster = v.(int) },
+		extract: func(cfg Config) any { return cfg.Enrichment.ConsolidationMinCluster },
+	},
+	{
+		key: "enrichment.fact_extraction_enabled", typ: kBool, env: "TBYD_ENRICHMENT_FACT_EXTRACTION_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.FactExtractionEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Enrichment.FactExtractionEnabled },
+	},
+	{
+		key: "enrichment.fact_min_confidence", typ: kFloat, env: "TBYD_ENRICHMENT_FACT_MIN_CONFIDENCE",
+		apply:   func(cfg *Config, v any) { cfg.Enrichment.FactMinConfidence = v.(float64) },
+		extract: func(cfg Config) any { return cfg.Enrichment.FactMinConfidence },
+	},
+	{
+		key: "profile.auto_accept_enabled", typ: kBool, env: "TBYD_PROFILE_AUTO_ACCEPT_ENABLED",
+		apply:   func(cfg *Config, v any) { cfg.Profile.AutoAcceptEnabled = v.(bool) },
+		extract: func(cfg Config) any { return cfg.Profile.AutoAcceptEnabled },
+	},
+	{
+		key: "profile.auto_accept_min_count", typ: kInt, env: "TBYD_PROFILE_AUTO_ACCEPT_MIN_COUNT",
+		apply:   func(cfg *Config, v any) { cfg.Profile.AutoAcceptMinCount = v.(int) },
+		extract: func(cfg Config) any { return cfg.Profile.AutoAcceptMinCount },
+	},
+	{
+		key: "profile.auto_accept_min_confidence", typ: kFloat, env: "TBYD_PROFILE_AUTO_ACCEPT_MIN_CONFIDENCE",
+		apply:   func(cfg *Config, v any) { cfg.Profile.AutoAcceptMinConfidence = v.(float64) },
+		extract: func(cfg Config) any { return cfg.Profile.AutoAcceptMinConfidence },
+	},
+	{
+		key: "profile.delta_expiry_days", typ: kInt, env: "TBYD_PROFILE_DELTA_EXPIRY_DAYS",
+		apply:   func(cfg *Config, v any) { cfg.Profile.DeltaExpiryDays = v.(int) },
+		extract: func(cfg Config) any { return cfg.Profile.DeltaExpiryDays },
+	},
+	{
+		key: "scheduler.timezone", typ: kString, env: "TBYD_SCHEDULER_TIMEZONE",
+		apply:   func(cfg *Config, v any) { cfg.Scheduler.Timezone = v.(string) },
+		extract: func(cfg Config) any { return cfg.Scheduler.Timezone },
+	},
+	{
+		key: "scheduler.jitter", typ: kString, env: "TBYD_SCHEDULER_JITTER",
+		apply:   func(cfg *Config, v any) { cfg.Scheduler.Jitter = v.(string) },
+		extract: func(cfg Config) any { return cfg.Scheduler.Jitter },
+	},
+	{
+		key: "scheduler.schedules", typ: kString, env: "TBYD_SCHEDULER_SCHEDULES",
+		apply:   func(cfg *Config, v any) { cfg.Scheduler.Schedules = v.(string) },
+		extract: func(cfg Config) any { return cfg.Scheduler.Schedules },
+	},
 }
 
 func applyBackend(cfg *Config, b ConfigBackend) error {
@@ -204,6 +364,27 @@ func applyBackend(cfg *Config, b ConfigBackend) error {
 	return nil
 }
 
+// validateEnums rejects string keys whose value, from any source, is not one
+// of the spec's allowed values.
+func validateEnums(cfg Config) error {
+	for _, s := range specs {
+		if s.oneOf == nil {
+			continue
+		}
+		if err := s.checkOneOf(s.extract(cfg).(string)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s keySpec) checkOneOf(v string) error {
+	if s.oneOf == nil || slices.Contains(s.oneOf, v) {
+		return nil
+	}
+	return fmt.Errorf("invalid value %q for %s: must be one of %s", v, s.key, strings.Join(s.oneOf, ", "))
+}
+
 func applyEnvOverrides(cfg *Config) {
 	for _, s := range specs {
 		if s.env == "" {
@@ -44,6 +44,9 @@ func setKeyWith(b ConfigBackend, key, value string) error {
 		}
 		switch s.typ {
 		case kString:
+			if err := s.checkOneOf(value); err != nil {
+				return err
+			}
 			return b.SetString(key, value)
 		case kInt:
 			i, err := strconv.Atoi(value)
@@ -56,6 +59,11 @@ func setKeyWith(b ConfigBackend, key, value string) error {
 				return fmt.Errorf("invalid boolean value for %s: %w", key, err)
 			}
 			return b.SetString(key, value)
+		case kFloat:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("invalid float value for %s: %w", key, err)
+			}
+			return b.SetString(key, value)
 		}
 	}
 
@@ -65,7 +73,7 @@ func setKeyWith(b ConfigBackend, key, value string) error {
 // IsKeySet reports whether the given key has been explicitly stored in the
 // platform backend (ignoring environment variable overrides and defaults).
 // It uses GetString for all key types because SetKey stores kBool values via
-// SetString (see the kBool case above). If SetKey ever switches to a typed
+// SetString (see the kBool and kFloat cases above). If SetKey ever switches to a typed
 // setter for booleans, this function must be updated in tandem.
 func IsKeySet(key string) (bool, error) {
 	return isKeySetWith(newPlatformBackend(), key)
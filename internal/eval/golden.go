@@ -0,0 +1,110 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GoldenQuery is one evaluation query with the IDs of the documents a good
+// retrieval should return. Relevant IDs are matched against both the chunk's
+// SourceID (context doc or interaction ID) and its vector record ID.
+type GoldenQuery struct {
+	ID       string   `json:"id" yaml:"id"`
+	Query    string   `json:"query" yaml:"query"`
+	Relevant []string `json:"relevant" yaml:"relevant"`
+}
+
+// goldenFile is the YAML layout: a top-level "queries" list.
+type goldenFile struct {
+	Queries []GoldenQuery `yaml:"queries"`
+}
+
+// LoadGoldenSet reads a golden query set from path. Files ending in .yaml or
+// .yml are parsed as YAML with a top-level "queries" list; .jsonl files hold
+// one JSON query object per line (blank lines and lines starting with # are
+// skipped). Queries without an ID are numbered by position.
+func LoadGoldenSet(path string) ([]GoldenQuery, error) {
+	var queries []GoldenQuery
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		queries, err = loadYAML(path)
+	case ".jsonl":
+		queries, err = loadJSONL(path)
+	default:
+		return nil, fmt.Errorf("unsupported golden set format %q (want .yaml, .yml, or .jsonl)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("golden set %s contains no queries", path)
+	}
+	seen := make(map[string]bool, len(queries))
+	for i := range queries {
+		q := &queries[i]
+		if q.ID == "" {
+			q.ID = fmt.Sprintf("q%03d", i+1)
+		}
+		if seen[q.ID] {
+			return nil, fmt.Errorf("duplicate query id %q", q.ID)
+		}
+		seen[q.ID] = true
+		if strings.TrimSpace(q.Query) == "" {
+			return nil, fmt.Errorf("query %q has empty text", q.ID)
+		}
+		if len(q.Relevant) == 0 {
+			return nil, fmt.Errorf("query %q has no relevant IDs", q.ID)
+		}
+	}
+	return queries, nil
+}
+
+func loadYAML(path string) ([]GoldenQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading golden set: %w", err)
+	}
+	var f goldenFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing golden set YAML: %w", err)
+	}
+	return f.Queries, nil
+}
+
+func loadJSONL(path string) ([]GoldenQuery, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading golden set: %w", err)
+	}
+	defer f.Close()
+
+	var queries []GoldenQuery
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var q GoldenQuery
+		if err := json.Unmarshal([]byte(text), &q); err != nil {
+			return nil, fmt.Errorf("parsing golden set line %d: %w", line, err)
+		}
+		queries = append(queries, q)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading golden set: %w", err)
+	}
+	return queries, nil
+}
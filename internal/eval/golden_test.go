@@ -0,0 +1,79 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadGoldenSet_YAML(t *testing.T) {
+	path := writeFile(t, "golden.yaml", `
+queries:
+  - id: go-errors
+    query: how do I wrap errors in go
+    relevant: [doc-1, doc-2]
+  - query: sqlite wal mode
+    relevant: [doc-3]
+`)
+
+	queries, err := LoadGoldenSet(path)
+	if err != nil {
+		t.Fatalf("LoadGoldenSet: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("got %d queries, want 2", len(queries))
+	}
+	if queries[0].ID != "go-errors" || len(queries[0].Relevant) != 2 {
+		t.Errorf("queries[0] = %+v", queries[0])
+	}
+	if queries[1].ID != "q002" {
+		t.Errorf("generated ID = %q, want q002", queries[1].ID)
+	}
+}
+
+func TestLoadGoldenSet_JSONL(t *testing.T) {
+	path := writeFile(t, "golden.jsonl", `# comment line
+{"id": "a", "query": "first", "relevant": ["doc-1"]}
+
+{"id": "b", "query": "second", "relevant": ["doc-2", "doc-3"]}
+`)
+
+	queries, err := LoadGoldenSet(path)
+	if err != nil {
+		t.Fatalf("LoadGoldenSet: %v", err)
+	}
+	if len(queries) != 2 || queries[1].ID != "b" || len(queries[1].Relevant) != 2 {
+		t.Fatalf("got %+v", queries)
+	}
+}
+
+func TestLoadGoldenSet_Invalid(t *testing.T) {
+	tests := []struct {
+		name, file, content, wantErr string
+	}{
+		{"unsupported ext", "golden.txt", "", "unsupported"},
+		{"empty", "golden.jsonl", "\n", "no queries"},
+		{"duplicate id", "golden.jsonl", `{"id":"a","query":"x","relevant":["d"]}` + "\n" + `{"id":"a","query":"y","relevant":["d"]}`, "duplicate"},
+		{"no relevant", "golden.jsonl", `{"id":"a","query":"x"}`, "no relevant"},
+		{"empty query", "golden.yaml", "queries:\n  - id: a\n    relevant: [d]\n", "empty text"},
+		{"bad json", "golden.jsonl", `{"id":`, "line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadGoldenSet(writeFile(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
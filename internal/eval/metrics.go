@@ -0,0 +1,70 @@
+package eval
+
+import "math"
+
+// judge returns, for each retrieved position, the relevant ID it matched (or
+// "" for a miss). Each relevant ID is credited at most once, at its first
+// occurrence, so duplicate chunks of one document cannot inflate recall.
+func judge(retrieved [][2]string, relevant map[string]bool) []string {
+	credited := make(map[string]bool, len(relevant))
+	hits := make([]string, len(retrieved))
+	for i, ids := range retrieved {
+		for _, id := range ids {
+			if id != "" && relevant[id] && !credited[id] {
+				credited[id] = true
+				hits[i] = id
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// recall is the fraction of relevant IDs found in hits.
+func recall(hits []string, numRelevant int) float64 {
+	if numRelevant == 0 {
+		return 0
+	}
+	found := 0
+	for _, h := range hits {
+		if h != "" {
+			found++
+		}
+	}
+	return float64(found) / float64(numRelevant)
+}
+
+// reciprocalRank is 1/rank of the first hit, or 0 if there is none.
+func reciprocalRank(hits []string) float64 {
+	for i, h := range hits {
+		if h != "" {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// ndcg computes binary-relevance nDCG over hits, normalised by the ideal
+// ranking of min(numRelevant, k) relevant results.
+func ndcg(hits []string, numRelevant, k int) float64 {
+	var dcg float64
+	for i, h := range hits {
+		if h != "" {
+			dcg += 1 / math.Log2(float64(i+2))
+		}
+	}
+	var idcg float64
+	for i := range min(numRelevant, k) {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// round4 rounds to four decimal places so reports are stable across runs and
+// float formatting noise does not show up in diffs.
+func round4(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,271 @@
+// Package eval runs golden query sets against the retrieval stack and reports
+// ranking quality and latency per search strategy.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/intent"
+	"github.com/kalambet/tbyd/internal/reranking"
+	"github.com/kalambet/tbyd/internal/retrieval"
+)
+
+// candidateMultiplier mirrors the enrichment pipeline: retrieve topK×4
+// candidates, rerank, then trim back to topK.
+const candidateMultiplier = 4
+
+// Searcher is the retrieval surface under evaluation. *retrieval.Retriever
+// satisfies it.
+type Searcher interface {
+	RetrieveForIntent(ctx context.Context, query string, extracted intent.Intent, topK int) []retrieval.ContextChunk
+}
+
+// Strategy is one retrieval configuration to evaluate.
+type Strategy struct {
+	Name           string
+	SearchStrategy string   // "vector_only", "hybrid", or "keyword_heavy"
+	HybridRatio    *float64 // nil = retriever default for the strategy
+	Expand         bool     // search with query expansion; see SetExpandingSearcher
+	Rerank         bool
+}
+
+// DefaultStrategies returns vector-only, hybrid, and keyword-heavy search,
+// each followed by its reranked variant when withRerank is true and by its
+// query-expansion variants when withExpand is true.
+func DefaultStrategies(withRerank, withExpand bool) []Strategy {
+	var out []Strategy
+	for _, s := range []string{"vector_only", "hybrid", "keyword_heavy"} {
+		out = append(out, Strategy{Name: s, SearchStrategy: s})
+		if withRerank {
+			out = append(out, Strategy{Name: s + "+rerank", SearchStrategy: s, Rerank: true})
+		}
+		if withExpand {
+			out = append(out, Strategy{Name: s + "+expand", SearchStrategy: s, Expand: true})
+			if withRerank {
+				out = append(out, Strategy{Name: s + "+expand+rerank", SearchStrategy: s, Expand: true, Rerank: true})
+			}
+		}
+	}
+	return out
+}
+
+// Runner evaluates strategies over a golden set.
+type Runner struct {
+	searcher  Searcher
+	expanding Searcher
+	reranker  reranking.Reranker
+	k         int
+}
+
+// NewRunner creates a Runner scoring the top k results. searcher should not
+// expand queries, so that the base strategies measure retrieval alone.
+// reranker is used only by strategies with Rerank set and may be nil if none
+// are.
+func NewRunner(searcher Searcher, reranker reranking.Reranker, k int) *Runner {
+	return &Runner{searcher: searcher, reranker: reranker, k: k}
+}
+
+// SetExpandingSearcher sets the searcher used by strategies with Expand set.
+// Must be called before Run.
+func (r *Runner) SetExpandingSearcher(s Searcher) {
+	r.expanding = s
+}
+
+// Report is the full evaluation result. Field order, strategy order, and
+// per-query order are deterministic so that JSON output diffs cleanly.
+type Report struct {
+	K          int              `json:"k"`
+	Queries    int              `json:"queries"`
+	Strategies []StrategyReport `json:"strategies"`
+}
+
+// StrategyReport holds aggregate and per-query results for one strategy.
+type StrategyReport struct {
+	Name      string        `json:"name"`
+	RecallAtK float64       `json:"recall_at_k"`
+	MRR       float64       `json:"mrr"`
+	NDCG      float64       `json:"ndcg_at_k"`
+	Latency   *LatencyStats `json:"latency,omitempty"`
+	PerQuery  []QueryReport `json:"per_query"`
+}
+
+// LatencyStats summarises per-query latency in milliseconds.
+type LatencyStats struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+}
+
+// QueryReport is the outcome of one golden query under one strategy.
+type QueryReport struct {
+	ID             string   `json:"id"`
+	Recall         float64  `json:"recall"`
+	ReciprocalRank float64  `json:"reciprocal_rank"`
+	NDCG           float64  `json:"ndcg"`
+	Missed         []string `json:"missed,omitempty"`
+	Retrieved      []string `json:"retrieved"`
+}
+
+// Run evaluates every strategy over every query. Reranker errors fall back to
+// the retrieval order, as the enrichment pipeline does.
+func (r *Runner) Run(ctx context.Context, queries []GoldenQuery, strategies []Strategy) (Report, error) {
+	if r.k <= 0 {
+		return Report{}, fmt.Errorf("k must be positive, got %d", r.k)
+	}
+
+	for _, s := range strategies {
+		if s.Rerank && r.reranker == nil {
+			return Report{}, fmt.Errorf("strategy %q requires a reranker", s.Name)
+		}
+		if s.Expand && r.expanding == nil {
+			return Report{}, fmt.Errorf("strategy %q requires an expanding searcher", s.Name)
+		}
+	}
+
+	report := Report{K: r.k, Queries: len(queries)}
+	for _, s := range strategies {
+
+		sr := StrategyReport{Name: s.Name}
+		latencies := make([]time.Duration, 0, len(queries))
+		for _, q := range queries {
+			if err := ctx.Err(); err != nil {
+				return Report{}, err
+			}
+
+			start := time.Now()
+			chunks := r.retrieve(ctx, q.Query, s)
+			latencies = append(latencies, time.Since(start))
+
+			qr := scoreQuery(q, chunks, r.k)
+			sr.RecallAtK += qr.Recall
+			sr.MRR += qr.ReciprocalRank
+			sr.NDCG += qr.NDCG
+			sr.PerQuery = append(sr.PerQuery, qr)
+		}
+
+		if n := float64(len(queries)); n > 0 {
+			sr.RecallAtK = round4(sr.RecallAtK / n)
+			sr.MRR = round4(sr.MRR / n)
+			sr.NDCG = round4(sr.NDCG / n)
+		}
+		sr.Latency = summarizeLatency(latencies)
+		report.Strategies = append(report.Strategies, sr)
+	}
+	return report, nil
+}
+
+func (r *Runner) retrieve(ctx context.Context, query string, s Strategy) []retrieval.ContextChunk {
+	in := intent.Intent{SearchStrategy: s.SearchStrategy, HybridRatio: s.HybridRatio}
+	searcher := r.searcher
+	if s.Expand {
+		searcher = r.expanding
+	}
+	chunks := searcher.RetrieveForIntent(ctx, query, in, r.k*candidateMultiplier)
+
+	if s.Rerank {
+		if reranked, err := r.reranker.Rerank(ctx, query, chunks); err == nil {
+			chunks = reranked
+		}
+	}
+	if len(chunks) > r.k {
+		chunks = chunks[:r.k]
+	}
+	return chunks
+}
+
+func scoreQuery(q GoldenQuery, chunks []retrieval.ContextChunk, k int) QueryReport {
+	relevant := make(map[string]bool, len(q.Relevant))
+	for _, id := range q.Relevant {
+		relevant[id] = true
+	}
+
+	ids := make([][2]string, len(chunks))
+	retrieved := make([]string, len(chunks))
+	for i, ch := range chunks {
+		ids[i] = [2]string{ch.SourceID, ch.ID}
+		retrieved[i] = ch.SourceID
+	}
+	hits := judge(ids, relevant)
+
+	found := make(map[string]bool, len(hits))
+	for _, h := range hits {
+		found[h] = true
+	}
+	var missed []string
+	for _, id := range q.Relevant {
+		if !found[id] {
+			missed = append(missed, id)
+		}
+	}
+	sort.Strings(missed)
+
+	return QueryReport{
+		ID:             q.ID,
+		Recall:         round4(recall(hits, len(relevant))),
+		ReciprocalRank: round4(reciprocalRank(hits)),
+		NDCG:           round4(ndcg(hits, len(relevant), k)),
+		Missed:         missed,
+		Retrieved:      retrieved,
+	}
+}
+
+func summarizeLatency(d []time.Duration) *LatencyStats {
+	if len(d) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(d)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, v := range sorted {
+		total += v
+	}
+	ms := func(v time.Duration) float64 { return round4(float64(v) / float64(time.Millisecond)) }
+	pct := func(p float64) time.Duration {
+		idx := int(p*float64(len(sorted))+0.5) - 1
+		return sorted[min(max(idx, 0), len(sorted)-1)]
+	}
+
+	return &LatencyStats{
+		MeanMs: ms(total / time.Duration(len(sorted))),
+		P50Ms:  ms(pct(0.50)),
+		P95Ms:  ms(pct(0.95)),
+	}
+}
+
+// StripTiming removes latency figures so that two runs over the same store
+// and golden set produce byte-identical reports.
+func (r *Report) StripTiming() {
+	for i := range r.Strategies {
+		r.Strategies[i].Latency = nil
+	}
+}
+
+// WriteJSON writes the report as indented JSON followed by a newline.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteTable writes a one-line-per-strategy summary table.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STRATEGY\tRECALL@%d\tMRR\tNDCG@%d\tP50 MS\tP95 MS\n", r.K, r.K)
+	for _, s := range r.Strategies {
+		p50, p95 := "-", "-"
+		if s.Latency != nil {
+			p50 = fmt.Sprintf("%.1f", s.Latency.P50Ms)
+			p95 = fmt.Sprintf("%.1f", s.Latency.P95Ms)
+		}
+		fmt.Fprintf(tw, "%s\t%.4f\t%.4f\t%.4f\t%s\t%s\n", s.Name, s.RecallAtK, s.MRR, s.NDCG, p50, p95)
+	}
+	return tw.Flush()
+}
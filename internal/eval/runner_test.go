@@ -0,0 +1,214 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/kalambet/tbyd/internal/intent"
+	"github.com/kalambet/tbyd/internal/retrieval"
+)
+
+// fakeSearcher returns a fixed ranking of SourceIDs per search strategy.
+type fakeSearcher struct {
+	results map[string][]string
+	calls   []intent.Intent
+}
+
+func (f *fakeSearcher) RetrieveForIntent(_ context.Context, _ string, in intent.Intent, topK int) []retrieval.ContextChunk {
+	f.calls = append(f.calls, in)
+	var chunks []retrieval.ContextChunk
+	for _, id := range f.results[in.SearchStrategy] {
+		chunks = append(chunks, retrieval.ContextChunk{ID: "vec-" + id, SourceID: id})
+	}
+	if len(chunks) > topK {
+		chunks = chunks[:topK]
+	}
+	return chunks
+}
+
+// reverseReranker reverses the candidate order.
+type reverseReranker struct{ err error }
+
+func (r *reverseReranker) Rerank(_ context.Context, _ string, chunks []retrieval.ContextChunk) ([]retrieval.ContextChunk, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	out := make([]retrieval.ContextChunk, len(chunks))
+	for i, ch := range chunks {
+		out[len(chunks)-1-i] = ch
+	}
+	return out, nil
+}
+
+func almostEqual(a, b float64) bool { return math.Abs(a-b) < 1e-4 }
+
+func TestMetrics(t *testing.T) {
+	rel := map[string]bool{"a": true, "b": true}
+	// Relevant docs at ranks 2 and 3; "a" repeats at rank 4 and is not re-credited.
+	hits := judge([][2]string{{"x", ""}, {"a", ""}, {"", "b"}, {"a", ""}}, rel)
+
+	if got := recall(hits, 2); got != 1 {
+		t.Errorf("recall = %g, want 1", got)
+	}
+	if got := reciprocalRank(hits); got != 0.5 {
+		t.Errorf("MRR = %g, want 0.5", got)
+	}
+	// DCG = 1/log2(3) + 1/log2(4); IDCG = 1 + 1/log2(3).
+	want := (1/math.Log2(3) + 0.5) / (1 + 1/math.Log2(3))
+	if got := ndcg(hits, 2, 4); !almostEqual(got, want) {
+		t.Errorf("nDCG = %g, want %g", got, want)
+	}
+	if got := reciprocalRank(judge([][2]string{{"x", ""}}, rel)); got != 0 {
+		t.Errorf("MRR with no hits = %g, want 0", got)
+	}
+}
+
+func TestRunner_PerStrategyReport(t *testing.T) {
+	searcher := &fakeSearcher{results: map[string][]string{
+		"vector_only":   {"a", "x", "y"},
+		"hybrid":        {"x", "y", "a"},
+		"keyword_heavy": {"x", "y", "z"},
+	}}
+	queries := []GoldenQuery{{ID: "q1", Query: "q", Relevant: []string{"a"}}}
+
+	report, err := NewRunner(searcher, &reverseReranker{}, 3).Run(context.Background(), queries, DefaultStrategies(true, false))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := map[string]float64{
+		"vector_only":          1,
+		"vector_only+rerank":   1.0 / 3,
+		"hybrid":               1.0 / 3,
+		"hybrid+rerank":        1,
+		"keyword_heavy":        0,
+		"keyword_heavy+rerank": 0,
+	}
+	if len(report.Strategies) != len(want) {
+		t.Fatalf("got %d strategies, want %d", len(report.Strategies), len(want))
+	}
+	for _, s := range report.Strategies {
+		if !almostEqual(s.MRR, want[s.Name]) {
+			t.Errorf("%s MRR = %g, want %g", s.Name, s.MRR, want[s.Name])
+		}
+		if s.Latency == nil {
+			t.Errorf("%s missing latency", s.Name)
+		}
+	}
+	if missed := report.Strategies[4].PerQuery[0].Missed; len(missed) != 1 || missed[0] != "a" {
+		t.Errorf("keyword_heavy missed = %v, want [a]", missed)
+	}
+	// Retrieval fetches the pipeline's candidate pool, not just k.
+	if len(searcher.calls) == 0 || searcher.calls[0].SearchStrategy != "vector_only" {
+		t.Fatalf("unexpected searcher calls: %+v", searcher.calls)
+	}
+}
+
+func TestRunner_RerankErrorKeepsRetrievalOrder(t *testing.T) {
+	searcher := &fakeSearcher{results: map[string][]string{"hybrid": {"a", "b"}}}
+	queries := []GoldenQuery{{ID: "q1", Query: "q", Relevant: []string{"a"}}}
+	strategies := []Strategy{{Name: "hybrid+rerank", SearchStrategy: "hybrid", Rerank: true}}
+
+	report, err := NewRunner(searcher, &reverseReranker{err: errors.New("timeout")}, 2).Run(context.Background(), queries, strategies)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Strategies[0].MRR != 1 {
+		t.Errorf("MRR = %g, want 1 (original order)", report.Strategies[0].MRR)
+	}
+}
+
+func TestRunner_RerankStrategyWithoutReranker(t *testing.T) {
+	_, err := NewRunner(&fakeSearcher{}, nil, 5).Run(context.Background(), nil, DefaultStrategies(true, false))
+	if err == nil {
+		t.Fatal("expected error when a rerank strategy has no reranker")
+	}
+}
+
+func TestRunner_ExpandStrategiesUseExpandingSearcher(t *testing.T) {
+	plain := &fakeSearcher{results: map[string][]string{"hybrid": {"x", "a"}}}
+	expanding := &fakeSearcher{results: map[string][]string{"hybrid": {"a", "x"}}}
+	queries := []GoldenQuery{{ID: "q1", Query: "q", Relevant: []string{"a"}}}
+	strategies := []Strategy{
+		{Name: "hybrid", SearchStrategy: "hybrid"},
+		{Name: "hybrid+expand", SearchStrategy: "hybrid", Expand: true},
+	}
+
+	r := NewRunner(plain, nil, 2)
+	if _, err := r.Run(context.Background(), queries, strategies); err == nil {
+		t.Fatal("expected error when an expand strategy has no expanding searcher")
+	}
+
+	r.SetExpandingSearcher(expanding)
+	report, err := r.Run(context.Background(), queries, strategies)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(plain.calls) != 1 || len(expanding.calls) != 1 {
+		t.Errorf("calls = %d plain, %d expanding; want 1 each", len(plain.calls), len(expanding.calls))
+	}
+	if report.Strategies[0].MRR != 0.5 || report.Strategies[1].MRR != 1 {
+		t.Errorf("MRR = %g, %g; want 0.5 without expansion and 1 with", report.Strategies[0].MRR, report.Strategies[1].MRR)
+	}
+}
+
+func TestDefaultStrategies_ExpansionIsOptIn(t *testing.T) {
+	for _, s := range DefaultStrategies(true, false) {
+		if s.Expand {
+			t.Errorf("strategy %q expands queries without withExpand", s.Name)
+		}
+	}
+	var expand int
+	for _, s := range DefaultStrategies(true, true) {
+		if s.Expand {
+			expand++
+		}
+	}
+	if expand != 6 {
+		t.Errorf("got %d expand strategies, want 6 (+expand and +expand+rerank per search)", expand)
+	}
+}
+
+func TestReport_DiffableOutput(t *testing.T) {
+	searcher := &fakeSearcher{results: map[string][]string{"hybrid": {"b", "a"}}}
+	queries := []GoldenQuery{
+		{ID: "q1", Query: "one", Relevant: []string{"a", "c"}},
+		{ID: "q2", Query: "two", Relevant: []string{"b"}},
+	}
+	strategies := []Strategy{{Name: "hybrid", SearchStrategy: "hybrid"}}
+
+	render := func() string {
+		report, err := NewRunner(searcher, nil, 2).Run(context.Background(), queries, strategies)
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		report.StripTiming()
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON: %v", err)
+		}
+		return buf.String()
+	}
+
+	first, second := render(), render()
+	if first != second {
+		t.Errorf("reports differ across runs:\n%s\n---\n%s", first, second)
+	}
+	if strings.Contains(first, "latency") {
+		t.Error("stripped report still contains latency")
+	}
+	if !strings.Contains(first, `"recall_at_k": 0.75`) {
+		t.Errorf("unexpected aggregate recall in:\n%s", first)
+	}
+
+	var table bytes.Buffer
+	report, _ := NewRunner(searcher, nil, 2).Run(context.Background(), queries, strategies)
+	report.WriteTable(&table)
+	if !strings.Contains(table.String(), "RECALL@2") || !strings.Contains(table.String(), "hybrid") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
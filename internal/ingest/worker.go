@@ -39,6 +39,12 @@ type Summarizer interface {
 	Summarize(ctx context.Context, userQuery, cloudResponse string) (string, error)
 }
 
+// TopicInvalidator evicts cached enrichments whose topics overlap newly
+// ingested content.
+type TopicInvalidator interface {
+	InvalidateByTopics(topics []string)
+}
+
 // interactionIDNamespacePrefix is the namespace prefix used to derive
 // deterministic vector IDs from interaction IDs via uuid.NewSHA1.
 const interactionIDNamespacePrefix = "interaction:"
@@ -49,6 +55,7 @@ type Worker struct {
 	embedder   ContentEmbedder
 	vectors    VectorInserter
 	summarizer Summarizer
+	cache      TopicInvalidator
 	poll       time.Duration
 	logger     *slog.Logger
 }
@@ -73,6 +80,12 @@ func (w *Worker) SetSummarizer(s Summarizer) {
 	w.summarizer = s
 }
 
+// SetCache configures the query cache to invalidate when a tagged context doc
+// is ingested, so enrichments on its topics pick up the new content.
+func (w *Worker) SetCache(c TopicInvalidator) {
+	w.cache = c
+}
+
 // Run polls for jobs until ctx is cancelled.
 func (w *Worker) Run(ctx context.Context) {
 	for {
@@ -159,6 +172,8 @@ func (w *Worker) processEnrichJob(ctx context.Context, job *storage.Job) error {
 		Embedding:  vec,
 		CreatedAt:  time.Now().UTC(),
 		Tags:       doc.Tags,
+		Partition:  doc.Partition,
+		Project:    doc.Project,
 	}
 
 	if err := w.vectors.Insert(retrieval.VectorTable, []retrieval.Record{rec}); err != nil {
@@ -169,6 +184,17 @@ func (w *Worker) processEnrichJob(ctx context.Context, job *storage.Job) error {
 		return fmt.Errorf("updating vector_id: %w", err)
 	}
 
+	// Untagged docs leave the cache alone: flushing everything on every
+	// ingest would defeat it, and stale-but-complete entries expire by TTL.
+	if w.cache != nil {
+		var tags []string
+		if err := json.Unmarshal([]byte(doc.Tags), &tags); err != nil {
+			w.logger.Warn("could not parse doc tags for cache invalidation", "doc_id", doc.ID, "error", err)
+		} else if len(tags) > 0 {
+			w.cache.InvalidateByTopics(tags)
+		}
+	}
+
 	return nil
 }
 
@@ -227,6 +253,7 @@ func (w *Worker) processSummarizeJob(ctx context.Context, job *storage.Job) erro
 		Embedding:  vec,
 		CreatedAt:  time.Now().UTC(),
 		Tags:       "[]",
+		Partition:  interaction.Partition,
 	}
 
 	if err := w.vectors.Insert(retrieval.VectorTable, []retrieval.Record{rec}); err != nil {
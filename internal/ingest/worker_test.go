@@ -123,6 +123,54 @@ func TestWorker_ProcessesJob(t *testing.T) {
 	}
 }
 
+type recordingInvalidator struct {
+	topics [][]string
+}
+
+func (r *recordingInvalidator) InvalidateByTopics(topics []string) {
+	r.topics = append(r.topics, topics)
+}
+
+func TestWorker_IngestInvalidatesCacheByTags(t *testing.T) {
+	store := openTestStore(t)
+	enqueueTestJob(t, store, "doc-1", "Hello world")
+
+	inv := &recordingInvalidator{}
+	w := NewWorker(store, &mockEmbedder{
+		embedFn: func(_ context.Context, _ string) ([]float32, error) {
+			return []float32{0.1, 0.2, 0.3}, nil
+		},
+	}, &mockVectorInserter{}, 0)
+	w.SetCache(inv)
+
+	if _, err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce error: %v", err)
+	}
+	if len(inv.topics) != 1 || len(inv.topics[0]) != 1 || inv.topics[0][0] != "test" {
+		t.Errorf("invalidated topics = %v, want [[test]]", inv.topics)
+	}
+}
+
+func TestWorker_FailedIngestLeavesCache(t *testing.T) {
+	store := openTestStore(t)
+	enqueueTestJob(t, store, "doc-1", "Hello world")
+
+	inv := &recordingInvalidator{}
+	w := NewWorker(store, &mockEmbedder{
+		embedFn: func(_ context.Context, _ string) ([]float32, error) {
+			return nil, fmt.Errorf("embed failed")
+		},
+	}, &mockVectorInserter{}, 0)
+	w.SetCache(inv)
+
+	if _, err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce error: %v", err)
+	}
+	if len(inv.topics) != 0 {
+		t.Errorf("cache invalidated for a failed ingest: %v", inv.topics)
+	}
+}
+
 func TestWorker_RetryOnFailure(t *testing.T) {
 	store := openTestStore(t)
 	enqueueTestJob(t, store, "doc-r", "retry content")
@@ -19,23 +19,58 @@ import (
 // EnrichmentMetadata captures diagnostic information about the enrichment process.
 type EnrichmentMetadata struct {
 	IntentExtracted      bool
+	IntentType           string
+	Topics               []string // intent topics, recorded for interest promotion and decay
 	ChunksUsed           []string
+	ChunkLegs            map[string][]string // vector ID -> retrieval legs that surfaced it
+	SearchStrategy       string              // effective strategy: "vector_only", "hybrid", or "keyword_heavy"
 	EnrichmentDurationMs int64
 	RerankingDurationMs  int64
 	CacheHit             bool
 	CacheLevel           string // "exact" or "semantic"
+	ModelPreference      string // the persona profile's cloud_model_preference, for model routing
 }
 
 // Enricher orchestrates the enrichment pipeline: intent extraction, context
 // retrieval, reranking, profile loading, and prompt composition.
 type Enricher struct {
-	extractor *intent.Extractor
-	retriever *retrieval.Retriever
-	reranker  reranking.Reranker
-	profile   *profile.Manager
-	composer  *composer.Composer
-	cache     *cache.QueryCache
-	topK      int
+	extractor   *intent.Extractor
+	retriever   *retrieval.Retriever
+	reranker    reranking.Reranker
+	profile     *profile.Manager
+	personas    *profile.Registry // optional; see SetPersonas
+	projectOnly bool              // see SetProjectOnly
+	composer    *composer.Composer
+	cache       *cache.QueryCache
+	topK        int
+}
+
+// Persona selects whose profile and knowledge-base partition an enrichment
+// uses, and the project workspace it prefers. The zero Persona is the default
+// persona with no active project.
+type Persona struct {
+	Name        string // "" or profile.DefaultPersona for the default persona
+	Partitioned bool   // retrieve only from the persona's own partition
+	Project     string // active project workspace; "" for none
+}
+
+// CacheScope returns the query-cache scope for a persona name. The default
+// persona uses the unscoped cache.
+func CacheScope(persona string) string {
+	if persona == profile.DefaultPersona {
+		return ""
+	}
+	return persona
+}
+
+// Partition returns the knowledge-base partition documents ingested as p
+// belong to, and that p retrieves from: its own name when partitioned,
+// otherwise the shared partition "".
+func (p Persona) Partition() string {
+	if p.Partitioned && CacheScope(p.Name) != "" {
+		return p.Name
+	}
+	return ""
 }
 
 // NewEnricher creates an Enricher wired to all pipeline components.
@@ -68,6 +103,20 @@ func NewEnricher(
 	}
 }
 
+// SetPersonas enables per-persona enrichment: EnrichAs uses reg's manager for
+// non-default personas. Must be called before the enricher is used
+// concurrently.
+func (e *Enricher) SetPersonas(reg *profile.Registry) {
+	e.personas = reg
+}
+
+// SetProjectOnly makes retrieval for an active project drop other projects'
+// documents instead of down-ranking them. Must be called before the enricher
+// is used concurrently.
+func (e *Enricher) SetProjectOnly(only bool) {
+	e.projectOnly = only
+}
+
 // candidateMultiplier controls how many extra candidates are fetched for
 // reranking. Retrieval fetches topK*candidateMultiplier chunks; after reranking
 // the result is trimmed back to topK. This lets the reranker surface relevant
@@ -86,21 +135,41 @@ const candidateMultiplier = 4
 // On failure at any step, the pipeline degrades gracefully — the original
 // request is enriched with whatever context is available.
 func (e *Enricher) Enrich(ctx context.Context, req proxy.ChatRequest) (out proxy.ChatRequest, meta EnrichmentMetadata) {
+	return e.EnrichAs(ctx, Persona{}, req)
+}
+
+// EnrichAs is Enrich for the given persona: its profile is injected, its
+// knowledge-base partition is searched with its active project preferred, and
+// its cache entries are kept apart from other personas' and projects'.
+func (e *Enricher) EnrichAs(ctx context.Context, persona Persona, req proxy.ChatRequest) (out proxy.ChatRequest, meta EnrichmentMetadata) {
 	start := time.Now()
 	defer func() {
 		meta.EnrichmentDurationMs = time.Since(start).Milliseconds()
 	}()
 
 	lastUserMsg := extractLastUserMessage(req.Messages)
+	cacheScope := CacheScope(persona.Name)
+	profileMgr := e.profile
+	if cacheScope != "" && e.personas != nil {
+		profileMgr = e.personas.For(persona.Name)
+	}
 
 	// 0. Check cache.
 	var queryEmbedding []float32
+	var cacheGeneration uint64
 	if e.cache != nil {
-		cr := e.cache.Get(ctx, lastUserMsg)
+		cr := e.cache.GetFor(ctx, cacheScope, persona.Project, lastUserMsg)
 		if cr.Hit {
-			if m, ok := cr.Entry.Metadata.(EnrichmentMetadata); ok {
+			switch m := cr.Entry.Metadata.(type) {
+			case EnrichmentMetadata:
 				meta = m
-			} else {
+			case json.RawMessage:
+				// Entries restored from the persisted cache carry raw JSON.
+				if err := json.Unmarshal(m, &meta); err != nil {
+					slog.Warn("cache: could not decode persisted metadata, dropping cached metrics", "error", err)
+					meta = EnrichmentMetadata{}
+				}
+			default:
 				slog.Warn("cache: metadata type mismatch, dropping cached metrics",
 					"type", fmt.Sprintf("%T", cr.Entry.Metadata))
 			}
@@ -110,17 +179,18 @@ func (e *Enricher) Enrich(ctx context.Context, req proxy.ChatRequest) (out proxy
 			return cr.Entry.EnrichedRequest, meta
 		}
 		queryEmbedding = cr.Embedding // reuse embedding from L2 lookup
+		cacheGeneration = cr.Generation
 	}
 
 	// Capture profile version before pipeline work begins. If the profile
 	// changes mid-pipeline, the stored cache entry will carry an older version
-	// and be rejected on subsequent Get calls (see QueryCache.minProfileVersion).
-	profileVersion := e.profile.ProfileVersion()
+	// and be rejected on subsequent Get calls (see QueryCache.InvalidateProfile).
+	profileVersion := profileMgr.ProfileVersion()
 
 	// 1. Load profile (single fetch, reused for extraction and composition).
 	var p profile.Profile
 	var profileLoaded bool
-	if loaded, profErr := e.profile.GetProfile(); profErr == nil {
+	if loaded, profErr := profileMgr.GetProfile(); profErr == nil {
 		p = loaded
 		profileLoaded = true
 	} else {
@@ -130,24 +200,30 @@ func (e *Enricher) Enrich(ctx context.Context, req proxy.ChatRequest) (out proxy
 	// Compute profile summary once — reused for both extraction and composition.
 	var profileSummary string
 	if profileLoaded {
-		profileSummary = e.profile.SummarizeProfile(p)
+		profileSummary = profileMgr.SummarizeProfile(p)
+		meta.ModelPreference = p.CloudModelPreference
 	}
 
 	// Derive calibration from the already-loaded profile to avoid a redundant
 	// storage round-trip through the CalibrationProvider.
 	var calibration profile.CalibrationContext
 	if profileLoaded {
-		calibration = e.profile.BuildCalibration(p)
+		calibration = profileMgr.BuildCalibration(p)
 	}
 
 	// Extract intent — pass profile summary and calibration for domain-aware extraction.
 	extracted := e.extractor.Extract(ctx, lastUserMsg, nil, profileSummary, calibration)
 	if extracted.IntentType != "" {
 		meta.IntentExtracted = true
+		meta.IntentType = extracted.IntentType
+		meta.Topics = extracted.Topics
 	}
 
+	meta.SearchStrategy = retrieval.EffectiveStrategy(extracted.SearchStrategy)
+
 	// 2. Retrieve a larger candidate pool for reranking.
-	candidates := e.retriever.RetrieveForIntent(ctx, lastUserMsg, extracted, e.topK*candidateMultiplier)
+	scope := retrieval.PartitionScope(persona.Partition()).WithProject(persona.Project, e.projectOnly)
+	candidates := e.retriever.RetrieveForIntentIn(ctx, lastUserMsg, extracted, e.topK*candidateMultiplier, scope)
 
 	// 3. Rerank candidates and trim to topK.
 	rerankStart := time.Now()
@@ -161,8 +237,20 @@ func (e *Enricher) Enrich(ctx context.Context, req proxy.ChatRequest) (out proxy
 		chunks = chunks[:e.topK]
 	}
 
+	var sourceIDs []string
+	seenSources := make(map[string]bool, len(chunks))
 	for _, ch := range chunks {
 		meta.ChunksUsed = append(meta.ChunksUsed, ch.ID)
+		if ch.SourceID != "" && !seenSources[ch.SourceID] {
+			seenSources[ch.SourceID] = true
+			sourceIDs = append(sourceIDs, ch.SourceID)
+		}
+		if len(ch.Legs) > 0 {
+			if meta.ChunkLegs == nil {
+				meta.ChunkLegs = make(map[string][]string, len(chunks))
+			}
+			meta.ChunkLegs[ch.ID] = ch.Legs
+		}
 	}
 
 	// 4. Build explicit preferences from the already-loaded profile.
@@ -197,7 +285,12 @@ func (e *Enricher) Enrich(ctx context.Context, req proxy.ChatRequest) (out proxy
 			EnrichedRequest: enriched,
 			Metadata:        metaForCache,
 			Topics:          extracted.Topics,
+			ChunkIDs:        meta.ChunksUsed,
+			SourceIDs:       sourceIDs,
+			Persona:         cacheScope,
+			Project:         persona.Project,
 			ProfileVersion:  profileVersion,
+			Generation:      cacheGeneration,
 		})
 	}
 
@@ -149,8 +149,10 @@ func TestEnrichEndToEnd_ProfileInjected(t *testing.T) {
 	enricher, _, _, store := setupIntegrationPipeline(t)
 
 	// Set profile fields.
-	store.SetProfileKey("identity.role", "software engineer")
-	store.SetProfileKey("communication.tone", "direct")
+	store.CommitProfileChange("test", "set profile", map[string]string{
+		"identity.role":      "software engineer",
+		"communication.tone": "direct",
+	}, nil)
 
 	req := makeIntegrationReq("How do I design a REST API?")
 	enriched, _ := enricher.Enrich(context.Background(), req)
@@ -61,10 +61,12 @@ func (m *mockEngine) PullModel(ctx context.Context, name string, fn func(engine.
 type mockVectorStore struct {
 	searchResults []retrieval.ScoredRecord
 	searchErr     error
+	filters       []string // filter passed to each Search call
 }
 
 func (m *mockVectorStore) Insert(table string, records []retrieval.Record) error { return nil }
 func (m *mockVectorStore) Search(table string, vector []float32, topK int, filter string) ([]retrieval.ScoredRecord, error) {
+	m.filters = append(m.filters, filter)
 	if m.searchErr != nil {
 		return nil, m.searchErr
 	}
@@ -96,24 +98,6 @@ type mockProfileStore struct {
 	err  error
 }
 
-func (m *mockProfileStore) SetProfileKey(key, value string) error {
-	if m.err != nil {
-		return m.err
-	}
-	if m.keys == nil {
-		m.keys = make(map[string]string)
-	}
-	m.keys[key] = value
-	return nil
-}
-
-func (m *mockProfileStore) GetProfileKey(key string) (string, error) {
-	if m.err != nil {
-		return "", m.err
-	}
-	return m.keys[key], nil
-}
-
 func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -124,15 +108,27 @@ func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
 	return m.keys, nil
 }
 
-func (m *mockProfileStore) DeleteProfileKey(key string) error {
+func (m *mockProfileStore) CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
 	if m.keys == nil {
-		return errors.New("not found")
+		m.keys = make(map[string]string)
 	}
-	if _, ok := m.keys[key]; !ok {
-		return errors.New("not found")
+	for _, k := range del {
+		if _, ok := m.keys[k]; !ok {
+			return 0, errors.New("not found")
+		}
+		delete(m.keys, k)
 	}
-	delete(m.keys, key)
-	return nil
+	for k, v := range set {
+		m.keys[k] = v
+	}
+	return 1, nil
+}
+
+func (m *mockProfileStore) GetProfileSnapshot(version int64) (map[string]string, error) {
+	return nil, errors.New("not found")
 }
 
 // --- helpers ---
@@ -357,7 +353,7 @@ func TestEnrich_MetadataPopulated(t *testing.T) {
 		},
 	}
 
-	ps := &mockProfileStore{}
+	ps := &mockProfileStore{keys: map[string]string{"cloud_model_preference": "openai/gpt-4o"}}
 
 	enricher := buildEnricher(chatter, eng, vs, ps)
 	_, meta := enricher.Enrich(context.Background(), makeReq("recall db schema"))
@@ -368,6 +364,13 @@ func TestEnrich_MetadataPopulated(t *testing.T) {
 	if meta.ChunksUsed[0] != "id-aaa" || meta.ChunksUsed[1] != "id-bbb" {
 		t.Errorf("ChunksUsed = %v, want [id-aaa, id-bbb]", meta.ChunksUsed)
 	}
+	// No strategy in the extracted intent: retrieval ran as hybrid.
+	if meta.SearchStrategy != "hybrid" {
+		t.Errorf("SearchStrategy = %q, want hybrid", meta.SearchStrategy)
+	}
+	if meta.IntentType != "recall" || meta.ModelPreference != "openai/gpt-4o" {
+		t.Errorf("IntentType, ModelPreference = %q, %q; want recall, openai/gpt-4o", meta.IntentType, meta.ModelPreference)
+	}
 }
 
 func TestEnrich_DurationTracked(t *testing.T) {
@@ -713,6 +716,14 @@ func TestEnrich_CacheMiss(t *testing.T) {
 	if !meta2.CacheHit {
 		t.Error("expected CacheHit to be true on second call")
 	}
+
+	// Deleting the source document evicts the entry built from it.
+	qc.InvalidateBySources([]string{"src-1"})
+	extractorCalled = false
+	_, meta3 := enricher.Enrich(context.Background(), makeReq("tell me about Go"))
+	if meta3.CacheHit || !extractorCalled {
+		t.Error("entry built from a deleted source was served from the cache")
+	}
 }
 
 func TestEnrich_CacheInvalidatedOnProfileUpdate(t *testing.T) {
@@ -766,7 +777,7 @@ func TestEnrich_CacheInvalidatedOnProfileUpdate(t *testing.T) {
 	}
 
 	// Update profile, which should invalidate cache.
-	profileMgr.SetField("communication.tone", "casual")
+	profileMgr.SetField(profile.SourceAPI, "communication.tone", "casual")
 
 	// Verify cache miss after invalidation.
 	_, meta = enricher.Enrich(context.Background(), makeReq("test query"))
@@ -774,3 +785,132 @@ func TestEnrich_CacheInvalidatedOnProfileUpdate(t *testing.T) {
 		t.Error("expected cache miss after profile update")
 	}
 }
+
+func TestEnrichAs_UsesPersonaProfileAndPartition(t *testing.T) {
+	chatter := &mockChatter{
+		chatFn: func(ctx context.Context, model string, msgs []ollama.Message, schema *ollama.Schema) (string, error) {
+			return `{"intent_type":"question","topics":[],"search_strategy":"vector_only"}`, nil
+		},
+	}
+	eng := &mockEngine{
+		embedFn: func(ctx context.Context, model string, text string) ([]float32, error) {
+			return make([]float32, 768), nil
+		},
+	}
+	vs := &mockVectorStore{
+		searchResults: []retrieval.ScoredRecord{
+			{Record: retrieval.Record{ID: "c1", SourceID: "s1", TextChunk: "context"}, Score: 0.9},
+		},
+	}
+	stores := map[string]*mockProfileStore{
+		profile.DefaultPersona: {keys: map[string]string{"identity.role": "hobbyist"}},
+		"work":                 {keys: map[string]string{"identity.role": "staff engineer"}},
+	}
+	reg := profile.NewRegistry(func(persona string) profile.ProfileStore { return stores[persona] })
+
+	cacheEmb := &mockCacheEmbedder{
+		embedFn: func(ctx context.Context, text string) ([]float32, error) {
+			return make([]float32, 768), nil
+		},
+	}
+	qc := cache.NewQueryCacheWithClock(cacheEmb, true, 0.92, 5*time.Minute, 30*time.Minute, &mockClock{now: time.Now()})
+
+	enricher := NewEnricher(
+		intent.NewExtractor(chatter, "test-fast", nil),
+		retrieval.NewRetriever(retrieval.NewEmbedder(eng, "test-embed"), vs),
+		reg.Default(),
+		composer.New(4000),
+		&reranking.NoOpReranker{},
+		5,
+		qc,
+	)
+	enricher.SetPersonas(reg)
+
+	work := Persona{Name: "work", Partitioned: true}
+	enriched, meta := enricher.EnrichAs(context.Background(), work, makeReq("what do I do"))
+	if meta.CacheHit {
+		t.Fatal("first work enrichment was a cache hit")
+	}
+	var msgs []map[string]string
+	json.Unmarshal(enriched.Messages, &msgs)
+	if len(msgs) == 0 || !strings.Contains(msgs[0]["content"], "staff engineer") {
+		t.Error("work enrichment did not inject the work profile")
+	}
+	if len(vs.filters) == 0 || !strings.HasPrefix(vs.filters[0], "partition:work") {
+		t.Errorf("work search filters = %q, want the work partition", vs.filters)
+	}
+
+	// The default persona must not be served the work persona's cached result.
+	vs.filters = nil
+	enriched, meta = enricher.Enrich(context.Background(), makeReq("what do I do"))
+	if meta.CacheHit {
+		t.Error("default enrichment was served the work persona's cache entry")
+	}
+	json.Unmarshal(enriched.Messages, &msgs)
+	if len(msgs) == 0 || !strings.Contains(msgs[0]["content"], "hobbyist") {
+		t.Error("default enrichment did not inject the default profile")
+	}
+	if len(vs.filters) == 0 || vs.filters[0] != "partition:" {
+		t.Errorf("default search filters = %q, want the shared partition", vs.filters)
+	}
+
+	if _, meta = enricher.EnrichAs(context.Background(), work, makeReq("what do I do")); !meta.CacheHit {
+		t.Error("second work enrichment missed the cache")
+	}
+}
+
+func TestEnrichAs_PrefersActiveProject(t *testing.T) {
+	chatter := &mockChatter{
+		chatFn: func(ctx context.Context, model string, msgs []ollama.Message, schema *ollama.Schema) (string, error) {
+			return `{"intent_type":"question","topics":[],"search_strategy":"vector_only"}`, nil
+		},
+	}
+	eng := &mockEngine{
+		embedFn: func(ctx context.Context, model string, text string) ([]float32, error) {
+			return make([]float32, 768), nil
+		},
+	}
+	vs := &mockVectorStore{
+		searchResults: []retrieval.ScoredRecord{
+			{Record: retrieval.Record{ID: "c1", SourceID: "s1", TextChunk: "make build", Project: "alpha"}, Score: 0.9},
+		},
+	}
+	cacheEmb := &mockCacheEmbedder{
+		embedFn: func(ctx context.Context, text string) ([]float32, error) {
+			return make([]float32, 768), nil
+		},
+	}
+	qc := cache.NewQueryCacheWithClock(cacheEmb, true, 0.92, 5*time.Minute, 30*time.Minute, &mockClock{now: time.Now()})
+
+	enricher := NewEnricher(
+		intent.NewExtractor(chatter, "test-fast", nil),
+		retrieval.NewRetriever(retrieval.NewEmbedder(eng, "test-embed"), vs),
+		profile.NewManager(&mockProfileStore{keys: map[string]string{}}),
+		composer.New(4000),
+		&reranking.NoOpReranker{},
+		5,
+		qc,
+	)
+
+	alpha := Persona{Project: "alpha"}
+	if _, meta := enricher.EnrichAs(context.Background(), alpha, makeReq("how do I run the build")); meta.CacheHit {
+		t.Fatal("first alpha enrichment was a cache hit")
+	}
+	if len(vs.filters) == 0 || vs.filters[0] != "partition:;project:alpha" {
+		t.Errorf("alpha search filters = %q, want the alpha project boost", vs.filters)
+	}
+
+	// Another project must not be served alpha's cached enrichment.
+	vs.filters = nil
+	if _, meta := enricher.EnrichAs(context.Background(), Persona{Project: "beta"}, makeReq("how do I run the build")); meta.CacheHit {
+		t.Error("beta enrichment was served the alpha cache entry")
+	}
+
+	enricher.SetProjectOnly(true)
+	vs.filters = nil
+	qc.Invalidate()
+	enricher.EnrichAs(context.Background(), alpha, makeReq("how do I run the build"))
+	if len(vs.filters) == 0 || vs.filters[0] != "partition:;project-only:alpha" {
+		t.Errorf("project-only search filters = %q, want the alpha project filter", vs.filters)
+	}
+}
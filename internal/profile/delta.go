@@ -7,4 +7,18 @@ type ProfileDelta struct {
 	AddPreferences    []string
 	RemovePreferences []string
 	UpdateFields      map[string]string
+	// RemoveOpinions drops opinions, e.g. one side of a contradiction.
+	RemoveOpinions []string `json:",omitempty"`
+	// PromoteInterests moves emerging interests to primary, and
+	// DecayInterests moves primary interests back to emerging. Interests are
+	// matched case-insensitively; names not in the source list are added to
+	// the target list as given.
+	PromoteInterests []string `json:",omitempty"`
+	DecayInterests   []string `json:",omitempty"`
+}
+
+// IsEmpty reports whether the delta changes nothing.
+func (d ProfileDelta) IsEmpty() bool {
+	return len(d.AddPreferences) == 0 && len(d.RemovePreferences) == 0 && len(d.UpdateFields) == 0 &&
+		len(d.RemoveOpinions) == 0 && len(d.PromoteInterests) == 0 && len(d.DecayInterests) == 0
 }
@@ -0,0 +1,251 @@
+package profile
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Profile export documents follow a versioned JSON Schema. Bump
+// ExportVersion, and add a new schema file, for any incompatible change.
+const (
+	ExportVersion  = 1
+	ExportSchemaID = "https://github.com/kalambet/tbyd/schemas/profile-export.v1.json"
+)
+
+// ExportSchema is the JSON Schema of version ExportVersion export documents.
+//
+//go:embed schema/profile-export.v1.json
+var ExportSchema []byte
+
+// Export is a portable profile document: a persona's profile and the profile
+// deltas waiting for review.
+type Export struct {
+	Schema        string          `json:"$schema"`
+	Version       int             `json:"version"`
+	ExportedAt    time.Time       `json:"exported_at"`
+	Persona       string          `json:"persona,omitempty"`
+	Profile       Profile         `json:"profile"`
+	PendingDeltas []ExportedDelta `json:"pending_deltas,omitempty"`
+}
+
+// ExportedDelta is a pending profile delta in an export document.
+type ExportedDelta struct {
+	Description string       `json:"description"`
+	Source      string       `json:"source"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Delta       ProfileDelta `json:"delta"`
+}
+
+// ErrInvalidExport is returned when a document does not match the export schema.
+var ErrInvalidExport = errors.New("invalid profile export")
+
+// NewExport returns an export document for p.
+func NewExport(persona string, p Profile, deltas []ExportedDelta, now time.Time) Export {
+	return Export{
+		Schema:        ExportSchemaID,
+		Version:       ExportVersion,
+		ExportedAt:    now.UTC(),
+		Persona:       persona,
+		Profile:       p,
+		PendingDeltas: deltas,
+	}
+}
+
+// ParseExport decodes and validates an export document. Unknown fields and
+// unsupported versions are rejected with ErrInvalidExport.
+func ParseExport(data []byte) (Export, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var doc Export
+	if err := dec.Decode(&doc); err != nil {
+		return Export{}, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if dec.More() {
+		return Export{}, fmt.Errorf("%w: trailing data after document", ErrInvalidExport)
+	}
+	if doc.Schema != ExportSchemaID {
+		return Export{}, fmt.Errorf("%w: $schema is %q, want %q", ErrInvalidExport, doc.Schema, ExportSchemaID)
+	}
+	if doc.Version != ExportVersion {
+		return Export{}, fmt.Errorf("%w: unsupported version %d (supported: %d)", ErrInvalidExport, doc.Version, ExportVersion)
+	}
+	return doc, nil
+}
+
+// StorageKeys returns p as profile storage keys, the inverse of the layout
+// read by GetProfile. Empty fields are omitted.
+func StorageKeys(p Profile) map[string]string {
+	keys := make(map[string]string)
+	setString := func(key, v string) {
+		if v != "" {
+			keys[key] = v
+		}
+	}
+	setJSON := func(key string, v any, empty bool) {
+		if empty {
+			return
+		}
+		if b, err := json.Marshal(v); err == nil {
+			keys[key] = string(b)
+		}
+	}
+
+	setString("identity.role", p.Identity.Role)
+	setJSON("identity.expertise", p.Identity.Expertise, len(p.Identity.Expertise) == 0)
+	if wc := p.Identity.WorkingContext; wc != nil {
+		setJSON("identity.working_context", wc, len(wc.CurrentProjects) == 0 && wc.TeamSize == "" && len(wc.TechStack) == 0)
+	}
+	setString("communication.tone", p.Communication.Tone)
+	setString("communication.format", p.Communication.Format)
+	setString("communication.detail_level", p.Communication.DetailLevel)
+	setJSON("interests.primary", p.Interests.Primary, len(p.Interests.Primary) == 0)
+	setJSON("interests.emerging", p.Interests.Emerging, len(p.Interests.Emerging) == 0)
+	setJSON("opinions", p.Opinions, len(p.Opinions) == 0)
+	setJSON("preferences", p.Preferences, len(p.Preferences) == 0)
+	setString("language", p.Language)
+	setString("cloud_model_preference", p.CloudModelPreference)
+	return keys
+}
+
+// MergeProfiles returns base with incoming layered on top: non-empty scalars
+// in incoming win, lists are unioned in order, and expertise entries in
+// incoming replace base's for the same domain.
+func MergeProfiles(base, incoming Profile) Profile {
+	out := deepCopyProfile(&base)
+	in := deepCopyProfile(&incoming)
+
+	pick := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	pick(&out.Identity.Role, in.Identity.Role)
+	if len(in.Identity.Expertise) > 0 {
+		if out.Identity.Expertise == nil {
+			out.Identity.Expertise = make(map[string]string, len(in.Identity.Expertise))
+		}
+		for domain, level := range in.Identity.Expertise {
+			out.Identity.Expertise[domain] = level
+		}
+	}
+	if wc := in.Identity.WorkingContext; wc != nil {
+		if out.Identity.WorkingContext == nil {
+			out.Identity.WorkingContext = &WorkingContext{}
+		}
+		owc := out.Identity.WorkingContext
+		owc.CurrentProjects = unionStrings(owc.CurrentProjects, wc.CurrentProjects)
+		owc.TechStack = unionStrings(owc.TechStack, wc.TechStack)
+		pick(&owc.TeamSize, wc.TeamSize)
+	}
+	pick(&out.Communication.Tone, in.Communication.Tone)
+	pick(&out.Communication.Format, in.Communication.Format)
+	pick(&out.Communication.DetailLevel, in.Communication.DetailLevel)
+	out.Interests.Primary = unionStrings(out.Interests.Primary, in.Interests.Primary)
+	out.Interests.Emerging = unionStrings(out.Interests.Emerging, in.Interests.Emerging)
+	out.Opinions = unionStrings(out.Opinions, in.Opinions)
+	out.Preferences = unionStrings(out.Preferences, in.Preferences)
+	pick(&out.Language, in.Language)
+	pick(&out.CloudModelPreference, in.CloudModelPreference)
+	return out
+}
+
+// unionStrings appends the values of b missing from a, keeping order.
+func unionStrings(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	for _, v := range a {
+		seen[v] = struct{}{}
+	}
+	for _, v := range b {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// ImportMode selects how an imported profile combines with the current one.
+type ImportMode string
+
+const (
+	ImportMerge   ImportMode = "merge"   // layer the import over the current profile
+	ImportReplace ImportMode = "replace" // make the profile exactly the import
+)
+
+// FieldChange describes how one profile storage key changes.
+type FieldChange struct {
+	Key string `json:"key"`
+	Op  string `json:"op"` // "add", "update", or "delete"
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Import applies incoming to the profile in mode and records the result as
+// one profile version. With dryRun set nothing is written. It returns the
+// key-level changes, sorted by key; keys whose value is unchanged are not
+// rewritten.
+func (m *Manager) Import(source Source, incoming Profile, mode ImportMode, dryRun bool) ([]FieldChange, error) {
+	if mode != ImportMerge && mode != ImportReplace {
+		return nil, fmt.Errorf("unknown import mode %q", mode)
+	}
+
+	m.mu.Lock()
+	stored, err := m.store.GetAllProfileKeys()
+	if err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("loading profile keys: %w", err)
+	}
+	current := buildProfile(stored)
+	target := incoming
+	if mode == ImportMerge {
+		target = MergeProfiles(current, incoming)
+	}
+
+	// Compare in canonical form so that formatting differences in stored
+	// JSON values do not show up as changes.
+	was, want := StorageKeys(current), StorageKeys(target)
+	set := make(map[string]string)
+	var del []string
+	var changes []FieldChange
+	for k, v := range want {
+		old, ok := was[k]
+		switch {
+		case !ok:
+			changes = append(changes, FieldChange{Key: k, Op: "add", New: v})
+		case old != v:
+			changes = append(changes, FieldChange{Key: k, Op: "update", Old: stored[k], New: v})
+		default:
+			continue
+		}
+		set[k] = v
+	}
+	if mode == ImportReplace {
+		for k, old := range stored {
+			if _, ok := want[k]; !ok {
+				del = append(del, k)
+				changes = append(changes, FieldChange{Key: k, Op: "delete", Old: old})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	sort.Strings(del)
+
+	if dryRun || len(changes) == 0 {
+		m.mu.Unlock()
+		return changes, nil
+	}
+	if _, err := m.store.CommitProfileChange(string(source), "import ("+string(mode)+")", set, del); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("importing profile: %w", err)
+	}
+	notify := m.invalidateLocked()
+	m.mu.Unlock()
+
+	notify()
+	return changes, nil
+}
@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSchema_CoversProfile(t *testing.T) {
+	var schema struct {
+		ID   string                     `json:"$id"`
+		Defs map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(ExportSchema, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.ID != ExportSchemaID {
+		t.Errorf("schema $id = %q, want %q", schema.ID, ExportSchemaID)
+	}
+
+	// Every JSON field of Profile, at every depth, must be described.
+	var checkObject func(path string, typ reflect.Type, raw json.RawMessage)
+	checkObject = func(path string, typ reflect.Type, raw json.RawMessage) {
+		var obj struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			prop, ok := obj.Properties[name]
+			if !ok {
+				t.Errorf("schema does not describe %s.%s", path, name)
+				continue
+			}
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				checkObject(path+"."+name, ft, prop)
+			}
+		}
+	}
+	checkObject("profile", reflect.TypeOf(Profile{}), schema.Defs["profile"])
+}
+
+func TestParseExport(t *testing.T) {
+	p := Profile{Identity: IdentityProfile{Role: "engineer"}, Preferences: []string{"be concise"}}
+	deltas := []ExportedDelta{{Description: "d", Source: "nightly_synthesis", Delta: ProfileDelta{AddPreferences: []string{"x"}}}}
+	data, err := json.Marshal(NewExport("default", p, deltas, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ParseExport(data)
+	if err != nil {
+		t.Fatalf("ParseExport of a fresh export: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Profile, p) || len(doc.PendingDeltas) != 1 {
+		t.Errorf("round trip = %+v, want the exported profile and delta", doc)
+	}
+
+	for name, doc := range map[string]string{
+		"unknown field":   `{"$schema":"` + ExportSchemaID + `","version":1,"profile":{"favourite_colour":"blue"}}`,
+		"wrong version":   `{"$schema":"` + ExportSchemaID + `","version":2,"profile":{}}`,
+		"missing $schema": `{"version":1,"profile":{}}`,
+		"trailing data":   `{"$schema":"` + ExportSchemaID + `","version":1,"profile":{}} {}`,
+	} {
+		if _, err := ParseExport([]byte(doc)); !errors.Is(err, ErrInvalidExport) {
+			t.Errorf("%s: err = %v, want ErrInvalidExport", name, err)
+		}
+	}
+}
+
+func TestStorageKeys_RoundTrip(t *testing.T) {
+	p := Profile{
+		Identity: IdentityProfile{
+			Role:           "staff engineer",
+			Expertise:      map[string]string{"go": "expert"},
+			WorkingContext: &WorkingContext{CurrentProjects: []string{"tbyd"}, TechStack: []string{"sqlite"}},
+		},
+		Communication:        CommunicationProfile{Tone: "direct", Format: "markdown", DetailLevel: "medium"},
+		Interests:            Interests{Primary: []string{"databases"}, Emerging: []string{"rust"}},
+		Opinions:             []string{"tests matter"},
+		Preferences:          []string{"be concise"},
+		Language:             "en",
+		CloudModelPreference: "openai/gpt-4o",
+	}
+	keys := StorageKeys(p)
+	for _, key := range knownStorageKeys {
+		if _, ok := keys[key]; !ok {
+			t.Errorf("StorageKeys did not produce %q", key)
+		}
+	}
+	if got := buildProfile(keys); !reflect.DeepEqual(got, p) {
+		t.Errorf("buildProfile(StorageKeys(p)) = %+v, want %+v", got, p)
+	}
+	if keys := StorageKeys(Profile{}); len(keys) != 0 {
+		t.Errorf("StorageKeys(empty) = %v, want none", keys)
+	}
+}
+
+func TestImport_MergeReplaceDryRun(t *testing.T) {
+	mgr, store := newTestManager()
+	store.data["communication.tone"] = "direct"
+	store.data["preferences"] = `["be concise"]`
+	store.data["identity.role"] = "engineer"
+
+	incoming := Profile{
+		Communication: CommunicationProfile{Tone: "formal"},
+		Preferences:   []string{"use tables", "be concise"},
+	}
+
+	// A dry run reports the merge without writing it.
+	changes, err := mgr.Import(SourceCLI, incoming, ImportMerge, true)
+	if err != nil {
+		t.Fatalf("dry-run Import: %v", err)
+	}
+	want := []FieldChange{
+		{Key: "communication.tone", Op: "update", Old: "direct", New: "formal"},
+		{Key: "preferences", Op: "update", Old: `["be concise"]`, New: `["be concise","use tables"]`},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("merge changes = %+v, want %+v", changes, want)
+	}
+	if len(store.snapshots) != 0 || store.data["communication.tone"] != "direct" {
+		t.Fatalf("dry run wrote to the store: %v", store.data)
+	}
+
+	if _, err := mgr.Import(SourceCLI, incoming, ImportMerge, false); err != nil {
+		t.Fatalf("merge Import: %v", err)
+	}
+	p, _ := mgr.GetProfile()
+	if p.Identity.Role != "engineer" || p.Communication.Tone != "formal" || len(p.Preferences) != 2 {
+		t.Errorf("merged profile = %+v, want role kept, tone replaced and preferences unioned", p)
+	}
+	if len(store.snapshots) != 1 || store.sources[0] != string(SourceCLI) {
+		t.Errorf("merge recorded %d versions from %v, want one from cli", len(store.snapshots), store.sources)
+	}
+
+	// Replace drops what the import does not carry.
+	changes, err = mgr.Import(SourceCLI, incoming, ImportReplace, false)
+	if err != nil {
+		t.Fatalf("replace Import: %v", err)
+	}
+	if len(changes) != 2 || changes[0] != (FieldChange{Key: "identity.role", Op: "delete", Old: "engineer"}) {
+		t.Errorf("replace changes = %+v, want identity.role deleted and preferences reordered", changes)
+	}
+	if _, ok := store.data["identity.role"]; ok {
+		t.Error("replace kept identity.role")
+	}
+
+	// Importing the same profile again changes nothing and records no version.
+	changes, err = mgr.Import(SourceCLI, incoming, ImportReplace, false)
+	if err != nil || len(changes) != 0 || len(store.snapshots) != 2 {
+		t.Errorf("repeat import = %+v, %v with %d versions; want no changes", changes, err, len(store.snapshots))
+	}
+
+	if _, err := mgr.Import(SourceCLI, incoming, "overwrite", false); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
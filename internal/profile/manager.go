@@ -32,12 +32,28 @@ func isNotFound(err error) bool {
 // ProfileStore defines the storage operations the Manager needs.
 // Implemented by storage.Store.
 type ProfileStore interface {
-	SetProfileKey(key, value string) error
-	GetProfileKey(key string) (string, error)
 	GetAllProfileKeys() (map[string]string, error)
-	DeleteProfileKey(key string) error
+	// CommitProfileChange sets and deletes keys and records the resulting
+	// profile as a new version, atomically. Deleting a missing key returns a
+	// not-found error and writes nothing.
+	CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error)
+	// GetProfileSnapshot returns every key as of version; 0 is the empty profile.
+	GetProfileSnapshot(version int64) (map[string]string, error)
 }
 
+// Source identifies who made a profile change. It is recorded with every
+// version in the profile history.
+type Source string
+
+const (
+	SourceCLI      Source = "cli"
+	SourceAPI      Source = "api"
+	SourceMCP      Source = "mcp"
+	SourceFeedback Source = "feedback"
+	SourceNightly  Source = "nightly"
+	SourcePolicy   Source = "policy" // delta auto-accepted by the delta policy engine
+)
+
 // Clock abstracts time for testability.
 type Clock interface {
 	Now() time.Time
@@ -57,24 +73,28 @@ type Manager struct {
 	cached         *Profile
 	cachedAt       time.Time
 	onInvalidate   func()
-	profileVersion int64 // monotonically increasing; bumped on each SetField
+	onVersion      func(version int64) // set by Registry
+	profileVersion int64               // monotonically increasing; bumped on each SetField
+	persona        string
 }
 
-// NewManager creates a Manager with a 60-second cache TTL.
+// NewManager creates a Manager for the default persona with a 60-second cache TTL.
 func NewManager(store ProfileStore) *Manager {
 	return &Manager{
-		store: store,
-		clock: realClock{},
-		ttl:   60 * time.Second,
+		store:   store,
+		clock:   realClock{},
+		ttl:     60 * time.Second,
+		persona: DefaultPersona,
 	}
 }
 
 // NewManagerWithClock creates a Manager with a custom clock (for testing).
 func NewManagerWithClock(store ProfileStore, clock Clock, ttl time.Duration) *Manager {
 	return &Manager{
-		store: store,
-		clock: clock,
-		ttl:   ttl,
+		store:   store,
+		clock:   clock,
+		ttl:     ttl,
+		persona: DefaultPersona,
 	}
 }
 
@@ -122,8 +142,9 @@ func (m *Manager) OnInvalidate(fn func()) {
 	m.onInvalidate = fn
 }
 
-// SetField persists a profile key and invalidates the cache.
-func (m *Manager) SetField(key string, value interface{}) error {
+// SetField persists a profile key as a new profile version and invalidates
+// the cache.
+func (m *Manager) SetField(source Source, key string, value interface{}) error {
 	var str string
 	switch v := value.(type) {
 	case string:
@@ -139,21 +160,17 @@ func (m *Manager) SetField(key string, value interface{}) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := m.store.SetProfileKey(key, str); err != nil {
+	if _, err := m.store.CommitProfileChange(string(source), "set "+key, map[string]string{key: str}, nil); err != nil {
 		return fmt.Errorf("setting profile key %q: %w", key, err)
 	}
 
-	m.cached = nil
-	m.profileVersion++
-
-	if m.onInvalidate != nil {
-		m.onInvalidate()
-	}
+	m.invalidateLocked()()
 	return nil
 }
 
-// DeleteField removes a profile field identified by a dot-notation path and
-// invalidates the cache. Returns ErrFieldNotFound if the path does not exist.
+// DeleteField removes a profile field identified by a dot-notation path,
+// recording a new profile version, and invalidates the cache. Returns
+// ErrFieldNotFound if the path does not exist.
 //
 // Supported path forms:
 //   - Scalar fields:         "communication.tone", "identity.role"
@@ -164,7 +181,7 @@ func (m *Manager) SetField(key string, value interface{}) error {
 // The write lock is held for the full duration of the storage read-modify-write
 // and cache invalidation to prevent TOCTOU races with concurrent SetField or
 // DeleteField calls.
-func (m *Manager) DeleteField(path string) error {
+func (m *Manager) DeleteField(source Source, path string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -178,11 +195,12 @@ func (m *Manager) DeleteField(path string) error {
 		return err
 	}
 
+	summary := "delete " + path
 	if subPath == "" {
 		// Delete the entire storage key. The store returns a not-found error if
 		// the key was removed by a concurrent writer between our GetAllProfileKeys
 		// call and this delete; surface that as ErrFieldNotFound to the caller.
-		if err := m.store.DeleteProfileKey(storageKey); err != nil {
+		if _, err := m.store.CommitProfileChange(string(source), summary, nil, []string{storageKey}); err != nil {
 			if isNotFound(err) {
 				return ErrFieldNotFound
 			}
@@ -198,16 +216,12 @@ func (m *Manager) DeleteField(path string) error {
 		if err != nil {
 			return fmt.Errorf("deleting sub-path %q from key %q: %w", subPath, storageKey, err)
 		}
-		if err := m.store.SetProfileKey(storageKey, updated); err != nil {
+		if _, err := m.store.CommitProfileChange(string(source), summary, map[string]string{storageKey: updated}, nil); err != nil {
 			return fmt.Errorf("updating profile key %q: %w", storageKey, err)
 		}
 	}
 
-	m.cached = nil
-	m.profileVersion++
-	if m.onInvalidate != nil {
-		m.onInvalidate()
-	}
+	m.invalidateLocked()()
 	return nil
 }
 
@@ -397,141 +411,272 @@ func deleteFromJSON(raw, selector string) (string, error) {
 	}
 }
 
-// ApplyDelta applies a ProfileDelta to the user profile in two phases:
-//
-//  1. Preferences phase: AddPreferences and RemovePreferences are applied as a
-//     single atomic read-modify-write under the write lock. No concurrent
-//     SetField or DeleteField can interleave with this phase.
-//  2. UpdateFields phase: all key/value pairs are written under a single
-//     write lock to avoid repeated lock cycling. No other writer can interleave
-//     between field updates within the same delta.
+// ApplyDelta applies a ProfileDelta to the user profile as a single profile
+// version. AddPreferences and RemovePreferences are merged into the stored
+// preferences array, RemoveOpinions is removed from the opinions array,
+// PromoteInterests and DecayInterests move interests between the primary and
+// emerging lists, and UpdateFields are written as-is. The write lock is held
+// for the whole read-modify-write, so no concurrent SetField or DeleteField
+// can interleave, and the store commits every key together.
 //
-// The two phases are NOT jointly atomic — a concurrent writer could observe
-// the preferences change before UpdateFields are applied. This is acceptable
-// because the synthesis pipeline only uses AddPreferences/RemovePreferences
-// today, and UpdateFields entries are independent key-value pairs with no
-// cross-field invariants.
+// Note: this mutex is process-local. tbyd is designed as a single-user,
+// single-process local tool backed by SQLite (which enforces single-writer at
+// the database level). Multi-replica horizontal scaling is not a supported
+// deployment model.
 //
-// onInvalidate is called at most once per ApplyDelta invocation, after all
-// writes are committed and all locks are released.
-func (m *Manager) ApplyDelta(delta ProfileDelta) error {
-	changed := false
-
-	// --- preferences (AddPreferences + RemovePreferences) ---
-	// applyPreferencesDelta holds m.mu.Lock() internally and does NOT call
-	// onInvalidate; we do that once at the end.
-	if len(delta.AddPreferences) > 0 || len(delta.RemovePreferences) > 0 {
-		if err := m.applyPreferencesDelta(delta); err != nil {
-			return err
-		}
-		changed = true
+// onInvalidate is called at most once per ApplyDelta invocation, after the
+// change is committed and the lock is released.
+func (m *Manager) ApplyDelta(source Source, delta ProfileDelta) error {
+	editsPrefs := len(delta.AddPreferences) > 0 || len(delta.RemovePreferences) > 0
+	editsOpinions := len(delta.RemoveOpinions) > 0
+	movesInterests := len(delta.PromoteInterests) > 0 || len(delta.DecayInterests) > 0
+	if delta.IsEmpty() {
+		return nil
 	}
 
-	// --- UpdateFields ---
-	// Acquire the lock once for all field writes to avoid repeated lock
-	// cycling and to batch the cache invalidations into the single call below.
-	if len(delta.UpdateFields) > 0 {
-		m.mu.Lock()
-		for key, value := range delta.UpdateFields {
-			if err := m.setFieldNoInvalidate(key, value); err != nil {
+	m.mu.Lock()
+	set := make(map[string]string, len(delta.UpdateFields)+4)
+	if editsPrefs || editsOpinions || movesInterests {
+		keys, err := m.store.GetAllProfileKeys()
+		if err != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("loading profile keys: %w", err)
+		}
+		if editsPrefs {
+			prefs, err := mergeList(keys["preferences"], delta.AddPreferences, delta.RemovePreferences)
+			if err != nil {
+				m.mu.Unlock()
+				return fmt.Errorf("merging preferences: %w", err)
+			}
+			set["preferences"] = prefs
+		}
+		if editsOpinions {
+			opinions, err := mergeList(keys["opinions"], nil, delta.RemoveOpinions)
+			if err != nil {
 				m.mu.Unlock()
-				return fmt.Errorf("applying delta field %q: %w", key, err)
+				return fmt.Errorf("merging opinions: %w", err)
 			}
+			set["opinions"] = opinions
 		}
+		if movesInterests {
+			primary, emerging, err := moveInterests(buildProfile(keys).Interests, delta)
+			if err != nil {
+				m.mu.Unlock()
+				return err
+			}
+			set["interests.primary"] = primary
+			set["interests.emerging"] = emerging
+		}
+	}
+	for key, value := range delta.UpdateFields {
+		set[key] = value
+	}
+
+	if _, err := m.store.CommitProfileChange(string(source), deltaSummary(delta), set, nil); err != nil {
 		m.mu.Unlock()
-		changed = true
+		return fmt.Errorf("applying profile delta: %w", err)
 	}
+	notify := m.invalidateLocked()
+	m.mu.Unlock()
 
 	// Single cache invalidation after all changes, with no lock held.
-	if changed {
-		m.mu.RLock()
-		fn := m.onInvalidate
-		m.mu.RUnlock()
-		if fn != nil {
-			fn()
+	notify()
+	return nil
+}
+
+// mergeList applies additions (idempotent) and removals to the JSON string
+// array raw, returning the new JSON array.
+func mergeList(raw string, add, remove []string) (string, error) {
+	var items []string
+	if raw != "" {
+		if jsonErr := json.Unmarshal([]byte(raw), &items); jsonErr != nil {
+			slog.Warn("malformed profile list, treating as empty", "error", jsonErr)
+			items = nil
 		}
 	}
 
-	return nil
-}
+	// Build a set of existing items for O(1) lookup.
+	existing := make(map[string]struct{}, len(items))
+	for _, v := range items {
+		existing[v] = struct{}{}
+	}
 
-// applyPreferencesDelta holds the write lock for the full read-modify-write
-// cycle on the preferences array, preventing TOCTOU races within this process.
-//
-// Note: this mutex is process-local. tbyd is designed as a single-user,
-// single-process local tool backed by SQLite (which enforces single-writer at
-// the database level). Multi-replica horizontal scaling is not a supported
-// deployment model. If that ever changes, this would need a database-level
-// advisory lock or compare-and-swap on a version column.
-func (m *Manager) applyPreferencesDelta(delta ProfileDelta) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	// Add new items (idempotent).
+	for _, a := range add {
+		if _, ok := existing[a]; !ok {
+			items = append(items, a)
+			existing[a] = struct{}{}
+		}
+	}
 
-	keys, err := m.store.GetAllProfileKeys()
+	// Remove items.
+	if len(remove) > 0 {
+		removeSet := make(map[string]struct{}, len(remove))
+		for _, r := range remove {
+			removeSet[r] = struct{}{}
+		}
+		filtered := items[:0]
+		for _, v := range items {
+			if _, drop := removeSet[v]; !drop {
+				filtered = append(filtered, v)
+			}
+		}
+		items = filtered
+	}
+
+	b, err := json.Marshal(items)
 	if err != nil {
-		return fmt.Errorf("loading profile keys: %w", err)
+		return "", fmt.Errorf("marshalling list: %w", err)
 	}
+	return string(b), nil
+}
 
-	var prefs []string
-	if raw, ok := keys["preferences"]; ok {
-		if jsonErr := json.Unmarshal([]byte(raw), &prefs); jsonErr != nil {
-			slog.Warn("malformed preferences key, treating as empty", "error", jsonErr)
-			prefs = nil
-		}
+// moveInterests applies the delta's promotions and decays to interests,
+// returning the new primary and emerging lists as JSON arrays.
+func moveInterests(interests Interests, delta ProfileDelta) (primary, emerging string, err error) {
+	p, e := interests.Primary, interests.Emerging
+	for _, name := range delta.PromoteInterests {
+		var moved string
+		e, moved = takeInterest(e, name)
+		p = putInterest(p, moved)
+	}
+	for _, name := range delta.DecayInterests {
+		var moved string
+		p, moved = takeInterest(p, name)
+		e = putInterest(e, moved)
 	}
 
-	// Build a set of existing preferences for O(1) lookup.
-	existing := make(map[string]struct{}, len(prefs))
-	for _, p := range prefs {
-		existing[p] = struct{}{}
+	if p == nil {
+		p = []string{}
+	}
+	if e == nil {
+		e = []string{}
+	}
+	pb, err := json.Marshal(p)
+	if err != nil {
+		return "", "", fmt.Errorf("marshalling primary interests: %w", err)
+	}
+	eb, err := json.Marshal(e)
+	if err != nil {
+		return "", "", fmt.Errorf("marshalling emerging interests: %w", err)
 	}
+	return string(pb), string(eb), nil
+}
 
-	// Add new preferences (idempotent).
-	for _, add := range delta.AddPreferences {
-		if _, ok := existing[add]; !ok {
-			prefs = append(prefs, add)
-			existing[add] = struct{}{}
+// takeInterest removes every case-insensitive match of name from list. It
+// returns the remaining list and the stored spelling of the first match, or
+// name itself when there was none.
+func takeInterest(list []string, name string) ([]string, string) {
+	taken := name
+	found := false
+	kept := make([]string, 0, len(list))
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(name)) {
+			if !found {
+				taken, found = v, true
+			}
+			continue
 		}
+		kept = append(kept, v)
 	}
+	return kept, taken
+}
 
-	// Remove preferences.
-	if len(delta.RemovePreferences) > 0 {
-		removeSet := make(map[string]struct{}, len(delta.RemovePreferences))
-		for _, r := range delta.RemovePreferences {
-			removeSet[r] = struct{}{}
+// putInterest appends name to list unless a case-insensitive match is
+// already present.
+func putInterest(list []string, name string) []string {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(name)) {
+			return list
 		}
-		filtered := prefs[:0]
-		for _, p := range prefs {
-			if _, remove := removeSet[p]; !remove {
-				filtered = append(filtered, p)
-			}
+	}
+	return append(list, name)
+}
+
+// deltaSummary describes a delta for the profile history.
+func deltaSummary(delta ProfileDelta) string {
+	var parts []string
+	if n := len(delta.AddPreferences); n > 0 {
+		parts = append(parts, fmt.Sprintf("add %d preference(s)", n))
+	}
+	if n := len(delta.RemovePreferences); n > 0 {
+		parts = append(parts, fmt.Sprintf("remove %d preference(s)", n))
+	}
+	if n := len(delta.RemoveOpinions); n > 0 {
+		parts = append(parts, fmt.Sprintf("remove %d opinion(s)", n))
+	}
+	if n := len(delta.PromoteInterests); n > 0 {
+		parts = append(parts, fmt.Sprintf("promote %d interest(s)", n))
+	}
+	if n := len(delta.DecayInterests); n > 0 {
+		parts = append(parts, fmt.Sprintf("decay %d interest(s)", n))
+	}
+	if len(delta.UpdateFields) > 0 {
+		keys := make([]string, 0, len(delta.UpdateFields))
+		for k := range delta.UpdateFields {
+			keys = append(keys, k)
 		}
-		prefs = filtered
+		sort.Strings(keys)
+		parts = append(parts, "set "+strings.Join(keys, ", "))
 	}
+	return strings.Join(parts, "; ")
+}
 
-	b, err := json.Marshal(prefs)
+// Rollback restores the profile as of version by committing it as a new
+// version, so the history itself is never rewritten. Returns the new version
+// number. Version 0 restores the empty profile.
+func (m *Manager) Rollback(source Source, version int64) (int64, error) {
+	m.mu.Lock()
+	target, err := m.store.GetProfileSnapshot(version)
+	if err != nil {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("loading profile version %d: %w", version, err)
+	}
+	current, err := m.store.GetAllProfileKeys()
 	if err != nil {
-		return fmt.Errorf("marshalling preferences: %w", err)
+		m.mu.Unlock()
+		return 0, fmt.Errorf("loading profile keys: %w", err)
 	}
+	var del []string
+	for k := range current {
+		if _, ok := target[k]; !ok {
+			del = append(del, k)
+		}
+	}
+	sort.Strings(del)
 
-	if setErr := m.store.SetProfileKey("preferences", string(b)); setErr != nil {
-		return fmt.Errorf("setting profile key \"preferences\": %w", setErr)
+	newVersion, err := m.store.CommitProfileChange(string(source), fmt.Sprintf("rollback to v%d", version), target, del)
+	if err != nil {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("rolling back to profile version %d: %w", version, err)
 	}
+	notify := m.invalidateLocked()
+	m.mu.Unlock()
 
-	m.cached = nil
-	m.profileVersion++
-	return nil
+	notify()
+	return newVersion, nil
 }
 
-// setFieldNoInvalidate persists a profile key, nils the cache, and bumps the
-// version, but does NOT call onInvalidate. The caller must hold m.mu.Lock().
-func (m *Manager) setFieldNoInvalidate(key string, value string) error {
-	if err := m.store.SetProfileKey(key, value); err != nil {
-		return fmt.Errorf("setting profile key %q: %w", key, err)
-	}
+// invalidateLocked drops the cached profile and bumps the version. It returns
+// a function that runs the registered callbacks; callers invoke it once the
+// change is committed. Called with m.mu held.
+func (m *Manager) invalidateLocked() func() {
 	m.cached = nil
 	m.profileVersion++
-	return nil
+	version, onInvalidate, onVersion := m.profileVersion, m.onInvalidate, m.onVersion
+	return func() {
+		if onInvalidate != nil {
+			onInvalidate()
+		}
+		if onVersion != nil {
+			onVersion(version)
+		}
+	}
+}
+
+// Persona returns the persona whose profile this manager serves.
+func (m *Manager) Persona() string {
+	return m.persona
 }
 
 // ProfileVersion returns the current profile version. It is a monotonically
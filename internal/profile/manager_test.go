@@ -2,6 +2,7 @@ package profile
 
 import (
 	"errors"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -10,49 +11,59 @@ import (
 // --- Mock ProfileStore ---
 
 type mockProfileStore struct {
-	mu   sync.Mutex
-	data map[string]string
+	mu        sync.Mutex
+	data      map[string]string
+	snapshots []map[string]string // snapshots[i] is version i+1
+	sources   []string
 }
 
 func newMockProfileStore() *mockProfileStore {
 	return &mockProfileStore{data: make(map[string]string)}
 }
 
-func (m *mockProfileStore) SetProfileKey(key, value string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.data[key] = value
-	return nil
-}
-
-func (m *mockProfileStore) GetProfileKey(key string) (string, error) {
+func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	v, ok := m.data[key]
-	if !ok {
-		return "", errors.New("not found")
+	cp := make(map[string]string, len(m.data))
+	for k, v := range m.data {
+		cp[k] = v
 	}
-	return v, nil
+	return cp, nil
 }
 
-func (m *mockProfileStore) GetAllProfileKeys() (map[string]string, error) {
+func (m *mockProfileStore) CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	cp := make(map[string]string, len(m.data))
+	for _, k := range del {
+		if _, ok := m.data[k]; !ok {
+			return 0, errors.New("not found")
+		}
+	}
+	for _, k := range del {
+		delete(m.data, k)
+	}
+	for k, v := range set {
+		m.data[k] = v
+	}
+	snap := make(map[string]string, len(m.data))
 	for k, v := range m.data {
-		cp[k] = v
+		snap[k] = v
 	}
-	return cp, nil
+	m.snapshots = append(m.snapshots, snap)
+	m.sources = append(m.sources, source)
+	return int64(len(m.snapshots)), nil
 }
 
-func (m *mockProfileStore) DeleteProfileKey(key string) error {
+func (m *mockProfileStore) GetProfileSnapshot(version int64) (map[string]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.data[key]; !ok {
-		return errors.New("not found")
+	if version == 0 {
+		return map[string]string{}, nil
+	}
+	if version < 0 || int(version) > len(m.snapshots) {
+		return nil, errors.New("not found")
 	}
-	delete(m.data, key)
-	return nil
+	return m.snapshots[version-1], nil
 }
 
 // fixedClock returns a constant time (cache never expires in tests).
@@ -74,7 +85,7 @@ func TestApplyDelta_AddsPreferences(t *testing.T) {
 	delta := ProfileDelta{
 		AddPreferences: []string{"concise responses", "use examples"},
 	}
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("ApplyDelta failed: %v", err)
 	}
 
@@ -91,14 +102,14 @@ func TestApplyDelta_RemovesPreferences(t *testing.T) {
 	mgr, _ := newTestManager()
 
 	// Seed with two preferences.
-	if err := mgr.SetField("preferences", []string{"concise responses", "use examples"}); err != nil {
+	if err := mgr.SetField(SourceAPI, "preferences", []string{"concise responses", "use examples"}); err != nil {
 		t.Fatalf("SetField failed: %v", err)
 	}
 
 	delta := ProfileDelta{
 		RemovePreferences: []string{"concise responses"},
 	}
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("ApplyDelta failed: %v", err)
 	}
 
@@ -122,10 +133,10 @@ func TestApplyDelta_Idempotent(t *testing.T) {
 	}
 
 	// Apply the same delta twice.
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("first ApplyDelta failed: %v", err)
 	}
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("second ApplyDelta failed: %v", err)
 	}
 
@@ -147,7 +158,7 @@ func TestApplyDelta_InvalidatesCache(t *testing.T) {
 	delta := ProfileDelta{
 		AddPreferences: []string{"some preference"},
 	}
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("ApplyDelta failed: %v", err)
 	}
 
@@ -170,7 +181,7 @@ func TestApplyDelta_InvalidatesExactlyOnce(t *testing.T) {
 			"communication.detail_level": "high",
 		},
 	}
-	if err := mgr.ApplyDelta(delta); err != nil {
+	if err := mgr.ApplyDelta(SourceAPI, delta); err != nil {
 		t.Fatalf("ApplyDelta failed: %v", err)
 	}
 
@@ -178,3 +189,126 @@ func TestApplyDelta_InvalidatesExactlyOnce(t *testing.T) {
 		t.Errorf("expected onInvalidate to be called exactly once, got %d", callCount)
 	}
 }
+
+func TestApplyDelta_MovesInterests(t *testing.T) {
+	mgr, _ := newTestManager()
+
+	if err := mgr.SetField(SourceAPI, "interests.primary", []string{"Go", "Kubernetes"}); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := mgr.SetField(SourceAPI, "interests.emerging", []string{"Rust"}); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+
+	delta := ProfileDelta{
+		PromoteInterests: []string{"rust"},
+		DecayInterests:   []string{"kubernetes"},
+	}
+	if err := mgr.ApplyDelta(SourceNightly, delta); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	p, err := mgr.GetProfile()
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if want := []string{"Go", "Rust"}; !reflect.DeepEqual(p.Interests.Primary, want) {
+		t.Errorf("primary = %v, want %v", p.Interests.Primary, want)
+	}
+	if want := []string{"Kubernetes"}; !reflect.DeepEqual(p.Interests.Emerging, want) {
+		t.Errorf("emerging = %v, want %v", p.Interests.Emerging, want)
+	}
+
+	// Applying the same moves again changes nothing.
+	if err := mgr.ApplyDelta(SourceNightly, delta); err != nil {
+		t.Fatalf("second ApplyDelta failed: %v", err)
+	}
+	again, _ := mgr.GetProfile()
+	if !reflect.DeepEqual(again.Interests, p.Interests) {
+		t.Errorf("interests changed on reapply: %+v -> %+v", p.Interests, again.Interests)
+	}
+}
+
+func TestApplyDelta_RemovesOpinions(t *testing.T) {
+	mgr, _ := newTestManager()
+
+	if err := mgr.SetField(SourceAPI, "opinions", []string{"tabs are better", "ORMs are fine"}); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := mgr.ApplyDelta(SourceNightly, ProfileDelta{RemoveOpinions: []string{"tabs are better"}}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	p, err := mgr.GetProfile()
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if want := []string{"ORMs are fine"}; !reflect.DeepEqual(p.Opinions, want) {
+		t.Errorf("opinions = %v, want %v", p.Opinions, want)
+	}
+}
+
+// --- History tests ---
+
+func TestApplyDelta_SingleVersionWithSource(t *testing.T) {
+	mgr, store := newTestManager()
+
+	delta := ProfileDelta{
+		AddPreferences: []string{"be concise"},
+		UpdateFields:   map[string]string{"communication.tone": "technical"},
+	}
+	if err := mgr.ApplyDelta(SourceNightly, delta); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+
+	if len(store.sources) != 1 || store.sources[0] != string(SourceNightly) {
+		t.Errorf("recorded sources = %v, want one %q version", store.sources, SourceNightly)
+	}
+}
+
+func TestRollback_RestoresSnapshot(t *testing.T) {
+	mgr, store := newTestManager()
+
+	if err := mgr.SetField(SourceCLI, "communication.tone", "direct"); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := mgr.SetField(SourceAPI, "communication.tone", "formal"); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := mgr.SetField(SourceAPI, "identity.role", "engineer"); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	before := mgr.ProfileVersion()
+
+	invalidated := false
+	mgr.OnInvalidate(func() { invalidated = true })
+
+	v, err := mgr.Rollback(SourceCLI, 1)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if v != 4 {
+		t.Errorf("rollback version = %d, want 4 (appended, not rewritten)", v)
+	}
+
+	p, err := mgr.GetProfile()
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if p.Communication.Tone != "direct" {
+		t.Errorf("tone = %q, want %q", p.Communication.Tone, "direct")
+	}
+	if p.Identity.Role != "" {
+		t.Errorf("role = %q, want it removed by rollback", p.Identity.Role)
+	}
+	if !invalidated || mgr.ProfileVersion() <= before {
+		t.Error("rollback did not invalidate the profile cache")
+	}
+	if got := store.sources[len(store.sources)-1]; got != string(SourceCLI) {
+		t.Errorf("rollback source = %q, want %q", got, SourceCLI)
+	}
+
+	if _, err := mgr.Rollback(SourceCLI, 99); err == nil {
+		t.Error("expected error rolling back to a missing version")
+	}
+}
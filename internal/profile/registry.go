@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultPersona is the persona used when a request does not select one.
+// Matches storage.DefaultPersona.
+const DefaultPersona = "default"
+
+// Registry hands out one Manager per persona, each with its own profile cache
+// and version counter, so a change to one persona's profile never invalidates
+// another's enrichments.
+type Registry struct {
+	open  func(persona string) ProfileStore
+	clock Clock
+	ttl   time.Duration
+
+	mu           sync.Mutex
+	managers     map[string]*Manager
+	onInvalidate func(persona string, version int64)
+}
+
+// NewRegistry creates a Registry whose managers use a 60-second cache TTL.
+// open returns the profile store scoped to a persona.
+func NewRegistry(open func(persona string) ProfileStore) *Registry {
+	return NewRegistryWithClock(open, realClock{}, 60*time.Second)
+}
+
+// NewRegistryWithClock creates a Registry with a custom clock (for testing).
+func NewRegistryWithClock(open func(persona string) ProfileStore, clock Clock, ttl time.Duration) *Registry {
+	return &Registry{
+		open:     open,
+		clock:    clock,
+		ttl:      ttl,
+		managers: make(map[string]*Manager),
+	}
+}
+
+// OnInvalidate registers a callback that fires with the persona and its new
+// profile version whenever any persona's profile changes, including managers
+// already handed out.
+func (r *Registry) OnInvalidate(fn func(persona string, version int64)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onInvalidate = fn
+	for persona, m := range r.managers {
+		m.mu.Lock()
+		m.onVersion = versionCallback(fn, persona)
+		m.mu.Unlock()
+	}
+}
+
+func versionCallback(fn func(persona string, version int64), persona string) func(int64) {
+	if fn == nil {
+		return nil
+	}
+	return func(version int64) { fn(persona, version) }
+}
+
+// For returns the manager for persona, creating it on first use. An empty
+// persona selects DefaultPersona. The persona is not checked for existence;
+// callers resolve it against storage first.
+func (r *Registry) For(persona string) *Manager {
+	if persona == "" {
+		persona = DefaultPersona
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.managers[persona]; ok {
+		return m
+	}
+	m := NewManagerWithClock(r.open(persona), r.clock, r.ttl)
+	m.persona = persona
+	m.onVersion = versionCallback(r.onInvalidate, persona)
+	r.managers[persona] = m
+	return m
+}
+
+// Default returns the manager for DefaultPersona.
+func (r *Registry) Default() *Manager {
+	return r.For(DefaultPersona)
+}
+
+// Reset drops persona's cached profile and bumps its version, e.g. after the
+// persona is deleted outside its manager. The manager itself is kept so that
+// its version counter never restarts below the query cache's floor.
+func (r *Registry) Reset(persona string) {
+	r.mu.Lock()
+	m, ok := r.managers[persona]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	notify := m.invalidateLocked()
+	m.mu.Unlock()
+	notify()
+}
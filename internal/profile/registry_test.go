@@ -0,0 +1,62 @@
+package profile
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistry_PersonasAreIsolated(t *testing.T) {
+	stores := map[string]*mockProfileStore{}
+	reg := NewRegistryWithClock(func(persona string) ProfileStore {
+		s := newMockProfileStore()
+		stores[persona] = s
+		return s
+	}, &fixedClock{t: time.Now()}, time.Hour)
+
+	type event struct {
+		persona string
+		version int64
+	}
+	var events []event
+	reg.OnInvalidate(func(persona string, version int64) {
+		events = append(events, event{persona, version})
+	})
+
+	if reg.For("") != reg.Default() {
+		t.Fatal(`For("") did not return the default manager`)
+	}
+	work := reg.For("work")
+	if work.Persona() != "work" || reg.For("work") != work {
+		t.Fatalf("For(work) = persona %q, want a single cached work manager", work.Persona())
+	}
+
+	if err := work.SetField(SourceAPI, "identity", `{"role":"engineer"}`); err != nil {
+		t.Fatalf("SetField: %v", err)
+	}
+	if err := work.SetField(SourceAPI, "communication", `{"tone":"direct"}`); err != nil {
+		t.Fatalf("SetField: %v", err)
+	}
+
+	if _, ok := stores["work"].data["identity"]; !ok {
+		t.Error("work profile change not written to the work store")
+	}
+	if len(stores[DefaultPersona].data) != 0 {
+		t.Error("work profile change leaked into the default store")
+	}
+	if got := reg.Default().ProfileVersion(); got != 0 {
+		t.Errorf("default ProfileVersion = %d, want 0", got)
+	}
+	want := []event{{"work", 1}, {"work", 2}}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] {
+		t.Errorf("invalidation events = %v, want %v", events, want)
+	}
+
+	reg.Reset("work")
+	if got := work.ProfileVersion(); got != 3 {
+		t.Errorf("ProfileVersion after Reset = %d, want 3", got)
+	}
+	reg.Reset("unknown") // no manager yet; must not create one or fire
+	if len(events) != 3 {
+		t.Errorf("got %d invalidation events, want 3", len(events))
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AutoModel is the placeholder model name clients send to let tbyd choose the
+// upstream model.
+const AutoModel = "tbyd/auto"
+
+// Route reasons reported alongside the chosen model.
+const (
+	RouteRequested  = "requested"  // the client named a model
+	RouteIntent     = "intent"     // an intent rule matched; see Route.Intent
+	RoutePreference = "preference" // the profile's cloud model preference
+	RouteDefault    = "default"    // the configured default model
+)
+
+// Route is the upstream model chosen for a request and why.
+type Route struct {
+	Model  string
+	Reason string // one of the Route* reasons
+	Intent string // intent type of the matching rule when Reason is RouteIntent
+}
+
+// Explain returns a short description of the route, e.g. "intent=recall".
+func (r Route) Explain() string {
+	if r.Reason == RouteIntent {
+		return RouteIntent + "=" + r.Intent
+	}
+	return r.Reason
+}
+
+// Router resolves AutoModel to an upstream model. Requests that name any
+// other model are forwarded unchanged.
+type Router struct {
+	defaultModel string
+	intentModels map[string]string // intent type -> model
+}
+
+// NewRouter creates a Router. intentModels is a comma-separated list of
+// intent=model rules, e.g. "recall=openai/gpt-4o-mini,task=anthropic/claude-opus-4".
+func NewRouter(defaultModel, intentModels string) (*Router, error) {
+	r := &Router{defaultModel: defaultModel, intentModels: make(map[string]string)}
+	for _, rule := range strings.Split(intentModels, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		intent, model, ok := strings.Cut(rule, "=")
+		intent, model = strings.ToLower(strings.TrimSpace(intent)), strings.TrimSpace(model)
+		if !ok || intent == "" || model == "" {
+			return nil, fmt.Errorf("invalid intent routing rule %q: want intent=model", rule)
+		}
+		if model == AutoModel {
+			return nil, fmt.Errorf("invalid intent routing rule %q: cannot route to %s", rule, AutoModel)
+		}
+		r.intentModels[intent] = model
+	}
+	return r, nil
+}
+
+// Route chooses the upstream model for a request that asked for requested.
+// For AutoModel, a rule for intentType wins over the profile's preference,
+// which wins over the default model. A nil Router forwards every model
+// unchanged.
+func (r *Router) Route(requested, intentType, preference string) Route {
+	if r == nil || requested != AutoModel {
+		return Route{Model: requested, Reason: RouteRequested}
+	}
+	if model, ok := r.intentModels[strings.ToLower(intentType)]; ok {
+		return Route{Model: model, Reason: RouteIntent, Intent: strings.ToLower(intentType)}
+	}
+	if preference != "" && preference != AutoModel {
+		return Route{Model: preference, Reason: RoutePreference}
+	}
+	return Route{Model: r.defaultModel, Reason: RouteDefault}
+}
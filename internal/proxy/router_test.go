@@ -0,0 +1,46 @@
+package proxy
+
+import "testing"
+
+func TestRouter_Route(t *testing.T) {
+	r, err := NewRouter("anthropic/claude-opus-4", " recall = openai/gpt-4o-mini , TASK=anthropic/claude-opus-4.1,")
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+
+	tests := []struct {
+		name                          string
+		requested, intent, preference string
+		want                          Route
+	}{
+		{"explicit model is kept", "openai/gpt-4o", "recall", "x/y", Route{Model: "openai/gpt-4o", Reason: RouteRequested}},
+		{"intent rule", AutoModel, "recall", "x/y", Route{Model: "openai/gpt-4o-mini", Reason: RouteIntent, Intent: "recall"}},
+		{"intent rule is case-insensitive", AutoModel, "Task", "", Route{Model: "anthropic/claude-opus-4.1", Reason: RouteIntent, Intent: "task"}},
+		{"preference without a rule", AutoModel, "question", "x/y", Route{Model: "x/y", Reason: RoutePreference}},
+		{"default", AutoModel, "", "", Route{Model: "anthropic/claude-opus-4", Reason: RouteDefault}},
+		{"auto preference is ignored", AutoModel, "", AutoModel, Route{Model: "anthropic/claude-opus-4", Reason: RouteDefault}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Route(tt.requested, tt.intent, tt.preference); got != tt.want {
+				t.Errorf("Route() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var nilRouter *Router
+	if got := nilRouter.Route(AutoModel, "recall", "x/y"); got.Model != AutoModel {
+		t.Errorf("nil router routed %s to %s, want it unchanged", AutoModel, got.Model)
+	}
+	if got := (Route{Reason: RouteIntent, Intent: "recall"}).Explain(); got != "intent=recall" {
+		t.Errorf("Explain() = %q, want intent=recall", got)
+	}
+}
+
+func TestNewRouter_InvalidRules(t *testing.T) {
+	for _, rules := range []string{"recall", "=m", "recall=", "task=" + AutoModel} {
+		if _, err := NewRouter("m", rules); err == nil {
+			t.Errorf("NewRouter(%q) succeeded, want an error", rules)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package reranking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/retrieval"
+)
+
+// maxRerankResponseBytes caps the response body read from the rerank endpoint.
+const maxRerankResponseBytes = 4 << 20
+
+// CrossEncoderReranker scores all chunks in a single request to a rerank
+// endpoint (TEI or llama.cpp server style POST /rerank). Threshold and timeout
+// behave as in LLMReranker: chunks scoring below threshold are dropped and a
+// timeout returns an error so the caller falls back to the original order.
+type CrossEncoderReranker struct {
+	httpClient *http.Client
+	endpoint   string
+	model      string
+	timeout    time.Duration
+	threshold  float64
+	rawLogits  bool
+}
+
+// NewCrossEncoderReranker creates a reranker that posts to baseURL + "/rerank".
+// model is forwarded in the request for servers hosting several rerankers and
+// may be empty. Set rawLogits for servers that return unnormalized logits
+// (llama.cpp); every score is then mapped through a sigmoid. TEI already
+// returns 0–1 scores and should leave it false.
+func NewCrossEncoderReranker(baseURL, model string, timeout time.Duration, threshold float64, rawLogits bool) *CrossEncoderReranker {
+	return &CrossEncoderReranker{
+		httpClient: &http.Client{},
+		endpoint:   strings.TrimRight(baseURL, "/") + "/rerank",
+		model:      model,
+		timeout:    timeout,
+		threshold:  threshold,
+		rawLogits:  rawLogits,
+	}
+}
+
+// rerankRequest uses the TEI field names, which llama.cpp's server also
+// accepts on /rerank.
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Texts     []string `json:"texts"`
+	Truncate  bool     `json:"truncate"`
+	RawScores bool     `json:"raw_scores"`
+}
+
+// rerankResult covers both response shapes: TEI returns "score", while
+// Jina/Cohere-style servers return "relevance_score".
+type rerankResult struct {
+	Index          int      `json:"index"`
+	Score          *float64 `json:"score"`
+	RelevanceScore *float64 `json:"relevance_score"`
+}
+
+// Rerank sends the query and all chunk texts in one request and returns the
+// chunks re-scored, filtered by threshold, and sorted by score descending.
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, chunks []retrieval.ContextChunk) ([]retrieval.ContextChunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	texts := make([]string, len(chunks))
+	for i, ch := range chunks {
+		texts[i] = ch.Text
+	}
+	body, err := json.Marshal(rerankRequest{Model: r.model, Query: query, Texts: texts, Truncate: true})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling rerank request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating rerank request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("reranking timed out after %s", r.timeout)
+		}
+		return nil, fmt.Errorf("calling rerank endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxRerankResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading rerank response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	scores, err := parseRerankResponse(raw, len(chunks), r.rawLogits)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]retrieval.ContextChunk, 0, len(chunks))
+	for i, ch := range chunks {
+		ch.Score = float32(scores[i])
+		if float64(ch.Score) >= r.threshold {
+			filtered = append(filtered, ch)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Score > filtered[j].Score
+	})
+
+	return filtered, nil
+}
+
+// parseRerankResponse accepts either a bare array of results (TEI) or an
+// object with a "results" array (llama.cpp, Jina, Cohere) and returns scores
+// keyed by chunk index. The response must score every one of the n chunks:
+// retrieval scores are not on the reranker's scale, so a partial response
+// cannot be mixed with them and is reported as an error instead. When
+// rawLogits is set every score is mapped through a sigmoid.
+func parseRerankResponse(raw []byte, n int, rawLogits bool) (map[int]float64, error) {
+	var results []rerankResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		var wrapped struct {
+			Results []rerankResult `json:"results"`
+		}
+		if err2 := json.Unmarshal(raw, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("decoding rerank response: %w", err)
+		}
+		results = wrapped.Results
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("rerank response contains no results: %.200s", raw)
+	}
+
+	scores := make(map[int]float64, len(results))
+	for _, res := range results {
+		if res.Index < 0 || res.Index >= n {
+			return nil, fmt.Errorf("rerank response index %d out of range [0,%d)", res.Index, n)
+		}
+		var s float64
+		switch {
+		case res.Score != nil:
+			s = *res.Score
+		case res.RelevanceScore != nil:
+			s = *res.RelevanceScore
+		default:
+			return nil, fmt.Errorf("rerank response for index %d has no score", res.Index)
+		}
+		if rawLogits {
+			s = 1 / (1 + math.Exp(-s))
+		}
+		scores[res.Index] = s
+	}
+	if len(scores) < n {
+		return nil, fmt.Errorf("rerank response scored %d of %d chunks", len(scores), n)
+	}
+	return scores, nil
+}
@@ -0,0 +1,183 @@
+package reranking
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRerankServer(t *testing.T, handler func(w http.ResponseWriter, req rerankRequest)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rerank" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		var req rerankRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCrossEncoderReranker_SingleBatchedRequest(t *testing.T) {
+	calls := 0
+	srv := newRerankServer(t, func(w http.ResponseWriter, req rerankRequest) {
+		calls++
+		if req.Query != "query" {
+			t.Errorf("query = %q, want %q", req.Query, "query")
+		}
+		if len(req.Texts) != 3 {
+			t.Errorf("got %d texts, want 3", len(req.Texts))
+		}
+		w.Write([]byte(`[{"index":1,"score":0.95},{"index":2,"score":0.6},{"index":0,"score":0.1}]`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0.3, false)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(3, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("endpoint called %d times, want 1", calls)
+	}
+	if len(result) != 2 {
+		t.Fatalf("got %d chunks, want 2 (chunk-0 below threshold)", len(result))
+	}
+	if result[0].ID != "chunk-1" || result[1].ID != "chunk-2" {
+		t.Errorf("order = [%s %s], want [chunk-1 chunk-2]", result[0].ID, result[1].ID)
+	}
+}
+
+func TestCrossEncoderReranker_ResultsEnvelope(t *testing.T) {
+	srv := newRerankServer(t, func(w http.ResponseWriter, req rerankRequest) {
+		if req.Model != "bge-reranker" {
+			t.Errorf("model = %q, want bge-reranker", req.Model)
+		}
+		w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.2},{"index":1,"relevance_score":0.8}]}`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL+"/", "bge-reranker", 5*time.Second, 0, false)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 2 || result[0].ID != "chunk-1" {
+		t.Fatalf("got %+v, want chunk-1 first", result)
+	}
+	if result[0].Score != 0.8 {
+		t.Errorf("score = %g, want 0.8", result[0].Score)
+	}
+}
+
+func TestCrossEncoderReranker_LogitsNormalized(t *testing.T) {
+	srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+		w.Write([]byte(`[{"index":0,"score":4.2},{"index":1,"score":-3.1}]`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0.3, true)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].ID != "chunk-0" {
+		t.Fatalf("got %+v, want only chunk-0", result)
+	}
+	if result[0].Score <= 0.9 || result[0].Score > 1 {
+		t.Errorf("score = %g, want sigmoid(4.2) in (0.9, 1]", result[0].Score)
+	}
+}
+
+func TestCrossEncoderReranker_Timeout(t *testing.T) {
+	srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`[]`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 20*time.Millisecond, 0.3, false)
+	if _, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5)); err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestCrossEncoderReranker_ServerError(t *testing.T) {
+	srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0.3, false)
+	if _, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5)); err == nil {
+		t.Fatal("expected error on non-200 response")
+	}
+}
+
+func TestCrossEncoderReranker_IndexOutOfRange(t *testing.T) {
+	srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+		w.Write([]byte(`[{"index":5,"score":0.9}]`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0.3, false)
+	if _, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5)); err == nil {
+		t.Fatal("expected error for out-of-range index")
+	}
+}
+
+func TestCrossEncoderReranker_EmptyInput(t *testing.T) {
+	r := NewCrossEncoderReranker("http://127.0.0.1:1", "", time.Second, 0.3, false)
+	result, err := r.Rerank(context.Background(), "query", nil)
+	if err != nil || len(result) != 0 {
+		t.Fatalf("got (%v, %v), want empty result without a request", result, err)
+	}
+}
+
+func TestCrossEncoderReranker_LogitNormalizationIndependentOfBatch(t *testing.T) {
+	// With raw logits, a pair's score must not depend on its neighbours: a
+	// batch whose logits all happen to fall in [0,1] is still normalized.
+	srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+		w.Write([]byte(`[{"index":0,"score":0.5},{"index":1,"score":0.0}]`))
+	})
+
+	r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0, true)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(result))
+	}
+	if math.Abs(float64(result[1].Score)-0.5) > 1e-6 {
+		t.Errorf("sigmoid(0) = %g, want 0.5", result[1].Score)
+	}
+	if math.Abs(float64(result[0].Score)-1/(1+math.Exp(-0.5))) > 1e-6 {
+		t.Errorf("sigmoid(0.5) = %g, want %g", result[0].Score, 1/(1+math.Exp(-0.5)))
+	}
+}
+
+func TestCrossEncoderReranker_IncompleteResponseIsError(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty object": `{}`,
+		"error object": `{"error":"model not loaded"}`,
+		"empty array":  `[]`,
+		"partial":      `[{"index":0,"score":0.9}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := newRerankServer(t, func(w http.ResponseWriter, _ rerankRequest) {
+				w.Write([]byte(body))
+			})
+
+			r := NewCrossEncoderReranker(srv.URL, "", 5*time.Second, 0.3, false)
+			if _, err := r.Rerank(context.Background(), "query", makeChunks(2, 0.5)); err == nil {
+				t.Fatal("expected an error so the caller falls back to the original order")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package reranking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/engine"
+	"github.com/kalambet/tbyd/internal/retrieval"
+)
+
+// maxListwiseChunkChars bounds each candidate's text in the listwise prompt so
+// that topK×4 candidates fit in the fast model's context window.
+const maxListwiseChunkChars = 600
+
+// ListwiseReranker ranks all candidates with a single structured-output call
+// to the fast model. The model sees the query and numbered candidates and
+// returns an ordered list of candidate IDs with relevance scores.
+//
+// Threshold and timeout behave as in LLMReranker. A malformed response leaves
+// the candidates in their original order.
+type ListwiseReranker struct {
+	engine    engine.Engine
+	model     string
+	timeout   time.Duration
+	threshold float64
+}
+
+// NewListwiseReranker creates a ListwiseReranker using the given engine and model.
+func NewListwiseReranker(eng engine.Engine, model string, timeout time.Duration, threshold float64) *ListwiseReranker {
+	return &ListwiseReranker{
+		engine:    eng,
+		model:     model,
+		timeout:   timeout,
+		threshold: threshold,
+	}
+}
+
+var listwiseSchema = &engine.Schema{
+	Type: "object",
+	Properties: map[string]engine.SchemaProperty{
+		"ranking": {Type: "array", Description: `Candidates ordered most to least relevant, each {"id": <int>, "score": <float 0.0–1.0>}`},
+	},
+	Required: []string{"ranking"},
+}
+
+type listwiseEntry struct {
+	ID    int     `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Rerank asks the model to order all chunks in one call. Ranked candidates are
+// threshold-filtered and sorted by the model's score. Candidates the model
+// omits (a truncated or partial ranking) were never judged, so they follow
+// the ranked ones in their original order with their original scores rather
+// than being dropped. Returns an error only on chat failure or timeout.
+func (r *ListwiseReranker) Rerank(ctx context.Context, query string, chunks []retrieval.ContextChunk) ([]retrieval.ContextChunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	resp, err := r.engine.Chat(timeoutCtx, r.model, buildListwisePrompt(query, chunks), listwiseSchema)
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("reranking timed out after %s", r.timeout)
+		}
+		return nil, fmt.Errorf("listwise rerank chat: %w", err)
+	}
+
+	ranking, err := parseRanking(resp, len(chunks))
+	if err != nil {
+		slog.Warn("reranker: malformed listwise response, keeping original order", "error", err)
+		return chunks, nil
+	}
+
+	filtered := make([]retrieval.ContextChunk, 0, len(chunks))
+	ranked := make([]bool, len(chunks))
+	for _, e := range ranking {
+		ranked[e.ID-1] = true
+		if e.Score < r.threshold {
+			continue
+		}
+		ch := chunks[e.ID-1]
+		ch.Score = float32(e.Score)
+		filtered = append(filtered, ch)
+	}
+
+	// Stable so that equal scores keep the model's ordering.
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Score > filtered[j].Score
+	})
+
+	for i, ch := range chunks {
+		if !ranked[i] {
+			filtered = append(filtered, ch)
+		}
+	}
+
+	return filtered, nil
+}
+
+func buildListwisePrompt(query string, chunks []retrieval.ContextChunk) []engine.Message {
+	var sb strings.Builder
+	sb.WriteString("Rank the candidate passages by relevance to the query.\n")
+	sb.WriteString("Query: " + query + "\n\nCandidates:\n")
+	for i, ch := range chunks {
+		text := ch.Text
+		if len(text) > maxListwiseChunkChars {
+			text = strings.ToValidUTF8(text[:maxListwiseChunkChars], "")
+		}
+		fmt.Fprintf(&sb, "[%d] %s\n", i+1, strings.ReplaceAll(text, "\n", " "))
+	}
+	sb.WriteString("\nReturn every candidate ID exactly once, most relevant first, with a relevance score from 0.0 to 1.0.\n")
+	sb.WriteString(`Respond with only a JSON object: {"ranking": [{"id": <int>, "score": <float>}, ...]}`)
+
+	return []engine.Message{{Role: "user", Content: sb.String()}}
+}
+
+// parseRanking decodes the model's ranking and validates it against n
+// candidates. Entries with unknown or repeated IDs are skipped; scores are
+// clamped to [0, 1]. A response with no usable entries is an error.
+func parseRanking(resp string, n int) ([]listwiseEntry, error) {
+	obj, err := extractJSONObject(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Ranking []listwiseEntry `json:"ranking"`
+	}
+	if err := json.Unmarshal([]byte(obj), &parsed); err != nil {
+		return nil, fmt.Errorf("unmarshal ranking: %w", err)
+	}
+
+	seen := make(map[int]bool, len(parsed.Ranking))
+	valid := make([]listwiseEntry, 0, len(parsed.Ranking))
+	for _, e := range parsed.Ranking {
+		if e.ID < 1 || e.ID > n || seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		e.Score = min(max(e.Score, 0), 1)
+		valid = append(valid, e)
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("ranking contains no valid candidate IDs")
+	}
+	return valid, nil
+}
@@ -0,0 +1,154 @@
+package reranking
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/engine"
+)
+
+func TestListwiseReranker_SingleCallReorders(t *testing.T) {
+	var calls atomic.Int32
+	eng := &mockEngine{
+		chatFn: func(_ context.Context, _ string, msgs []engine.Message, _ *engine.Schema) (string, error) {
+			calls.Add(1)
+			if !strings.Contains(msgs[0].Content, "[3] text 2") {
+				t.Errorf("prompt missing numbered candidate: %q", msgs[0].Content)
+			}
+			return `{"ranking": [{"id": 3, "score": 0.9}, {"id": 1, "score": 0.6}, {"id": 2, "score": 0.1}]}`, nil
+		},
+	}
+
+	r := NewListwiseReranker(eng, "phi3.5", 5*time.Second, 0.3)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(3, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("chat called %d times, want 1", calls.Load())
+	}
+	if len(result) != 2 {
+		t.Fatalf("got %d chunks, want 2 (chunk-1 below threshold)", len(result))
+	}
+	if result[0].ID != "chunk-2" || result[1].ID != "chunk-0" {
+		t.Errorf("order = [%s %s], want [chunk-2 chunk-0]", result[0].ID, result[1].ID)
+	}
+	if result[0].Score != 0.9 {
+		t.Errorf("score = %g, want 0.9", result[0].Score)
+	}
+}
+
+func TestListwiseReranker_MalformedKeepsOriginalOrder(t *testing.T) {
+	for name, resp := range map[string]string{
+		"not json":     "I think candidate 2 is best.",
+		"no valid ids": `{"ranking": [{"id": 0, "score": 0.9}, {"id": 42, "score": 0.8}]}`,
+		"wrong shape":  `{"ranking": "2,1,3"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			eng := &mockEngine{
+				chatFn: func(context.Context, string, []engine.Message, *engine.Schema) (string, error) {
+					return resp, nil
+				},
+			}
+
+			chunks := makeChunks(3, 0.5)
+			r := NewListwiseReranker(eng, "phi3.5", 5*time.Second, 0.3)
+			result, err := r.Rerank(context.Background(), "query", chunks)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(chunks) {
+				t.Fatalf("got %d chunks, want %d", len(result), len(chunks))
+			}
+			for i := range chunks {
+				if result[i].ID != chunks[i].ID || result[i].Score != chunks[i].Score {
+					t.Errorf("result[%d] = %+v, want original %+v", i, result[i], chunks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListwiseReranker_OmittedAndDuplicateIDs(t *testing.T) {
+	eng := &mockEngine{
+		chatFn: func(context.Context, string, []engine.Message, *engine.Schema) (string, error) {
+			return "```json\n{\"ranking\": [{\"id\": 2, \"score\": 1.7}, {\"id\": 2, \"score\": 0.1}]}\n```", nil
+		},
+	}
+
+	r := NewListwiseReranker(eng, "phi3.5", 5*time.Second, 0)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(3, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(result))
+	}
+	if result[0].ID != "chunk-1" || result[0].Score != 1 {
+		t.Errorf("first = %+v, want chunk-1 with clamped score 1", result[0])
+	}
+	// Omitted candidates follow in their original order, scores untouched.
+	for i, want := range []string{"chunk-0", "chunk-2"} {
+		if result[i+1].ID != want || result[i+1].Score != 0.5 {
+			t.Errorf("result[%d] = %+v, want %s with original score 0.5", i+1, result[i+1], want)
+		}
+	}
+}
+
+func TestListwiseReranker_TruncatedRankingKeepsUnranked(t *testing.T) {
+	// The model stops after two of five candidates; the rest must survive a
+	// non-zero threshold and keep their retrieval order below the ranked ones.
+	eng := &mockEngine{
+		chatFn: func(context.Context, string, []engine.Message, *engine.Schema) (string, error) {
+			return `{"ranking": [{"id": 4, "score": 0.8}, {"id": 2, "score": 0.1}]}`, nil
+		},
+	}
+
+	r := NewListwiseReranker(eng, "phi3.5", 5*time.Second, 0.3)
+	result, err := r.Rerank(context.Background(), "query", makeChunks(5, 0.5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"chunk-3", "chunk-0", "chunk-2", "chunk-4"}
+	if len(result) != len(want) {
+		t.Fatalf("got %d chunks, want %d (only the ranked chunk-1 is below threshold)", len(result), len(want))
+	}
+	for i, id := range want {
+		if result[i].ID != id {
+			t.Errorf("result[%d] = %s, want %s", i, result[i].ID, id)
+		}
+	}
+}
+
+func TestListwiseReranker_Timeout(t *testing.T) {
+	eng := &mockEngine{
+		chatFn: func(ctx context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+
+	r := NewListwiseReranker(eng, "phi3.5", 10*time.Millisecond, 0.3)
+	if _, err := r.Rerank(context.Background(), "query", makeChunks(3, 0.5)); err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestListwiseReranker_ChatError(t *testing.T) {
+	eng := &mockEngine{
+		chatFn: func(context.Context, string, []engine.Message, *engine.Schema) (string, error) {
+			return "", errors.New("connection refused")
+		},
+	}
+
+	r := NewListwiseReranker(eng, "phi3.5", 5*time.Second, 0.3)
+	if _, err := r.Rerank(context.Background(), "query", makeChunks(3, 0.5)); err == nil {
+		t.Fatal("expected error on chat failure")
+	}
+}
@@ -180,6 +180,23 @@ func (r *LLMReranker) scoreChunk(ctx context.Context, query string, chunk retrie
 //  3. Attempts json.Unmarshal on the extracted substring
 //  4. On failure: returns originalScore so the chunk is not penalised
 func parseScore(resp string, originalScore float32) (float64, error) {
+	obj, err := extractJSONObject(resp)
+	if err != nil {
+		return float64(originalScore), err
+	}
+
+	var parsed struct {
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(obj), &parsed); err != nil {
+		return float64(originalScore), fmt.Errorf("unmarshal score: %w", err)
+	}
+	return parsed.Score, nil
+}
+
+// extractJSONObject strips markdown code fences and returns the substring from
+// the first { to the last } of resp.
+func extractJSONObject(resp string) (string, error) {
 	s := strings.TrimSpace(resp)
 
 	// Strip markdown code fences if present. Skip the optional language tag
@@ -196,16 +213,9 @@ func parseScore(resp string, originalScore float32) (float64, error) {
 	start := strings.Index(s, "{")
 	end := strings.LastIndex(s, "}")
 	if start == -1 || end <= start {
-		return float64(originalScore), fmt.Errorf("no JSON object in response")
-	}
-
-	var obj struct {
-		Score float64 `json:"score"`
-	}
-	if err := json.Unmarshal([]byte(s[start:end+1]), &obj); err != nil {
-		return float64(originalScore), fmt.Errorf("unmarshal score: %w", err)
+		return "", fmt.Errorf("no JSON object in response")
 	}
-	return obj.Score, nil
+	return s[start : end+1], nil
 }
 
 // NoOpReranker passes chunks through unchanged. Used when reranking is disabled.
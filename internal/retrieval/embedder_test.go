@@ -13,9 +13,13 @@ import (
 // mockEngine implements engine.Engine for testing.
 type mockEngine struct {
 	embedFn func(ctx context.Context, model string, text string) ([]float32, error)
+	chatFn  func(ctx context.Context, model string, msgs []engine.Message, schema *engine.Schema) (string, error)
 }
 
-func (m *mockEngine) Chat(_ context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+func (m *mockEngine) Chat(ctx context.Context, model string, msgs []engine.Message, schema *engine.Schema) (string, error) {
+	if m.chatFn != nil {
+		return m.chatFn(ctx, model, msgs, schema)
+	}
 	return "", fmt.Errorf("not implemented")
 }
 func (m *mockEngine) Embed(ctx context.Context, model string, text string) ([]float32, error) {
@@ -0,0 +1,268 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/engine"
+	"github.com/kalambet/tbyd/internal/intent"
+)
+
+type stubExpander struct {
+	texts []string
+	err   error
+}
+
+func (s *stubExpander) Expand(_ context.Context, _ string) ([]string, error) {
+	return s.texts, s.err
+}
+
+func TestLLMExpander_ParsesPassageAndParaphrases(t *testing.T) {
+	eng := &mockEngine{
+		chatFn: func(_ context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+			return "```json\n{\"hypothetical_answer\": \"Go uses goroutines for concurrency.\", \"paraphrases\": [\"golang concurrency\", \"How does Go do concurrency?\", \"extra\"]}\n```", nil
+		},
+	}
+
+	e := NewLLMExpander(eng, "phi3.5", time.Second, 2)
+	texts, err := e.Expand(context.Background(), "how does go do concurrency?")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+
+	// The paraphrase identical to the query (case-insensitive) is dropped and
+	// the list is capped at two paraphrases before filtering.
+	want := []string{"Go uses goroutines for concurrency.", "golang concurrency"}
+	if len(texts) != len(want) {
+		t.Fatalf("got %v, want %v", texts, want)
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Errorf("texts[%d] = %q, want %q", i, texts[i], want[i])
+		}
+	}
+}
+
+func TestLLMExpander_MalformedResponse(t *testing.T) {
+	eng := &mockEngine{
+		chatFn: func(_ context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+			return "not json", nil
+		},
+	}
+
+	e := NewLLMExpander(eng, "phi3.5", time.Second, 2)
+	texts, err := e.Expand(context.Background(), "query")
+	if err == nil {
+		t.Fatal("expected error for malformed response")
+	}
+	if texts != nil {
+		t.Errorf("texts = %v, want nil", texts)
+	}
+}
+
+func TestLLMExpander_DisablesAfterRepeatedTimeouts(t *testing.T) {
+	var calls atomic.Int32
+	eng := &mockEngine{
+		chatFn: func(ctx context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+			calls.Add(1)
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	e := NewLLMExpander(eng, "phi3.5", 5*time.Millisecond, 2)
+	e.now = func() time.Time { return now }
+
+	for range expanderSlowStreak {
+		if _, err := e.Expand(context.Background(), "query"); err == nil {
+			t.Fatal("expected timeout error")
+		}
+	}
+
+	texts, err := e.Expand(context.Background(), "query")
+	if err != nil || texts != nil {
+		t.Fatalf("disabled expander returned (%v, %v), want (nil, nil)", texts, err)
+	}
+	if got := calls.Load(); got != expanderSlowStreak {
+		t.Errorf("engine called %d times, want %d", got, expanderSlowStreak)
+	}
+
+	// After the cooldown the expander probes the engine again.
+	now = now.Add(expanderCooldown)
+	e.Expand(context.Background(), "query")
+	if got := calls.Load(); got != expanderSlowStreak+1 {
+		t.Errorf("engine called %d times after cooldown, want %d", got, expanderSlowStreak+1)
+	}
+}
+
+func TestLLMExpander_FastCallResetsStreak(t *testing.T) {
+	slow := true
+	eng := &mockEngine{
+		chatFn: func(ctx context.Context, _ string, _ []engine.Message, _ *engine.Schema) (string, error) {
+			if slow {
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return `{"hypothetical_answer": "answer", "paraphrases": []}`, nil
+		},
+	}
+
+	e := NewLLMExpander(eng, "phi3.5", 5*time.Millisecond, 2)
+	for range expanderSlowStreak - 1 {
+		e.Expand(context.Background(), "query")
+	}
+	slow = false
+	e.Expand(context.Background(), "query")
+	slow = true
+	e.Expand(context.Background(), "query")
+
+	if !e.available() {
+		t.Error("expander disabled although slow calls were not consecutive")
+	}
+}
+
+func TestRetrieveForIntent_ExpansionFusesResults(t *testing.T) {
+	queryVec := []float32{1, 0}
+	hydeVec := []float32{0, 1}
+
+	var batchEmbeds atomic.Int32
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, text string) ([]float32, error) {
+			if text == "query" {
+				return queryVec, nil
+			}
+			batchEmbeds.Add(1)
+			return hydeVec, nil
+		},
+	}
+
+	rec := func(id string) ScoredRecord {
+		return ScoredRecord{Record: Record{ID: id, SourceID: id, SourceType: "doc", TextChunk: id}, Score: 0.9}
+	}
+	store := &mockVectorStore{
+		searchFn: func(_ string, vec []float32, _ int, _ string) ([]ScoredRecord, error) {
+			if vec[0] == 1 {
+				return []ScoredRecord{rec("a"), rec("b")}, nil
+			}
+			return []ScoredRecord{rec("c"), rec("b")}, nil
+		},
+	}
+
+	r := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	r.SetExpander(&stubExpander{texts: []string{"hypothetical passage"}}, 0)
+
+	chunks := r.RetrieveForIntent(context.Background(), "query", intent.Intent{SearchStrategy: "vector_only"}, 5)
+
+	if batchEmbeds.Load() != 1 {
+		t.Errorf("expansion embedded %d texts, want 1", batchEmbeds.Load())
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	// "b" appears in both lists so it outranks "a", which appears only in the
+	// higher-weighted base list, which in turn outranks "c".
+	wantOrder := []string{"b", "a", "c"}
+	for i, id := range wantOrder {
+		if chunks[i].SourceID != id {
+			t.Errorf("chunks[%d] = %q, want %q", i, chunks[i].SourceID, id)
+		}
+	}
+}
+
+func TestRetrieveForIntent_ExpansionFailureKeepsBase(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, _ string) ([]float32, error) {
+			return makeVector(8), nil
+		},
+	}
+	store := &mockVectorStore{
+		searchFn: func(_ string, _ []float32, _ int, _ string) ([]ScoredRecord, error) {
+			return []ScoredRecord{{Record: Record{ID: "r1", SourceID: "s1"}, Score: 0.8}}, nil
+		},
+	}
+
+	r := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	r.SetExpander(&stubExpander{err: errors.New("engine down")}, 0)
+
+	chunks := r.RetrieveForIntent(context.Background(), "query", intent.Intent{SearchStrategy: "vector_only"}, 5)
+	if len(chunks) != 1 || chunks[0].ID != "r1" {
+		t.Fatalf("got %+v, want base result r1", chunks)
+	}
+	if chunks[0].Score != 0.8 {
+		t.Errorf("score = %g, want original 0.8", chunks[0].Score)
+	}
+}
+
+func TestRetrieveForIntent_ExpansionKeepsOriginalScores(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, text string) ([]float32, error) {
+			if text == "query" {
+				return []float32{1, 0}, nil
+			}
+			return []float32{0, 1}, nil
+		},
+	}
+	store := &mockVectorStore{
+		searchFn: func(_ string, vec []float32, _ int, _ string) ([]ScoredRecord, error) {
+			if vec[0] == 1 {
+				return []ScoredRecord{{Record: Record{ID: "a", SourceID: "a"}, Score: 0.82}}, nil
+			}
+			return []ScoredRecord{
+				{Record: Record{ID: "a", SourceID: "a"}, Score: 0.4},
+				{Record: Record{ID: "c", SourceID: "c"}, Score: 0.65},
+			}, nil
+		},
+	}
+
+	r := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	r.SetExpander(&stubExpander{texts: []string{"hypothetical passage"}}, 0)
+
+	chunks := r.RetrieveForIntent(context.Background(), "query", intent.Intent{SearchStrategy: "vector_only"}, 5)
+	want := map[string]float32{"a": 0.82, "c": 0.65}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(want))
+	}
+	for _, ch := range chunks {
+		if ch.Score != want[ch.SourceID] {
+			t.Errorf("chunk %s score = %g, want similarity %g (not the fused RRF value)", ch.SourceID, ch.Score, want[ch.SourceID])
+		}
+	}
+}
+
+func TestRetrieveForIntent_ExpansionBudgetBoundsSearch(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, text string) ([]float32, error) {
+			if text == "query" {
+				return []float32{1, 0}, nil
+			}
+			return []float32{0, 1}, nil
+		},
+	}
+	release := make(chan struct{})
+	defer close(release)
+	store := &mockVectorStore{
+		searchFn: func(_ string, vec []float32, _ int, _ string) ([]ScoredRecord, error) {
+			if vec[0] == 1 {
+				return []ScoredRecord{{Record: Record{ID: "a", SourceID: "a"}, Score: 0.9}}, nil
+			}
+			<-release // expansion search hangs past the budget
+			return []ScoredRecord{{Record: Record{ID: "late", SourceID: "late"}, Score: 0.9}}, nil
+		},
+	}
+
+	r := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	r.SetExpander(&stubExpander{texts: []string{"hypothetical passage"}}, 50*time.Millisecond)
+
+	start := time.Now()
+	chunks := r.RetrieveForIntent(context.Background(), "query", intent.Intent{SearchStrategy: "vector_only"}, 5)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("retrieval took %v, want it bounded by the expansion budget", elapsed)
+	}
+	if len(chunks) != 1 || chunks[0].SourceID != "a" {
+		t.Errorf("got %+v, want only the base result", chunks)
+	}
+}
@@ -0,0 +1,63 @@
+package retrieval
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// graphTimeout bounds the knowledge-graph lookup so a slow store cannot hold
+// up retrieval.
+const graphTimeout = 200 * time.Millisecond
+
+// graphScoreWeight scales the scores of results found through graph
+// neighbours. A neighbour is one hop from what the user asked about, so its
+// results rank below equally similar results for the query or its entities.
+const graphScoreWeight = 0.8
+
+// EntityGraph finds entities related to named ones in the knowledge graph.
+// *storage.Store satisfies it.
+type EntityGraph interface {
+	RelatedEntities(ctx context.Context, scope storage.GraphScope, names []string, limit int) ([]string, error)
+}
+
+// SetGraph enables knowledge-graph expansion in RetrieveForIntent: up to
+// neighbours entities related to the intent's entities are searched as well.
+// Passing nil or neighbours <= 0 disables it. Must be called before the
+// Retriever is used concurrently.
+func (r *Retriever) SetGraph(g EntityGraph, neighbours int) {
+	if neighbours <= 0 {
+		g = nil
+	}
+	r.graph = g
+	r.graphNeighbours = neighbours
+}
+
+// relatedEntities returns the graph neighbours of entities according to the
+// docs in scope's partition, or nil when graph expansion is disabled or the
+// lookup fails.
+func (r *Retriever) relatedEntities(ctx context.Context, entities []string, scope Scope) []string {
+	if r.graph == nil || len(entities) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, graphTimeout)
+	defer cancel()
+	graphScope := storage.GraphScope{Partitioned: scope.Partitioned, Partition: scope.Partition}
+	related, err := r.graph.RelatedEntities(ctx, graphScope, entities, r.graphNeighbours)
+	if err != nil {
+		slog.Warn("knowledge graph lookup failed, skipping graph expansion", "error", err)
+		return nil
+	}
+	return related
+}
+
+// tagGraphResults marks results as found through a graph neighbour and
+// scales their scores by graphScoreWeight.
+func tagGraphResults(results []ScoredRecord) {
+	tagLegs(results, LegGraph)
+	for i := range results {
+		results[i].Score *= graphScoreWeight
+	}
+}
@@ -3,6 +3,7 @@ package retrieval
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -21,12 +22,19 @@ type ContextChunk struct {
 	Score      float32
 	Tags       string
 	CreatedAt  time.Time
+	Legs       []string // retrieval legs that surfaced the chunk; see LegVector et al.
+	Project    string   // project workspace of the source document; "" is none
 }
 
 // Retriever combines embedding and vector search to find relevant context.
 type Retriever struct {
-	embedder *Embedder
-	store    VectorStore
+	embedder        *Embedder
+	store           VectorStore
+	expander        QueryExpander
+	expansionBudget time.Duration
+	ratios          HybridRatioSource
+	graph           EntityGraph
+	graphNeighbours int
 }
 
 // NewRetriever creates a Retriever backed by the given Embedder and VectorStore.
@@ -34,14 +42,36 @@ func NewRetriever(embedder *Embedder, store VectorStore) *Retriever {
 	return &Retriever{embedder: embedder, store: store}
 }
 
-// Retrieve embeds the query and returns the top-K most similar context chunks.
+// SetExpander enables query expansion in RetrieveForIntent. Passing nil
+// disables it. budget bounds the follow-up work on the expansion texts
+// (embedding and searching them); zero means unbounded. Must be called before
+// the Retriever is used concurrently.
+func (r *Retriever) SetExpander(e QueryExpander, budget time.Duration) {
+	r.expander = e
+	r.expansionBudget = budget
+}
+
+// SetHybridRatioSource makes RetrieveForIntent use learned per-intent hybrid
+// ratios in place of the built-in default. Passing nil restores the default.
+// Must be called before the Retriever is used concurrently.
+func (r *Retriever) SetHybridRatioSource(src HybridRatioSource) {
+	r.ratios = src
+}
+
+// Retrieve embeds the query and returns the top-K most similar context chunks
+// from every knowledge-base partition.
 func (r *Retriever) Retrieve(ctx context.Context, query string, topK int) ([]ContextChunk, error) {
+	return r.RetrieveIn(ctx, query, topK, Scope{})
+}
+
+// RetrieveIn is Retrieve limited to scope.
+func (r *Retriever) RetrieveIn(ctx context.Context, query string, topK int, scope Scope) ([]ContextChunk, error) {
 	vec, err := r.embedder.Embed(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	scored, err := r.store.Search(expectedTable, vec, topK, "")
+	scored, err := r.store.Search(expectedTable, vec, topK, scope.filter(""))
 	if err != nil {
 		return nil, err
 	}
@@ -66,12 +96,31 @@ func (r *Retriever) RetrieveByIDs(ctx context.Context, ids []string) ([]ContextC
 // defaultHybridRatio is the default vector weight when the intent doesn't specify one.
 const defaultHybridRatio = 0.7
 
+// EffectiveStrategy returns the search strategy RetrieveForIntent runs for
+// an intent's SearchStrategy. Empty and unrecognised values search as hybrid.
+func EffectiveStrategy(strategy string) string {
+	switch strategy {
+	case "vector_only", "keyword_heavy":
+		return strategy
+	}
+	return "hybrid"
+}
+
 // RetrieveForIntent uses the extracted intent to perform richer context retrieval.
 // It selects between vector-only, hybrid, or keyword-heavy search based on
 // the intent's SearchStrategy. For hybrid/keyword_heavy strategies, it uses
 // SearchHybrid which combines BM25 keyword search with vector similarity.
 // On embedding failure, it returns an empty slice (graceful degradation).
+//
+// When an expander is set, expansion runs concurrently with the base search and
+// its texts are searched separately, then merged into the base results with
+// RRF. Expansion failures leave the base results untouched.
 func (r *Retriever) RetrieveForIntent(ctx context.Context, query string, extracted intent.Intent, topK int) []ContextChunk {
+	return r.RetrieveForIntentIn(ctx, query, extracted, topK, Scope{})
+}
+
+// RetrieveForIntentIn is RetrieveForIntent limited to scope.
+func (r *Retriever) RetrieveForIntentIn(ctx context.Context, query string, extracted intent.Intent, topK int, scope Scope) []ContextChunk {
 	if topK <= 0 {
 		return nil
 	}
@@ -93,40 +142,202 @@ func (r *Retriever) RetrieveForIntent(ctx context.Context, query string, extract
 	if len(extracted.Topics) > 0 {
 		filter = "topics:" + strings.Join(extracted.Topics, ",")
 	}
+	filter = scope.filter(filter)
 
 	// Determine search strategy and hybrid ratio.
-	strategy := extracted.SearchStrategy
-	if strategy == "" {
-		strategy = "hybrid"
-	}
+	strategy := EffectiveStrategy(extracted.SearchStrategy)
 	var hybridRatio float64
 	if extracted.HybridRatio != nil {
 		hybridRatio = *extracted.HybridRatio
 	} else if strategy == "keyword_heavy" {
 		hybridRatio = 0.3 // keyword-heavy: 30% vector, 70% keyword
+	} else if learned, ok := r.learnedRatio(extracted.IntentType); ok {
+		hybridRatio = learned
 	} else {
 		hybridRatio = defaultHybridRatio
 	}
 
-	// For vector_only strategy, use the original multi-embedding approach.
+	// Start query expansion alongside the base search so its latency budget
+	// overlaps with retrieval instead of adding to it.
+	var expansions chan []string
+	if r.expander != nil {
+		expansions = make(chan []string, 1)
+		go func() {
+			texts, err := r.expander.Expand(ctx, query)
+			if err != nil {
+				slog.Warn("query expansion failed, using original query only", "error", err)
+			}
+			expansions <- texts
+		}()
+	}
+
+	related := r.relatedEntities(ctx, extracted.Entities, scope)
+
+	var chunks []ContextChunk
 	if strategy == "vector_only" {
-		return r.retrieveVectorOnly(ctx, query, extracted, topK, filter)
+		// For vector_only strategy, use the original multi-embedding approach.
+		chunks = r.retrieveVectorOnly(ctx, query, extracted, related, topK, filter)
+	} else {
+		// For hybrid and keyword_heavy: use SearchHybrid with entity expansion.
+		chunks = r.retrieveHybrid(ctx, query, extracted, related, topK, float32(hybridRatio), filter)
 	}
 
-	// For hybrid and keyword_heavy: use SearchHybrid with entity expansion.
-	return r.retrieveHybrid(ctx, query, extracted, topK, float32(hybridRatio), filter)
+	if expansions == nil {
+		return chunks
+	}
+	return r.fuseExpansions(ctx, chunks, <-expansions, topK, filter)
+}
+
+// learnedRatio returns the learned hybrid ratio for intentType, if any.
+func (r *Retriever) learnedRatio(intentType string) (float64, bool) {
+	if r.ratios == nil {
+		return 0, false
+	}
+	return r.ratios.Ratio(intentType)
+}
+
+// expansionListWeight scales the RRF contribution of each expansion result
+// list. Expansions are model guesses, so the original query's results keep
+// the larger share and win ties.
+const expansionListWeight = 0.5
+
+// fuseExpansions embeds the expansion texts in one batch, runs a vector search
+// for each, and merges those lists with the base results using weighted RRF
+// keyed on SourceID. The fused value only decides the order: every chunk keeps
+// the score its own search gave it (base chunks their original score), so
+// downstream thresholds and the composer still see similarity scores.
+//
+// The work is bounded by the expansion budget; searches still running at the
+// deadline are dropped. If embedding fails or nothing new is found, base is
+// returned unchanged.
+func (r *Retriever) fuseExpansions(ctx context.Context, base []ContextChunk, texts []string, topK int, filter string) []ContextChunk {
+	if len(texts) == 0 {
+		return base
+	}
+	if r.expansionBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.expansionBudget)
+		defer cancel()
+	}
+
+	vecs, err := r.embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		slog.Warn("query expansion embed failed, using original query only", "error", err)
+		return base
+	}
+
+	lists := r.searchExpansions(ctx, vecs, topK, filter)
+
+	const rrfK = 60
+	type fusedEntry struct {
+		chunk ContextChunk
+		rrf   float64
+	}
+	fused := make(map[string]*fusedEntry)
+	add := func(ch ContextChunk, rank int, weight float64) {
+		s := weight / float64(rrfK+rank+1)
+		entry, ok := fused[ch.SourceID]
+		if !ok {
+			fused[ch.SourceID] = &fusedEntry{chunk: ch, rrf: s}
+			return
+		}
+		entry.rrf += s
+		entry.chunk.Legs = mergeLegs(entry.chunk.Legs, ch.Legs)
+	}
+
+	for rank, ch := range base {
+		add(ch, rank, 1.0)
+	}
+	for _, list := range lists {
+		// Dedupe within a list first so one source cannot collect several
+		// ranks' worth of score from a single expansion.
+		tagLegs(list, LegExpansion)
+		for rank, ch := range deduplicateAndTrim(list, topK) {
+			add(ch, rank, expansionListWeight)
+		}
+	}
+
+	if len(fused) == 0 {
+		return base
+	}
+
+	entries := make([]*fusedEntry, 0, len(fused))
+	for _, entry := range fused {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].rrf != entries[j].rrf {
+			return entries[i].rrf > entries[j].rrf
+		}
+		return entries[i].chunk.SourceID < entries[j].chunk.SourceID
+	})
+
+	if len(entries) > topK {
+		entries = entries[:topK]
+	}
+	merged := make([]ContextChunk, len(entries))
+	for i, entry := range entries {
+		merged[i] = entry.chunk
+	}
+	return merged
+}
+
+// searchExpansions runs one vector search per expansion embedding, at most
+// four at a time. It returns whatever finished before ctx is done; the store
+// API takes no context, so late searches complete in the background and their
+// results are discarded.
+func (r *Retriever) searchExpansions(ctx context.Context, vecs [][]float32, topK int, filter string) [][]ScoredRecord {
+	type result struct {
+		i    int
+		recs []ScoredRecord
+	}
+	results := make(chan result, len(vecs)) // buffered so abandoned searches never block
+	sem := make(chan struct{}, 4)
+
+	go func() {
+		for i, vec := range vecs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				defer func() { <-sem }()
+				recs, err := r.store.Search(expectedTable, vec, topK, filter)
+				if err != nil {
+					slog.Warn("query expansion search failed, skipping", "error", err)
+				}
+				results <- result{i: i, recs: recs}
+			}()
+		}
+	}()
+
+	lists := make([][]ScoredRecord, len(vecs))
+	for range vecs {
+		select {
+		case res := <-results:
+			lists[res.i] = res.recs
+		case <-ctx.Done():
+			slog.Warn("query expansion exceeded its budget, using partial results", "error", ctx.Err())
+			return lists
+		}
+	}
+	return lists
 }
 
 // retrieveVectorOnly performs vector-only retrieval with entity expansion.
-func (r *Retriever) retrieveVectorOnly(ctx context.Context, query string, extracted intent.Intent, topK int, filter string) []ContextChunk {
+// related holds graph neighbours of the entities, searched after them.
+func (r *Retriever) retrieveVectorOnly(ctx context.Context, query string, extracted intent.Intent, related []string, topK int, filter string) []ContextChunk {
 	perSearchK := topK
 	if len(extracted.Entities) > 0 {
 		perSearchK = topK * 2
 	}
 
-	textsToSearch := make([]string, 0, 1+len(extracted.Entities))
+	textsToSearch := make([]string, 0, 1+len(extracted.Entities)+len(related))
 	textsToSearch = append(textsToSearch, query)
 	textsToSearch = append(textsToSearch, extracted.Entities...)
+	textsToSearch = append(textsToSearch, related...)
+	firstRelated := 1 + len(extracted.Entities)
 
 	var allScored []ScoredRecord
 	var mu sync.Mutex
@@ -134,7 +345,7 @@ func (r *Retriever) retrieveVectorOnly(ctx context.Context, query string, extrac
 	g, gCtx := errgroup.WithContext(ctx)
 	g.SetLimit(4)
 
-	for _, text := range textsToSearch {
+	for i, text := range textsToSearch {
 		g.Go(func() error {
 			vec, err := r.embedder.Embed(gCtx, text)
 			if err != nil {
@@ -147,6 +358,14 @@ func (r *Retriever) retrieveVectorOnly(ctx context.Context, query string, extrac
 				slog.Warn("retrieval search failed, skipping", "text_len", len(text), "error", err)
 				return nil
 			}
+			switch {
+			case i == 0:
+				tagLegs(results, LegVector)
+			case i >= firstRelated:
+				tagGraphResults(results)
+			default:
+				tagLegs(results, LegEntity)
+			}
 
 			if len(results) > 0 {
 				mu.Lock()
@@ -165,14 +384,17 @@ func (r *Retriever) retrieveVectorOnly(ctx context.Context, query string, extrac
 }
 
 // retrieveHybrid performs hybrid (vector + BM25) retrieval with entity expansion.
-func (r *Retriever) retrieveHybrid(ctx context.Context, query string, extracted intent.Intent, topK int, vectorWeight float32, filter string) []ContextChunk {
+// related holds graph neighbours of the entities, searched after them.
+func (r *Retriever) retrieveHybrid(ctx context.Context, query string, extracted intent.Intent, related []string, topK int, vectorWeight float32, filter string) []ContextChunk {
 	// Retrieve more candidates for merging/deduplication.
 	perSearchK := topK * 4
 
-	// Build search texts: original query + entities.
-	textsToSearch := make([]string, 0, 1+len(extracted.Entities))
+	// Build search texts: original query + entities + their graph neighbours.
+	textsToSearch := make([]string, 0, 1+len(extracted.Entities)+len(related))
 	textsToSearch = append(textsToSearch, query)
 	textsToSearch = append(textsToSearch, extracted.Entities...)
+	textsToSearch = append(textsToSearch, related...)
+	firstRelated := 1 + len(extracted.Entities)
 
 	var allScored []ScoredRecord
 	var mu sync.Mutex
@@ -180,7 +402,7 @@ func (r *Retriever) retrieveHybrid(ctx context.Context, query string, extracted
 	g, gCtx := errgroup.WithContext(ctx)
 	g.SetLimit(4)
 
-	for _, text := range textsToSearch {
+	for i, text := range textsToSearch {
 		g.Go(func() error {
 			vec, err := r.embedder.Embed(gCtx, text)
 			if err != nil {
@@ -193,6 +415,14 @@ func (r *Retriever) retrieveHybrid(ctx context.Context, query string, extracted
 				slog.Warn("hybrid retrieval search failed, skipping", "text_len", len(text), "error", err)
 				return nil
 			}
+			// Query results keep the vector/keyword legs set by SearchHybrid;
+			// entity and neighbour searches are attributed to their expansion
+			// as a whole.
+			if i >= firstRelated {
+				tagGraphResults(results)
+			} else if i > 0 {
+				tagLegs(results, LegEntity)
+			}
 
 			if len(results) > 0 {
 				mu.Lock()
@@ -210,8 +440,8 @@ func (r *Retriever) retrieveHybrid(ctx context.Context, query string, extracted
 	return deduplicateAndTrim(allScored, topK)
 }
 
-// deduplicateAndTrim deduplicates ScoredRecords by SourceID (keeping highest score),
-// sorts by score descending, and trims to topK.
+// deduplicateAndTrim deduplicates ScoredRecords by SourceID (keeping highest score
+// and the union of legs), sorts by score descending, and trims to topK.
 func deduplicateAndTrim(allScored []ScoredRecord, topK int) []ContextChunk {
 	if len(allScored) == 0 {
 		return nil
@@ -219,9 +449,17 @@ func deduplicateAndTrim(allScored []ScoredRecord, topK int) []ContextChunk {
 
 	seen := make(map[string]ScoredRecord)
 	for _, sr := range allScored {
-		if existing, ok := seen[sr.SourceID]; !ok || sr.Score > existing.Score {
+		existing, ok := seen[sr.SourceID]
+		if !ok {
 			seen[sr.SourceID] = sr
+			continue
+		}
+		legs := mergeLegs(existing.Legs, sr.Legs)
+		if sr.Score > existing.Score {
+			existing = sr
 		}
+		existing.Legs = legs
+		seen[sr.SourceID] = existing
 	}
 
 	deduped := make([]ScoredRecord, 0, len(seen))
@@ -251,11 +489,55 @@ func scoredToChunks(scored []ScoredRecord) []ContextChunk {
 			Score:      s.Score,
 			Tags:       s.Tags,
 			CreatedAt:  s.CreatedAt,
+			Legs:       s.Legs,
+			Project:    s.Project,
 		}
 	}
 	return chunks
 }
 
+// tagLegs attributes every record in results to a single retrieval leg.
+func tagLegs(results []ScoredRecord, leg string) {
+	for i := range results {
+		results[i].Legs = []string{leg}
+	}
+}
+
+// legOrder fixes the order of merged legs so output is deterministic.
+var legOrder = []string{LegVector, LegKeyword, LegEntity, LegGraph, LegExpansion}
+
+// mergeLegs returns the union of a and b in legOrder, followed by any unknown
+// legs in first-seen order.
+func mergeLegs(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 {
+		return b
+	}
+	present := make(map[string]bool, len(a)+len(b))
+	for _, l := range a {
+		present[l] = true
+	}
+	for _, l := range b {
+		present[l] = true
+	}
+	merged := make([]string, 0, len(present))
+	for _, l := range legOrder {
+		if present[l] {
+			merged = append(merged, l)
+			delete(present, l)
+		}
+	}
+	for _, l := range slices.Concat(a, b) {
+		if present[l] {
+			merged = append(merged, l)
+			delete(present, l)
+		}
+	}
+	return merged
+}
+
 func recordsToChunks(records []Record) []ContextChunk {
 	chunks := make([]ContextChunk, len(records))
 	for i, r := range records {
@@ -266,6 +548,7 @@ func recordsToChunks(records []Record) []ContextChunk {
 			Text:       r.TextChunk,
 			Tags:       r.Tags,
 			CreatedAt:  r.CreatedAt,
+			Project:    r.Project,
 		}
 	}
 	return chunks
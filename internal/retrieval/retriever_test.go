@@ -3,10 +3,13 @@ package retrieval
 import (
 	"context"
 	"errors"
+	"math"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/kalambet/tbyd/internal/intent"
+	"github.com/kalambet/tbyd/internal/storage"
 )
 
 // mockVectorStore implements VectorStore for testing.
@@ -291,3 +294,187 @@ func TestRetrieveForIntent_EmbedFails(t *testing.T) {
 		t.Errorf("got %d chunks, want 0", len(chunks))
 	}
 }
+
+type fixedRatioSource map[string]float64
+
+func (f fixedRatioSource) Ratio(intentType string) (float64, bool) {
+	r, ok := f[intentType]
+	return r, ok
+}
+
+func TestRetrieveForIntent_LearnedHybridRatio(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, _ string) ([]float32, error) {
+			return makeVector(768), nil
+		},
+	}
+
+	var gotWeight float32
+	store := &mockVectorStore{
+		searchFn: func(_ string, _ []float32, _ int, _ string) ([]ScoredRecord, error) {
+			return nil, nil
+		},
+		searchHybridFn: func(_ string, _ []float32, _ string, _ int, vectorWeight float32, _ string) ([]ScoredRecord, error) {
+			gotWeight = vectorWeight
+			return nil, nil
+		},
+	}
+
+	retriever := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	retriever.SetHybridRatioSource(fixedRatioSource{"recall": 0.4})
+
+	tests := []struct {
+		name   string
+		intent intent.Intent
+		want   float32
+	}{
+		{"learned", intent.Intent{IntentType: "recall", SearchStrategy: "hybrid"}, 0.4},
+		{"unlearned intent", intent.Intent{IntentType: "task", SearchStrategy: "hybrid"}, defaultHybridRatio},
+		{"keyword heavy ignores learned", intent.Intent{IntentType: "recall", SearchStrategy: "keyword_heavy"}, 0.3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotWeight = -1
+			retriever.RetrieveForIntent(context.Background(), "query", tt.intent, 5)
+			if math.Abs(float64(gotWeight-tt.want)) > 1e-6 {
+				t.Errorf("vector weight = %v, want %v", gotWeight, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrieveForIntent_LegAttribution(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, _ string) ([]float32, error) {
+			return makeVector(768), nil
+		},
+	}
+
+	now := time.Now().UTC()
+	store := &mockVectorStore{
+		searchHybridFn: func(_ string, _ []float32, query string, _ int, _ float32, _ string) ([]ScoredRecord, error) {
+			if query == "schema" {
+				// Entity search re-finds src2 and adds src3.
+				return []ScoredRecord{
+					{Record: Record{ID: "r2", SourceID: "src2", TextChunk: "b", CreatedAt: now, Tags: `[]`}, Score: 0.7, Legs: []string{LegKeyword}},
+					{Record: Record{ID: "r3", SourceID: "src3", TextChunk: "c", CreatedAt: now, Tags: `[]`}, Score: 0.6, Legs: []string{LegVector}},
+				}, nil
+			}
+			return []ScoredRecord{
+				{Record: Record{ID: "r1", SourceID: "src1", TextChunk: "a", CreatedAt: now, Tags: `[]`}, Score: 0.9, Legs: []string{LegVector, LegKeyword}},
+				{Record: Record{ID: "r2", SourceID: "src2", TextChunk: "b", CreatedAt: now, Tags: `[]`}, Score: 0.8, Legs: []string{LegVector}},
+			}, nil
+		},
+	}
+
+	retriever := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	chunks := retriever.RetrieveForIntent(context.Background(), "query", intent.Intent{
+		IntentType:     "recall",
+		SearchStrategy: "hybrid",
+		Entities:       []string{"schema"},
+	}, 5)
+
+	got := make(map[string][]string)
+	for _, c := range chunks {
+		got[c.ID] = c.Legs
+	}
+	want := map[string][]string{
+		"r1": {LegVector, LegKeyword},
+		"r2": {LegVector, LegEntity},
+		"r3": {LegEntity},
+	}
+	for id, legs := range want {
+		if !slices.Equal(got[id], legs) {
+			t.Errorf("chunk %s legs = %v, want %v", id, got[id], legs)
+		}
+	}
+}
+
+type fakeGraph struct {
+	related map[string][]string
+	calls   int
+	scope   storage.GraphScope
+}
+
+func (f *fakeGraph) RelatedEntities(_ context.Context, scope storage.GraphScope, names []string, limit int) ([]string, error) {
+	f.calls++
+	f.scope = scope
+	var out []string
+	for _, n := range names {
+		out = append(out, f.related[n]...)
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func TestRetrieveForIntent_GraphExpansion(t *testing.T) {
+	eng := &mockEngine{
+		embedFn: func(_ context.Context, _ string, _ string) ([]float32, error) {
+			return makeVector(768), nil
+		},
+	}
+
+	now := time.Now().UTC()
+	store := &mockVectorStore{
+		searchHybridFn: func(_ string, _ []float32, query string, _ int, _ float32, _ string) ([]ScoredRecord, error) {
+			switch query {
+			case "SQLite":
+				// Neighbour search adds src3, scored as high as the query's best.
+				return []ScoredRecord{
+					{Record: Record{ID: "r3", SourceID: "src3", TextChunk: "c", CreatedAt: now, Tags: `[]`}, Score: 0.9, Legs: []string{LegVector}},
+				}, nil
+			case "tbyd":
+				return []ScoredRecord{
+					{Record: Record{ID: "r2", SourceID: "src2", TextChunk: "b", CreatedAt: now, Tags: `[]`}, Score: 0.7, Legs: []string{LegVector}},
+				}, nil
+			}
+			return []ScoredRecord{
+				{Record: Record{ID: "r1", SourceID: "src1", TextChunk: "a", CreatedAt: now, Tags: `[]`}, Score: 0.9, Legs: []string{LegVector}},
+			}, nil
+		},
+	}
+
+	graph := &fakeGraph{related: map[string][]string{"tbyd": {"SQLite", "Go"}}}
+	retriever := NewRetriever(NewEmbedder(eng, "nomic-embed-text"), store)
+	retriever.SetGraph(graph, 1)
+
+	chunks := retriever.RetrieveForIntent(context.Background(), "query", intent.Intent{
+		IntentType:     "recall",
+		SearchStrategy: "hybrid",
+		Entities:       []string{"tbyd"},
+	}, 5)
+
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	if chunks[0].ID != "r1" || chunks[1].ID != "r3" {
+		t.Errorf("order = %s, %s, want r1 then the down-weighted neighbour result r3", chunks[0].ID, chunks[1].ID)
+	}
+	if !slices.Equal(chunks[1].Legs, []string{LegGraph}) {
+		t.Errorf("neighbour result legs = %v, want [%s]", chunks[1].Legs, LegGraph)
+	}
+	if want := float32(0.9 * graphScoreWeight); math.Abs(float64(chunks[1].Score-want)) > 1e-6 {
+		t.Errorf("neighbour result score = %v, want %v", chunks[1].Score, want)
+	}
+
+	if graph.scope != (storage.GraphScope{}) {
+		t.Errorf("unscoped retrieval searched the graph in %+v, want every partition", graph.scope)
+	}
+
+	// A partitioned search only follows relations stated in its partition.
+	retriever.RetrieveForIntentIn(context.Background(), "query", intent.Intent{
+		SearchStrategy: "hybrid",
+		Entities:       []string{"tbyd"},
+	}, 5, PartitionScope("work"))
+	if graph.scope != (storage.GraphScope{Partitioned: true, Partition: "work"}) {
+		t.Errorf("graph scope = %+v, want the work partition", graph.scope)
+	}
+
+	// No entities: the graph is not consulted.
+	retriever.RetrieveForIntent(context.Background(), "query", intent.Intent{SearchStrategy: "hybrid"}, 5)
+	if graph.calls != 2 {
+		t.Errorf("graph calls = %d, want 2", graph.calls)
+	}
+}
@@ -0,0 +1,124 @@
+package retrieval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope restricts retrieval to one knowledge-base partition and prefers the
+// documents of the active project. The zero Scope searches every partition
+// with no project preference.
+type Scope struct {
+	Partitioned bool   // restrict results to Partition
+	Partition   string // "" is the shared partition
+	Project     string // active project; "" for none
+	ProjectOnly bool   // drop other projects' documents instead of down-ranking them
+}
+
+// PartitionScope returns a Scope limited to the named partition; "" limits
+// retrieval to the shared partition.
+func PartitionScope(partition string) Scope {
+	return Scope{Partitioned: true, Partition: partition}
+}
+
+// WithProject returns s with project active. With only set, documents of
+// other projects are excluded; otherwise they are down-ranked. Documents
+// that belong to no project are always kept.
+func (s Scope) WithProject(project string, only bool) Scope {
+	s.Project = project
+	s.ProjectOnly = only && project != ""
+	return s
+}
+
+// Project ranking factors, multiplied into the similarity score before the
+// top-K cut when a project is active.
+const (
+	ActiveProjectBoost  = 1.25 // documents of the active project
+	OtherProjectPenalty = 0.8  // documents of any other project
+)
+
+// projectWeight returns the ranking factor of a document in project.
+func (s Scope) projectWeight(project string) float32 {
+	if s.Project == "" || project == "" {
+		return 1
+	}
+	if project == s.Project {
+		return ActiveProjectBoost
+	}
+	return OtherProjectPenalty
+}
+
+// weightSQL returns a SQL expression for projectWeight of the project column
+// qualified by col (e.g. "v."), so that rows can be weighted before a LIMIT.
+func (s Scope) weightSQL(col string) (string, []any) {
+	if s.Project == "" {
+		return "1", nil
+	}
+	return fmt.Sprintf("CASE WHEN %[1]sproject = '' THEN 1 WHEN %[1]sproject = ? THEN %[2]g ELSE %[3]g END",
+		col, float32(ActiveProjectBoost), float32(OtherProjectPenalty)), []any{s.Project}
+}
+
+// Scope clauses lead a filter string so that values in later clauses (such
+// as model-extracted topics) cannot forge one.
+const (
+	partitionClause   = "partition:"
+	projectClause     = "project:"
+	projectOnlyClause = "project-only:"
+)
+
+// filter prepends the scope's clauses to rest. Clauses are separated by ";".
+func (s Scope) filter(rest string) string {
+	var clauses []string
+	if s.Partitioned {
+		clauses = append(clauses, partitionClause+s.Partition)
+	}
+	if s.Project != "" {
+		if s.ProjectOnly {
+			clauses = append(clauses, projectOnlyClause+s.Project)
+		} else {
+			clauses = append(clauses, projectClause+s.Project)
+		}
+	}
+	if rest != "" {
+		clauses = append(clauses, rest)
+	}
+	return strings.Join(clauses, ";")
+}
+
+// parseScope reads the leading scope clauses of a filter string built by
+// Scope.filter, stopping at the first clause of any other kind.
+func parseScope(filter string) Scope {
+	var s Scope
+	for _, clause := range strings.Split(filter, ";") {
+		switch {
+		case strings.HasPrefix(clause, partitionClause) && !s.Partitioned && s.Project == "":
+			s.Partitioned = true
+			s.Partition = clause[len(partitionClause):]
+		case strings.HasPrefix(clause, projectClause) && s.Project == "":
+			s.Project = clause[len(projectClause):]
+		case strings.HasPrefix(clause, projectOnlyClause) && s.Project == "":
+			s.Project = clause[len(projectOnlyClause):]
+			s.ProjectOnly = true
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+// where returns the SQL conditions, joined by AND, that restrict
+// context_vectors rows to the scope. col qualifies the column names (e.g.
+// "v."). It returns "" when the scope does not restrict rows.
+func (s Scope) where(col string) (string, []any) {
+	var conds []string
+	var args []any
+	if s.Partitioned {
+		conds = append(conds, col+"kb_partition = ?")
+		args = append(args, s.Partition)
+	}
+	if s.ProjectOnly {
+		conds = append(conds, col+"project IN ('', ?)")
+		args = append(args, s.Project)
+	}
+	return strings.Join(conds, " AND "), args
+}
@@ -57,8 +57,8 @@ func (s *SQLiteStore) Insert(table string, records []Record) error {
 	}
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at, tags)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+		INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at, tags, kb_partition, project)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("preparing insert statement: %w", err)
@@ -71,7 +71,7 @@ func (s *SQLiteStore) Insert(table string, records []Record) error {
 		if createdAt.IsZero() {
 			createdAt = time.Now().UTC()
 		}
-		if _, err := stmt.Exec(r.ID, r.SourceID, r.SourceType, r.TextChunk, blob, createdAt.Format(time.RFC3339), r.Tags); err != nil {
+		if _, err := stmt.Exec(r.ID, r.SourceID, r.SourceType, r.TextChunk, blob, createdAt.Format(time.RFC3339), r.Tags, r.Partition, r.Project); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("inserting record %s: %w", r.ID, err)
 		}
@@ -89,8 +89,8 @@ type idScore struct {
 
 // Search performs brute-force cosine similarity search over all vectors,
 // returning the top-K most similar records.
-// NOTE: the filter parameter is accepted for VectorStore interface compatibility
-// but is not yet implemented in the SQLite backend. A future LanceDB backend
+// NOTE: of the filter clauses, the SQLite backend only applies the leading
+// scope clauses (see Scope); the rest are ignored. A future LanceDB backend
 // will support DataFusion SQL predicates for metadata filtering.
 func (s *SQLiteStore) Search(table string, vector []float32, topK int, filter string) ([]ScoredRecord, error) {
 	if err := validateTable(table); err != nil {
@@ -101,9 +101,16 @@ func (s *SQLiteStore) Search(table string, vector []float32, topK int, filter st
 	}
 
 	// Phase 1: scan id, embedding, and quality_score to find top-K candidates.
-	// quality_score is multiplied into the cosine similarity before heap insertion
-	// so that poor-quality chunks are downranked before the top-K cut.
-	rows, err := s.db.Query(`SELECT id, embedding, quality_score FROM context_vectors`)
+	// quality_score and the project weight are multiplied into the cosine
+	// similarity before heap insertion so that poor-quality and off-project
+	// chunks are downranked before the top-K cut.
+	scope := parseScope(filter)
+	q := `SELECT id, embedding, quality_score, project FROM context_vectors`
+	where, args := scope.where("")
+	if where != "" {
+		q += ` WHERE ` + where
+	}
+	rows, err := s.db.Query(q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("querying vectors: %w", err)
 	}
@@ -121,10 +128,10 @@ func (s *SQLiteStore) Search(table string, vector []float32, topK int, filter st
 	var buf []float32
 
 	for rows.Next() {
-		var id string
+		var id, project string
 		var blob []byte
 		var qualityScore float32
-		if err := rows.Scan(&id, &blob, &qualityScore); err != nil {
+		if err := rows.Scan(&id, &blob, &qualityScore, &project); err != nil {
 			return nil, fmt.Errorf("scanning row: %w", err)
 		}
 
@@ -133,7 +140,7 @@ func (s *SQLiteStore) Search(table string, vector []float32, topK int, filter st
 			return nil, fmt.Errorf("decoding embedding for %s: %w", id, err)
 		}
 
-		score := cosineSimilarity(vector, buf, queryNorm) * qualityScore
+		score := cosineSimilarity(vector, buf, queryNorm) * qualityScore * scope.projectWeight(project)
 		if h.Len() < topK {
 			heap.Push(h, idScore{ID: id, Score: score})
 		} else if score > (*h)[0].Score {
@@ -212,7 +219,7 @@ func (s *SQLiteStore) ExportAll(table string) ([]Record, error) {
 		return nil, err
 	}
 	rows, err := s.db.Query(`
-		SELECT id, source_id, source_type, text_chunk, embedding, created_at, tags, quality_score
+		SELECT id, source_id, source_type, text_chunk, embedding, created_at, tags, quality_score, kb_partition, project
 		FROM context_vectors ORDER BY created_at ASC`)
 	if err != nil {
 		return nil, fmt.Errorf("querying all vectors: %w", err)
@@ -224,7 +231,7 @@ func (s *SQLiteStore) ExportAll(table string) ([]Record, error) {
 		var r Record
 		var blob []byte
 		var createdAt string
-		if err := rows.Scan(&r.ID, &r.SourceID, &r.SourceType, &r.TextChunk, &blob, &createdAt, &r.Tags, &r.QualityScore); err != nil {
+		if err := rows.Scan(&r.ID, &r.SourceID, &r.SourceType, &r.TextChunk, &blob, &createdAt, &r.Tags, &r.QualityScore, &r.Partition, &r.Project); err != nil {
 			return nil, fmt.Errorf("scanning row: %w", err)
 		}
 		embedding, err := decodeFloat32s(blob)
@@ -341,12 +348,25 @@ func (s *SQLiteStore) SearchKeyword(table string, query string, topK int, filter
 
 	// FTS5 rank returns negative BM25 scores (more negative = better match).
 	// We retrieve extra candidates to allow for min-max normalization.
-	rows, err := s.db.Query(`
-		SELECT doc_id, rank
-		FROM context_vectors_fts
-		WHERE text_chunk MATCH ?
-		ORDER BY rank
-		LIMIT ?`, query, topK*2)
+	// Weighting the raw rank, not the normalized score, lets an active
+	// project reorder hits that normalization would pin to 0 or 1; it is
+	// applied in SQL so the project's documents are not cut by the LIMIT.
+	scope := parseScope(filter)
+	weight, args := scope.weightSQL("v.")
+	q := `
+		SELECT f.doc_id, f.rank * ` + weight + ` AS weighted_rank
+		FROM context_vectors_fts f JOIN context_vectors v ON v.id = f.doc_id
+		WHERE f.text_chunk MATCH ?`
+	args = append(args, query)
+	if where, whereArgs := scope.where("v."); where != "" {
+		q += ` AND ` + where
+		args = append(args, whereArgs...)
+	}
+	q += `
+		ORDER BY weighted_rank
+		LIMIT ?`
+	args = append(args, topK*2)
+	rows, err := s.db.Query(q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("FTS5 keyword search: %w", err)
 	}
@@ -354,7 +374,7 @@ func (s *SQLiteStore) SearchKeyword(table string, query string, topK int, filter
 
 	type ftsHit struct {
 		docID string
-		rank  float64 // raw BM25 rank (negative)
+		rank  float64 // raw BM25 rank (negative), scaled by the project weight
 	}
 	var hits []ftsHit
 	for rows.Next() {
@@ -505,6 +525,7 @@ func (s *SQLiteStore) SearchHybrid(table string, vector []float32, query string,
 		if entry, ok := fused[id]; ok {
 			entry.score += rrfScore
 		} else {
+			sr.Legs = []string{LegVector}
 			fused[id] = &fusedEntry{record: sr, score: rrfScore}
 		}
 	}
@@ -514,7 +535,9 @@ func (s *SQLiteStore) SearchHybrid(table string, vector []float32, query string,
 		rrfScore := keywordWeight / float64(rrfK+rank+1)
 		if entry, ok := fused[id]; ok {
 			entry.score += rrfScore
+			entry.record.Legs = append(entry.record.Legs, LegKeyword)
 		} else {
+			sr.Legs = []string{LegKeyword}
 			fused[id] = &fusedEntry{record: sr, score: rrfScore}
 		}
 	}
@@ -544,7 +567,7 @@ func (s *SQLiteStore) fetchRecordsByIDs(ctx context.Context, ids []string) ([]Re
 	for i, id := range ids {
 		queryArgs[i] = id
 	}
-	q := `SELECT id, source_id, source_type, text_chunk, embedding, created_at, tags, quality_score
+	q := `SELECT id, source_id, source_type, text_chunk, embedding, created_at, tags, quality_score, kb_partition, project
 		FROM context_vectors WHERE id IN (?` + strings.Repeat(",?", len(ids)-1) + `)`
 
 	rows, err := s.db.QueryContext(ctx, q, queryArgs...)
@@ -558,7 +581,7 @@ func (s *SQLiteStore) fetchRecordsByIDs(ctx context.Context, ids []string) ([]Re
 		var r Record
 		var blob []byte
 		var createdAt string
-		if err := rows.Scan(&r.ID, &r.SourceID, &r.SourceType, &r.TextChunk, &blob, &createdAt, &r.Tags, &r.QualityScore); err != nil {
+		if err := rows.Scan(&r.ID, &r.SourceID, &r.SourceType, &r.TextChunk, &blob, &createdAt, &r.Tags, &r.QualityScore, &r.Partition, &r.Project); err != nil {
 			return nil, fmt.Errorf("scanning record: %w", err)
 		}
 		embedding, err := decodeFloat32s(blob)
@@ -3,6 +3,7 @@ package retrieval
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -28,7 +29,9 @@ func openTestDB(t *testing.T) *sql.DB {
 			embedding BLOB NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			tags TEXT DEFAULT '[]',
-			quality_score REAL NOT NULL DEFAULT 1.0
+			quality_score REAL NOT NULL DEFAULT 1.0,
+			kb_partition TEXT NOT NULL DEFAULT '',
+			project TEXT NOT NULL DEFAULT ''
 		)`)
 	if err != nil {
 		t.Fatalf("creating table: %v", err)
@@ -289,6 +292,75 @@ func openTestDBWithFTS(t *testing.T) *sql.DB {
 	return db
 }
 
+// TestSearchKeyword_ProjectWeightBeforeLimit verifies that the active
+// project's weight is applied before candidates are cut, so its document is
+// found even when more than topK*2 shared documents match slightly better.
+func TestSearchKeyword_ProjectWeightBeforeLimit(t *testing.T) {
+	db := openTestDBWithFTS(t)
+	s := NewSQLiteStore(db)
+
+	records := []Record{{
+		ID:         "r-alpha",
+		SourceID:   "src-alpha",
+		SourceType: "doc",
+		TextChunk:  "build notes for the release pipeline, build steps and checks",
+		Embedding:  makeTestVector(768, 0.1),
+		CreatedAt:  time.Now().UTC(),
+		Tags:       `[]`,
+		Project:    "alpha",
+	}}
+	for i := range 4 {
+		records = append(records, Record{
+			ID:         fmt.Sprintf("r-shared-%d", i),
+			SourceID:   fmt.Sprintf("src-shared-%d", i),
+			SourceType: "doc",
+			TextChunk:  "build notes for the release pipeline, build",
+			Embedding:  makeTestVector(768, 0.1),
+			CreatedAt:  time.Now().UTC(),
+			Tags:       `[]`,
+		})
+	}
+	// Documents without the term keep its IDF positive.
+	for i := range 10 {
+		records = append(records, Record{
+			ID:         fmt.Sprintf("r-other-%d", i),
+			SourceID:   fmt.Sprintf("src-other-%d", i),
+			SourceType: "doc",
+			TextChunk:  "meeting notes",
+			Embedding:  makeTestVector(768, 0.1),
+			CreatedAt:  time.Now().UTC(),
+			Tags:       `[]`,
+		})
+	}
+	if err := s.Insert("context_vectors", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	ids := func(rs []ScoredRecord) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	unscoped, err := s.SearchKeyword("context_vectors", "build", 1, "")
+	if err != nil {
+		t.Fatalf("SearchKeyword: %v", err)
+	}
+	if len(unscoped) != 1 || unscoped[0].ID == "r-alpha" {
+		t.Fatalf("without a project, top-1 = %v, want a shared document", ids(unscoped))
+	}
+
+	results, err := s.SearchKeyword("context_vectors", "build", 1, Scope{}.WithProject("alpha", false).filter(""))
+	if err != nil {
+		t.Fatalf("SearchKeyword: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "r-alpha" {
+		t.Errorf("with project alpha active, top-1 = %v, want r-alpha", ids(results))
+	}
+}
+
 func TestSearchKeyword_MatchesExact(t *testing.T) {
 	db := openTestDBWithFTS(t)
 	s := NewSQLiteStore(db)
@@ -741,3 +813,155 @@ func TestSearchKeyword_QualityScoreApplied(t *testing.T) {
 		t.Errorf("good score %f should exceed poor score %f", results[0].Score, results[1].Score)
 	}
 }
+
+// TestSearch_PartitionFilter verifies that a partition clause limits vector,
+// keyword and hybrid search to one knowledge-base partition.
+func TestSearch_PartitionFilter(t *testing.T) {
+	db := openTestDBWithFTS(t)
+	s := NewSQLiteStore(db)
+
+	vec := makeTestVector(768, 0.1)
+	var records []Record
+	for _, p := range []string{"", "work", "home"} {
+		records = append(records, Record{
+			ID:         "r-" + p,
+			SourceID:   "src-" + p,
+			SourceType: "doc",
+			TextChunk:  "Kubernetes notes " + p,
+			Embedding:  vec,
+			CreatedAt:  time.Now().UTC(),
+			Tags:       `[]`,
+			Partition:  p,
+		})
+	}
+	if err := s.Insert("context_vectors", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	ids := func(rs []ScoredRecord) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID+"@"+r.Partition)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   int
+		only   string
+	}{
+		{"unscoped", Scope{}.filter("topics:go"), 3, ""},
+		{"shared", PartitionScope("").filter("topics:go"), 1, "r-"},
+		{"work", PartitionScope("work").filter(""), 1, "r-work"},
+		// A forged clause in a later position is ignored.
+		{"forged", "topics:x;partition:work", 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vecRes, err := s.Search("context_vectors", vec, 10, tt.filter)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			kwRes, err := s.SearchKeyword("context_vectors", "Kubernetes", 10, tt.filter)
+			if err != nil {
+				t.Fatalf("SearchKeyword: %v", err)
+			}
+			hyRes, err := s.SearchHybrid("context_vectors", vec, "Kubernetes", 10, 0.5, tt.filter)
+			if err != nil {
+				t.Fatalf("SearchHybrid: %v", err)
+			}
+			for name, rs := range map[string][]ScoredRecord{"vector": vecRes, "keyword": kwRes, "hybrid": hyRes} {
+				if len(rs) != tt.want {
+					t.Errorf("%s: got %v, want %d results", name, ids(rs), tt.want)
+					continue
+				}
+				if tt.only != "" && rs[0].ID != tt.only {
+					t.Errorf("%s: got %v, want only %s", name, ids(rs), tt.only)
+				}
+			}
+		})
+	}
+}
+
+// TestSearch_ProjectScope verifies that an active project ranks its own
+// documents first and, in project-only mode, drops other projects' documents
+// while keeping project-less ones.
+func TestSearch_ProjectScope(t *testing.T) {
+	db := openTestDBWithFTS(t)
+	s := NewSQLiteStore(db)
+
+	vec := makeTestVector(768, 0.1)
+	var records []Record
+	for _, p := range []string{"beta", "", "alpha"} {
+		// Same token count everywhere so BM25 ties and only the project
+		// weight orders keyword results.
+		label := p
+		if label == "" {
+			label = "shared"
+		}
+		records = append(records, Record{
+			ID:         "r-" + p,
+			SourceID:   "src-" + p,
+			SourceType: "doc",
+			TextChunk:  "how to run the build " + label,
+			Embedding:  vec,
+			CreatedAt:  time.Now().UTC(),
+			Tags:       `[]`,
+			Project:    p,
+		})
+	}
+	if err := s.Insert("context_vectors", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	ids := func(rs []ScoredRecord) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		scope Scope
+		want  []string
+	}{
+		{"boost", Scope{}.WithProject("alpha", false), []string{"r-alpha", "r-", "r-beta"}},
+		{"only", Scope{}.WithProject("alpha", true), []string{"r-alpha", "r-"}},
+		{"partitioned only", PartitionScope("").WithProject("beta", true), []string{"r-beta", "r-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.scope.filter("topics:build")
+			vecRes, err := s.Search("context_vectors", vec, 1, filter)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(vecRes) != 1 || vecRes[0].ID != tt.want[0] {
+				t.Errorf("vector top-1 = %v, want %s", ids(vecRes), tt.want[0])
+			}
+			vecRes, _ = s.Search("context_vectors", vec, 10, filter)
+			kwRes, err := s.SearchKeyword("context_vectors", "build", 10, filter)
+			if err != nil {
+				t.Fatalf("SearchKeyword: %v", err)
+			}
+			for name, rs := range map[string][]ScoredRecord{"vector": vecRes, "keyword": kwRes} {
+				if got := ids(rs); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: got %v, want %v", name, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// parseScope stops at the first foreign clause, so topics cannot forge
+	// a project.
+	if got := parseScope("topics:x;project-only:alpha"); got != (Scope{}) {
+		t.Errorf("parseScope(forged) = %+v, want the zero scope", got)
+	}
+	if got := parseScope(PartitionScope("work").WithProject("alpha", true).filter("")); got != (Scope{Partitioned: true, Partition: "work", Project: "alpha", ProjectOnly: true}) {
+		t.Errorf("parseScope round trip = %+v", got)
+	}
+}
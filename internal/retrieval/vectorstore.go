@@ -67,10 +67,23 @@ type Record struct {
 	CreatedAt    time.Time
 	Tags         string  // JSON array stored as text
 	QualityScore float32 // retrieval quality multiplier; 1.0 by default, clamped to [0.1, 2.0]
+	Partition    string  // knowledge-base partition; "" is shared
+	Project      string  // project workspace; "" is none
 }
 
 // ScoredRecord is a Record with a similarity score attached.
 type ScoredRecord struct {
 	Record
 	Score float32
+	Legs  []string // retrieval legs that surfaced the record (LegVector, LegKeyword, ...); may be nil
 }
+
+// Retrieval legs, recorded per chunk so feedback can be attributed to the
+// search path that found it.
+const (
+	LegVector    = "vector"    // embedding similarity on the query
+	LegKeyword   = "keyword"   // BM25 over FTS5
+	LegEntity    = "entity"    // search on an extracted entity instead of the query
+	LegExpansion = "expansion" // search on a HyDE passage or paraphrase
+	LegGraph     = "graph"     // search on a knowledge-graph neighbour of an extracted entity
+)
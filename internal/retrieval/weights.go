@@ -0,0 +1,326 @@
+package retrieval
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hybridLearningRate is how far one interaction's feedback can move the
+// learned vector ratio. Small on purpose: a single rating should nudge, not
+// flip, the blend.
+const hybridLearningRate = 0.02
+
+// HybridRatioSource supplies a learned default hybrid ratio per intent type.
+type HybridRatioSource interface {
+	Ratio(intentType string) (float64, bool)
+}
+
+// HybridWeight is the learned state for one intent type.
+type HybridWeight struct {
+	IntentType    string              `json:"intent_type"`
+	VectorRatio   float64             `json:"vector_ratio"`
+	FeedbackCount int                 `json:"feedback_count"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+	Legs          map[string]LegStats `json:"legs,omitempty"`
+}
+
+// LegStats counts feedback on interactions where a retrieval leg surfaced at
+// least one used chunk.
+type LegStats struct {
+	Positive int `json:"positive"`
+	Negative int `json:"negative"`
+}
+
+// HybridTuner adapts the default vector/keyword blend per intent type from
+// interaction feedback. Positive feedback on chunks found only by vector
+// search moves the ratio toward vector; chunks found only by keyword search
+// move it toward keyword. Negative feedback moves it the other way. The ratio
+// is clamped to [min, max]. Entity and expansion legs are tallied but do not
+// shift the ratio, since they are not part of the vector/keyword blend.
+//
+// Only hybrid searches use the learned ratio, so callers should record
+// feedback only for interactions retrieved with the hybrid strategy.
+//
+// Learned ratios are cached in memory; the cache is loaded lazily and updated
+// on every write, so HybridTuner must be the only writer of its tables.
+type HybridTuner struct {
+	db       *sql.DB
+	min, max float64
+
+	mu     sync.RWMutex
+	ratios map[string]float64
+	loaded bool
+}
+
+// NewHybridTuner creates a HybridTuner over db with ratio bounds [min, max].
+func NewHybridTuner(db *sql.DB, min, max float64) *HybridTuner {
+	if min > max {
+		min, max = max, min
+	}
+	return &HybridTuner{db: db, min: clamp01(min), max: clamp01(max)}
+}
+
+// Ratio returns the learned vector ratio for intentType, or false if nothing
+// has been learned for it yet. Load errors are treated as "nothing learned".
+func (t *HybridTuner) Ratio(intentType string) (float64, bool) {
+	if intentType == "" {
+		return 0, false
+	}
+	if err := t.ensureLoaded(); err != nil {
+		return 0, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	r, ok := t.ratios[intentType]
+	if !ok {
+		return 0, false
+	}
+	return t.clamp(r), true
+}
+
+// RecordFeedback applies a change in one interaction's rating from previous
+// to score, each -1, 1, or 0 for unrated. Only the difference is applied, so
+// re-rating an interaction replaces its earlier effect rather than adding to
+// it, and repeating a rating is a no-op. legs maps each used vector ID to the
+// retrieval legs that surfaced it. Interactions without an intent type or
+// without leg information are ignored.
+func (t *HybridTuner) RecordFeedback(ctx context.Context, intentType string, legs map[string][]string, previous, score int) error {
+	if intentType == "" || len(legs) == 0 || previous == score {
+		return nil
+	}
+
+	// Average the vector-vs-keyword signal over chunks, and note which legs
+	// contributed at all.
+	var signal float64
+	var counted int
+	surfaced := make(map[string]bool)
+	for _, chunkLegs := range legs {
+		var vec, kw bool
+		for _, l := range chunkLegs {
+			surfaced[l] = true
+			switch l {
+			case LegVector:
+				vec = true
+			case LegKeyword:
+				kw = true
+			}
+		}
+		switch {
+		case vec && !kw:
+			signal++
+		case kw && !vec:
+			signal--
+		}
+		counted++
+	}
+	if counted > 0 {
+		signal /= float64(counted)
+	}
+	delta := hybridLearningRate * signal * float64(score-previous)
+
+	// A first rating counts toward feedback_count; a re-rating does not.
+	first := 0
+	if previous == 0 {
+		first = 1
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning hybrid weight update: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The ratio is updated in SQL so concurrent feedback cannot lose updates
+	// without holding t.mu across the transaction.
+	var next float64
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO hybrid_weights (intent_type, vector_ratio, feedback_count, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(intent_type) DO UPDATE SET
+			vector_ratio = MIN(MAX(vector_ratio + ?, ?), ?),
+			feedback_count = feedback_count + excluded.feedback_count,
+			updated_at = excluded.updated_at
+		RETURNING vector_ratio`,
+		intentType, t.clamp(defaultHybridRatio+delta), first, now,
+		delta, t.min, t.max,
+	).Scan(&next); err != nil {
+		return fmt.Errorf("updating hybrid weight: %w", err)
+	}
+
+	pos := boolInt(score == 1) - boolInt(previous == 1)
+	neg := boolInt(score == -1) - boolInt(previous == -1)
+	for leg := range surfaced {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO retrieval_leg_stats (intent_type, leg, positive_count, negative_count)
+			VALUES (?, ?, MAX(?, 0), MAX(?, 0))
+			ON CONFLICT(intent_type, leg) DO UPDATE SET
+				positive_count = MAX(positive_count + ?, 0),
+				negative_count = MAX(negative_count + ?, 0)`,
+			intentType, leg, pos, neg, pos, neg,
+		); err != nil {
+			return fmt.Errorf("updating retrieval leg stats: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing hybrid weight update: %w", err)
+	}
+
+	t.mu.Lock()
+	if t.loaded {
+		t.ratios[intentType] = next
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// Reset forgets what was learned for intentType, or for every intent type
+// when intentType is empty, so retrieval falls back to the built-in defaults.
+func (t *HybridTuner) Reset(ctx context.Context, intentType string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning hybrid weight reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := "", []any{}
+	if intentType != "" {
+		where, args = " WHERE intent_type = ?", []any{intentType}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM hybrid_weights`+where, args...); err != nil {
+		return fmt.Errorf("resetting hybrid weights: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM retrieval_leg_stats`+where, args...); err != nil {
+		return fmt.Errorf("resetting retrieval leg stats: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing hybrid weight reset: %w", err)
+	}
+
+	if intentType == "" {
+		t.ratios = make(map[string]float64)
+		t.loaded = true
+	} else if t.loaded {
+		delete(t.ratios, intentType)
+	}
+	return nil
+}
+
+// List returns the learned state for every intent type, sorted by name.
+func (t *HybridTuner) List(ctx context.Context) ([]HybridWeight, error) {
+	rows, err := t.db.QueryContext(ctx, `
+		SELECT intent_type, vector_ratio, feedback_count, updated_at
+		FROM hybrid_weights ORDER BY intent_type`)
+	if err != nil {
+		return nil, fmt.Errorf("listing hybrid weights: %w", err)
+	}
+	defer rows.Close()
+
+	var weights []HybridWeight
+	index := make(map[string]int)
+	for rows.Next() {
+		var w HybridWeight
+		var updatedAt string
+		if err := rows.Scan(&w.IntentType, &w.VectorRatio, &w.FeedbackCount, &updatedAt); err != nil {
+			return nil, err
+		}
+		if ts, err := time.Parse(time.RFC3339, updatedAt); err == nil {
+			w.UpdatedAt = ts
+		}
+		w.VectorRatio = t.clamp(w.VectorRatio)
+		index[w.IntentType] = len(weights)
+		weights = append(weights, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	legRows, err := t.db.QueryContext(ctx, `
+		SELECT intent_type, leg, positive_count, negative_count FROM retrieval_leg_stats`)
+	if err != nil {
+		return nil, fmt.Errorf("listing retrieval leg stats: %w", err)
+	}
+	defer legRows.Close()
+	for legRows.Next() {
+		var intentType, leg string
+		var s LegStats
+		if err := legRows.Scan(&intentType, &leg, &s.Positive, &s.Negative); err != nil {
+			return nil, err
+		}
+		i, ok := index[intentType]
+		if !ok {
+			continue
+		}
+		if weights[i].Legs == nil {
+			weights[i].Legs = make(map[string]LegStats)
+		}
+		weights[i].Legs[leg] = s
+	}
+	if err := legRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(weights, func(i, j int) bool { return weights[i].IntentType < weights[j].IntentType })
+	return weights, nil
+}
+
+func (t *HybridTuner) ensureLoaded() error {
+	t.mu.RLock()
+	loaded := t.loaded
+	t.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loaded {
+		return nil
+	}
+
+	rows, err := t.db.Query(`SELECT intent_type, vector_ratio FROM hybrid_weights`)
+	if err != nil {
+		return fmt.Errorf("loading hybrid weights: %w", err)
+	}
+	defer rows.Close()
+
+	ratios := make(map[string]float64)
+	for rows.Next() {
+		var intentType string
+		var ratio float64
+		if err := rows.Scan(&intentType, &ratio); err != nil {
+			return fmt.Errorf("loading hybrid weights: %w", err)
+		}
+		ratios[intentType] = ratio
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loading hybrid weights: %w", err)
+	}
+	t.ratios = ratios
+	t.loaded = true
+	return nil
+}
+
+func (t *HybridTuner) clamp(r float64) float64 {
+	return min(max(r, t.min), t.max)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	return min(max(v, 0), 1)
+}
@@ -0,0 +1,212 @@
+package retrieval
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+)
+
+func openWeightsDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("opening test db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+		CREATE TABLE hybrid_weights (
+			intent_type TEXT PRIMARY KEY,
+			vector_ratio REAL NOT NULL,
+			feedback_count INTEGER NOT NULL DEFAULT 0,
+			updated_at TEXT NOT NULL
+		);
+		CREATE TABLE retrieval_leg_stats (
+			intent_type TEXT NOT NULL,
+			leg TEXT NOT NULL,
+			positive_count INTEGER NOT NULL DEFAULT 0,
+			negative_count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (intent_type, leg)
+		)`)
+	if err != nil {
+		t.Fatalf("creating tables: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestHybridTuner_NothingLearned(t *testing.T) {
+	tuner := NewHybridTuner(openWeightsDB(t), 0.3, 0.9)
+	if _, ok := tuner.Ratio("recall"); ok {
+		t.Error("Ratio reported a learned value before any feedback")
+	}
+}
+
+func TestHybridTuner_FeedbackMovesRatio(t *testing.T) {
+	ctx := context.Background()
+	tuner := NewHybridTuner(openWeightsDB(t), 0.3, 0.9)
+
+	vectorOnly := map[string][]string{"c1": {LegVector}, "c2": {LegVector}}
+	keywordOnly := map[string][]string{"c1": {LegKeyword}}
+
+	if err := tuner.RecordFeedback(ctx, "recall", vectorOnly, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	r, ok := tuner.Ratio("recall")
+	if !ok || !approxEqual(r, defaultHybridRatio+hybridLearningRate) {
+		t.Errorf("after positive vector feedback ratio = %v (ok=%v), want %v", r, ok, defaultHybridRatio+hybridLearningRate)
+	}
+
+	if err := tuner.RecordFeedback(ctx, "task", keywordOnly, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if r, _ := tuner.Ratio("task"); !approxEqual(r, defaultHybridRatio-hybridLearningRate) {
+		t.Errorf("after positive keyword feedback ratio = %v, want %v", r, defaultHybridRatio-hybridLearningRate)
+	}
+
+	// Negative feedback on vector-only chunks moves toward keyword.
+	if err := tuner.RecordFeedback(ctx, "question", vectorOnly, 0, -1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if r, _ := tuner.Ratio("question"); !approxEqual(r, defaultHybridRatio-hybridLearningRate) {
+		t.Errorf("after negative vector feedback ratio = %v, want %v", r, defaultHybridRatio-hybridLearningRate)
+	}
+
+	// Chunks surfaced by both legs carry no signal.
+	if err := tuner.RecordFeedback(ctx, "chat", map[string][]string{"c1": {LegVector, LegKeyword}}, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if r, _ := tuner.Ratio("chat"); !approxEqual(r, defaultHybridRatio) {
+		t.Errorf("after mixed-leg feedback ratio = %v, want %v", r, defaultHybridRatio)
+	}
+}
+
+func TestHybridTuner_ReratingAppliesOnlyTheDifference(t *testing.T) {
+	ctx := context.Background()
+	tuner := NewHybridTuner(openWeightsDB(t), 0.3, 0.9)
+	legs := map[string][]string{"c1": {LegVector}}
+
+	// Repeating the same rating must not drift the ratio.
+	for i := 0; i < 5; i++ {
+		previous := 1
+		if i == 0 {
+			previous = 0
+		}
+		if err := tuner.RecordFeedback(ctx, "recall", legs, previous, 1); err != nil {
+			t.Fatalf("RecordFeedback: %v", err)
+		}
+	}
+	if r, _ := tuner.Ratio("recall"); !approxEqual(r, defaultHybridRatio+hybridLearningRate) {
+		t.Errorf("after repeated +1 ratio = %v, want %v", r, defaultHybridRatio+hybridLearningRate)
+	}
+
+	// Flipping to -1 replaces the earlier +1 rather than cancelling it out.
+	if err := tuner.RecordFeedback(ctx, "recall", legs, 1, -1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if r, _ := tuner.Ratio("recall"); !approxEqual(r, defaultHybridRatio-hybridLearningRate) {
+		t.Errorf("after re-rating to -1 ratio = %v, want %v", r, defaultHybridRatio-hybridLearningRate)
+	}
+
+	weights, err := tuner.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if weights[0].FeedbackCount != 1 {
+		t.Errorf("feedback_count = %d, want 1 for a single rated interaction", weights[0].FeedbackCount)
+	}
+	if got := weights[0].Legs[LegVector]; got != (LegStats{Negative: 1}) {
+		t.Errorf("vector leg = %+v, want 0 positive / 1 negative", got)
+	}
+}
+
+func TestHybridTuner_Bounds(t *testing.T) {
+	ctx := context.Background()
+	tuner := NewHybridTuner(openWeightsDB(t), 0.5, 0.75)
+
+	for i := 0; i < 50; i++ {
+		if err := tuner.RecordFeedback(ctx, "recall", map[string][]string{"c1": {LegVector}}, 0, 1); err != nil {
+			t.Fatalf("RecordFeedback: %v", err)
+		}
+		if err := tuner.RecordFeedback(ctx, "task", map[string][]string{"c1": {LegKeyword}}, 0, 1); err != nil {
+			t.Fatalf("RecordFeedback: %v", err)
+		}
+	}
+	if r, _ := tuner.Ratio("recall"); r != 0.75 {
+		t.Errorf("recall ratio = %v, want upper bound 0.75", r)
+	}
+	if r, _ := tuner.Ratio("task"); r != 0.5 {
+		t.Errorf("task ratio = %v, want lower bound 0.5", r)
+	}
+}
+
+func TestHybridTuner_PersistsAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	db := openWeightsDB(t)
+
+	if err := NewHybridTuner(db, 0.3, 0.9).RecordFeedback(ctx, "recall", map[string][]string{"c1": {LegVector}}, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	r, ok := NewHybridTuner(db, 0.3, 0.9).Ratio("recall")
+	if !ok || !approxEqual(r, defaultHybridRatio+hybridLearningRate) {
+		t.Errorf("reloaded ratio = %v (ok=%v), want %v", r, ok, defaultHybridRatio+hybridLearningRate)
+	}
+}
+
+func TestHybridTuner_ListAndReset(t *testing.T) {
+	ctx := context.Background()
+	tuner := NewHybridTuner(openWeightsDB(t), 0.3, 0.9)
+
+	legs := map[string][]string{"c1": {LegVector, LegEntity}, "c2": {LegKeyword}}
+	if err := tuner.RecordFeedback(ctx, "recall", legs, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if err := tuner.RecordFeedback(ctx, "recall", legs, 0, -1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if err := tuner.RecordFeedback(ctx, "task", legs, 0, 1); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+
+	weights, err := tuner.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(weights) != 2 || weights[0].IntentType != "recall" || weights[1].IntentType != "task" {
+		t.Fatalf("List = %+v, want recall and task", weights)
+	}
+	if weights[0].FeedbackCount != 2 {
+		t.Errorf("recall feedback_count = %d, want 2", weights[0].FeedbackCount)
+	}
+	if got := weights[0].Legs[LegEntity]; got != (LegStats{Positive: 1, Negative: 1}) {
+		t.Errorf("recall entity leg = %+v, want 1/1", got)
+	}
+	if _, ok := weights[0].Legs[LegExpansion]; ok {
+		t.Error("expansion leg recorded although it surfaced nothing")
+	}
+
+	if err := tuner.Reset(ctx, "recall"); err != nil {
+		t.Fatalf("Reset(recall): %v", err)
+	}
+	if _, ok := tuner.Ratio("recall"); ok {
+		t.Error("recall still has a learned ratio after reset")
+	}
+	if _, ok := tuner.Ratio("task"); !ok {
+		t.Error("task lost its learned ratio after resetting recall")
+	}
+
+	if err := tuner.Reset(ctx, ""); err != nil {
+		t.Fatalf("Reset(all): %v", err)
+	}
+	weights, err = tuner.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(weights) != 0 {
+		t.Errorf("List after full reset = %+v, want empty", weights)
+	}
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = nil error, want error", expr)
+		}
+	}
+}
+
+func TestSpec_Next(t *testing.T) {
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"0 3 * * *", "2026-05-01 02:59", "2026-05-01 03:00"},
+		{"0 3 * * *", "2026-05-01 03:00", "2026-05-02 03:00"},
+		{"*/15 * * * *", "2026-05-01 10:07", "2026-05-01 10:15"},
+		{"*/15 * * * *", "2026-05-01 10:45", "2026-05-01 11:00"},
+		{"0,30 9-17 * * 1-5", "2026-05-01 17:30", "2026-05-04 09:00"}, // Friday evening to Monday
+		{"@hourly", "2026-05-01 10:07", "2026-05-01 11:00"},
+		{"@monthly", "2026-05-15 00:00", "2026-06-01 00:00"},
+		{"0 0 * * 7", "2026-05-01 00:00", "2026-05-03 00:00"}, // 7 is Sunday
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		// Both day fields restricted: either matches (the 13th or any Friday).
+		{"0 0 13 * 5", "2026-05-02 00:00", "2026-05-08 00:00"},
+	}
+	for _, tt := range tests {
+		spec, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := spec.Next(utc(tt.from)); !got.Equal(utc(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04 Mon"), tt.want)
+		}
+	}
+}
+
+func TestSpec_NextInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	spec, err := Parse("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := spec.Next(time.Date(2026, 6, 1, 12, 0, 0, 0, berlin))
+	if want := time.Date(2026, 6, 2, 2, 30, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+
+	// 02:30 does not exist on the spring-forward day; the run still happens
+	// that night rather than being skipped to the next day.
+	got = spec.Next(time.Date(2026, 3, 29, 0, 0, 0, 0, berlin))
+	if got.Day() != 29 || got.Month() != time.March {
+		t.Errorf("Next across DST gap = %s, want a run on March 29", got)
+	}
+}
+
+func TestSpec_NextImpossible(t *testing.T) {
+	spec, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %s, want zero for a date that never occurs", got)
+	}
+}
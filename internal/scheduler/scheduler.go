@@ -0,0 +1,355 @@
+// Package scheduler runs periodic background work on cron schedules.
+//
+// Workers that consume a job type from the job queue declare it periodic with
+// Register, usually from an init function; the Scheduler then enqueues a job
+// of that type on its schedule. Work that is not queue-driven is added with
+// AddFunc. Every schedule can be overridden from configuration, runs in a
+// configurable timezone with optional jitter, and persists its last run so a
+// run missed while the server was down or the machine slept is caught up
+// once on the next check.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// Store abstracts the storage operations Scheduler needs.
+type Store interface {
+	EnqueueJob(ctx context.Context, job storage.Job) error
+	GetScheduleRuns() (map[string]time.Time, error)
+	SetScheduleRun(name string, at time.Time) error
+}
+
+var (
+	// ErrUnknown is returned by Trigger for a name nothing is scheduled under.
+	ErrUnknown = errors.New("no such schedule")
+
+	// ErrBusy is returned by Trigger while the previous run is in progress.
+	ErrBusy = errors.New("previous run still in progress")
+
+	// ErrNotRunning is returned by Trigger before Run is called.
+	ErrNotRunning = errors.New("scheduler is not running")
+)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]string{}
+)
+
+// Register declares jobType periodic, enqueued by default on the cron
+// schedule spec. It panics if spec does not parse or jobType is registered
+// twice, as both are programming errors.
+func Register(jobType, spec string) {
+	if _, err := Parse(spec); err != nil {
+		panic(fmt.Sprintf("scheduler: Register %s: %v", jobType, err))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[jobType]; dup {
+		panic("scheduler: Register called twice for " + jobType)
+	}
+	registry[jobType] = spec
+}
+
+// Options configures a Scheduler.
+type Options struct {
+	// Location is the timezone schedules are evaluated in; nil means
+	// time.Local.
+	Location *time.Location
+
+	// Jitter is the maximum random delay added to each run, spreading load
+	// when several jobs share a schedule.
+	Jitter time.Duration
+
+	// Overrides replaces the default schedule of the named jobs.
+	Overrides map[string]string
+}
+
+// ParseOverrides parses schedule overrides written as semicolon-separated
+// name=expression rules, e.g. "nightly_synthesis=0 3 * * *; delta_policy=@hourly".
+func ParseOverrides(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, rule := range strings.Split(s, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, expr, ok := strings.Cut(rule, "=")
+		name, expr = strings.TrimSpace(name), strings.TrimSpace(expr)
+		if !ok || name == "" || expr == "" {
+			return nil, fmt.Errorf("invalid schedule rule %q: want name=cron expression", rule)
+		}
+		if _, err := Parse(expr); err != nil {
+			return nil, err
+		}
+		out[name] = expr
+	}
+	return out, nil
+}
+
+type entry struct {
+	name    string
+	spec    Spec
+	run     func(ctx context.Context) error
+	last    time.Time
+	due     time.Time
+	running atomic.Bool
+}
+
+// Scheduler triggers registered job types and added functions on their
+// schedules.
+type Scheduler struct {
+	store     Store
+	loc       *time.Location
+	jitter    time.Duration
+	overrides map[string]string
+	poll      time.Duration
+	now       func() time.Time
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	entries []*entry
+	runCtx  context.Context // set by Run; nil before
+	wg      sync.WaitGroup
+}
+
+// New creates a Scheduler with an entry for every registered job type.
+func New(store Store, opts Options) *Scheduler {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &Scheduler{
+		store:     store,
+		loc:       loc,
+		jitter:    opts.Jitter,
+		overrides: opts.Overrides,
+		poll:      time.Minute,
+		now:       time.Now,
+		logger:    slog.Default(),
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for jobType, spec := range registry {
+		jobType := jobType
+		// Registered specs are valid and names unique, so add cannot fail.
+		_ = s.add(jobType, spec, func(ctx context.Context) error {
+			return store.EnqueueJob(ctx, storage.Job{
+				ID:          uuid.New().String(),
+				Type:        jobType,
+				PayloadJSON: "{}",
+			})
+		})
+	}
+	return s
+}
+
+// AddFunc schedules run under name on defaultSpec, unless overridden. Runs
+// of the same entry never overlap; a run still in progress when the next is
+// due skips that run. Returns an error if defaultSpec does not parse or name
+// is already scheduled.
+func (s *Scheduler) AddFunc(name, defaultSpec string, run func(ctx context.Context) error) error {
+	return s.add(name, defaultSpec, run)
+}
+
+// add schedules run under name. An override that does not parse is logged
+// and the default spec used instead.
+func (s *Scheduler) add(name, spec string, run func(ctx context.Context) error) error {
+	parsed, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("schedule for %s: %w", name, err)
+	}
+	if o, ok := s.overrides[name]; ok {
+		if op, err := Parse(o); err != nil {
+			s.logger.Warn("scheduler: invalid schedule override ignored", "name", name, "error", err)
+		} else {
+			parsed = op
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.name == name {
+			return fmt.Errorf("schedule for %s: already added", name)
+		}
+	}
+	s.entries = append(s.entries, &entry{name: name, spec: parsed, run: run})
+	return nil
+}
+
+// Run loads the persisted last runs and triggers entries as they fall due,
+// until ctx is cancelled. Entries never run before are recorded as
+// scheduled now and first run at their next occurrence.
+//
+// Wall-clock time is checked every minute rather than waiting on a timer for
+// the next occurrence, since timers do not advance while the machine sleeps.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.runCtx = ctx
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			// No Trigger may start a run once the wait begins.
+			s.mu.Lock()
+			s.runCtx = nil
+			s.mu.Unlock()
+			s.wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// load initialises each entry's last run from storage.
+func (s *Scheduler) load() error {
+	runs, err := s.store.GetScheduleRuns()
+	if err != nil {
+		return fmt.Errorf("loading schedule runs: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	known := make(map[string]bool, len(s.entries))
+	now := s.now()
+	for _, e := range s.entries {
+		known[e.name] = true
+		if last, ok := runs[e.name]; ok {
+			e.last = last
+			continue
+		}
+		e.last = now
+		if err := s.store.SetScheduleRun(e.name, now); err != nil {
+			return fmt.Errorf("recording schedule for %s: %w", e.name, err)
+		}
+	}
+	for name := range s.overrides {
+		if !known[name] {
+			s.logger.Warn("scheduler: schedule override for unknown job ignored", "name", name)
+		}
+	}
+
+	names := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		names = append(names, e.name+"="+e.spec.String())
+	}
+	sort.Strings(names)
+	s.logger.Info("scheduler: started", "timezone", s.loc.String(), "schedules", strings.Join(names, "; "))
+	return nil
+}
+
+// tick starts every entry that is due. However many occurrences were missed
+// since an entry's last run, it runs once.
+func (s *Scheduler) tick(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, e := range s.entries {
+		if e.due.IsZero() {
+			e.due = s.nextDue(e, e.last)
+			if e.due.IsZero() {
+				continue
+			}
+		}
+		if now.Before(e.due) {
+			continue
+		}
+
+		e.last = now
+		e.due = s.nextDue(e, now)
+		if err := s.store.SetScheduleRun(e.name, now); err != nil {
+			s.logger.Error("scheduler: failed to record run", "name", e.name, "error", err)
+		}
+
+		if !s.start(ctx, e) {
+			s.logger.Warn("scheduler: previous run still in progress, skipping", "name", e.name)
+		}
+	}
+}
+
+// start runs e in the background unless it is already running, and reports
+// whether it did.
+func (s *Scheduler) start(ctx context.Context, e *entry) bool {
+	if !e.running.CompareAndSwap(false, true) {
+		return false
+	}
+	s.logger.Info("scheduler: run triggered", "name", e.name)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer e.running.Store(false)
+		started := time.Now()
+		if err := e.run(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("scheduler: run failed", "name", e.name, "duration", time.Since(started), "error", err)
+			return
+		}
+		s.logger.Info("scheduler: run finished", "name", e.name, "duration", time.Since(started))
+	}()
+	return true
+}
+
+// Trigger runs the entry scheduled under name now, outside its schedule; the
+// next scheduled run is unaffected. The run continues in the background
+// until it finishes or Run's context is cancelled.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runCtx == nil {
+		return ErrNotRunning
+	}
+	for _, e := range s.entries {
+		if e.name != name {
+			continue
+		}
+		if !s.start(s.runCtx, e) {
+			return ErrBusy
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknown, name)
+}
+
+// Names returns the names of all scheduled entries, sorted.
+func (s *Scheduler) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		names = append(names, e.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nextDue returns the first occurrence of e's schedule after t, plus jitter.
+func (s *Scheduler) nextDue(e *entry, t time.Time) time.Time {
+	next := e.spec.Next(t.In(s.loc))
+	if next.IsZero() {
+		return next
+	}
+	if s.jitter > 0 {
+		next = next.Add(rand.N(s.jitter))
+	}
+	return next
+}
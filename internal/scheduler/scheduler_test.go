@@ -0,0 +1,265 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type mockStore struct {
+	mu       sync.Mutex
+	runs     map[string]time.Time
+	enqueued []storage.Job
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{runs: make(map[string]time.Time)}
+}
+
+func (m *mockStore) EnqueueJob(_ context.Context, job storage.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued = append(m.enqueued, job)
+	return nil
+}
+
+func (m *mockStore) GetScheduleRuns() (map[string]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]time.Time, len(m.runs))
+	for k, v := range m.runs {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *mockStore) SetScheduleRun(name string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[name] = at
+	return nil
+}
+
+// newTestScheduler returns a Scheduler with no registered job types and a
+// clock the test controls.
+func newTestScheduler(store Store, opts Options, now *time.Time) *Scheduler {
+	s := New(store, opts)
+	s.entries = nil
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestScheduler_CatchesUpMissedRunsOnce(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.runs["job"] = now.Add(-72 * time.Hour) // three nightly runs missed
+
+	s := newTestScheduler(store, Options{Location: time.UTC}, &now)
+	var calls atomic.Int32
+	if err := s.AddFunc("job", "0 3 * * *", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.tick(context.Background())
+	s.tick(context.Background())
+	s.wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("runs after catch-up = %d, want 1", got)
+	}
+	if !store.runs["job"].Equal(now) {
+		t.Errorf("recorded last run = %s, want %s", store.runs["job"], now)
+	}
+
+	// Next occurrence is tomorrow at 03:00.
+	now = time.Date(2026, 5, 2, 2, 59, 0, 0, time.UTC)
+	s.tick(context.Background())
+	now = time.Date(2026, 5, 2, 3, 0, 0, 0, time.UTC)
+	s.tick(context.Background())
+	s.wg.Wait()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("runs after next occurrence = %d, want 2", got)
+	}
+}
+
+func TestScheduler_RecordsBaselineForNewEntries(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	s := newTestScheduler(store, Options{Location: time.UTC}, &now)
+	var calls atomic.Int32
+	_ = s.AddFunc("job", "0 3 * * *", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !store.runs["job"].Equal(now) {
+		t.Fatalf("baseline = %s, want %s", store.runs["job"], now)
+	}
+
+	s.tick(context.Background())
+	s.wg.Wait()
+	if got := calls.Load(); got != 0 {
+		t.Errorf("runs before first occurrence = %d, want 0", got)
+	}
+}
+
+func TestScheduler_RegisteredJobsEnqueue(t *testing.T) {
+	registryMu.Lock()
+	registry["test_job"] = "@hourly"
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test_job")
+		registryMu.Unlock()
+	})
+
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)
+	store.runs["test_job"] = now.Add(-time.Hour)
+
+	s := New(store, Options{Location: time.UTC})
+	s.now = func() time.Time { return now }
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(context.Background())
+	s.wg.Wait()
+
+	var found bool
+	for _, j := range store.enqueued {
+		if j.Type == "test_job" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("enqueued = %+v, want a test_job job", store.enqueued)
+	}
+}
+
+func TestScheduler_Overrides(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	opts := Options{
+		Location:  time.UTC,
+		Overrides: map[string]string{"job": "*/5 * * * *", "bad": "not a spec"},
+	}
+	s := newTestScheduler(store, opts, &now)
+	noop := func(context.Context) error { return nil }
+	if err := s.AddFunc("job", "0 3 * * *", noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFunc("bad", "0 4 * * *", noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFunc("job", "0 3 * * *", noop); err == nil {
+		t.Error("AddFunc with duplicate name = nil error, want error")
+	}
+	if err := s.AddFunc("other", "0 61 * * *", noop); err == nil {
+		t.Error("AddFunc with invalid spec = nil error, want error")
+	}
+
+	specs := make(map[string]string)
+	for _, e := range s.entries {
+		specs[e.name] = e.spec.String()
+	}
+	if specs["job"] != "*/5 * * * *" {
+		t.Errorf("job spec = %q, want override", specs["job"])
+	}
+	if specs["bad"] != "0 4 * * *" {
+		t.Errorf("bad spec = %q, want default after invalid override", specs["bad"])
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestScheduler(store, Options{Location: time.UTC, Jitter: 10 * time.Minute}, &now)
+	_ = s.AddFunc("job", "0 13 * * *", func(context.Context) error { return nil })
+
+	base := time.Date(2026, 5, 1, 13, 0, 0, 0, time.UTC)
+	for range 20 {
+		due := s.nextDue(s.entries[0], now)
+		if due.Before(base) || !due.Before(base.Add(10*time.Minute)) {
+			t.Fatalf("due = %s, want within 10m after %s", due, base)
+		}
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	got, err := ParseOverrides(" nightly_synthesis=0 3 * * * ; delta_policy=@hourly;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["nightly_synthesis"] != "0 3 * * *" || got["delta_policy"] != "@hourly" {
+		t.Errorf("ParseOverrides = %v", got)
+	}
+
+	for _, s := range []string{"nightly_synthesis", "=@hourly", "job=bogus"} {
+		if _, err := ParseOverrides(s); err == nil {
+			t.Errorf("ParseOverrides(%q) = nil error, want error", s)
+		}
+	}
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestScheduler(store, Options{Location: time.UTC}, &now)
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	_ = s.AddFunc("job", "0 3 * * *", func(context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	if err := s.Trigger("job"); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Trigger before Run = %v, want ErrNotRunning", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	for {
+		if err := s.Trigger("job"); !errors.Is(err, ErrNotRunning) {
+			if err != nil {
+				t.Fatalf("Trigger = %v", err)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Trigger("job"); !errors.Is(err, ErrBusy) {
+		t.Errorf("Trigger while running = %v, want ErrBusy", err)
+	}
+	if err := s.Trigger("missing"); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Trigger(missing) = %v, want ErrUnknown", err)
+	}
+
+	close(release)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("runs = %d, want 1", got)
+	}
+	if !store.runs["job"].Equal(now) {
+		t.Errorf("manual run moved the recorded last run to %s", store.runs["job"])
+	}
+}
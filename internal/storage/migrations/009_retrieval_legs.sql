@@ -0,0 +1,21 @@
+-- Record which retrieval legs surfaced each chunk an interaction used, so
+-- feedback can be attributed to vector vs keyword vs entity-expansion search.
+ALTER TABLE interactions ADD COLUMN intent_type TEXT DEFAULT '';
+ALTER TABLE interactions ADD COLUMN retrieval_legs TEXT DEFAULT '{}';
+
+-- Learned default hybrid ratio (vector weight) per intent type.
+CREATE TABLE IF NOT EXISTS hybrid_weights (
+    intent_type TEXT PRIMARY KEY,
+    vector_ratio REAL NOT NULL,
+    feedback_count INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Per-intent, per-leg feedback tallies for inspection.
+CREATE TABLE IF NOT EXISTS retrieval_leg_stats (
+    intent_type TEXT NOT NULL,
+    leg TEXT NOT NULL,
+    positive_count INTEGER NOT NULL DEFAULT 0,
+    negative_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (intent_type, leg)
+);
@@ -0,0 +1,22 @@
+-- Persisted query cache so the daemon does not start cold after a restart.
+-- Rows are rewritten wholesale on every save.
+CREATE TABLE IF NOT EXISTS query_cache_entries (
+    level TEXT NOT NULL,              -- 'exact' (L1) or 'semantic' (L2)
+    seq INTEGER NOT NULL,             -- insertion order, oldest first
+    query_hash TEXT NOT NULL,
+    cached_at TEXT NOT NULL,
+    profile_version INTEGER NOT NULL DEFAULT 0,
+    topics TEXT NOT NULL DEFAULT '[]',
+    enriched_request TEXT NOT NULL,
+    metadata TEXT NOT NULL DEFAULT 'null',
+    embedding BLOB,                   -- unit-normalized float32s, semantic rows only
+    PRIMARY KEY (level, seq)
+);
+
+-- Single-row header describing the saved snapshot.
+CREATE TABLE IF NOT EXISTS query_cache_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    embed_model TEXT NOT NULL,
+    min_profile_version INTEGER NOT NULL DEFAULT 0,
+    saved_at TEXT NOT NULL
+);
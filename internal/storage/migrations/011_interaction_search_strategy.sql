@@ -0,0 +1,3 @@
+-- Record the search strategy each interaction retrieved with, so hybrid
+-- ratio learning only counts feedback on hybrid searches.
+ALTER TABLE interactions ADD COLUMN search_strategy TEXT DEFAULT '';
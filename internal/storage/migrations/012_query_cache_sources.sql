@@ -0,0 +1,6 @@
+-- Record which chunks and sources each persisted cache entry drew on, so that
+-- deleting content can evict the entries built from it. Rows saved before
+-- this column existed cannot be attributed, so they are dropped.
+DELETE FROM query_cache_entries;
+ALTER TABLE query_cache_entries ADD COLUMN chunk_ids TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE query_cache_entries ADD COLUMN source_ids TEXT NOT NULL DEFAULT '[]';
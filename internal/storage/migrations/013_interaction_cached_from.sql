@@ -0,0 +1,3 @@
+-- Link interactions answered from the response cache to the interaction whose
+-- upstream response was replayed. Empty for interactions that reached the cloud.
+ALTER TABLE interactions ADD COLUMN cached_from TEXT DEFAULT '';
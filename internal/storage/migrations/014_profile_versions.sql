@@ -0,0 +1,16 @@
+-- Versioned profile history. Every profile mutation commits a row holding the
+-- full profile after the change, so any two versions can be diffed and any
+-- version restored. An existing profile is recorded as the first version.
+CREATE TABLE IF NOT EXISTS profile_versions (
+    version    INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL,
+    source     TEXT NOT NULL,
+    summary    TEXT NOT NULL DEFAULT '',
+    changes    TEXT NOT NULL DEFAULT '[]',
+    snapshot   TEXT NOT NULL DEFAULT '{}'
+);
+
+INSERT INTO profile_versions (created_at, source, summary, snapshot)
+SELECT strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), 'migration', 'profile before history was recorded', json_group_object(key, value)
+FROM user_profile
+HAVING COUNT(*) > 0;
@@ -0,0 +1,53 @@
+-- Named personas, each with its own profile and history, and optionally its
+-- own knowledge-base partition.
+CREATE TABLE IF NOT EXISTS personas (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    partitioned INTEGER NOT NULL DEFAULT 0, -- 1: documents ingested as this persona are only retrieved for it
+    created_at TEXT NOT NULL
+);
+
+INSERT INTO personas (name, created_at) VALUES ('default', strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+
+-- How requests are mapped to personas. kind is 'token' (sha256 of an API
+-- token, hex), 'client' (X-TBYD-Client or MCP client name, lowercased) or
+-- 'model' (requested model name).
+CREATE TABLE IF NOT EXISTS persona_selectors (
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    persona TEXT NOT NULL,
+    PRIMARY KEY (kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_persona_selectors_persona ON persona_selectors(persona);
+
+-- Profile keys are scoped per persona; existing keys belong to 'default'.
+CREATE TABLE user_profile_scoped (
+    persona TEXT NOT NULL DEFAULT 'default',
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (persona, key)
+);
+
+INSERT INTO user_profile_scoped (persona, key, value, updated_at)
+SELECT 'default', key, value, updated_at FROM user_profile;
+
+DROP TABLE user_profile;
+ALTER TABLE user_profile_scoped RENAME TO user_profile;
+
+ALTER TABLE profile_versions ADD COLUMN persona TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_profile_versions_persona ON profile_versions(persona, version);
+
+-- Knowledge-base partition of a document and its vectors; '' is shared.
+ALTER TABLE context_docs ADD COLUMN kb_partition TEXT NOT NULL DEFAULT '';
+ALTER TABLE context_vectors ADD COLUMN kb_partition TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_context_vectors_kb_partition ON context_vectors(kb_partition);
+
+-- The persona an interaction was made as, and the partition its summary goes to.
+ALTER TABLE interactions ADD COLUMN persona TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE interactions ADD COLUMN kb_partition TEXT NOT NULL DEFAULT '';
+
+-- Persisted query-cache entries are scoped per persona.
+ALTER TABLE query_cache_entries ADD COLUMN persona TEXT NOT NULL DEFAULT '';
+ALTER TABLE query_cache_state ADD COLUMN profile_floors TEXT NOT NULL DEFAULT '{}';
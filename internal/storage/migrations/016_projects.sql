@@ -0,0 +1,26 @@
+-- Project workspaces: a named codebase or area of work with its root paths
+-- and tech stack. Documents tagged with a project are boosted (or kept
+-- exclusively) when that project is active.
+CREATE TABLE IF NOT EXISTS projects (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    stack TEXT NOT NULL DEFAULT '[]', -- JSON array of technologies
+    created_at TEXT NOT NULL
+);
+
+-- Absolute directory paths that belong to a project; a request whose working
+-- directory or MCP root lies under a path selects its project.
+CREATE TABLE IF NOT EXISTS project_roots (
+    path TEXT PRIMARY KEY,
+    project TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_roots_project ON project_roots(project);
+
+-- Project of a document and its vectors; '' belongs to no project.
+ALTER TABLE context_docs ADD COLUMN project TEXT NOT NULL DEFAULT '';
+ALTER TABLE context_vectors ADD COLUMN project TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_context_vectors_project ON context_vectors(project);
+
+-- Persisted query-cache entries are scoped per project as well as persona.
+ALTER TABLE query_cache_entries ADD COLUMN project TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- Intent topics extracted for an interaction, as a JSON array. Interest
+-- promotion and decay count topic activity over time from this column.
+ALTER TABLE interactions ADD COLUMN topics TEXT NOT NULL DEFAULT '[]';
+
+-- Structured evidence behind a proposed profile delta (JSON), e.g. the
+-- mention counts and date ranges that justify an interest change.
+ALTER TABLE pending_profile_deltas ADD COLUMN evidence_json TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- Automatic decisions the delta policy engine made on pending profile deltas,
+-- with the rule that fired, so each can be audited and undone.
+CREATE TABLE IF NOT EXISTS delta_decisions (
+    id TEXT PRIMARY KEY,
+    delta_id TEXT NOT NULL,
+    action TEXT NOT NULL,              -- "accepted" | "expired"
+    rule TEXT NOT NULL,
+    applied_json TEXT NOT NULL DEFAULT '', -- ProfileDelta actually applied; '' when none
+    created_at TEXT NOT NULL,
+    undone_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_delta_decisions_delta ON delta_decisions(delta_id);
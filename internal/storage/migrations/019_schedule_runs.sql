@@ -0,0 +1,7 @@
+-- When each scheduled job last ran, so missed runs are caught up after the
+-- server was down or the machine slept. A job is recorded when it is first
+-- scheduled, before its first run.
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    name TEXT PRIMARY KEY,
+    last_run_at TEXT NOT NULL
+);
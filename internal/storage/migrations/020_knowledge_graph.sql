@@ -0,0 +1,46 @@
+-- Knowledge graph of the people, projects, technologies and other entities
+-- mentioned across context docs, populated by deep enrichment.
+CREATE TABLE IF NOT EXISTS kg_entities (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,                 -- display name, as first seen
+    kind TEXT NOT NULL DEFAULT 'other', -- person, project, technology, organization, place, product, concept, other
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Every name an entity is known by, normalised (lowercase, single spaces).
+-- The entity's own name is an alias too.
+CREATE TABLE IF NOT EXISTS kg_aliases (
+    alias TEXT PRIMARY KEY,
+    entity_id TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_kg_aliases_entity ON kg_aliases(entity_id);
+
+-- Docs that mention each entity.
+CREATE TABLE IF NOT EXISTS kg_mentions (
+    entity_id TEXT NOT NULL,
+    doc_id TEXT NOT NULL,
+    PRIMARY KEY (entity_id, doc_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kg_mentions_doc ON kg_mentions(doc_id);
+
+-- Directed relations between entities, one row per doc that states them;
+-- a relation's weight is the number of docs behind it.
+CREATE TABLE IF NOT EXISTS kg_relations (
+    source_id TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    relation TEXT NOT NULL,             -- e.g. works_on, uses, part_of
+    doc_id TEXT NOT NULL,
+    PRIMARY KEY (source_id, target_id, relation, doc_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kg_relations_target ON kg_relations(target_id);
+CREATE INDEX IF NOT EXISTS idx_kg_relations_doc ON kg_relations(doc_id);
+
+-- Deleting a doc removes what it contributed to the graph.
+CREATE TRIGGER IF NOT EXISTS context_docs_kg_ad AFTER DELETE ON context_docs BEGIN
+    DELETE FROM kg_mentions WHERE doc_id = old.id;
+    DELETE FROM kg_relations WHERE doc_id = old.id;
+END;
@@ -0,0 +1,25 @@
+-- Interaction summaries folded into a consolidated memory doc. The summary
+-- vector is kept, down-weighted, so the original stays available for audit;
+-- prior_quality is its quality_score before consolidation.
+CREATE TABLE IF NOT EXISTS memory_consolidations (
+    interaction_id TEXT PRIMARY KEY,    -- each interaction is consolidated at most once
+    vector_id TEXT NOT NULL,
+    doc_id TEXT NOT NULL,               -- the consolidated memory context doc
+    prior_quality REAL NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_consolidations_doc ON memory_consolidations(doc_id);
+
+-- Deleting a consolidated memory restores the weight of its sources and
+-- releases them for a later consolidation pass.
+CREATE TRIGGER IF NOT EXISTS context_docs_consolidation_ad AFTER DELETE ON context_docs BEGIN
+    UPDATE context_vectors
+    SET quality_score = (SELECT m.prior_quality FROM memory_consolidations m WHERE m.vector_id = context_vectors.id AND m.doc_id = old.id)
+    WHERE id IN (SELECT vector_id FROM memory_consolidations WHERE doc_id = old.id);
+    DELETE FROM memory_consolidations WHERE doc_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS interactions_consolidation_ad AFTER DELETE ON interactions BEGIN
+    DELETE FROM memory_consolidations WHERE interaction_id = old.id;
+END;
@@ -0,0 +1,32 @@
+-- Facts and decisions the deep model extracted from completed interactions,
+-- waiting for review. Accepting one saves it as a context doc.
+CREATE TABLE IF NOT EXISTS pending_facts (
+    id TEXT PRIMARY KEY,
+    interaction_id TEXT NOT NULL,       -- the interaction the fact was stated in
+    kind TEXT NOT NULL,                 -- "fact" | "decision"
+    statement TEXT NOT NULL,
+    confidence REAL NOT NULL,           -- 0..1, as judged by the model
+    kb_partition TEXT NOT NULL DEFAULT '',
+    accepted INTEGER,                   -- NULL = not reviewed
+    reviewed_at TEXT,
+    doc_id TEXT NOT NULL DEFAULT '',    -- context doc created on acceptance
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_facts_unreviewed ON pending_facts(created_at) WHERE accepted IS NULL;
+CREATE INDEX IF NOT EXISTS idx_pending_facts_interaction ON pending_facts(interaction_id);
+
+-- Interactions fact extraction has processed, whether or not it found
+-- anything, so each is sent to the model once.
+CREATE TABLE IF NOT EXISTS fact_extractions (
+    interaction_id TEXT PRIMARY KEY,
+    facts INTEGER NOT NULL,             -- facts proposed
+    created_at TEXT NOT NULL
+);
+
+-- Deleting an interaction drops the facts still waiting for review; accepted
+-- facts are context docs of their own by then.
+CREATE TRIGGER IF NOT EXISTS interactions_facts_ad AFTER DELETE ON interactions BEGIN
+    DELETE FROM pending_facts WHERE interaction_id = old.id AND accepted IS NULL;
+    DELETE FROM fact_extractions WHERE interaction_id = old.id;
+END;
@@ -0,0 +1,3 @@
+-- The delta actually applied when a pending delta was accepted, which differs
+-- from delta_json when the reviewer accepted an edited version; '' until then.
+ALTER TABLE pending_profile_deltas ADD COLUMN applied_json TEXT NOT NULL DEFAULT '';
@@ -8,8 +8,25 @@ import (
 // ErrNotFound is returned when a requested record does not exist.
 var ErrNotFound = errors.New("not found")
 
-// ErrAlreadyReviewed is returned when a pending delta has already been accepted or rejected.
-var ErrAlreadyReviewed = errors.New("delta already reviewed")
+// ErrAlreadyReviewed is returned when a pending delta or fact has already been accepted or rejected.
+var ErrAlreadyReviewed = errors.New("already reviewed")
+
+// ErrAlreadyUndone is returned when a delta decision has already been undone.
+var ErrAlreadyUndone = errors.New("decision already undone")
+
+// ErrJobRunning is returned when a job cannot be changed because a worker is
+// processing it.
+var ErrJobRunning = errors.New("job is running")
+
+// ErrJobNotFailed is returned when retrying a job that has not failed.
+var ErrJobNotFailed = errors.New("job has not failed")
+
+// ErrConflict is returned when a record or selector with the same key already exists.
+var ErrConflict = errors.New("already exists")
+
+// DefaultPersona owns the profile when no other persona is selected. It
+// always exists and cannot be deleted.
+const DefaultPersona = "default"
 
 type Interaction struct {
 	ID             string    `json:"id"`
@@ -22,19 +39,26 @@ type Interaction struct {
 	FeedbackScore  int       `json:"feedback_score"`
 	FeedbackNotes  string    `json:"feedback_notes,omitempty"`
 	VectorIDs      string    `json:"vector_ids"` // JSON array stored as text
+	IntentType     string    `json:"intent_type,omitempty"`
+	RetrievalLegs  string    `json:"retrieval_legs,omitempty"` // JSON object: vector ID -> legs that surfaced it
+	SearchStrategy string    `json:"search_strategy,omitempty"`
+	CachedFrom     string    `json:"cached_from,omitempty"` // interaction whose cached response was replayed
+	Persona        string    `json:"persona,omitempty"`
+	Partition      string    `json:"kb_partition,omitempty"` // knowledge-base partition its summary is stored in; "" is shared
+	Topics         string    `json:"topics,omitempty"`       // JSON array of intent topics stored as text
 }
 
 type Job struct {
-	ID          string
-	Type        string
-	PayloadJSON string
-	Status      string // "pending", "running", "completed", "failed"
-	Attempts    int
-	MaxAttempts int
-	RunAfter    time.Time
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	LastError   string
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	PayloadJSON string    `json:"payload_json"`
+	Status      string    `json:"status"` // "pending", "running", "completed", "failed"
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	RunAfter    time.Time `json:"run_after"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastError   string    `json:"last_error,omitempty"`
 }
 
 type ContextDoc struct {
@@ -47,6 +71,57 @@ type ContextDoc struct {
 	VectorID     string
 	Metadata     string // JSON object stored as text
 	DeepMetadata string // JSON object stored as text; populated by the deep enrichment pass
+	Partition    string // knowledge-base partition; "" is shared
+	Project      string // project workspace the document belongs to; "" is none
+}
+
+// Persona is a named profile with its own identity, preferences and history.
+// Requests are routed to a persona by an API token, a client name or the
+// requested model.
+type Persona struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Partitioned bool      `json:"partitioned"` // documents ingested as this persona are only retrieved for it
+	Clients     []string  `json:"clients"`
+	Models      []string  `json:"models"`
+	Tokens      int       `json:"tokens"` // number of API tokens that select this persona
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Persona selector kinds; see Store.ResolvePersona.
+const (
+	SelectorToken  = "token"
+	SelectorClient = "client"
+	SelectorModel  = "model"
+)
+
+// Project is a workspace — a codebase or area of work — with the directory
+// roots that select it and its tech stack. Context docs tagged with a project
+// are preferred in retrieval while it is active.
+type Project struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Roots       []string  `json:"roots"` // absolute directory paths
+	Stack       []string  `json:"stack"` // technologies, e.g. "go", "sqlite"
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ProfileVersion is one committed profile change. Version 0 is the empty
+// profile before any change.
+type ProfileVersion struct {
+	Version   int64                `json:"version"`
+	CreatedAt time.Time            `json:"created_at"`
+	Source    string               `json:"source"` // "cli", "api", "mcp", "feedback", "nightly", "policy", "migration"
+	Summary   string               `json:"summary"`
+	Changes   []ProfileFieldChange `json:"changes"`
+}
+
+// ProfileFieldChange describes how one profile key differs between two versions.
+type ProfileFieldChange struct {
+	Key string `json:"key"`
+	Op  string `json:"op"` // "add", "update", or "delete"
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
 }
 
 // PendingProfileDelta is a proposed change to the user profile waiting for
@@ -56,8 +131,121 @@ type PendingProfileDelta struct {
 	ID          string     `json:"id"`
 	DeltaJSON   string     `json:"delta_json"`
 	Description string     `json:"description"`
-	Source      string     `json:"source"`       // "nightly_synthesis" | "feedback_aggregation"
+	Source      string     `json:"source"`       // "nightly_synthesis" | "feedback_aggregation" | "interest_review" | "preference_reconcile"
 	Accepted    *bool      `json:"accepted"`     // nil = not reviewed
 	ReviewedAt  *time.Time `json:"reviewed_at"`
 	CreatedAt   time.Time  `json:"created_at"`
+	// EvidenceJSON is the structured evidence behind the proposal (JSON), or
+	// "" when the source provides none.
+	EvidenceJSON string `json:"evidence_json,omitempty"`
+	// AppliedJSON is the ProfileDelta applied on acceptance (JSON), which
+	// differs from DeltaJSON when the reviewer edited it; "" until accepted.
+	AppliedJSON string `json:"applied_json,omitempty"`
+}
+
+// DeltaDecision is an automatic decision on a pending profile delta made by
+// the delta policy engine.
+type DeltaDecision struct {
+	ID          string     `json:"id"`
+	DeltaID     string     `json:"delta_id"`
+	Action      string     `json:"action"`                 // "accepted" | "expired"
+	Rule        string     `json:"rule"`                   // policy rule that fired
+	AppliedJSON string     `json:"applied_json,omitempty"` // ProfileDelta actually applied; "" when none
+	CreatedAt   time.Time  `json:"created_at"`
+	UndoneAt    *time.Time `json:"undone_at"`
+}
+
+// Entity is a node in the knowledge graph: a person, project, technology or
+// other named thing mentioned across context docs.
+type Entity struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`     // see EntityKinds
+	Mentions  int       `json:"mentions"` // docs that mention it
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EntityRelation is a relation between an entity and another, aggregated
+// over the docs that state it.
+type EntityRelation struct {
+	Relation  string `json:"relation"`
+	Direction string `json:"direction"` // "out" when the entity is the source, "in" when it is the target
+	OtherID   string `json:"other_id"`
+	OtherName string `json:"other_name"`
+	Weight    int    `json:"weight"` // docs stating the relation
+}
+
+// EntityDetail is an entity with its aliases, relations and the docs that
+// mention it.
+type EntityDetail struct {
+	Entity
+	Aliases   []string         `json:"aliases"`
+	Relations []EntityRelation `json:"relations"`
+	DocIDs    []string         `json:"doc_ids"`
+}
+
+// GraphScope limits knowledge-graph queries to what the docs of one
+// knowledge-base partition say. The zero GraphScope spans every partition.
+type GraphScope struct {
+	Partitioned bool   // count only mentions and relations from docs in Partition
+	Partition   string // "" is the shared partition
+}
+
+// DocGraph is what one context doc contributes to the knowledge graph.
+type DocGraph struct {
+	Entities  []DocEntity
+	Relations []DocRelation
+}
+
+// DocEntity is an entity as a doc mentions it.
+type DocEntity struct {
+	Name    string
+	Kind    string
+	Aliases []string
+}
+
+// DocRelation is a relation a doc states between two entities, named by
+// entity name or alias, e.g. {"Alice", "works_on", "tbyd"}.
+type DocRelation struct {
+	Source   string
+	Relation string
+	Target   string
+}
+
+// EpisodicMemory is the summary vector of one interaction, a candidate for
+// memory consolidation.
+type EpisodicMemory struct {
+	InteractionID string
+	VectorID      string
+	Summary       string
+	Topics        string // JSON array of the interaction's intent topics
+	Partition     string
+	Project       string
+	CreatedAt     time.Time // of the interaction
+}
+
+// ConsolidationSource is an interaction summary folded into a consolidated
+// memory doc.
+type ConsolidationSource struct {
+	InteractionID string    `json:"interaction_id"`
+	VectorID      string    `json:"vector_id"`
+	UserQuery     string    `json:"user_query"`
+	Summary       string    `json:"summary"`
+	CreatedAt     time.Time `json:"created_at"` // of the interaction
+}
+
+// PendingFact is a fact or decision extracted from a completed interaction,
+// waiting for review. Accepting it saves it as a context doc.
+type PendingFact struct {
+	ID            string     `json:"id"`
+	InteractionID string     `json:"interaction_id"`
+	Kind          string     `json:"kind"` // "fact" | "decision"
+	Statement     string     `json:"statement"`
+	Confidence    float64    `json:"confidence"`
+	Partition     string     `json:"kb_partition,omitempty"` // of the source interaction
+	Accepted      *bool      `json:"accepted"`               // nil = not reviewed
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	DocID         string     `json:"doc_id,omitempty"` // context doc created on acceptance
+	CreatedAt     time.Time  `json:"created_at"`
 }
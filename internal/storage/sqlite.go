@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
@@ -12,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
@@ -75,6 +79,38 @@ func (s *Store) DB() *sql.DB {
 	return s.db
 }
 
+// Snapshot writes a transactionally consistent copy of the database to
+// destPath using VACUUM INTO. destPath must not already exist.
+func (s *Store) Snapshot(ctx context.Context, destPath string) error {
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", destPath); err != nil {
+		return fmt.Errorf("snapshotting database: %w", err)
+	}
+	return nil
+}
+
+// SnapshotReadOnly writes a transactionally consistent copy of the database in
+// dataDir to destPath without writing to it: the source is opened read-only
+// and no migrations are run, so a live database on an older schema is left
+// untouched. Open the copy with Open to bring it up to the current schema.
+func SnapshotReadOnly(ctx context.Context, dataDir, destPath string) error {
+	src := filepath.Join(dataDir, "tbyd.db")
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("no database found in %s: %w", dataDir, err)
+	}
+
+	dsn := "file:" + src + "?mode=ro&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("opening database read-only: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", destPath); err != nil {
+		return fmt.Errorf("snapshotting database: %w", err)
+	}
+	return nil
+}
+
 // Close closes the underlying database connection.
 func (s *Store) Close() error {
 	return s.db.Close()
@@ -181,11 +217,24 @@ func (s *Store) SaveInteraction(ctx context.Context, i Interaction) error {
 	if status == "" {
 		status = "completed"
 	}
+	retrievalLegs := i.RetrievalLegs
+	if retrievalLegs == "" {
+		retrievalLegs = "{}"
+	}
+	persona := i.Persona
+	if persona == "" {
+		persona = DefaultPersona
+	}
+	topics := i.Topics
+	if topics == "" {
+		topics = "[]"
+	}
 	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO interactions (id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO interactions (id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition, topics)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		i.ID, i.CreatedAt.UTC().Format(time.RFC3339), i.UserQuery, i.EnrichedPrompt,
 		i.CloudModel, i.CloudResponse, status, i.FeedbackScore, i.FeedbackNotes, i.VectorIDs,
+		i.IntentType, retrievalLegs, i.SearchStrategy, i.CachedFrom, persona, i.Partition, topics,
 	)
 	return err
 }
@@ -194,9 +243,9 @@ func (s *Store) GetInteraction(id string) (Interaction, error) {
 	var i Interaction
 	var createdAt string
 	err := s.db.QueryRow(`
-		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids
+		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition, topics
 		FROM interactions WHERE id = ?`, id,
-	).Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs)
+	).Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs, &i.IntentType, &i.RetrievalLegs, &i.SearchStrategy, &i.CachedFrom, &i.Persona, &i.Partition, &i.Topics)
 	if err == sql.ErrNoRows {
 		return Interaction{}, ErrNotFound
 	}
@@ -228,7 +277,7 @@ func (s *Store) UpdateFeedback(id string, score int, notes string) error {
 
 func (s *Store) GetRecentInteractions(limit int) ([]Interaction, error) {
 	rows, err := s.db.Query(`
-		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids
+		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition
 		FROM interactions ORDER BY created_at DESC LIMIT ?`, limit,
 	)
 	if err != nil {
@@ -240,7 +289,7 @@ func (s *Store) GetRecentInteractions(limit int) ([]Interaction, error) {
 	for rows.Next() {
 		var i Interaction
 		var createdAt string
-		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs); err != nil {
+		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs, &i.IntentType, &i.RetrievalLegs, &i.SearchStrategy, &i.CachedFrom, &i.Persona, &i.Partition); err != nil {
 			return nil, err
 		}
 		t, err := time.Parse(time.RFC3339, createdAt)
@@ -257,7 +306,7 @@ func (s *Store) GetRecentInteractions(limit int) ([]Interaction, error) {
 // feedback score, ordered by most recent first, up to limit rows.
 func (s *Store) GetInteractionsWithFeedback(limit int) ([]Interaction, error) {
 	rows, err := s.db.Query(`
-		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids
+		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition
 		FROM interactions WHERE feedback_score != 0 ORDER BY created_at DESC LIMIT ?`, limit,
 	)
 	if err != nil {
@@ -269,7 +318,7 @@ func (s *Store) GetInteractionsWithFeedback(limit int) ([]Interaction, error) {
 	for rows.Next() {
 		var i Interaction
 		var createdAt string
-		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs); err != nil {
+		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs, &i.IntentType, &i.RetrievalLegs, &i.SearchStrategy, &i.CachedFrom, &i.Persona, &i.Partition); err != nil {
 			return nil, err
 		}
 		t, err := time.Parse(time.RFC3339, createdAt)
@@ -394,18 +443,12 @@ func (s *Store) GetSignalCounts() ([]SignalCount, error) {
 
 // --- User Profile ---
 
-func (s *Store) SetProfileKey(key, value string) error {
-	_, err := s.db.Exec(`
-		INSERT INTO user_profile (key, value, updated_at) VALUES (?, ?, ?)
-		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
-		key, value, time.Now().UTC().Format(time.RFC3339),
-	)
-	return err
-}
+// The Store's own profile methods operate on the default persona; use
+// PersonaProfile for the others.
 
 func (s *Store) GetProfileKey(key string) (string, error) {
 	var value string
-	err := s.db.QueryRow("SELECT value FROM user_profile WHERE key = ?", key).Scan(&value)
+	err := s.db.QueryRow("SELECT value FROM user_profile WHERE persona = ? AND key = ?", DefaultPersona, key).Scan(&value)
 	if err == sql.ErrNoRows {
 		return "", ErrNotFound
 	}
@@ -413,7 +456,49 @@ func (s *Store) GetProfileKey(key string) (string, error) {
 }
 
 func (s *Store) GetAllProfileKeys() (map[string]string, error) {
-	rows, err := s.db.Query("SELECT key, value FROM user_profile")
+	return s.PersonaProfile(DefaultPersona).GetAllProfileKeys()
+}
+
+// --- Profile history ---
+
+// CommitProfileChange records a change to the default persona's profile; see
+// PersonaProfile.CommitProfileChange.
+func (s *Store) CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error) {
+	return s.PersonaProfile(DefaultPersona).CommitProfileChange(source, summary, set, del)
+}
+
+// ListProfileVersions returns the default persona's profile versions, newest first.
+func (s *Store) ListProfileVersions(limit, offset int) ([]ProfileVersion, error) {
+	return s.PersonaProfile(DefaultPersona).ListProfileVersions(limit, offset)
+}
+
+// GetProfileSnapshot returns the default persona's profile as of version.
+func (s *Store) GetProfileSnapshot(version int64) (map[string]string, error) {
+	return s.PersonaProfile(DefaultPersona).GetProfileSnapshot(version)
+}
+
+// DiffProfileVersions compares two versions of the default persona's profile.
+func (s *Store) DiffProfileVersions(from, to int64) ([]ProfileFieldChange, error) {
+	return s.PersonaProfile(DefaultPersona).DiffProfileVersions(from, to)
+}
+
+// PersonaProfile is the profile and profile history of one persona. Version
+// numbers are shared across personas, so a persona's history may skip
+// numbers; versions of other personas are not found through it.
+type PersonaProfile struct {
+	db      *sql.DB
+	persona string
+}
+
+// PersonaProfile returns the profile storage of the named persona. It does not
+// check that the persona exists.
+func (s *Store) PersonaProfile(persona string) *PersonaProfile {
+	return &PersonaProfile{db: s.db, persona: persona}
+}
+
+// GetAllProfileKeys returns every profile key of the persona.
+func (p *PersonaProfile) GetAllProfileKeys() (map[string]string, error) {
+	rows, err := p.db.Query("SELECT key, value FROM user_profile WHERE persona = ?", p.persona)
 	if err != nil {
 		return nil, err
 	}
@@ -430,556 +515,2000 @@ func (s *Store) GetAllProfileKeys() (map[string]string, error) {
 	return result, rows.Err()
 }
 
-func (s *Store) DeleteProfileKey(key string) error {
-	res, err := s.db.Exec(`DELETE FROM user_profile WHERE key = ?`, key)
+// CommitProfileChange sets and deletes profile keys and records the resulting
+// profile as a new version, in one transaction. Deleting a key that does not
+// exist returns ErrNotFound and writes nothing. When the profile would not
+// change, nothing is written and the latest version is returned.
+func (p *PersonaProfile) CommitProfileChange(source, summary string, set map[string]string, del []string) (int64, error) {
+	tx, err := p.db.Begin()
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("beginning profile change: %w", err)
 	}
-	n, err := res.RowsAffected()
+	defer tx.Rollback()
+
+	before, err := profileKeysTx(tx, p.persona)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if n == 0 {
-		return ErrNotFound
+	after := make(map[string]string, len(before)+len(set))
+	for k, v := range before {
+		after[k] = v
 	}
-	return nil
-}
-
-// --- Context Docs ---
-
-func (s *Store) SaveContextDoc(doc ContextDoc) error {
-	metadata := doc.Metadata
-	if metadata == "" {
-		metadata = "{}"
+	for _, k := range del {
+		if _, ok := after[k]; !ok {
+			return 0, ErrNotFound
+		}
+		delete(after, k)
 	}
-	deepMetadata := doc.DeepMetadata
-	if deepMetadata == "" {
-		deepMetadata = "{}"
+	for k, v := range set {
+		after[k] = v
 	}
-	_, err := s.db.Exec(`
-		INSERT INTO context_docs (id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		doc.ID, doc.Title, doc.Content, doc.Source, doc.Tags,
-		doc.CreatedAt.UTC().Format(time.RFC3339), doc.VectorID, metadata, deepMetadata,
-	)
-	return err
-}
 
-func (s *Store) GetContextDoc(id string) (ContextDoc, error) {
-	var d ContextDoc
-	var createdAt string
-	err := s.db.QueryRow(`
-		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata
-		FROM context_docs WHERE id = ?`, id,
-	).Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata)
-	if err == sql.ErrNoRows {
-		return ContextDoc{}, ErrNotFound
+	changes := diffProfileKeys(before, after)
+	if len(changes) == 0 {
+		var latest int64
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM profile_versions WHERE persona = ?`, p.persona).Scan(&latest); err != nil {
+			return 0, fmt.Errorf("reading latest profile version: %w", err)
+		}
+		return latest, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range changes {
+		if c.Op == "delete" {
+			if _, err := tx.Exec(`DELETE FROM user_profile WHERE persona = ? AND key = ?`, p.persona, c.Key); err != nil {
+				return 0, fmt.Errorf("deleting profile key %q: %w", c.Key, err)
+			}
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO user_profile (persona, key, value, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(persona, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			p.persona, c.Key, c.New, now,
+		); err != nil {
+			return 0, fmt.Errorf("setting profile key %q: %w", c.Key, err)
+		}
 	}
+
+	changesJSON, err := json.Marshal(changes)
 	if err != nil {
-		return ContextDoc{}, err
+		return 0, fmt.Errorf("marshalling profile changes: %w", err)
 	}
-	t, err := time.Parse(time.RFC3339, createdAt)
+	snapshotJSON, err := json.Marshal(after)
 	if err != nil {
-		return ContextDoc{}, fmt.Errorf("parsing created_at: %w", err)
+		return 0, fmt.Errorf("marshalling profile snapshot: %w", err)
 	}
-	d.CreatedAt = t
-	return d, nil
+	var version int64
+	if err := tx.QueryRow(`
+		INSERT INTO profile_versions (persona, created_at, source, summary, changes, snapshot)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING version`,
+		p.persona, now, source, summary, string(changesJSON), string(snapshotJSON),
+	).Scan(&version); err != nil {
+		return 0, fmt.Errorf("recording profile version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing profile change: %w", err)
+	}
+	return version, nil
 }
 
-func (s *Store) ListContextDocs(limit int) ([]ContextDoc, error) {
-	rows, err := s.db.Query(`
-		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata
-		FROM context_docs ORDER BY created_at DESC LIMIT ?`, limit,
+// ListProfileVersions returns the persona's profile versions, newest first.
+func (p *PersonaProfile) ListProfileVersions(limit, offset int) ([]ProfileVersion, error) {
+	rows, err := p.db.Query(`
+		SELECT version, created_at, source, summary, changes
+		FROM profile_versions WHERE persona = ? ORDER BY version DESC LIMIT ? OFFSET ?`, p.persona, limit, offset,
 	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("listing profile versions: %w", err)
 	}
 	defer rows.Close()
 
-	var results []ContextDoc
+	var versions []ProfileVersion
 	for rows.Next() {
-		var d ContextDoc
-		var createdAt string
-		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata); err != nil {
+		var v ProfileVersion
+		var createdAt, changes string
+		if err := rows.Scan(&v.Version, &createdAt, &v.Source, &v.Summary, &changes); err != nil {
 			return nil, err
 		}
-		t, err := time.Parse(time.RFC3339, createdAt)
-		if err != nil {
-			return nil, fmt.Errorf("parsing created_at: %w", err)
+		if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+			v.CreatedAt = t
 		}
-		d.CreatedAt = t
-		results = append(results, d)
+		if err := json.Unmarshal([]byte(changes), &v.Changes); err != nil {
+			return nil, fmt.Errorf("parsing changes of profile version %d: %w", v.Version, err)
+		}
+		versions = append(versions, v)
 	}
-	return results, rows.Err()
+	return versions, rows.Err()
 }
 
-// --- Jobs ---
-
-func (s *Store) EnqueueJob(ctx context.Context, job Job) error {
-	now := time.Now().UTC().Format(time.RFC3339)
-	runAfter := now
-	if !job.RunAfter.IsZero() {
-		runAfter = job.RunAfter.UTC().Format(time.RFC3339)
+// GetProfileSnapshot returns every profile key as of version. Version 0 is
+// the empty profile. Returns ErrNotFound for versions that do not exist or
+// belong to another persona.
+func (p *PersonaProfile) GetProfileSnapshot(version int64) (map[string]string, error) {
+	if version == 0 {
+		return map[string]string{}, nil
 	}
-	maxAttempts := job.MaxAttempts
-	if maxAttempts == 0 {
-		maxAttempts = 3
+	var raw string
+	err := p.db.QueryRow(`SELECT snapshot FROM profile_versions WHERE version = ? AND persona = ?`, version, p.persona).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
 	}
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO jobs (id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at)
-		VALUES (?, ?, ?, 'pending', 0, ?, ?, ?, ?)`,
-		job.ID, job.Type, job.PayloadJSON, maxAttempts, runAfter, now, now,
-	)
-	return err
-}
-
-func (s *Store) ClaimNextJob(types []string) (*Job, error) {
-	if len(types) == 0 {
-		return nil, nil
+	if err != nil {
+		return nil, fmt.Errorf("loading profile version %d: %w", version, err)
 	}
-
-	now := time.Now().UTC().Format(time.RFC3339)
-	placeholders := strings.Repeat(",?", len(types)-1)
-	query := `SELECT id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at, last_error
-		FROM jobs
-		WHERE status = 'pending' AND run_after <= ? AND type IN (?` + placeholders + `)
-		ORDER BY run_after ASC, created_at ASC
-		LIMIT 1`
-
-	args := make([]any, 0, len(types)+1)
-	args = append(args, now)
-	for _, t := range types {
-		args = append(args, t)
+	snapshot := make(map[string]string)
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		return nil, fmt.Errorf("parsing profile version %d: %w", version, err)
 	}
+	return snapshot, nil
+}
 
-	tx, err := s.db.Begin()
+// DiffProfileVersions returns the key-level changes that turn version from
+// into version to, sorted by key.
+func (p *PersonaProfile) DiffProfileVersions(from, to int64) ([]ProfileFieldChange, error) {
+	a, err := p.GetProfileSnapshot(from)
 	if err != nil {
-		return nil, fmt.Errorf("beginning claim transaction: %w", err)
-	}
-
-	var j Job
-	var runAfter, createdAt, updatedAt string
-	var lastError sql.NullString
-	err = tx.QueryRow(query, args...).Scan(
-		&j.ID, &j.Type, &j.PayloadJSON, &j.Status, &j.Attempts, &j.MaxAttempts,
-		&runAfter, &createdAt, &updatedAt, &lastError,
-	)
-	if err == sql.ErrNoRows {
-		tx.Rollback()
-		return nil, nil
+		return nil, err
 	}
+	b, err := p.GetProfileSnapshot(to)
 	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("selecting next job: %w", err)
+		return nil, err
 	}
+	return diffProfileKeys(a, b), nil
+}
 
-	res, err := tx.Exec(`UPDATE jobs SET status = 'running', updated_at = ? WHERE id = ? AND status = 'pending'`, now, j.ID)
-	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("updating job status: %w", err)
-	}
-	n, err := res.RowsAffected()
+func profileKeysTx(tx *sql.Tx, persona string) (map[string]string, error) {
+	rows, err := tx.Query(`SELECT key, value FROM user_profile WHERE persona = ?`, persona)
 	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("checking updated job rows: %w", err)
+		return nil, fmt.Errorf("loading profile keys: %w", err)
 	}
-	if n != 1 {
-		tx.Rollback()
-		return nil, nil
-	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing claim: %w", err)
+	defer rows.Close()
+	keys := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		keys[k] = v
 	}
+	return keys, rows.Err()
+}
 
-	j.Status = "running"
-	j.LastError = lastError.String
-	if j.RunAfter, err = time.Parse(time.RFC3339, runAfter); err != nil {
-		return nil, fmt.Errorf("parsing run_after for job %s: %w", j.ID, err)
-	}
-	if j.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
-		return nil, fmt.Errorf("parsing created_at for job %s: %w", j.ID, err)
+// diffProfileKeys compares two profile snapshots key by key.
+func diffProfileKeys(from, to map[string]string) []ProfileFieldChange {
+	var changes []ProfileFieldChange
+	for k, old := range from {
+		nv, ok := to[k]
+		switch {
+		case !ok:
+			changes = append(changes, ProfileFieldChange{Key: k, Op: "delete", Old: old})
+		case nv != old:
+			changes = append(changes, ProfileFieldChange{Key: k, Op: "update", Old: old, New: nv})
+		}
 	}
-	if j.UpdatedAt, err = time.Parse(time.RFC3339, now); err != nil {
-		return nil, fmt.Errorf("parsing updated_at for job %s: %w", j.ID, err)
+	for k, nv := range to {
+		if _, ok := from[k]; !ok {
+			changes = append(changes, ProfileFieldChange{Key: k, Op: "add", New: nv})
+		}
 	}
-	return &j, nil
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
 }
 
-func (s *Store) CompleteJob(id string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
-	res, err := s.db.Exec(`UPDATE jobs SET status = 'completed', updated_at = ? WHERE id = ?`, now, id)
-	if err != nil {
-		return err
-	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if n == 0 {
-		return ErrNotFound
+// --- Personas ---
+
+// selectorValue normalizes a selector for storage and lookup. Tokens are
+// stored as SHA-256 hex so the database never holds them in the clear.
+func selectorValue(kind, value string) string {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case SelectorToken:
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	case SelectorClient:
+		return strings.ToLower(value)
+	default:
+		return value
 	}
-	return nil
 }
 
-func (s *Store) FailJob(id string, errMsg string) error {
+// CreatePersona stores a persona with its client and model selectors.
+// Returns ErrConflict if the name or any selector is already taken.
+func (s *Store) CreatePersona(p Persona) error {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("beginning fail transaction: %w", err)
+		return fmt.Errorf("beginning persona insert: %w", err)
 	}
 	defer tx.Rollback()
 
-	var attempts, maxAttempts int
-	err = tx.QueryRow(`SELECT attempts, max_attempts FROM jobs WHERE id = ?`, id).Scan(&attempts, &maxAttempts)
-	if err == sql.ErrNoRows {
-		return ErrNotFound
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM personas WHERE name = ?`, p.Name).Scan(&exists); err != nil {
+		return fmt.Errorf("checking persona %q: %w", p.Name, err)
 	}
-	if err != nil {
-		return err
+	if exists > 0 {
+		return fmt.Errorf("persona %q: %w", p.Name, ErrConflict)
 	}
-
-	now := time.Now().UTC()
-	attempts++
-
-	if attempts >= maxAttempts {
-		_, err = tx.Exec(`UPDATE jobs SET status = 'failed', attempts = ?, last_error = ?, updated_at = ? WHERE id = ?`,
-			attempts, errMsg, now.Format(time.RFC3339), id)
-	} else {
-		backoff := time.Duration(math.Pow(2, float64(attempts))) * time.Second
-		runAfter := now.Add(backoff)
-		_, err = tx.Exec(`UPDATE jobs SET status = 'pending', attempts = ?, last_error = ?, run_after = ?, updated_at = ? WHERE id = ?`,
-			attempts, errMsg, runAfter.Format(time.RFC3339), now.Format(time.RFC3339), id)
+	createdAt := p.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if _, err := tx.Exec(`INSERT INTO personas (name, description, partitioned, created_at) VALUES (?, ?, ?, ?)`,
+		p.Name, p.Description, p.Partitioned, createdAt.UTC().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("inserting persona %q: %w", p.Name, err)
+	}
+	for _, c := range p.Clients {
+		if err := addSelectorTx(tx, SelectorClient, c, p.Name); err != nil {
+			return err
+		}
+	}
+	for _, m := range p.Models {
+		if err := addSelectorTx(tx, SelectorModel, m, p.Name); err != nil {
+			return err
+		}
 	}
+	return tx.Commit()
+}
 
+// AddPersonaToken registers an API token that selects persona. Only the
+// token's hash is stored.
+func (s *Store) AddPersonaToken(persona, token string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
+		return fmt.Errorf("beginning token insert: %w", err)
+	}
+	defer tx.Rollback()
+	if err := addSelectorTx(tx, SelectorToken, token, persona); err != nil {
 		return err
 	}
-
 	return tx.Commit()
 }
 
-// --- Additional Methods ---
-
-func (s *Store) DeleteContextDoc(id string) error {
-	res, err := s.db.Exec(`DELETE FROM context_docs WHERE id = ?`, id)
-	if err != nil {
-		return err
+func addSelectorTx(tx *sql.Tx, kind, value, persona string) error {
+	v := selectorValue(kind, value)
+	var owner string
+	err := tx.QueryRow(`SELECT persona FROM persona_selectors WHERE kind = ? AND value = ?`, kind, v).Scan(&owner)
+	if err == nil {
+		return fmt.Errorf("%s %q already selects persona %q: %w", kind, value, owner, ErrConflict)
 	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("checking %s selector: %w", kind, err)
 	}
-	if n == 0 {
-		return ErrNotFound
+	if _, err := tx.Exec(`INSERT INTO persona_selectors (kind, value, persona) VALUES (?, ?, ?)`, kind, v, persona); err != nil {
+		return fmt.Errorf("inserting %s selector: %w", kind, err)
 	}
 	return nil
 }
 
-func (s *Store) DeleteInteraction(id string) error {
-	res, err := s.db.Exec(`DELETE FROM interactions WHERE id = ?`, id)
+// GetPersona returns the named persona with its selectors. Token selectors
+// are only counted.
+func (s *Store) GetPersona(name string) (Persona, error) {
+	var p Persona
+	var createdAt string
+	err := s.db.QueryRow(`SELECT name, description, partitioned, created_at FROM personas WHERE name = ?`, name).
+		Scan(&p.Name, &p.Description, &p.Partitioned, &createdAt)
+	if err == sql.ErrNoRows {
+		return Persona{}, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return Persona{}, err
 	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
+	if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		p.CreatedAt = t
 	}
-	if n == 0 {
-		return ErrNotFound
+	if err := s.loadPersonaSelectors(&p); err != nil {
+		return Persona{}, err
 	}
-	return nil
+	return p, nil
 }
 
-func (s *Store) ListInteractions(limit, offset int) ([]Interaction, error) {
-	rows, err := s.db.Query(`
-		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids
-		FROM interactions ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset,
-	)
+// ListPersonas returns every persona, the default first and the rest by name.
+func (s *Store) ListPersonas() ([]Persona, error) {
+	rows, err := s.db.Query(`SELECT name FROM personas ORDER BY name != ?, name`, DefaultPersona)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var results []Interaction
+	var names []string
 	for rows.Next() {
-		var i Interaction
-		var createdAt string
-		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs); err != nil {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		t, err := time.Parse(time.RFC3339, createdAt)
+		names = append(names, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	personas := make([]Persona, 0, len(names))
+	for _, n := range names {
+		p, err := s.GetPersona(n)
 		if err != nil {
-			return nil, fmt.Errorf("parsing created_at: %w", err)
+			return nil, err
 		}
-		i.CreatedAt = t
-		results = append(results, i)
+		personas = append(personas, p)
 	}
-	return results, rows.Err()
+	return personas, nil
 }
 
-func (s *Store) ListContextDocsPaginated(limit, offset int) ([]ContextDoc, error) {
-	rows, err := s.db.Query(`
-		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata
-		FROM context_docs ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset,
-	)
+func (s *Store) loadPersonaSelectors(p *Persona) error {
+	rows, err := s.db.Query(`SELECT kind, value FROM persona_selectors WHERE persona = ? ORDER BY kind, value`, p.Name)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("loading selectors of persona %q: %w", p.Name, err)
 	}
 	defer rows.Close()
-
-	var results []ContextDoc
 	for rows.Next() {
-		var d ContextDoc
-		var createdAt string
-		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata); err != nil {
-			return nil, err
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			return err
 		}
-		t, err := time.Parse(time.RFC3339, createdAt)
-		if err != nil {
-			return nil, fmt.Errorf("parsing created_at: %w", err)
+		switch kind {
+		case SelectorToken:
+			p.Tokens++
+		case SelectorClient:
+			p.Clients = append(p.Clients, value)
+		case SelectorModel:
+			p.Models = append(p.Models, value)
 		}
-		d.CreatedAt = t
-		results = append(results, d)
 	}
-	return results, rows.Err()
+	return rows.Err()
 }
 
-func (s *Store) UpdateInteractionVectorIDs(id, vectorIDsJSON string) error {
-	res, err := s.db.Exec(`UPDATE interactions SET vector_ids = ? WHERE id = ?`, vectorIDsJSON, id)
+// DeletePersona removes a persona with its selectors, profile and profile
+// history, and the documents, vectors and interactions in its knowledge-base
+// partition, so a persona later created with the same name starts empty. It
+// returns the IDs of the removed documents, vectors and interactions so that
+// cached enrichments built from them can be invalidated. The default persona
+// cannot be deleted.
+func (s *Store) DeletePersona(name string) ([]string, error) {
+	if name == DefaultPersona {
+		return nil, fmt.Errorf("the %s persona cannot be deleted", DefaultPersona)
+	}
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("beginning persona delete: %w", err)
 	}
-	n, err := res.RowsAffected()
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM personas WHERE name = ?`, name)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("deleting persona %q: %w", name, err)
 	}
-	if n == 0 {
-		return ErrNotFound
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	for _, q := range []string{
+		`DELETE FROM persona_selectors WHERE persona = ?`,
+		`DELETE FROM user_profile WHERE persona = ?`,
+		`DELETE FROM profile_versions WHERE persona = ?`,
+	} {
+		if _, err := tx.Exec(q, name); err != nil {
+			return nil, fmt.Errorf("deleting persona %q: %w", name, err)
+		}
 	}
-	return nil
-}
 
-func (s *Store) UpdateContextDocVectorID(id, vectorID string) error {
-	res, err := s.db.Exec(`UPDATE context_docs SET vector_id = ? WHERE id = ?`, vectorID, id)
-	if err != nil {
-		return err
+	var removed []string
+	for _, table := range []string{"context_docs", "context_vectors", "interactions"} {
+		ids, err := partitionIDs(tx, table, name)
+		if err != nil {
+			return nil, fmt.Errorf("listing persona %q %s: %w", name, table, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE kb_partition = ?`, name); err != nil {
+			return nil, fmt.Errorf("deleting persona %q %s: %w", name, table, err)
+		}
+		removed = append(removed, ids...)
 	}
-	n, err := res.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// partitionIDs returns the IDs of the rows of table in a knowledge-base
+// partition.
+func partitionIDs(tx *sql.Tx, table, partition string) ([]string, error) {
+	rows, err := tx.Query(`SELECT id FROM `+table+` WHERE kb_partition = ?`, partition)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if n == 0 {
-		return ErrNotFound
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
-	return nil
+	return ids, rows.Err()
 }
 
-func (s *Store) CountContextDocs() (int, error) {
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM context_docs`).Scan(&count)
-	return count, err
+// ResolvePersona returns the persona selected by a token, client or model.
+// Returns ErrNotFound when no persona claims the selector.
+func (s *Store) ResolvePersona(kind, value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", ErrNotFound
+	}
+	var persona string
+	err := s.db.QueryRow(`SELECT persona FROM persona_selectors WHERE kind = ? AND value = ?`,
+		kind, selectorValue(kind, value)).Scan(&persona)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolving %s selector: %w", kind, err)
+	}
+	return persona, nil
 }
 
-func (s *Store) CountInteractions() (int, error) {
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM interactions`).Scan(&count)
-	return count, err
+// --- Projects ---
+
+// normalizeRoot cleans a project root for storage and prefix matching.
+func normalizeRoot(path string) string {
+	return filepath.Clean(strings.TrimSpace(path))
 }
 
-// UpdateContextDocTagsAndDeepMetadata updates tags and deep_metadata atomically.
-func (s *Store) UpdateContextDocTagsAndDeepMetadata(id, tags, deepMetadataJSON string) error {
-	res, err := s.db.Exec(`UPDATE context_docs SET tags = ?, deep_metadata = ? WHERE id = ?`, tags, deepMetadataJSON, id)
+// CreateProject stores a project with its roots. Returns ErrConflict if the
+// name or any root is already taken.
+func (s *Store) CreateProject(p Project) error {
+	stack := p.Stack
+	if stack == nil {
+		stack = []string{}
+	}
+	stackJSON, err := json.Marshal(stack)
 	if err != nil {
-		return err
+		return fmt.Errorf("marshaling stack: %w", err)
 	}
-	n, err := res.RowsAffected()
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return fmt.Errorf("beginning project insert: %w", err)
 	}
-	if n == 0 {
-		return ErrNotFound
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM projects WHERE name = ?`, p.Name).Scan(&exists); err != nil {
+		return fmt.Errorf("checking project %q: %w", p.Name, err)
 	}
-	return nil
+	if exists > 0 {
+		return fmt.Errorf("project %q: %w", p.Name, ErrConflict)
+	}
+	createdAt := p.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if _, err := tx.Exec(`INSERT INTO projects (name, description, stack, created_at) VALUES (?, ?, ?, ?)`,
+		p.Name, p.Description, string(stackJSON), createdAt.UTC().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("inserting project %q: %w", p.Name, err)
+	}
+	for _, root := range p.Roots {
+		root = normalizeRoot(root)
+		var owner string
+		err := tx.QueryRow(`SELECT project FROM project_roots WHERE path = ?`, root).Scan(&owner)
+		if err == nil {
+			return fmt.Errorf("root %q already belongs to project %q: %w", root, owner, ErrConflict)
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("checking root %q: %w", root, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO project_roots (path, project) VALUES (?, ?)`, root, p.Name); err != nil {
+			return fmt.Errorf("inserting root %q: %w", root, err)
+		}
+	}
+	return tx.Commit()
 }
 
-// ClaimJobs claims up to limit pending jobs of the given types in a single transaction.
-// The transaction is required: it ensures the SELECT + UPDATE pair is atomic so no
-// two workers can claim the same job (prevents double-processing on concurrent calls).
-// Returns the claimed jobs with status set to "running".
-func (s *Store) ClaimJobs(types []string, limit int) ([]Job, error) {
-	if len(types) == 0 || limit <= 0 {
-		return nil, nil
+// GetProject returns the named project with its roots.
+func (s *Store) GetProject(name string) (Project, error) {
+	var p Project
+	var stack, createdAt string
+	err := s.db.QueryRow(`SELECT name, description, stack, created_at FROM projects WHERE name = ?`, name).
+		Scan(&p.Name, &p.Description, &stack, &createdAt)
+	if err == sql.ErrNoRows {
+		return Project{}, ErrNotFound
+	}
+	if err != nil {
+		return Project{}, err
+	}
+	if err := json.Unmarshal([]byte(stack), &p.Stack); err != nil {
+		return Project{}, fmt.Errorf("decoding stack of project %q: %w", name, err)
+	}
+	if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		p.CreatedAt = t
 	}
 
-	nowTime := time.Now().UTC()
-	now := nowTime.Format(time.RFC3339)
-	placeholders := strings.Repeat(",?", len(types)-1)
-	query := `SELECT id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at, last_error
-		FROM jobs
-		WHERE status = 'pending' AND run_after <= ? AND type IN (?` + placeholders + `)
-		ORDER BY run_after ASC, created_at ASC
-		LIMIT ?`
+	rows, err := s.db.Query(`SELECT path FROM project_roots WHERE project = ? ORDER BY path`, name)
+	if err != nil {
+		return Project{}, fmt.Errorf("loading roots of project %q: %w", name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var root string
+		if err := rows.Scan(&root); err != nil {
+			return Project{}, err
+		}
+		p.Roots = append(p.Roots, root)
+	}
+	return p, rows.Err()
+}
 
-	args := make([]any, 0, len(types)+2)
-	args = append(args, now)
-	for _, t := range types {
-		args = append(args, t)
+// ListProjects returns every project ordered by name.
+func (s *Store) ListProjects() ([]Project, error) {
+	rows, err := s.db.Query(`SELECT name FROM projects ORDER BY name`)
+	if err != nil {
+		return nil, err
 	}
-	args = append(args, limit)
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	projects := make([]Project, 0, len(names))
+	for _, n := range names {
+		p, err := s.GetProject(n)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
 
+// DeleteProject removes a project and its roots. Its documents are kept and
+// become project-less.
+func (s *Store) DeleteProject(name string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("beginning claim transaction: %w", err)
+		return fmt.Errorf("beginning project delete: %w", err)
 	}
 	defer tx.Rollback()
 
-	rows, err := tx.Query(query, args...)
+	res, err := tx.Exec(`DELETE FROM projects WHERE name = ?`, name)
 	if err != nil {
-		return nil, fmt.Errorf("selecting pending jobs: %w", err)
+		return fmt.Errorf("deleting project %q: %w", name, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
 	}
+	for _, q := range []string{
+		`DELETE FROM project_roots WHERE project = ?`,
+		`UPDATE context_docs SET project = '' WHERE project = ?`,
+		`UPDATE context_vectors SET project = '' WHERE project = ?`,
+	} {
+		if _, err := tx.Exec(q, name); err != nil {
+			return fmt.Errorf("deleting project %q: %w", name, err)
+		}
+	}
+	return tx.Commit()
+}
 
-	var jobs []Job
+// ResolveProjectByPath returns the project whose root contains path, picking
+// the deepest root when roots are nested. Returns ErrNotFound when no root
+// contains it.
+func (s *Store) ResolveProjectByPath(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", ErrNotFound
+	}
+	path = normalizeRoot(path)
+	rows, err := s.db.Query(`SELECT path, project FROM project_roots`)
+	if err != nil {
+		return "", fmt.Errorf("loading project roots: %w", err)
+	}
+	defer rows.Close()
+
+	var best, bestRoot string
 	for rows.Next() {
-		var j Job
-		var runAfter, createdAt, updatedAt string
-		var lastError sql.NullString
-		if err := rows.Scan(
-			&j.ID, &j.Type, &j.PayloadJSON, &j.Status, &j.Attempts, &j.MaxAttempts,
-			&runAfter, &createdAt, &updatedAt, &lastError,
-		); err != nil {
-			rows.Close()
-			return nil, fmt.Errorf("scanning job row: %w", err)
-		}
-		j.LastError = lastError.String
-		var parseErr error
-		if j.RunAfter, parseErr = time.Parse(time.RFC3339, runAfter); parseErr != nil {
-			rows.Close()
-			return nil, fmt.Errorf("parsing run_after: %w", parseErr)
+		var root, project string
+		if err := rows.Scan(&root, &project); err != nil {
+			return "", err
 		}
-		if j.CreatedAt, parseErr = time.Parse(time.RFC3339, createdAt); parseErr != nil {
-			rows.Close()
-			return nil, fmt.Errorf("parsing created_at: %w", parseErr)
+		if !pathWithin(path, root) || len(root) <= len(bestRoot) {
+			continue
 		}
-		jobs = append(jobs, j)
+		best, bestRoot = project, root
 	}
-	rows.Close()
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating job rows: %w", err)
+		return "", err
+	}
+	if best == "" {
+		return "", ErrNotFound
+	}
+	return best, nil
+}
+
+// pathWithin reports whether path is root or lies beneath it.
+func pathWithin(path, root string) bool {
+	if path == root || root == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// --- Context Docs ---
+
+func (s *Store) SaveContextDoc(doc ContextDoc) error {
+	metadata := doc.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
+	deepMetadata := doc.DeepMetadata
+	if deepMetadata == "" {
+		deepMetadata = "{}"
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO context_docs (id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.Title, doc.Content, doc.Source, doc.Tags,
+		doc.CreatedAt.UTC().Format(time.RFC3339), doc.VectorID, metadata, deepMetadata, doc.Partition, doc.Project,
+	)
+	return err
+}
+
+func (s *Store) GetContextDoc(id string) (ContextDoc, error) {
+	var d ContextDoc
+	var createdAt string
+	err := s.db.QueryRow(`
+		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project
+		FROM context_docs WHERE id = ?`, id,
+	).Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata, &d.Partition, &d.Project)
+	if err == sql.ErrNoRows {
+		return ContextDoc{}, ErrNotFound
+	}
+	if err != nil {
+		return ContextDoc{}, err
+	}
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return ContextDoc{}, fmt.Errorf("parsing created_at: %w", err)
 	}
+	d.CreatedAt = t
+	return d, nil
+}
+
+func (s *Store) ListContextDocs(limit int) ([]ContextDoc, error) {
+	rows, err := s.db.Query(`
+		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project
+		FROM context_docs ORDER BY created_at DESC LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ContextDoc
+	for rows.Next() {
+		var d ContextDoc
+		var createdAt string
+		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata, &d.Partition, &d.Project); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing created_at: %w", err)
+		}
+		d.CreatedAt = t
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// --- Jobs ---
+
+func (s *Store) EnqueueJob(ctx context.Context, job Job) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	runAfter := now
+	if !job.RunAfter.IsZero() {
+		runAfter = job.RunAfter.UTC().Format(time.RFC3339)
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO jobs (id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at)
+		VALUES (?, ?, ?, 'pending', 0, ?, ?, ?, ?)`,
+		job.ID, job.Type, job.PayloadJSON, maxAttempts, runAfter, now, now,
+	)
+	return err
+}
+
+func (s *Store) ClaimNextJob(types []string) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	placeholders := strings.Repeat(",?", len(types)-1)
+	query := `SELECT id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at, last_error
+		FROM jobs
+		WHERE status = 'pending' AND run_after <= ? AND type IN (?` + placeholders + `)
+		ORDER BY run_after ASC, created_at ASC
+		LIMIT 1`
+
+	args := make([]any, 0, len(types)+1)
+	args = append(args, now)
+	for _, t := range types {
+		args = append(args, t)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning claim transaction: %w", err)
+	}
+
+	var j Job
+	var runAfter, createdAt, updatedAt string
+	var lastError sql.NullString
+	err = tx.QueryRow(query, args...).Scan(
+		&j.ID, &j.Type, &j.PayloadJSON, &j.Status, &j.Attempts, &j.MaxAttempts,
+		&runAfter, &createdAt, &updatedAt, &lastError,
+	)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("selecting next job: %w", err)
+	}
+
+	res, err := tx.Exec(`UPDATE jobs SET status = 'running', updated_at = ? WHERE id = ? AND status = 'pending'`, now, j.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("updating job status: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("checking updated job rows: %w", err)
+	}
+	if n != 1 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing claim: %w", err)
+	}
+
+	j.Status = "running"
+	j.LastError = lastError.String
+	if j.RunAfter, err = time.Parse(time.RFC3339, runAfter); err != nil {
+		return nil, fmt.Errorf("parsing run_after for job %s: %w", j.ID, err)
+	}
+	if j.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return nil, fmt.Errorf("parsing created_at for job %s: %w", j.ID, err)
+	}
+	if j.UpdatedAt, err = time.Parse(time.RFC3339, now); err != nil {
+		return nil, fmt.Errorf("parsing updated_at for job %s: %w", j.ID, err)
+	}
+	return &j, nil
+}
+
+func (s *Store) CompleteJob(id string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := s.db.Exec(`UPDATE jobs SET status = 'completed', updated_at = ? WHERE id = ?`, now, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) FailJob(id string, errMsg string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning fail transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attempts, maxAttempts int
+	err = tx.QueryRow(`SELECT attempts, max_attempts FROM jobs WHERE id = ?`, id).Scan(&attempts, &maxAttempts)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	attempts++
+
+	if attempts >= maxAttempts {
+		_, err = tx.Exec(`UPDATE jobs SET status = 'failed', attempts = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+			attempts, errMsg, now.Format(time.RFC3339), id)
+	} else {
+		backoff := time.Duration(math.Pow(2, float64(attempts))) * time.Second
+		runAfter := now.Add(backoff)
+		_, err = tx.Exec(`UPDATE jobs SET status = 'pending', attempts = ?, last_error = ?, run_after = ?, updated_at = ? WHERE id = ?`,
+			attempts, errMsg, runAfter.Format(time.RFC3339), now.Format(time.RFC3339), id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListJobs returns jobs newest first, optionally filtered by type and status.
+func (s *Store) ListJobs(jobType, status string, limit, offset int) ([]Job, error) {
+	query := `SELECT id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at, last_error
+		FROM jobs WHERE 1=1`
+	var args []any
+	if jobType != "" {
+		query += ` AND type = ?`
+		args = append(args, jobType)
+	}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, id LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var runAfter, createdAt, updatedAt string
+		var lastError sql.NullString
+		if err := rows.Scan(
+			&j.ID, &j.Type, &j.PayloadJSON, &j.Status, &j.Attempts, &j.MaxAttempts,
+			&runAfter, &createdAt, &updatedAt, &lastError,
+		); err != nil {
+			return nil, err
+		}
+		j.LastError = lastError.String
+		if j.RunAfter, err = time.Parse(time.RFC3339, runAfter); err != nil {
+			return nil, fmt.Errorf("parsing run_after for job %s: %w", j.ID, err)
+		}
+		if j.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("parsing created_at for job %s: %w", j.ID, err)
+		}
+		if j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+			return nil, fmt.Errorf("parsing updated_at for job %s: %w", j.ID, err)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// RetryJob makes a failed job due now with a fresh set of attempts and no
+// last error. Returns ErrJobRunning if a worker holds it, ErrJobNotFailed if
+// it is pending or completed, or ErrNotFound.
+func (s *Store) RetryJob(id string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := s.db.Exec(`
+		UPDATE jobs SET status = 'pending', attempts = 0, last_error = NULL, run_after = ?, updated_at = ?
+		WHERE id = ? AND status = 'failed'`, now, now, id)
+	if err != nil {
+		return err
+	}
+	return s.checkJobChanged(res, id)
+}
+
+// DeleteJob removes a job that is not running. Returns ErrJobRunning if a
+// worker holds it, or ErrNotFound.
+func (s *Store) DeleteJob(id string) error {
+	res, err := s.db.Exec(`DELETE FROM jobs WHERE id = ? AND status != 'running'`, id)
+	if err != nil {
+		return err
+	}
+	return s.checkJobChanged(res, id)
+}
+
+// checkJobChanged maps a statement that matched no job with id to
+// ErrNotFound, ErrJobRunning if the job is running, or ErrJobNotFailed.
+func (s *Store) checkJobChanged(res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var status string
+	err = s.db.QueryRow(`SELECT status FROM jobs WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == "running" {
+		return ErrJobRunning
+	}
+	return fmt.Errorf("%w: it is %s", ErrJobNotFailed, status)
+}
+
+// --- Additional Methods ---
+
+func (s *Store) DeleteContextDoc(id string) error {
+	res, err := s.db.Exec(`DELETE FROM context_docs WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteInteraction(id string) error {
+	res, err := s.db.Exec(`DELETE FROM interactions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) ListInteractions(limit, offset int) ([]Interaction, error) {
+	rows, err := s.db.Query(`
+		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition
+		FROM interactions ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Interaction
+	for rows.Next() {
+		var i Interaction
+		var createdAt string
+		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs, &i.IntentType, &i.RetrievalLegs, &i.SearchStrategy, &i.CachedFrom, &i.Persona, &i.Partition); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing created_at: %w", err)
+		}
+		i.CreatedAt = t
+		results = append(results, i)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) ListContextDocsPaginated(limit, offset int) ([]ContextDoc, error) {
+	rows, err := s.db.Query(`
+		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project
+		FROM context_docs ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ContextDoc
+	for rows.Next() {
+		var d ContextDoc
+		var createdAt string
+		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata, &d.Partition, &d.Project); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing created_at: %w", err)
+		}
+		d.CreatedAt = t
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+func (s *Store) UpdateInteractionVectorIDs(id, vectorIDsJSON string) error {
+	res, err := s.db.Exec(`UPDATE interactions SET vector_ids = ? WHERE id = ?`, vectorIDsJSON, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) UpdateContextDocVectorID(id, vectorID string) error {
+	res, err := s.db.Exec(`UPDATE context_docs SET vector_id = ? WHERE id = ?`, vectorID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CountContextDocs() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM context_docs`).Scan(&count)
+	return count, err
+}
+
+func (s *Store) CountInteractions() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM interactions`).Scan(&count)
+	return count, err
+}
+
+// UpdateContextDocTagsAndDeepMetadata updates tags and deep_metadata atomically.
+func (s *Store) UpdateContextDocTagsAndDeepMetadata(id, tags, deepMetadataJSON string) error {
+	res, err := s.db.Exec(`UPDATE context_docs SET tags = ?, deep_metadata = ? WHERE id = ?`, tags, deepMetadataJSON, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimJobs claims up to limit pending jobs of the given types in a single transaction.
+// The transaction is required: it ensures the SELECT + UPDATE pair is atomic so no
+// two workers can claim the same job (prevents double-processing on concurrent calls).
+// Returns the claimed jobs with status set to "running".
+func (s *Store) ClaimJobs(types []string, limit int) ([]Job, error) {
+	if len(types) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	nowTime := time.Now().UTC()
+	now := nowTime.Format(time.RFC3339)
+	placeholders := strings.Repeat(",?", len(types)-1)
+	query := `SELECT id, type, payload_json, status, attempts, max_attempts, run_after, created_at, updated_at, last_error
+		FROM jobs
+		WHERE status = 'pending' AND run_after <= ? AND type IN (?` + placeholders + `)
+		ORDER BY run_after ASC, created_at ASC
+		LIMIT ?`
+
+	args := make([]any, 0, len(types)+2)
+	args = append(args, now)
+	for _, t := range types {
+		args = append(args, t)
+	}
+	args = append(args, limit)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning claim transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("selecting pending jobs: %w", err)
+	}
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var runAfter, createdAt, updatedAt string
+		var lastError sql.NullString
+		if err := rows.Scan(
+			&j.ID, &j.Type, &j.PayloadJSON, &j.Status, &j.Attempts, &j.MaxAttempts,
+			&runAfter, &createdAt, &updatedAt, &lastError,
+		); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning job row: %w", err)
+		}
+		j.LastError = lastError.String
+		var parseErr error
+		if j.RunAfter, parseErr = time.Parse(time.RFC3339, runAfter); parseErr != nil {
+			rows.Close()
+			return nil, fmt.Errorf("parsing run_after: %w", parseErr)
+		}
+		if j.CreatedAt, parseErr = time.Parse(time.RFC3339, createdAt); parseErr != nil {
+			rows.Close()
+			return nil, fmt.Errorf("parsing created_at: %w", parseErr)
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating job rows: %w", err)
+	}
+
+	// Mark each selected job as running.
+	for i := range jobs {
+		res, err := tx.Exec(
+			`UPDATE jobs SET status = 'running', updated_at = ? WHERE id = ? AND status = 'pending'`,
+			now, jobs[i].ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("claiming job %s: %w", jobs[i].ID, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("checking claimed rows for job %s: %w", jobs[i].ID, err)
+		}
+		if n == 1 {
+			jobs[i].Status = "running"
+			jobs[i].UpdatedAt = nowTime
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing claim transaction: %w", err)
+	}
+
+	// Return only successfully claimed jobs (status == "running").
+	claimed := jobs[:0]
+	for _, j := range jobs {
+		if j.Status == "running" {
+			claimed = append(claimed, j)
+		}
+	}
+	return claimed, nil
+}
+
+// ResetStaleJobs resets jobs stuck in 'running' for longer than timeout back to 'pending'.
+// Jobs that have exceeded max_attempts are set to 'failed' instead.
+// Returns the count of jobs reset.
+func (s *Store) ResetStaleJobs(jobTypes []string, timeout time.Duration) (int, error) {
+	if len(jobTypes) == 0 {
+		return 0, nil
+	}
+
+	threshold := time.Now().UTC().Add(-timeout).Format(time.RFC3339)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	placeholders := strings.Repeat(",?", len(jobTypes)-1)
+
+	// Select stale running jobs.
+	query := `SELECT id, attempts, max_attempts FROM jobs
+		WHERE status = 'running' AND type IN (?` + placeholders + `) AND updated_at < ?`
+
+	args := make([]any, 0, len(jobTypes)+1)
+	for _, t := range jobTypes {
+		args = append(args, t)
+	}
+	args = append(args, threshold)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("querying stale jobs: %w", err)
+	}
+
+	type staleJob struct {
+		id          string
+		attempts    int
+		maxAttempts int
+	}
+	var stale []staleJob
+	for rows.Next() {
+		var j staleJob
+		if err := rows.Scan(&j.id, &j.attempts, &j.maxAttempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning stale job: %w", err)
+		}
+		stale = append(stale, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterating stale jobs: %w", err)
+	}
+
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning reset transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	resetCount := 0
+	for _, j := range stale {
+		newAttempts := j.attempts + 1
+		var newStatus string
+		if newAttempts >= j.maxAttempts {
+			newStatus = "failed"
+		} else {
+			newStatus = "pending"
+		}
+		_, err := tx.Exec(
+			`UPDATE jobs SET status = ?, attempts = ?, updated_at = ? WHERE id = ? AND status = 'running'`,
+			newStatus, newAttempts, now, j.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("resetting stale job %s: %w", j.id, err)
+		}
+		resetCount++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing stale job reset: %w", err)
+	}
+	return resetCount, nil
+}
+
+// --- Knowledge graph ---
+
+// EntityKinds are the kinds an entity may have; others are stored as "other".
+var EntityKinds = []string{"person", "project", "technology", "organization", "place", "product", "concept", "other"}
+
+const (
+	maxEntityNameLen   = 200
+	maxRelationNameLen = 64
+	maxEntityDocIDs    = 100 // docs listed per entity in GetEntity
+)
+
+// NormalizeEntityName returns the form entity names and aliases are matched
+// in: lowercase with runs of whitespace collapsed to one space.
+func NormalizeEntityName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(truncateRunes(name, maxEntityNameLen))), " ")
+}
+
+// normalizeRelation returns a relation label in snake case, e.g. "works on"
+// becomes "works_on".
+func normalizeRelation(rel string) string {
+	return truncateRunes(strings.Join(strings.Fields(strings.ToLower(rel)), "_"), maxRelationNameLen)
+}
+
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+func entityKind(kind string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	for _, k := range EntityKinds {
+		if k == kind {
+			return kind
+		}
+	}
+	return "other"
+}
+
+// ReplaceDocGraph replaces what docID contributes to the knowledge graph with
+// g. Entities are matched to known ones by name or alias and created when
+// new; an entity known only as "other" takes the first specific kind reported
+// for it. Relation endpoints not among g's entities are resolved the same way.
+func (s *Store) ReplaceDocGraph(docID string, g DocGraph) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning graph transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		`DELETE FROM kg_mentions WHERE doc_id = ?`,
+		`DELETE FROM kg_relations WHERE doc_id = ?`,
+	} {
+		if _, err := tx.Exec(q, docID); err != nil {
+			return fmt.Errorf("clearing graph for doc %s: %w", docID, err)
+		}
+	}
+
+	mention := func(entityID string) error {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO kg_mentions (entity_id, doc_id) VALUES (?, ?)`, entityID, docID)
+		return err
+	}
+	for _, e := range g.Entities {
+		id, err := resolveEntity(tx, e.Name, e.Kind, e.Aliases, now)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			continue
+		}
+		if err := mention(id); err != nil {
+			return fmt.Errorf("recording mention of %q: %w", e.Name, err)
+		}
+	}
+	for _, r := range g.Relations {
+		rel := normalizeRelation(r.Relation)
+		if rel == "" {
+			continue
+		}
+		src, err := resolveEntity(tx, r.Source, "", nil, now)
+		if err != nil {
+			return err
+		}
+		tgt, err := resolveEntity(tx, r.Target, "", nil, now)
+		if err != nil {
+			return err
+		}
+		if src == "" || tgt == "" || src == tgt {
+			continue
+		}
+		for _, id := range []string{src, tgt} {
+			if err := mention(id); err != nil {
+				return fmt.Errorf("recording mention: %w", err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO kg_relations (source_id, target_id, relation, doc_id) VALUES (?, ?, ?, ?)`,
+			src, tgt, rel, docID); err != nil {
+			return fmt.Errorf("recording relation %s: %w", rel, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing graph for doc %s: %w", docID, err)
+	}
+	return nil
+}
+
+// resolveEntity returns the ID of the entity known by name or one of
+// aliases, creating it if none is, and records name and aliases as its
+// aliases. Returns "" for an empty name.
+func resolveEntity(tx *sql.Tx, name, kind string, aliases []string, now string) (string, error) {
+	key := NormalizeEntityName(name)
+	if key == "" {
+		return "", nil
+	}
+	keys := []string{key}
+	for _, a := range aliases {
+		if k := NormalizeEntityName(a); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	var id string
+	for _, k := range keys {
+		err := tx.QueryRow(`SELECT entity_id FROM kg_aliases WHERE alias = ?`, k).Scan(&id)
+		if err == nil {
+			break
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("resolving entity %q: %w", name, err)
+		}
+	}
+
+	kind = entityKind(kind)
+	if id == "" {
+		id = uuid.New().String()
+		if _, err := tx.Exec(`INSERT INTO kg_entities (id, name, kind, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			id, strings.TrimSpace(truncateRunes(name, maxEntityNameLen)), kind, now, now); err != nil {
+			return "", fmt.Errorf("creating entity %q: %w", name, err)
+		}
+	} else {
+		if _, err := tx.Exec(`UPDATE kg_entities SET kind = CASE WHEN kind = 'other' THEN ? ELSE kind END, updated_at = ? WHERE id = ?`,
+			kind, now, id); err != nil {
+			return "", fmt.Errorf("updating entity %q: %w", name, err)
+		}
+	}
+
+	for _, k := range keys {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO kg_aliases (alias, entity_id) VALUES (?, ?)`, k, id); err != nil {
+			return "", fmt.Errorf("recording alias %q: %w", k, err)
+		}
+	}
+	return id, nil
+}
+
+// docCond returns an SQL condition restricting the doc ID column col to docs
+// in the scope, with its arguments.
+func (g GraphScope) docCond(col string) (string, []any) {
+	if !g.Partitioned {
+		return "1", nil
+	}
+	return col + " IN (SELECT id FROM context_docs WHERE kb_partition = ?)", []any{g.Partition}
+}
+
+// ListEntities returns entities mentioned by at least one doc in scope, most
+// mentioned first. query matches any part of a name or alias; kind, when
+// set, restricts the kind.
+func (s *Store) ListEntities(scope GraphScope, query, kind string, limit, offset int) ([]Entity, error) {
+	pattern := ""
+	if q := NormalizeEntityName(query); q != "" {
+		r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+		pattern = "%" + r.Replace(q) + "%"
+	}
+	cond, args := scope.docCond("m.doc_id")
+	args = append(args, kind, kind, pattern, pattern, limit, offset)
+	rows, err := s.db.Query(`
+		SELECT e.id, e.name, e.kind, e.created_at, e.updated_at, COUNT(m.doc_id) AS mentions
+		FROM kg_entities e JOIN kg_mentions m ON m.entity_id = e.id
+		WHERE `+cond+`
+		  AND (? = '' OR e.kind = ?)
+		  AND (? = '' OR EXISTS (SELECT 1 FROM kg_aliases a WHERE a.entity_id = e.id AND a.alias LIKE ? ESCAPE '\'))
+		GROUP BY e.id
+		ORDER BY mentions DESC, e.name
+		LIMIT ? OFFSET ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Entity
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func scanEntity(sc scanner) (Entity, error) {
+	var e Entity
+	var createdAt, updatedAt string
+	if err := sc.Scan(&e.ID, &e.Name, &e.Kind, &createdAt, &updatedAt, &e.Mentions); err != nil {
+		return Entity{}, err
+	}
+	var err error
+	if e.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return Entity{}, fmt.Errorf("parsing created_at for entity %s: %w", e.ID, err)
+	}
+	if e.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+		return Entity{}, fmt.Errorf("parsing updated_at for entity %s: %w", e.ID, err)
+	}
+	return e, nil
+}
+
+// GetEntity returns an entity with its aliases, its relations strongest
+// first, and up to maxEntityDocIDs docs that mention it, counting only docs
+// in scope. Returns ErrNotFound if no doc in scope mentions it.
+func (s *Store) GetEntity(scope GraphScope, id string) (EntityDetail, error) {
+	mentionCond, mentionArgs := scope.docCond("m.doc_id")
+	e, err := scanEntity(s.db.QueryRow(`
+		SELECT e.id, e.name, e.kind, e.created_at, e.updated_at, COUNT(m.doc_id)
+		FROM kg_entities e JOIN kg_mentions m ON m.entity_id = e.id
+		WHERE e.id = ? AND `+mentionCond+`
+		GROUP BY e.id`, append([]any{id}, mentionArgs...)...))
+	if err == sql.ErrNoRows {
+		return EntityDetail{}, ErrNotFound
+	}
+	if err != nil {
+		return EntityDetail{}, err
+	}
+	d := EntityDetail{Entity: e, Aliases: []string{}, Relations: []EntityRelation{}, DocIDs: []string{}}
+
+	rows, err := s.db.Query(`SELECT alias FROM kg_aliases WHERE entity_id = ? ORDER BY alias`, id)
+	if err != nil {
+		return EntityDetail{}, err
+	}
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			rows.Close()
+			return EntityDetail{}, err
+		}
+		d.Aliases = append(d.Aliases, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return EntityDetail{}, err
+	}
+
+	relCond, relArgs := scope.docCond("r.doc_id")
+	args := append(append([]any{id}, relArgs...), id)
+	args = append(args, relArgs...)
+	rows, err = s.db.Query(`
+		SELECT r.relation, 'out', r.target_id, o.name, COUNT(*) AS weight
+		FROM kg_relations r JOIN kg_entities o ON o.id = r.target_id
+		WHERE r.source_id = ? AND `+relCond+`
+		GROUP BY r.relation, r.target_id
+		UNION ALL
+		SELECT r.relation, 'in', r.source_id, o.name, COUNT(*) AS weight
+		FROM kg_relations r JOIN kg_entities o ON o.id = r.source_id
+		WHERE r.target_id = ? AND `+relCond+`
+		GROUP BY r.relation, r.source_id
+		ORDER BY weight DESC, 4, 1`, args...)
+	if err != nil {
+		return EntityDetail{}, err
+	}
+	for rows.Next() {
+		var r EntityRelation
+		if err := rows.Scan(&r.Relation, &r.Direction, &r.OtherID, &r.OtherName, &r.Weight); err != nil {
+			rows.Close()
+			return EntityDetail{}, err
+		}
+		d.Relations = append(d.Relations, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return EntityDetail{}, err
+	}
+
+	docCond, docArgs := scope.docCond("doc_id")
+	args = append(append([]any{id}, docArgs...), maxEntityDocIDs)
+	rows, err = s.db.Query(`SELECT doc_id FROM kg_mentions WHERE entity_id = ? AND `+docCond+` ORDER BY doc_id LIMIT ?`, args...)
+	if err != nil {
+		return EntityDetail{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var docID string
+		if err := rows.Scan(&docID); err != nil {
+			return EntityDetail{}, err
+		}
+		d.DocIDs = append(d.DocIDs, docID)
+	}
+	return d, rows.Err()
+}
+
+// FindEntity returns the entity known by name or alias, as GetEntity does.
+func (s *Store) FindEntity(scope GraphScope, name string) (EntityDetail, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT entity_id FROM kg_aliases WHERE alias = ?`, NormalizeEntityName(name)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return EntityDetail{}, ErrNotFound
+	}
+	if err != nil {
+		return EntityDetail{}, err
+	}
+	return s.GetEntity(scope, id)
+}
+
+// RelatedEntities returns the names of up to limit entities related to any
+// of the named ones by docs in scope, most strongly related first. The named
+// entities themselves are excluded; unknown names are ignored.
+func (s *Store) RelatedEntities(ctx context.Context, scope GraphScope, names []string, limit int) ([]string, error) {
+	if len(names) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	aliases := make([]any, 0, len(names))
+	for _, n := range names {
+		if k := NormalizeEntityName(n); k != "" {
+			aliases = append(aliases, k)
+		}
+	}
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	in := "(SELECT entity_id FROM kg_aliases WHERE alias IN (?" + strings.Repeat(",?", len(aliases)-1) + "))"
+	cond, condArgs := scope.docCond("doc_id")
+	query := `
+		SELECT e.name FROM (
+			SELECT target_id AS other, COUNT(*) AS w FROM kg_relations WHERE source_id IN ` + in + ` AND ` + cond + ` GROUP BY target_id
+			UNION ALL
+			SELECT source_id AS other, COUNT(*) AS w FROM kg_relations WHERE target_id IN ` + in + ` AND ` + cond + ` GROUP BY source_id
+		) n JOIN kg_entities e ON e.id = n.other
+		WHERE n.other NOT IN ` + in + `
+		GROUP BY n.other
+		ORDER BY SUM(n.w) DESC, e.name
+		LIMIT ?`
+	args := make([]any, 0, 3*len(aliases)+2*len(condArgs)+1)
+	for range 2 {
+		args = append(args, aliases...)
+		args = append(args, condArgs...)
+	}
+	args = append(args, aliases...)
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
+
+// --- Memory consolidation ---
+
+// ListEpisodicMemories returns up to limit interaction summary vectors of
+// interactions created before before that have not been consolidated yet,
+// oldest first.
+func (s *Store) ListEpisodicMemories(before time.Time, limit int) ([]EpisodicMemory, error) {
+	rows, err := s.db.Query(`
+		SELECT i.id, v.id, v.text_chunk, i.topics, v.kb_partition, v.project, i.created_at
+		FROM interactions i JOIN context_vectors v ON v.source_id = i.id AND v.source_type = 'interaction'
+		WHERE i.created_at < ?
+		  AND NOT EXISTS (SELECT 1 FROM memory_consolidations m WHERE m.interaction_id = i.id)
+		ORDER BY i.created_at, i.id
+		LIMIT ?`,
+		before.UTC().Format(time.RFC3339), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EpisodicMemory
+	for rows.Next() {
+		var m EpisodicMemory
+		var createdAt string
+		if err := rows.Scan(&m.InteractionID, &m.VectorID, &m.Summary, &m.Topics, &m.Partition, &m.Project, &createdAt); err != nil {
+			return nil, err
+		}
+		if m.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("parsing created_at for interaction %s: %w", m.InteractionID, err)
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SaveConsolidatedMemory saves doc as the consolidated memory of sources,
+// links each source to it and scales their summary vectors' quality_score by
+// weight, all in one transaction. Returns ErrConflict if a source has
+// already been consolidated.
+func (s *Store) SaveConsolidatedMemory(doc ContextDoc, sources []EpisodicMemory, weight float64) error {
+	metadata := doc.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
+	deepMetadata := doc.DeepMetadata
+	if deepMetadata == "" {
+		deepMetadata = "{}"
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning consolidation transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO context_docs (id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.Title, doc.Content, doc.Source, doc.Tags,
+		doc.CreatedAt.UTC().Format(time.RFC3339), doc.VectorID, metadata, deepMetadata, doc.Partition, doc.Project,
+	); err != nil {
+		return fmt.Errorf("saving consolidated memory %s: %w", doc.ID, err)
+	}
+
+	for _, src := range sources {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO memory_consolidations (interaction_id, vector_id, doc_id, prior_quality, created_at)
+			SELECT ?, id, ?, quality_score, ? FROM context_vectors WHERE id = ?`,
+			src.InteractionID, doc.ID, now, src.VectorID,
+		)
+		if err != nil {
+			return fmt.Errorf("linking interaction %s: %w", src.InteractionID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("interaction %s: %w", src.InteractionID, ErrConflict)
+		}
+		if _, err := tx.Exec(`UPDATE context_vectors SET quality_score = quality_score * ? WHERE id = ?`, weight, src.VectorID); err != nil {
+			return fmt.Errorf("down-weighting vector %s: %w", src.VectorID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing consolidated memory %s: %w", doc.ID, err)
+	}
+	return nil
+}
+
+// ListConsolidatedMemoryIDs returns the IDs of the consolidated memory docs
+// that interactionID was folded into.
+func (s *Store) ListConsolidatedMemoryIDs(interactionID string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT doc_id FROM memory_consolidations WHERE interaction_id = ? ORDER BY doc_id`, interactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetConsolidationSources returns the interaction summaries consolidated into
+// docID, oldest first. Returns an empty slice for any other doc.
+func (s *Store) GetConsolidationSources(docID string) ([]ConsolidationSource, error) {
+	rows, err := s.db.Query(`
+		SELECT m.interaction_id, m.vector_id, i.user_query, COALESCE(v.text_chunk, ''), i.created_at
+		FROM memory_consolidations m
+		JOIN interactions i ON i.id = m.interaction_id
+		LEFT JOIN context_vectors v ON v.id = m.vector_id
+		WHERE m.doc_id = ?
+		ORDER BY i.created_at, i.id`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ConsolidationSource{}
+	for rows.Next() {
+		var src ConsolidationSource
+		var createdAt string
+		if err := rows.Scan(&src.InteractionID, &src.VectorID, &src.UserQuery, &src.Summary, &createdAt); err != nil {
+			return nil, err
+		}
+		if src.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("parsing created_at for interaction %s: %w", src.InteractionID, err)
+		}
+		out = append(out, src)
+	}
+	return out, rows.Err()
+}
+
+// --- Extracted facts ---
+
+// ListFactExtractionCandidates returns up to limit completed interactions
+// created before before that fact extraction has not processed yet, oldest
+// first.
+func (s *Store) ListFactExtractionCandidates(before time.Time, limit int) ([]Interaction, error) {
+	rows, err := s.db.Query(`
+		SELECT i.id, i.created_at, i.user_query, i.cloud_response, i.persona, i.kb_partition, i.topics
+		FROM interactions i
+		WHERE i.status = 'completed' AND i.created_at < ?
+		  AND NOT EXISTS (SELECT 1 FROM fact_extractions f WHERE f.interaction_id = i.id)
+		ORDER BY i.created_at, i.id
+		LIMIT ?`,
+		before.UTC().Format(time.RFC3339), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Interaction
+	for rows.Next() {
+		var i Interaction
+		var createdAt string
+		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.CloudResponse, &i.Persona, &i.Partition, &i.Topics); err != nil {
+			return nil, err
+		}
+		if i.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("parsing created_at for interaction %s: %w", i.ID, err)
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+// SaveExtractedFacts queues facts for review and marks interactionID as
+// processed, in one transaction. A fact whose statement matches a pending or
+// accepted fact, ignoring case, is dropped. Returns the number of facts
+// queued, or ErrConflict if the interaction has already been processed.
+func (s *Store) SaveExtractedFacts(interactionID string, facts []PendingFact) (int, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning fact extraction transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	// Mark each selected job as running.
-	for i := range jobs {
-		res, err := tx.Exec(
-			`UPDATE jobs SET status = 'running', updated_at = ? WHERE id = ? AND status = 'pending'`,
-			now, jobs[i].ID,
+	queued := 0
+	for _, f := range facts {
+		res, err := tx.Exec(`
+			INSERT INTO pending_facts (id, interaction_id, kind, statement, confidence, kb_partition, created_at)
+			SELECT ?, ?, ?, ?, ?, ?, ?
+			WHERE NOT EXISTS (
+				SELECT 1 FROM pending_facts
+				WHERE lower(statement) = lower(?) AND (accepted IS NULL OR accepted = 1)
+			)`,
+			f.ID, interactionID, f.Kind, f.Statement, f.Confidence, f.Partition,
+			f.CreatedAt.UTC().Format(time.RFC3339), f.Statement,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("claiming job %s: %w", jobs[i].ID, err)
-		}
-		n, err := res.RowsAffected()
-		if err != nil {
-			return nil, fmt.Errorf("checking claimed rows for job %s: %w", jobs[i].ID, err)
-		}
-		if n == 1 {
-			jobs[i].Status = "running"
-			jobs[i].UpdatedAt = nowTime
+			return 0, fmt.Errorf("saving fact %s: %w", f.ID, err)
 		}
+		n, _ := res.RowsAffected()
+		queued += int(n)
+	}
+
+	res, err := tx.Exec(`
+		INSERT OR IGNORE INTO fact_extractions (interaction_id, facts, created_at) VALUES (?, ?, ?)`,
+		interactionID, queued, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("marking interaction %s: %w", interactionID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("interaction %s: %w", interactionID, ErrConflict)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing claim transaction: %w", err)
+		return 0, fmt.Errorf("committing extracted facts: %w", err)
 	}
+	return queued, nil
+}
 
-	// Return only successfully claimed jobs (status == "running").
-	claimed := jobs[:0]
-	for _, j := range jobs {
-		if j.Status == "running" {
-			claimed = append(claimed, j)
+// ListPendingFacts returns facts that have not been reviewed yet, newest
+// first.
+func (s *Store) ListPendingFacts(limit, offset int) ([]PendingFact, error) {
+	rows, err := s.db.Query(`
+		SELECT id, interaction_id, kind, statement, confidence, kb_partition, accepted, reviewed_at, doc_id, created_at
+		FROM pending_facts
+		WHERE accepted IS NULL
+		ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []PendingFact
+	for rows.Next() {
+		f, err := scanPendingFact(rows)
+		if err != nil {
+			return nil, err
 		}
+		results = append(results, f)
 	}
-	return claimed, nil
+	return results, rows.Err()
 }
 
-// ResetStaleJobs resets jobs stuck in 'running' for longer than timeout back to 'pending'.
-// Jobs that have exceeded max_attempts are set to 'failed' instead.
-// Returns the count of jobs reset.
-func (s *Store) ResetStaleJobs(jobTypes []string, timeout time.Duration) (int, error) {
-	if len(jobTypes) == 0 {
-		return 0, nil
+// GetPendingFact returns a single extracted fact by ID, reviewed or not.
+func (s *Store) GetPendingFact(id string) (PendingFact, error) {
+	row := s.db.QueryRow(`
+		SELECT id, interaction_id, kind, statement, confidence, kb_partition, accepted, reviewed_at, doc_id, created_at
+		FROM pending_facts WHERE id = ?`, id)
+	f, err := scanPendingFact(row)
+	if err == sql.ErrNoRows {
+		return PendingFact{}, ErrNotFound
+	}
+	if err != nil {
+		return PendingFact{}, err
 	}
+	return f, nil
+}
 
-	threshold := time.Now().UTC().Add(-timeout).Format(time.RFC3339)
+// AcceptFact marks a pending fact as accepted with the given statement and
+// saves doc as its context doc, in one transaction. Returns
+// ErrAlreadyReviewed if the fact has already been reviewed, or ErrNotFound
+// if the ID does not exist.
+func (s *Store) AcceptFact(id, statement string, doc ContextDoc) error {
+	metadata := doc.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
+	deepMetadata := doc.DeepMetadata
+	if deepMetadata == "" {
+		deepMetadata = "{}"
+	}
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	placeholders := strings.Repeat(",?", len(jobTypes)-1)
-
-	// Select stale running jobs.
-	query := `SELECT id, attempts, max_attempts FROM jobs
-		WHERE status = 'running' AND type IN (?` + placeholders + `) AND updated_at < ?`
-
-	args := make([]any, 0, len(jobTypes)+1)
-	for _, t := range jobTypes {
-		args = append(args, t)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning fact review transaction: %w", err)
 	}
-	args = append(args, threshold)
+	defer tx.Rollback()
 
-	rows, err := s.db.Query(query, args...)
+	// Atomic: only update rows where accepted IS NULL to prevent TOCTOU races.
+	res, err := tx.Exec(`
+		UPDATE pending_facts SET accepted = 1, reviewed_at = ?, statement = ?, doc_id = ?
+		WHERE id = ? AND accepted IS NULL`,
+		now, statement, doc.ID, id,
+	)
 	if err != nil {
-		return 0, fmt.Errorf("querying stale jobs: %w", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return factReviewError(tx, id)
 	}
 
-	type staleJob struct {
-		id          string
-		attempts    int
-		maxAttempts int
+	if _, err := tx.Exec(`
+		INSERT INTO context_docs (id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.Title, doc.Content, doc.Source, doc.Tags,
+		doc.CreatedAt.UTC().Format(time.RFC3339), doc.VectorID, metadata, deepMetadata, doc.Partition, doc.Project,
+	); err != nil {
+		return fmt.Errorf("saving fact doc %s: %w", doc.ID, err)
 	}
-	var stale []staleJob
-	for rows.Next() {
-		var j staleJob
-		if err := rows.Scan(&j.id, &j.attempts, &j.maxAttempts); err != nil {
-			rows.Close()
-			return 0, fmt.Errorf("scanning stale job: %w", err)
-		}
-		stale = append(stale, j)
+	return tx.Commit()
+}
+
+// RejectFact marks a pending fact as rejected. Returns ErrAlreadyReviewed if
+// the fact has already been reviewed, or ErrNotFound if the ID does not
+// exist.
+func (s *Store) RejectFact(id string) error {
+	res, err := s.db.Exec(`
+		UPDATE pending_facts SET accepted = 0, reviewed_at = ?
+		WHERE id = ? AND accepted IS NULL`,
+		time.Now().UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return err
 	}
-	rows.Close()
-	if err := rows.Err(); err != nil {
-		return 0, fmt.Errorf("iterating stale jobs: %w", err)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return factReviewError(s.db, id)
 	}
+	return nil
+}
 
-	if len(stale) == 0 {
-		return 0, nil
+// factReviewError distinguishes "not found" from "already reviewed" after a
+// review updated no rows. q is the transaction the review ran in, if any, as
+// the store has a single connection.
+func factReviewError(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, id string) error {
+	var exists int
+	if err := q.QueryRow(`SELECT COUNT(*) FROM pending_facts WHERE id = ?`, id).Scan(&exists); err != nil {
+		return err
 	}
-
-	tx, err := s.db.Begin()
-	if err != nil {
-		return 0, fmt.Errorf("beginning reset transaction: %w", err)
+	if exists == 0 {
+		return ErrNotFound
 	}
-	defer tx.Rollback()
+	return ErrAlreadyReviewed
+}
 
-	resetCount := 0
-	for _, j := range stale {
-		newAttempts := j.attempts + 1
-		var newStatus string
-		if newAttempts >= j.maxAttempts {
-			newStatus = "failed"
-		} else {
-			newStatus = "pending"
-		}
-		_, err := tx.Exec(
-			`UPDATE jobs SET status = ?, attempts = ?, updated_at = ? WHERE id = ? AND status = 'running'`,
-			newStatus, newAttempts, now, j.id,
-		)
+func scanPendingFact(s scanner) (PendingFact, error) {
+	var f PendingFact
+	var accepted sql.NullInt64
+	var reviewedAt sql.NullString
+	var createdAt string
+	if err := s.Scan(&f.ID, &f.InteractionID, &f.Kind, &f.Statement, &f.Confidence, &f.Partition, &accepted, &reviewedAt, &f.DocID, &createdAt); err != nil {
+		return PendingFact{}, err
+	}
+	if accepted.Valid {
+		b := accepted.Int64 != 0
+		f.Accepted = &b
+	}
+	if reviewedAt.Valid && reviewedAt.String != "" {
+		t, err := time.Parse(time.RFC3339, reviewedAt.String)
 		if err != nil {
-			return 0, fmt.Errorf("resetting stale job %s: %w", j.id, err)
+			return PendingFact{}, fmt.Errorf("parsing reviewed_at: %w", err)
 		}
-		resetCount++
+		f.ReviewedAt = &t
 	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("committing stale job reset: %w", err)
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return PendingFact{}, fmt.Errorf("parsing created_at: %w", err)
 	}
-	return resetCount, nil
+	f.CreatedAt = t
+	return f, nil
 }
 
 // --- Time-windowed queries ---
@@ -988,7 +2517,7 @@ func (s *Store) ResetStaleJobs(jobTypes []string, timeout time.Duration) (int, e
 // feedback score and were created at or after since, ordered by most recent first.
 func (s *Store) GetInteractionsWithFeedbackSince(since time.Time) ([]Interaction, error) {
 	rows, err := s.db.Query(`
-		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids
+		SELECT id, created_at, user_query, enriched_prompt, cloud_model, cloud_response, status, feedback_score, feedback_notes, vector_ids, intent_type, retrieval_legs, search_strategy, cached_from, persona, kb_partition
 		FROM interactions
 		WHERE feedback_score != 0 AND created_at >= ?
 		ORDER BY created_at DESC`,
@@ -1003,7 +2532,7 @@ func (s *Store) GetInteractionsWithFeedbackSince(since time.Time) ([]Interaction
 	for rows.Next() {
 		var i Interaction
 		var createdAt string
-		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs); err != nil {
+		if err := rows.Scan(&i.ID, &createdAt, &i.UserQuery, &i.EnrichedPrompt, &i.CloudModel, &i.CloudResponse, &i.Status, &i.FeedbackScore, &i.FeedbackNotes, &i.VectorIDs, &i.IntentType, &i.RetrievalLegs, &i.SearchStrategy, &i.CachedFrom, &i.Persona, &i.Partition); err != nil {
 			return nil, err
 		}
 		t, err := time.Parse(time.RFC3339, createdAt)
@@ -1016,10 +2545,73 @@ func (s *Store) GetInteractionsWithFeedbackSince(since time.Time) ([]Interaction
 	return results, rows.Err()
 }
 
+// TopicActivity summarises how often one intent topic came up in the default
+// persona's interactions.
+type TopicActivity struct {
+	Topic            string    // lower-cased topic
+	Mentions         int       // interactions mentioning the topic
+	ActiveDays       int       // distinct UTC days with at least one mention
+	PositiveFeedback int       // mentioning interactions rated positively
+	FirstSeen        time.Time // earliest mention
+	LastSeen         time.Time // latest mention
+}
+
+// GetTopicActivity aggregates the intent topics of default-persona
+// interactions created at or after since, ordered by mentions, most first.
+// Topics are compared case-insensitively.
+func (s *Store) GetTopicActivity(since time.Time) ([]TopicActivity, error) {
+	rows, err := s.db.Query(`
+		SELECT lower(trim(t.value)) AS topic,
+			COUNT(DISTINCT i.id),
+			COUNT(DISTINCT substr(i.created_at, 1, 10)),
+			COUNT(DISTINCT CASE WHEN i.feedback_score > 0 THEN i.id END),
+			MIN(i.created_at),
+			MAX(i.created_at)
+		FROM interactions i, json_each(i.topics) t
+		WHERE i.persona = ? AND i.created_at >= ? AND json_valid(i.topics) AND trim(t.value) != ''
+		GROUP BY topic
+		ORDER BY 2 DESC, topic`,
+		DefaultPersona, since.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []TopicActivity
+	for rows.Next() {
+		var a TopicActivity
+		var first, last string
+		if err := rows.Scan(&a.Topic, &a.Mentions, &a.ActiveDays, &a.PositiveFeedback, &first, &last); err != nil {
+			return nil, err
+		}
+		if a.FirstSeen, err = time.Parse(time.RFC3339, first); err != nil {
+			return nil, fmt.Errorf("parsing first mention: %w", err)
+		}
+		if a.LastSeen, err = time.Parse(time.RFC3339, last); err != nil {
+			return nil, fmt.Errorf("parsing last mention: %w", err)
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// CountTopicInteractionsSince counts default-persona interactions created at
+// or after since that recorded at least one intent topic.
+func (s *Store) CountTopicInteractionsSince(since time.Time) (int, error) {
+	var n int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM interactions
+		WHERE persona = ? AND created_at >= ? AND topics NOT IN ('', '[]')`,
+		DefaultPersona, since.UTC().Format(time.RFC3339),
+	).Scan(&n)
+	return n, err
+}
+
 // GetContextDocsSince returns context docs created at or after since, ordered by most recent first.
 func (s *Store) GetContextDocsSince(since time.Time) ([]ContextDoc, error) {
 	rows, err := s.db.Query(`
-		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata
+		SELECT id, title, content, source, tags, created_at, vector_id, metadata, deep_metadata, kb_partition, project
 		FROM context_docs
 		WHERE created_at >= ?
 		ORDER BY created_at DESC`,
@@ -1034,7 +2626,7 @@ func (s *Store) GetContextDocsSince(since time.Time) ([]ContextDoc, error) {
 	for rows.Next() {
 		var d ContextDoc
 		var createdAt string
-		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata); err != nil {
+		if err := rows.Scan(&d.ID, &d.Title, &d.Content, &d.Source, &d.Tags, &createdAt, &d.VectorID, &d.Metadata, &d.DeepMetadata, &d.Partition, &d.Project); err != nil {
 			return nil, err
 		}
 		t, err := time.Parse(time.RFC3339, createdAt)
@@ -1052,10 +2644,10 @@ func (s *Store) GetContextDocsSince(since time.Time) ([]ContextDoc, error) {
 // SavePendingDelta inserts a new pending profile delta.
 func (s *Store) SavePendingDelta(delta PendingProfileDelta) error {
 	_, err := s.db.Exec(`
-		INSERT INTO pending_profile_deltas (id, delta_json, description, source, accepted, reviewed_at, created_at)
-		VALUES (?, ?, ?, ?, NULL, NULL, ?)`,
+		INSERT INTO pending_profile_deltas (id, delta_json, description, source, accepted, reviewed_at, created_at, evidence_json)
+		VALUES (?, ?, ?, ?, NULL, NULL, ?, ?)`,
 		delta.ID, delta.DeltaJSON, delta.Description, delta.Source,
-		delta.CreatedAt.UTC().Format(time.RFC3339),
+		delta.CreatedAt.UTC().Format(time.RFC3339), delta.EvidenceJSON,
 	)
 	return err
 }
@@ -1063,7 +2655,7 @@ func (s *Store) SavePendingDelta(delta PendingProfileDelta) error {
 // ListPendingDeltas returns all deltas that have not yet been reviewed (accepted IS NULL).
 func (s *Store) ListPendingDeltas() ([]PendingProfileDelta, error) {
 	rows, err := s.db.Query(`
-		SELECT id, delta_json, description, source, accepted, reviewed_at, created_at
+		SELECT id, delta_json, description, source, accepted, reviewed_at, created_at, evidence_json, applied_json
 		FROM pending_profile_deltas
 		WHERE accepted IS NULL
 		ORDER BY created_at DESC`)
@@ -1086,7 +2678,7 @@ func (s *Store) ListPendingDeltas() ([]PendingProfileDelta, error) {
 // GetPendingDelta returns a single pending delta by ID.
 func (s *Store) GetPendingDelta(id string) (*PendingProfileDelta, error) {
 	row := s.db.QueryRow(`
-		SELECT id, delta_json, description, source, accepted, reviewed_at, created_at
+		SELECT id, delta_json, description, source, accepted, reviewed_at, created_at, evidence_json, applied_json
 		FROM pending_profile_deltas WHERE id = ?`, id)
 	d, err := scanPendingDelta(row)
 	if err == sql.ErrNoRows {
@@ -1117,6 +2709,28 @@ func (s *Store) ReviewDelta(id string, accept bool) error {
 	if err != nil {
 		return err
 	}
+	return s.deltaReviewResult(res, id)
+}
+
+// AcceptDelta marks a pending delta as accepted atomically and records
+// appliedJSON, the ProfileDelta actually applied, which differs from the
+// proposal when the reviewer edited it. Returns ErrAlreadyReviewed if the
+// delta has already been reviewed, or ErrNotFound if the ID does not exist.
+func (s *Store) AcceptDelta(id, appliedJSON string) error {
+	res, err := s.db.Exec(`
+		UPDATE pending_profile_deltas SET accepted = 1, reviewed_at = ?, applied_json = ?
+		WHERE id = ? AND accepted IS NULL`,
+		time.Now().UTC().Format(time.RFC3339), appliedJSON, id,
+	)
+	if err != nil {
+		return err
+	}
+	return s.deltaReviewResult(res, id)
+}
+
+// deltaReviewResult checks that a review updated the delta, and otherwise
+// reports whether it is missing or was already reviewed.
+func (s *Store) deltaReviewResult(res sql.Result, id string) error {
 	n, err := res.RowsAffected()
 	if err != nil {
 		return err
@@ -1140,7 +2754,7 @@ func (s *Store) ReviewDelta(id string, accept bool) error {
 // Returns ErrNotFound if the delta does not exist.
 func (s *Store) UnreviewDelta(id string) error {
 	res, err := s.db.Exec(`
-		UPDATE pending_profile_deltas SET accepted = NULL, reviewed_at = NULL
+		UPDATE pending_profile_deltas SET accepted = NULL, reviewed_at = NULL, applied_json = ''
 		WHERE id = ?`, id,
 	)
 	if err != nil {
@@ -1171,6 +2785,156 @@ func (s *Store) HasPendingDeltaForSource(source string, since time.Time) (bool,
 	return count > 0, nil
 }
 
+// SaveDeltaDecision records an automatic decision on a pending delta.
+func (s *Store) SaveDeltaDecision(d DeltaDecision) error {
+	_, err := s.db.Exec(`
+		INSERT INTO delta_decisions (id, delta_id, action, rule, applied_json, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		d.ID, d.DeltaID, d.Action, d.Rule, d.AppliedJSON, d.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// ListDeltaDecisions returns delta decisions, newest first.
+func (s *Store) ListDeltaDecisions(limit, offset int) ([]DeltaDecision, error) {
+	rows, err := s.db.Query(`
+		SELECT id, delta_id, action, rule, applied_json, created_at, undone_at
+		FROM delta_decisions
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []DeltaDecision
+	for rows.Next() {
+		d, err := scanDeltaDecision(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// GetDeltaDecision returns a single delta decision by ID.
+func (s *Store) GetDeltaDecision(id string) (DeltaDecision, error) {
+	row := s.db.QueryRow(`
+		SELECT id, delta_id, action, rule, applied_json, created_at, undone_at
+		FROM delta_decisions WHERE id = ?`, id)
+	d, err := scanDeltaDecision(row)
+	if err == sql.ErrNoRows {
+		return DeltaDecision{}, ErrNotFound
+	}
+	return d, err
+}
+
+// MarkDecisionUndone atomically marks a decision as undone. Returns
+// ErrAlreadyUndone if it was undone before, or ErrNotFound.
+func (s *Store) MarkDecisionUndone(id string) error {
+	res, err := s.db.Exec(`
+		UPDATE delta_decisions SET undone_at = ?
+		WHERE id = ? AND undone_at IS NULL`,
+		time.Now().UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := s.GetDeltaDecision(id); err != nil {
+			return err
+		}
+		return ErrAlreadyUndone
+	}
+	return nil
+}
+
+// ClearDecisionUndone reverts MarkDecisionUndone, for when undoing the
+// decision's effect failed.
+func (s *Store) ClearDecisionUndone(id string) error {
+	_, err := s.db.Exec(`UPDATE delta_decisions SET undone_at = NULL WHERE id = ?`, id)
+	return err
+}
+
+// ListOverriddenDeltaIDs returns the IDs of deltas with an undone decision.
+// The policy engine leaves those to the user.
+func (s *Store) ListOverriddenDeltaIDs() (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT delta_id FROM delta_decisions WHERE undone_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+func scanDeltaDecision(s scanner) (DeltaDecision, error) {
+	var d DeltaDecision
+	var createdAt string
+	var undoneAt sql.NullString
+	if err := s.Scan(&d.ID, &d.DeltaID, &d.Action, &d.Rule, &d.AppliedJSON, &createdAt, &undoneAt); err != nil {
+		return DeltaDecision{}, err
+	}
+	var err error
+	if d.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return DeltaDecision{}, fmt.Errorf("parsing created_at: %w", err)
+	}
+	if undoneAt.Valid {
+		t, err := time.Parse(time.RFC3339, undoneAt.String)
+		if err != nil {
+			return DeltaDecision{}, fmt.Errorf("parsing undone_at: %w", err)
+		}
+		d.UndoneAt = &t
+	}
+	return d, nil
+}
+
+// GetScheduleRuns returns the last run time of every scheduled job.
+func (s *Store) GetScheduleRuns() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT name, last_run_at FROM schedule_runs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string]time.Time)
+	for rows.Next() {
+		var name, at string
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, fmt.Errorf("parsing last_run_at for %s: %w", name, err)
+		}
+		runs[name] = t
+	}
+	return runs, rows.Err()
+}
+
+// SetScheduleRun records when the scheduled job name last ran.
+func (s *Store) SetScheduleRun(name string, at time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO schedule_runs (name, last_run_at) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET last_run_at = excluded.last_run_at`,
+		name, at.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
 // scanner is satisfied by both *sql.Row and *sql.Rows so we can share scan logic.
 type scanner interface {
 	Scan(dest ...any) error
@@ -1181,7 +2945,7 @@ func scanPendingDelta(s scanner) (PendingProfileDelta, error) {
 	var accepted sql.NullInt64
 	var reviewedAt sql.NullString
 	var createdAt string
-	if err := s.Scan(&d.ID, &d.DeltaJSON, &d.Description, &d.Source, &accepted, &reviewedAt, &createdAt); err != nil {
+	if err := s.Scan(&d.ID, &d.DeltaJSON, &d.Description, &d.Source, &accepted, &reviewedAt, &createdAt, &d.EvidenceJSON, &d.AppliedJSON); err != nil {
 		return PendingProfileDelta{}, err
 	}
 	if accepted.Valid {
@@ -2,7 +2,13 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 )
@@ -294,8 +300,8 @@ func TestSaveInteraction_DefaultStatus(t *testing.T) {
 func TestProfileKeyRoundTrip(t *testing.T) {
 	s := openTestStore(t)
 
-	if err := s.SetProfileKey("language", "Go"); err != nil {
-		t.Fatalf("SetProfileKey: %v", err)
+	if _, err := s.CommitProfileChange("test", "set language", map[string]string{"language": "Go"}, nil); err != nil {
+		t.Fatalf("CommitProfileChange: %v", err)
 	}
 
 	val, err := s.GetProfileKey("language")
@@ -307,8 +313,8 @@ func TestProfileKeyRoundTrip(t *testing.T) {
 	}
 
 	// Overwrite and verify upsert works.
-	if err := s.SetProfileKey("language", "Rust"); err != nil {
-		t.Fatalf("SetProfileKey (overwrite): %v", err)
+	if _, err := s.CommitProfileChange("test", "set language", map[string]string{"language": "Rust"}, nil); err != nil {
+		t.Fatalf("CommitProfileChange (overwrite): %v", err)
 	}
 	val, err = s.GetProfileKey("language")
 	if err != nil {
@@ -330,10 +336,8 @@ func TestGetAllProfileKeys(t *testing.T) {
 		"os":       "macOS",
 		"terminal": "Ghostty",
 	}
-	for k, v := range keys {
-		if err := s.SetProfileKey(k, v); err != nil {
-			t.Fatalf("SetProfileKey(%q): %v", k, err)
-		}
+	if _, err := s.CommitProfileChange("test", "set keys", keys, nil); err != nil {
+		t.Fatalf("CommitProfileChange: %v", err)
 	}
 
 	got, err := s.GetAllProfileKeys()
@@ -351,7 +355,269 @@ func TestGetAllProfileKeys(t *testing.T) {
 	}
 }
 
+// TestProfileHistory commits changes and verifies versions, snapshots and diffs.
+func TestProfileHistory(t *testing.T) {
+	s := openTestStore(t)
+
+	v1, err := s.CommitProfileChange("cli", "set tone", map[string]string{"communication.tone": "direct", "identity.role": "engineer"}, nil)
+	if err != nil {
+		t.Fatalf("CommitProfileChange v1: %v", err)
+	}
+	v2, err := s.CommitProfileChange("api", "update", map[string]string{"communication.tone": "formal"}, []string{"identity.role"})
+	if err != nil {
+		t.Fatalf("CommitProfileChange v2: %v", err)
+	}
+	if v2 <= v1 {
+		t.Fatalf("versions not increasing: v1=%d v2=%d", v1, v2)
+	}
+
+	// A change that leaves the profile as-is records nothing.
+	same, err := s.CommitProfileChange("api", "noop", map[string]string{"communication.tone": "formal"}, nil)
+	if err != nil {
+		t.Fatalf("CommitProfileChange noop: %v", err)
+	}
+	if same != v2 {
+		t.Errorf("no-op commit returned v%d, want v%d", same, v2)
+	}
+
+	// Deleting a missing key fails without writing.
+	if _, err := s.CommitProfileChange("api", "bad", map[string]string{"x": "y"}, []string{"missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete of missing key: err = %v, want ErrNotFound", err)
+	}
+	if keys, _ := s.GetAllProfileKeys(); keys["x"] != "" {
+		t.Error("failed commit wrote keys")
+	}
+
+	versions, err := s.ListProfileVersions(10, 0)
+	if err != nil {
+		t.Fatalf("ListProfileVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if versions[0].Version != v2 || versions[0].Source != "api" {
+		t.Errorf("newest = v%d from %q, want v%d from api", versions[0].Version, versions[0].Source, v2)
+	}
+	want := []ProfileFieldChange{
+		{Key: "communication.tone", Op: "update", Old: "direct", New: "formal"},
+		{Key: "identity.role", Op: "delete", Old: "engineer"},
+	}
+	if !reflect.DeepEqual(versions[0].Changes, want) {
+		t.Errorf("v2 changes = %+v, want %+v", versions[0].Changes, want)
+	}
+
+	snap, err := s.GetProfileSnapshot(v1)
+	if err != nil {
+		t.Fatalf("GetProfileSnapshot: %v", err)
+	}
+	if snap["communication.tone"] != "direct" || snap["identity.role"] != "engineer" {
+		t.Errorf("v1 snapshot = %v", snap)
+	}
+	if snap, err := s.GetProfileSnapshot(0); err != nil || len(snap) != 0 {
+		t.Errorf("GetProfileSnapshot(0) = %v, %v; want empty", snap, err)
+	}
+	if _, err := s.GetProfileSnapshot(v2 + 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetProfileSnapshot(missing): err = %v, want ErrNotFound", err)
+	}
+
+	diff, err := s.DiffProfileVersions(0, v2)
+	if err != nil {
+		t.Fatalf("DiffProfileVersions: %v", err)
+	}
+	if len(diff) != 1 || diff[0] != (ProfileFieldChange{Key: "communication.tone", Op: "add", New: "formal"}) {
+		t.Errorf("diff v0..v2 = %+v", diff)
+	}
+}
+
 // TestSaveAndListContextDocs saves 3 docs and verifies ListContextDocs(2) returns 2.
+func TestPersonas(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.GetPersona(DefaultPersona); err != nil {
+		t.Fatalf("default persona missing after migration: %v", err)
+	}
+	if err := s.CreatePersona(Persona{Name: "work", Partitioned: true, Clients: []string{"Cursor"}, Models: []string{"openai/gpt-4o"}}); err != nil {
+		t.Fatalf("CreatePersona: %v", err)
+	}
+	if err := s.AddPersonaToken("work", "secret-token"); err != nil {
+		t.Fatalf("AddPersonaToken: %v", err)
+	}
+	if err := s.CreatePersona(Persona{Name: "work"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate name: err = %v, want ErrConflict", err)
+	}
+	if err := s.CreatePersona(Persona{Name: "home", Clients: []string{"cursor"}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate client selector: err = %v, want ErrConflict", err)
+	}
+	if _, err := s.GetPersona("home"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("persona with a conflicting selector was created: err = %v", err)
+	}
+
+	for _, tc := range []struct{ kind, value string }{
+		{SelectorToken, "secret-token"},
+		{SelectorClient, " cursor "},
+		{SelectorModel, "openai/gpt-4o"},
+	} {
+		if got, err := s.ResolvePersona(tc.kind, tc.value); err != nil || got != "work" {
+			t.Errorf("ResolvePersona(%s, %q) = %q, %v; want work", tc.kind, tc.value, got, err)
+		}
+	}
+	if _, err := s.ResolvePersona(SelectorToken, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown token: err = %v, want ErrNotFound", err)
+	}
+
+	var stored int
+	s.db.QueryRow(`SELECT COUNT(*) FROM persona_selectors WHERE value = 'secret-token'`).Scan(&stored)
+	if stored != 0 {
+		t.Error("token stored in the clear")
+	}
+
+	list, err := s.ListPersonas()
+	if err != nil || len(list) != 2 || list[0].Name != DefaultPersona {
+		t.Fatalf("ListPersonas = %+v, %v; want default then work", list, err)
+	}
+	if w := list[1]; !w.Partitioned || w.Tokens != 1 || len(w.Clients) != 1 || w.Clients[0] != "cursor" {
+		t.Errorf("work persona = %+v", w)
+	}
+
+	// Profiles are kept apart per persona.
+	work := s.PersonaProfile("work")
+	if _, err := work.CommitProfileChange("api", "set role", map[string]string{"identity.role": "staff engineer"}, nil); err != nil {
+		t.Fatalf("CommitProfileChange(work): %v", err)
+	}
+	if _, err := s.CommitProfileChange("api", "set role", map[string]string{"identity.role": "hobbyist"}, nil); err != nil {
+		t.Fatalf("CommitProfileChange(default): %v", err)
+	}
+	if keys, _ := work.GetAllProfileKeys(); keys["identity.role"] != "staff engineer" {
+		t.Errorf("work role = %q, want staff engineer", keys["identity.role"])
+	}
+	if v, _ := s.GetProfileKey("identity.role"); v != "hobbyist" {
+		t.Errorf("default role = %q, want hobbyist", v)
+	}
+	if versions, _ := s.ListProfileVersions(10, 0); len(versions) != 1 {
+		t.Errorf("default history has %d versions, want only its own", len(versions))
+	}
+
+	// Deleting the persona deletes its partition and nothing else.
+	for _, d := range []ContextDoc{
+		{ID: "doc-work", Title: "work", Content: "c", Source: "file", Tags: "[]", Partition: "work"},
+		{ID: "doc-shared", Title: "shared", Content: "c", Source: "file", Tags: "[]"},
+	} {
+		if err := s.SaveContextDoc(d); err != nil {
+			t.Fatalf("SaveContextDoc(%s): %v", d.ID, err)
+		}
+		if _, err := s.db.Exec(`INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at, tags, kb_partition)
+			VALUES (?, ?, 'context_doc', 'c', x'', ?, '[]', ?)`, "v-"+d.ID, d.ID, time.Now().UTC().Format(time.RFC3339), d.Partition); err != nil {
+			t.Fatalf("insert vector: %v", err)
+		}
+	}
+	if err := s.SaveInteraction(context.Background(), Interaction{
+		ID: "ix-work", CreatedAt: time.Now(), UserQuery: "q", Status: "completed", VectorIDs: "[]",
+		Persona: "work", Partition: "work",
+	}); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	if _, err := s.DeletePersona(DefaultPersona); err == nil {
+		t.Error("deleting the default persona succeeded")
+	}
+	removed, err := s.DeletePersona("work")
+	if err != nil {
+		t.Fatalf("DeletePersona: %v", err)
+	}
+	sort.Strings(removed)
+	if want := []string{"doc-work", "ix-work", "v-doc-work"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if _, err := s.DeletePersona("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetContextDoc("doc-work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetContextDoc(doc-work) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetContextDoc("doc-shared"); err != nil {
+		t.Errorf("shared doc deleted with the persona: %v", err)
+	}
+	var vectors int
+	s.db.QueryRow(`SELECT COUNT(*) FROM context_vectors`).Scan(&vectors)
+	if vectors != 1 {
+		t.Errorf("%d vectors left, want only the shared doc's", vectors)
+	}
+	if _, err := s.ResolvePersona(SelectorClient, "cursor"); !errors.Is(err, ErrNotFound) {
+		t.Error("selector survived persona deletion")
+	}
+	if keys, _ := work.GetAllProfileKeys(); len(keys) != 0 {
+		t.Error("profile survived persona deletion")
+	}
+}
+
+func TestProjects(t *testing.T) {
+	s := openTestStore(t)
+
+	if err := s.CreateProject(Project{Name: "tbyd", Roots: []string{"/src/tbyd/"}, Stack: []string{"go", "sqlite"}}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if err := s.CreateProject(Project{Name: "tbyd-docs", Roots: []string{"/src/tbyd/docs"}}); err != nil {
+		t.Fatalf("CreateProject(nested): %v", err)
+	}
+	if err := s.CreateProject(Project{Name: "tbyd"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate name: err = %v, want ErrConflict", err)
+	}
+	if err := s.CreateProject(Project{Name: "other", Roots: []string{"/src/tbyd"}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate root: err = %v, want ErrConflict", err)
+	}
+	if _, err := s.GetProject("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("project with a conflicting root was created: err = %v", err)
+	}
+
+	p, err := s.GetProject("tbyd")
+	if err != nil || len(p.Roots) != 1 || p.Roots[0] != "/src/tbyd" || len(p.Stack) != 2 {
+		t.Errorf("GetProject = %+v, %v; want the cleaned root and stack", p, err)
+	}
+
+	for path, want := range map[string]string{
+		"/src/tbyd":               "tbyd",
+		"/src/tbyd/internal/api":  "tbyd",
+		"/src/tbyd/docs/guide":    "tbyd-docs", // deepest root wins
+		"/src/tbyd-fork/internal": "",          // sibling with a shared prefix
+		"/home":                   "",
+	} {
+		got, err := s.ResolveProjectByPath(path)
+		if want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("ResolveProjectByPath(%s) = %q, %v; want ErrNotFound", path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("ResolveProjectByPath(%s) = %q, %v; want %s", path, got, err, want)
+		}
+	}
+
+	doc := ContextDoc{ID: "d1", Title: "build", Content: "make build", Source: "cli", Tags: "[]", CreatedAt: time.Now(), Project: "tbyd"}
+	if err := s.SaveContextDoc(doc); err != nil {
+		t.Fatalf("SaveContextDoc: %v", err)
+	}
+	if got, _ := s.GetContextDoc("d1"); got.Project != "tbyd" {
+		t.Errorf("doc project = %q, want tbyd", got.Project)
+	}
+
+	if err := s.DeleteProject("tbyd"); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if err := s.DeleteProject("tbyd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v, want ErrNotFound", err)
+	}
+	if got, _ := s.GetContextDoc("d1"); got.Project != "" {
+		t.Errorf("doc project after delete = %q, want none", got.Project)
+	}
+	if got, err := s.ResolveProjectByPath("/src/tbyd/internal"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted project still resolves: %q, %v", got, err)
+	}
+	if list, _ := s.ListProjects(); len(list) != 1 || list[0].Name != "tbyd-docs" {
+		t.Errorf("ListProjects = %+v, want tbyd-docs only", list)
+	}
+}
+
 func TestSaveAndListContextDocs(t *testing.T) {
 	s := openTestStore(t)
 
@@ -934,3 +1200,644 @@ func TestResetStaleJobs_EmptyTypes(t *testing.T) {
 		t.Errorf("reset count = %d, want 0", count)
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	src, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+
+	doc := ContextDoc{ID: "doc-1", Title: "t", Content: "snapshot me", Source: "cli", Tags: "[]", CreatedAt: time.Now().UTC()}
+	if err := src.SaveContextDoc(doc); err != nil {
+		t.Fatalf("SaveContextDoc: %v", err)
+	}
+
+	snapDir := t.TempDir()
+	if err := src.Snapshot(context.Background(), snapDir+"/tbyd.db"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	// Writes after the snapshot must not be visible in it.
+	if err := src.DeleteContextDoc("doc-1"); err != nil {
+		t.Fatalf("DeleteContextDoc: %v", err)
+	}
+
+	snap, err := Open(snapDir)
+	if err != nil {
+		t.Fatalf("Open snapshot: %v", err)
+	}
+	defer snap.Close()
+
+	got, err := snap.GetContextDoc("doc-1")
+	if err != nil {
+		t.Fatalf("GetContextDoc from snapshot: %v", err)
+	}
+	if got.Content != "snapshot me" {
+		t.Errorf("content = %q, want %q", got.Content, "snapshot me")
+	}
+}
+
+func TestSnapshotReadOnly_LeavesSourceUnmigrated(t *testing.T) {
+	// A database on an older schema: only the first migration applied.
+	srcDir := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(srcDir, "tbyd.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		t.Fatalf("setting journal mode: %v", err)
+	}
+	first, err := migrationsFS.ReadFile("migrations/001_initial.sql")
+	if err != nil {
+		t.Fatalf("reading first migration: %v", err)
+	}
+	if _, err := db.Exec(string(first)); err != nil {
+		t.Fatalf("applying first migration: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER PRIMARY KEY, applied_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		INSERT OR IGNORE INTO schema_version (version) VALUES (1)`); err != nil {
+		t.Fatalf("recording schema version: %v", err)
+	}
+	db.Close()
+
+	snapDir := t.TempDir()
+	if err := SnapshotReadOnly(context.Background(), srcDir, filepath.Join(snapDir, "tbyd.db")); err != nil {
+		t.Fatalf("SnapshotReadOnly: %v", err)
+	}
+
+	db, err = sql.Open("sqlite", filepath.Join(srcDir, "tbyd.db"))
+	if err != nil {
+		t.Fatalf("reopening source: %v", err)
+	}
+	defer db.Close()
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		t.Fatalf("reading source schema version: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("source schema version = %d after snapshot, want 1 (no migrations on the live DB)", version)
+	}
+
+	snap, err := Open(snapDir)
+	if err != nil {
+		t.Fatalf("Open snapshot: %v", err)
+	}
+	snap.Close()
+}
+
+func TestSnapshotReadOnly_MissingDatabase(t *testing.T) {
+	if err := SnapshotReadOnly(context.Background(), t.TempDir(), filepath.Join(t.TempDir(), "tbyd.db")); err == nil {
+		t.Fatal("expected error for a data directory without a database")
+	}
+}
+
+func TestGetTopicActivity(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	seed := []Interaction{
+		{ID: "t1", CreatedAt: day, Topics: `["Rust","databases"]`, FeedbackScore: 1},
+		{ID: "t2", CreatedAt: day.Add(time.Hour), Topics: `["rust"]`},
+		{ID: "t3", CreatedAt: day.Add(48 * time.Hour), Topics: `[" RUST "]`},
+		{ID: "t4", CreatedAt: day.Add(72 * time.Hour), Topics: `["rust"]`, Persona: "work"},
+		{ID: "t5", CreatedAt: day.Add(-48 * time.Hour), Topics: `["rust"]`},
+		{ID: "t6", CreatedAt: day.Add(96 * time.Hour)},
+	}
+	for _, i := range seed {
+		if err := s.SaveInteraction(ctx, i); err != nil {
+			t.Fatalf("SaveInteraction(%s): %v", i.ID, err)
+		}
+	}
+
+	activity, err := s.GetTopicActivity(day)
+	if err != nil {
+		t.Fatalf("GetTopicActivity: %v", err)
+	}
+	if len(activity) != 2 {
+		t.Fatalf("got %d topics, want 2: %+v", len(activity), activity)
+	}
+	rust := activity[0]
+	if rust.Topic != "rust" || rust.Mentions != 3 || rust.ActiveDays != 2 || rust.PositiveFeedback != 1 {
+		t.Errorf("rust activity = %+v, want 3 mentions on 2 days with 1 positive", rust)
+	}
+	if !rust.FirstSeen.Equal(day) || !rust.LastSeen.Equal(day.Add(48*time.Hour)) {
+		t.Errorf("rust seen %v..%v, want %v..%v", rust.FirstSeen, rust.LastSeen, day, day.Add(48*time.Hour))
+	}
+	if activity[1].Topic != "databases" || activity[1].Mentions != 1 {
+		t.Errorf("second topic = %+v, want databases with 1 mention", activity[1])
+	}
+
+	n, err := s.CountTopicInteractionsSince(day)
+	if err != nil {
+		t.Fatalf("CountTopicInteractionsSince: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("CountTopicInteractionsSince = %d, want 3", n)
+	}
+}
+
+func TestPendingDelta_EvidenceRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+
+	if err := s.SavePendingDelta(PendingProfileDelta{
+		ID:           "d-evidence",
+		DeltaJSON:    `{"PromoteInterests":["rust"]}`,
+		Description:  "promote rust",
+		Source:       "interest_review",
+		CreatedAt:    time.Now().UTC(),
+		EvidenceJSON: `[{"interest":"rust","mentions":6}]`,
+	}); err != nil {
+		t.Fatalf("SavePendingDelta: %v", err)
+	}
+
+	got, err := s.GetPendingDelta("d-evidence")
+	if err != nil {
+		t.Fatalf("GetPendingDelta: %v", err)
+	}
+	if got.EvidenceJSON != `[{"interest":"rust","mentions":6}]` {
+		t.Errorf("EvidenceJSON = %q", got.EvidenceJSON)
+	}
+}
+
+func TestDeltaDecision_UndoOnce(t *testing.T) {
+	s := openTestStore(t)
+
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.SaveDeltaDecision(DeltaDecision{
+		ID:          "dec-1",
+		DeltaID:     "d-1",
+		Action:      "accepted",
+		Rule:        "feedback_additions",
+		AppliedJSON: `{"AddPreferences":["uses Go"]}`,
+		CreatedAt:   created,
+	}); err != nil {
+		t.Fatalf("SaveDeltaDecision: %v", err)
+	}
+
+	list, err := s.ListDeltaDecisions(10, 0)
+	if err != nil {
+		t.Fatalf("ListDeltaDecisions: %v", err)
+	}
+	if len(list) != 1 || list[0].Rule != "feedback_additions" || !list[0].CreatedAt.Equal(created) || list[0].UndoneAt != nil {
+		t.Fatalf("decisions = %+v", list)
+	}
+
+	if err := s.MarkDecisionUndone("dec-1"); err != nil {
+		t.Fatalf("MarkDecisionUndone: %v", err)
+	}
+	if err := s.MarkDecisionUndone("dec-1"); !errors.Is(err, ErrAlreadyUndone) {
+		t.Errorf("second MarkDecisionUndone = %v, want ErrAlreadyUndone", err)
+	}
+	if err := s.MarkDecisionUndone("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkDecisionUndone(missing) = %v, want ErrNotFound", err)
+	}
+
+	overridden, err := s.ListOverriddenDeltaIDs()
+	if err != nil {
+		t.Fatalf("ListOverriddenDeltaIDs: %v", err)
+	}
+	if !overridden["d-1"] {
+		t.Errorf("overridden = %v, want d-1", overridden)
+	}
+
+	if err := s.ClearDecisionUndone("dec-1"); err != nil {
+		t.Fatalf("ClearDecisionUndone: %v", err)
+	}
+	got, err := s.GetDeltaDecision("dec-1")
+	if err != nil {
+		t.Fatalf("GetDeltaDecision: %v", err)
+	}
+	if got.UndoneAt != nil {
+		t.Errorf("UndoneAt = %v after clear, want nil", got.UndoneAt)
+	}
+}
+
+func TestScheduleRuns_Upsert(t *testing.T) {
+	s := openTestStore(t)
+
+	first := time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	if err := s.SetScheduleRun("nightly_synthesis", first); err != nil {
+		t.Fatalf("SetScheduleRun: %v", err)
+	}
+	if err := s.SetScheduleRun("nightly_synthesis", second); err != nil {
+		t.Fatalf("SetScheduleRun (update): %v", err)
+	}
+
+	runs, err := s.GetScheduleRuns()
+	if err != nil {
+		t.Fatalf("GetScheduleRuns: %v", err)
+	}
+	if len(runs) != 1 || !runs["nightly_synthesis"].Equal(second) {
+		t.Errorf("runs = %v, want nightly_synthesis at %s", runs, second)
+	}
+}
+
+func TestJobs_ListRetryDelete(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	for _, j := range []Job{
+		{ID: "a", Type: "summarize", PayloadJSON: "{}", MaxAttempts: 1},
+		{ID: "b", Type: "summarize", PayloadJSON: "{}"},
+		{ID: "c", Type: "nightly_synthesis", PayloadJSON: "{}"},
+	} {
+		if err := s.EnqueueJob(ctx, j); err != nil {
+			t.Fatalf("EnqueueJob(%s): %v", j.ID, err)
+		}
+	}
+	if _, err := s.ClaimNextJob([]string{"nightly_synthesis"}); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := s.ListJobs("summarize", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("summarize jobs = %d, want 2", len(jobs))
+	}
+	jobs, err = s.ListJobs("", "running", 10, 0)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "c" {
+		t.Errorf("running jobs = %+v, want c", jobs)
+	}
+
+	if err := s.RetryJob("c"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("RetryJob(running) = %v, want ErrJobRunning", err)
+	}
+	if err := s.DeleteJob("c"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("DeleteJob(running) = %v, want ErrJobRunning", err)
+	}
+	if err := s.RetryJob("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RetryJob(missing) = %v, want ErrNotFound", err)
+	}
+	if err := s.RetryJob("b"); !errors.Is(err, ErrJobNotFailed) {
+		t.Errorf("RetryJob(pending) = %v, want ErrJobNotFailed", err)
+	}
+
+	if err := s.DeleteJob("b"); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+	if err := s.DeleteJob("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteJob twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKnowledgeGraph(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"d1", "d2"} {
+		if err := s.SaveContextDoc(ContextDoc{ID: id, Title: id, Content: "content", Source: "file", Tags: "[]"}); err != nil {
+			t.Fatalf("SaveContextDoc(%s): %v", id, err)
+		}
+	}
+
+	err := s.ReplaceDocGraph("d1", DocGraph{
+		Entities: []DocEntity{
+			{Name: "Alice", Kind: "person"},
+			{Name: "tbyd", Kind: "project", Aliases: []string{"To Be Your  Double"}},
+		},
+		Relations: []DocRelation{
+			{Source: "Alice", Relation: "Works On", Target: "tbyd"},
+			{Source: "tbyd", Relation: "uses", Target: "SQLite"},          // endpoint not listed as an entity
+			{Source: "tbyd", Relation: "is", Target: "to be your double"}, // self-relation via alias
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocGraph(d1): %v", err)
+	}
+	err = s.ReplaceDocGraph("d2", DocGraph{
+		Entities: []DocEntity{{Name: "sqlite", Kind: "technology"}, {Name: "to be your double"}},
+		Relations: []DocRelation{
+			{Source: "To Be Your Double", Relation: "uses", Target: "SQLite"},
+			{Source: "Bob", Relation: "uses", Target: "SQLite"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocGraph(d2): %v", err)
+	}
+
+	tbyd, err := s.FindEntity(GraphScope{}, "TO BE YOUR DOUBLE")
+	if err != nil {
+		t.Fatalf("FindEntity by alias: %v", err)
+	}
+	if tbyd.Name != "tbyd" || tbyd.Kind != "project" || tbyd.Mentions != 2 {
+		t.Errorf("entity = %+v, want tbyd, project, 2 mentions", tbyd.Entity)
+	}
+	if !reflect.DeepEqual(tbyd.Aliases, []string{"tbyd", "to be your double"}) {
+		t.Errorf("aliases = %v", tbyd.Aliases)
+	}
+	if !reflect.DeepEqual(tbyd.DocIDs, []string{"d1", "d2"}) {
+		t.Errorf("doc IDs = %v", tbyd.DocIDs)
+	}
+	wantRels := []EntityRelation{
+		{Relation: "uses", Direction: "out", OtherName: "SQLite", Weight: 2},
+		{Relation: "works_on", Direction: "in", OtherName: "Alice", Weight: 1},
+	}
+	if len(tbyd.Relations) != len(wantRels) {
+		t.Fatalf("relations = %+v, want %d", tbyd.Relations, len(wantRels))
+	}
+	for i, want := range wantRels {
+		got := tbyd.Relations[i]
+		got.OtherID = ""
+		if got != want {
+			t.Errorf("relation %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	sqlite, err := s.FindEntity(GraphScope{}, "SQLite")
+	if err != nil {
+		t.Fatalf("FindEntity(SQLite): %v", err)
+	}
+	if sqlite.Kind != "technology" {
+		t.Errorf("SQLite kind = %q, want technology once a doc types it", sqlite.Kind)
+	}
+
+	related, err := s.RelatedEntities(ctx, GraphScope{}, []string{"alice", "tbyd"}, 5)
+	if err != nil {
+		t.Fatalf("RelatedEntities: %v", err)
+	}
+	if !reflect.DeepEqual(related, []string{"SQLite"}) {
+		t.Errorf("RelatedEntities(alice, tbyd) = %v, want [SQLite]", related)
+	}
+	related, err = s.RelatedEntities(ctx, GraphScope{}, []string{"SQLite", "unknown"}, 1)
+	if err != nil {
+		t.Fatalf("RelatedEntities: %v", err)
+	}
+	if !reflect.DeepEqual(related, []string{"tbyd"}) {
+		t.Errorf("RelatedEntities(SQLite) limit 1 = %v, want [tbyd]", related)
+	}
+
+	list, err := s.ListEntities(GraphScope{}, "", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ListEntities: %v", err)
+	}
+	if len(list) != 4 || list[0].Mentions != 2 {
+		t.Errorf("ListEntities = %+v, want 4 entities, most mentioned first", list)
+	}
+	list, err = s.ListEntities(GraphScope{}, "double", "project", 10, 0)
+	if err != nil {
+		t.Fatalf("ListEntities(double, project): %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "tbyd" {
+		t.Errorf("ListEntities(double, project) = %+v, want tbyd", list)
+	}
+
+	// Deleting a doc removes what it contributed.
+	if err := s.DeleteContextDoc("d1"); err != nil {
+		t.Fatalf("DeleteContextDoc: %v", err)
+	}
+	if _, err := s.FindEntity(GraphScope{}, "Alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindEntity(Alice) after delete = %v, want ErrNotFound", err)
+	}
+	tbyd, err = s.GetEntity(GraphScope{}, tbyd.ID)
+	if err != nil {
+		t.Fatalf("GetEntity: %v", err)
+	}
+	if tbyd.Mentions != 1 || len(tbyd.Relations) != 1 || tbyd.Relations[0].Weight != 1 {
+		t.Errorf("after delete = %+v, want one mention and one relation", tbyd)
+	}
+}
+
+func TestKnowledgeGraph_PartitionScope(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	for _, d := range []ContextDoc{
+		{ID: "shared", Title: "shared", Content: "content", Source: "file", Tags: "[]"},
+		{ID: "work", Title: "work", Content: "content", Source: "file", Tags: "[]", Partition: "work"},
+	} {
+		if err := s.SaveContextDoc(d); err != nil {
+			t.Fatalf("SaveContextDoc(%s): %v", d.ID, err)
+		}
+	}
+	if err := s.ReplaceDocGraph("shared", DocGraph{
+		Entities:  []DocEntity{{Name: "tbyd"}, {Name: "SQLite"}},
+		Relations: []DocRelation{{Source: "tbyd", Relation: "uses", Target: "SQLite"}},
+	}); err != nil {
+		t.Fatalf("ReplaceDocGraph(shared): %v", err)
+	}
+	if err := s.ReplaceDocGraph("work", DocGraph{
+		Entities:  []DocEntity{{Name: "tbyd"}, {Name: "Acme"}},
+		Relations: []DocRelation{{Source: "Acme", Relation: "funds", Target: "tbyd"}},
+	}); err != nil {
+		t.Fatalf("ReplaceDocGraph(work): %v", err)
+	}
+
+	shared := GraphScope{Partitioned: true}
+	if _, err := s.FindEntity(shared, "Acme"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindEntity(Acme) in shared = %v, want ErrNotFound", err)
+	}
+	tbyd, err := s.FindEntity(shared, "tbyd")
+	if err != nil {
+		t.Fatalf("FindEntity(tbyd) in shared: %v", err)
+	}
+	if tbyd.Mentions != 1 || len(tbyd.Relations) != 1 || tbyd.Relations[0].OtherName != "SQLite" || len(tbyd.DocIDs) != 1 || tbyd.DocIDs[0] != "shared" {
+		t.Errorf("tbyd in shared = %+v, want only the shared doc's mention and relation", tbyd)
+	}
+
+	related, err := s.RelatedEntities(ctx, shared, []string{"tbyd"}, 5)
+	if err != nil {
+		t.Fatalf("RelatedEntities(shared): %v", err)
+	}
+	if len(related) != 1 || related[0] != "SQLite" {
+		t.Errorf("RelatedEntities(shared) = %v, want [SQLite]", related)
+	}
+	related, err = s.RelatedEntities(ctx, GraphScope{Partitioned: true, Partition: "work"}, []string{"tbyd"}, 5)
+	if err != nil {
+		t.Fatalf("RelatedEntities(work): %v", err)
+	}
+	if len(related) != 1 || related[0] != "Acme" {
+		t.Errorf("RelatedEntities(work) = %v, want [Acme]", related)
+	}
+
+	list, err := s.ListEntities(shared, "", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ListEntities(shared): %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("ListEntities(shared) = %+v, want tbyd and sqlite", list)
+	}
+	list, err = s.ListEntities(GraphScope{}, "", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ListEntities: %v", err)
+	}
+	if len(list) != 3 || list[0].Name != "tbyd" || list[0].Mentions != 2 {
+		t.Errorf("ListEntities = %+v, want all three, tbyd mentioned twice", list)
+	}
+}
+
+func TestMemoryConsolidation(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	old := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	for i, id := range []string{"ix-1", "ix-2", "ix-3"} {
+		if err := s.SaveInteraction(ctx, Interaction{
+			ID: id, CreatedAt: old.Add(time.Duration(i) * time.Hour), UserQuery: "q " + id,
+			Status: "completed", VectorIDs: "[]", Topics: `["go"]`,
+		}); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+		if _, err := s.db.Exec(`INSERT INTO context_vectors (id, source_id, source_type, text_chunk, embedding, created_at, tags, quality_score)
+			VALUES (?, ?, 'interaction', ?, x'', ?, '[]', 1.2)`, "v-"+id, id, "summary of "+id, old.Format(time.RFC3339)); err != nil {
+			t.Fatalf("insert vector: %v", err)
+		}
+	}
+
+	mems, err := s.ListEpisodicMemories(old.Add(2*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListEpisodicMemories: %v", err)
+	}
+	if len(mems) != 2 || mems[0].InteractionID != "ix-1" || mems[0].VectorID != "v-ix-1" || mems[0].Summary != "summary of ix-1" {
+		t.Fatalf("ListEpisodicMemories = %+v, want ix-1 and ix-2", mems)
+	}
+
+	doc := ContextDoc{ID: "mem-1", Title: "Go", Content: "memory", Source: "memory_consolidation", Tags: "[]", CreatedAt: time.Now()}
+	if err := s.SaveConsolidatedMemory(doc, mems, 0.25); err != nil {
+		t.Fatalf("SaveConsolidatedMemory: %v", err)
+	}
+
+	var quality float64
+	if err := s.db.QueryRow(`SELECT quality_score FROM context_vectors WHERE id = 'v-ix-1'`).Scan(&quality); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(quality-0.3) > 1e-9 {
+		t.Errorf("down-weighted quality = %v, want 0.3", quality)
+	}
+	mems, err = s.ListEpisodicMemories(old.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListEpisodicMemories: %v", err)
+	}
+	if len(mems) != 1 || mems[0].InteractionID != "ix-3" {
+		t.Errorf("after consolidation = %+v, want only ix-3", mems)
+	}
+
+	sources, err := s.GetConsolidationSources("mem-1")
+	if err != nil {
+		t.Fatalf("GetConsolidationSources: %v", err)
+	}
+	if len(sources) != 2 || sources[0].UserQuery != "q ix-1" || sources[1].Summary != "summary of ix-2" {
+		t.Errorf("sources = %+v", sources)
+	}
+
+	again := ContextDoc{ID: "mem-2", Title: "Go", Content: "memory", Source: "memory_consolidation", Tags: "[]", CreatedAt: time.Now()}
+	if err := s.SaveConsolidatedMemory(again, []EpisodicMemory{{InteractionID: "ix-1", VectorID: "v-ix-1"}}, 0.25); !errors.Is(err, ErrConflict) {
+		t.Errorf("consolidating ix-1 twice = %v, want ErrConflict", err)
+	}
+	if _, err := s.GetContextDoc("mem-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("failed consolidation left doc mem-2 behind: %v", err)
+	}
+
+	// Deleting the memory restores its sources.
+	if err := s.DeleteContextDoc("mem-1"); err != nil {
+		t.Fatalf("DeleteContextDoc: %v", err)
+	}
+	if err := s.db.QueryRow(`SELECT quality_score FROM context_vectors WHERE id = 'v-ix-1'`).Scan(&quality); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(quality-1.2) > 1e-9 {
+		t.Errorf("restored quality = %v, want 1.2", quality)
+	}
+	mems, err = s.ListEpisodicMemories(old.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListEpisodicMemories: %v", err)
+	}
+	if len(mems) != 3 {
+		t.Errorf("after deleting the memory = %d candidates, want 3", len(mems))
+	}
+}
+
+func TestExtractedFacts(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	old := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	for i, ix := range []Interaction{
+		{ID: "ix-1", Status: "completed"},
+		{ID: "ix-2", Status: "completed"},
+		{ID: "ix-aborted", Status: "aborted"},
+	} {
+		ix.CreatedAt = old.Add(time.Duration(i) * time.Hour)
+		ix.UserQuery, ix.VectorIDs = "q "+ix.ID, "[]"
+		if err := s.SaveInteraction(ctx, ix); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+
+	candidates, err := s.ListFactExtractionCandidates(old.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListFactExtractionCandidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != "ix-1" || candidates[1].ID != "ix-2" {
+		t.Fatalf("candidates = %+v, want ix-1 and ix-2", candidates)
+	}
+
+	now := time.Now().UTC()
+	fact := func(id, statement string) PendingFact {
+		return PendingFact{ID: id, Kind: "decision", Statement: statement, Confidence: 0.9, CreatedAt: now}
+	}
+	n, err := s.SaveExtractedFacts("ix-1", []PendingFact{
+		fact("f-1", "Billing uses Postgres."),
+		fact("f-2", "The API is written in Go."),
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("SaveExtractedFacts = %d, %v; want 2", n, err)
+	}
+	// A repeated statement is dropped, but the interaction is still marked.
+	if n, err := s.SaveExtractedFacts("ix-2", []PendingFact{fact("f-3", "billing uses postgres.")}); err != nil || n != 0 {
+		t.Fatalf("SaveExtractedFacts(duplicate) = %d, %v; want 0", n, err)
+	}
+	if _, err := s.SaveExtractedFacts("ix-1", nil); !errors.Is(err, ErrConflict) {
+		t.Errorf("extracting ix-1 twice = %v, want ErrConflict", err)
+	}
+	if candidates, err := s.ListFactExtractionCandidates(old.Add(24*time.Hour), 10); err != nil || len(candidates) != 0 {
+		t.Errorf("candidates after extraction = %+v, %v; want none", candidates, err)
+	}
+
+	pending, err := s.ListPendingFacts(10, 0)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("ListPendingFacts = %+v, %v; want 2", pending, err)
+	}
+
+	doc := ContextDoc{ID: "doc-f-1", Title: "Decision", Content: "Billing uses Postgres 16.", Source: "fact_extraction", Tags: "[]", CreatedAt: now}
+	if err := s.AcceptFact("f-1", "Billing uses Postgres 16.", doc); err != nil {
+		t.Fatalf("AcceptFact: %v", err)
+	}
+	got, err := s.GetPendingFact("f-1")
+	if err != nil {
+		t.Fatalf("GetPendingFact: %v", err)
+	}
+	if got.Accepted == nil || !*got.Accepted || got.DocID != "doc-f-1" || got.Statement != "Billing uses Postgres 16." {
+		t.Errorf("accepted fact = %+v", got)
+	}
+	if _, err := s.GetContextDoc("doc-f-1"); err != nil {
+		t.Errorf("fact doc not saved: %v", err)
+	}
+	again := ContextDoc{ID: "doc-f-1-again", Title: "Decision", Content: "x", Source: "fact_extraction", Tags: "[]", CreatedAt: now}
+	if err := s.AcceptFact("f-1", "x", again); !errors.Is(err, ErrAlreadyReviewed) {
+		t.Errorf("accepting f-1 twice = %v, want ErrAlreadyReviewed", err)
+	}
+	if _, err := s.GetContextDoc("doc-f-1-again"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second acceptance saved a doc: %v", err)
+	}
+
+	if err := s.RejectFact("f-2"); err != nil {
+		t.Fatalf("RejectFact: %v", err)
+	}
+	if err := s.RejectFact("f-2"); !errors.Is(err, ErrAlreadyReviewed) {
+		t.Errorf("rejecting f-2 twice = %v, want ErrAlreadyReviewed", err)
+	}
+	if err := s.RejectFact("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rejecting a missing fact = %v, want ErrNotFound", err)
+	}
+	if pending, err := s.ListPendingFacts(10, 0); err != nil || len(pending) != 0 {
+		t.Errorf("pending after review = %+v, %v; want none", pending, err)
+	}
+}
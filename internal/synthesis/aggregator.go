@@ -109,7 +109,11 @@ func AggregateFromCounts(reader SignalCountReader) (profile.ProfileDelta, error)
 	if err != nil {
 		return profile.ProfileDelta{}, err
 	}
+	return aggregateCounts(counts), nil
+}
 
+// aggregateCounts applies the activation rules to per-pattern signal counts.
+func aggregateCounts(counts []storage.SignalCount) profile.ProfileDelta {
 	var delta profile.ProfileDelta
 	for _, c := range counts {
 		shouldAdd, shouldRemove := ShouldActivate(c.PositiveCount, c.NegativeCount)
@@ -123,5 +127,5 @@ func AggregateFromCounts(reader SignalCountReader) (profile.ProfileDelta, error)
 			delta.RemovePreferences = append(delta.RemovePreferences, c.PatternDisplay)
 		}
 	}
-	return delta, nil
+	return delta
 }
@@ -0,0 +1,200 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type mockConsolidationStore struct {
+	memories []storage.EpisodicMemory
+	before   time.Time
+	saved    []storage.ContextDoc
+	sources  map[string][]storage.EpisodicMemory
+	weights  []float64
+	enqueued []storage.Job
+}
+
+func (m *mockConsolidationStore) ListEpisodicMemories(before time.Time, limit int) ([]storage.EpisodicMemory, error) {
+	m.before = before
+	var out []storage.EpisodicMemory
+	for _, mem := range m.memories {
+		if mem.CreatedAt.Before(before) && len(out) < limit {
+			out = append(out, mem)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockConsolidationStore) SaveConsolidatedMemory(doc storage.ContextDoc, sources []storage.EpisodicMemory, weight float64) error {
+	if m.sources == nil {
+		m.sources = make(map[string][]storage.EpisodicMemory)
+	}
+	m.saved = append(m.saved, doc)
+	m.sources[doc.ID] = sources
+	m.weights = append(m.weights, weight)
+	return nil
+}
+
+func (m *mockConsolidationStore) EnqueueJob(_ context.Context, job storage.Job) error {
+	m.enqueued = append(m.enqueued, job)
+	return nil
+}
+
+func (m *mockConsolidationStore) ClaimNextJob(_ []string) (*storage.Job, error) { return nil, nil }
+func (m *mockConsolidationStore) CompleteJob(_ string) error                    { return nil }
+func (m *mockConsolidationStore) FailJob(_ string, _ string) error              { return nil }
+
+// episode returns an interaction summary on topic created at t.
+func episode(id, topic string, t time.Time) storage.EpisodicMemory {
+	topics, _ := json.Marshal([]string{topic})
+	return storage.EpisodicMemory{
+		InteractionID: id,
+		VectorID:      "v-" + id,
+		Summary:       "summary of " + id,
+		Topics:        string(topics),
+		CreatedAt:     t,
+	}
+}
+
+func TestClusterEpisodes(t *testing.T) {
+	// 2026-03-02 is a Monday, so the first week runs through 2026-03-08.
+	week := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	memories := []storage.EpisodicMemory{
+		episode("go-1", "Go", week),
+		episode("go-2", "go", week.Add(24*time.Hour)),
+		episode("go-3", "Go", week.Add(48*time.Hour)),
+		episode("go-next-week", "Go", week.Add(7*24*time.Hour)),
+		episode("rust-1", "rust", week),
+		episode("rust-2", "rust", week.Add(time.Hour)),
+		{InteractionID: "no-topic", Topics: "[]", CreatedAt: week},
+	}
+	shared := episode("go-partitioned", "go", week)
+	shared.Partition = "work"
+	memories = append(memories, shared)
+
+	clusters := clusterEpisodes(memories, 7*24*time.Hour, 3)
+	if len(clusters) != 1 {
+		t.Fatalf("got %d clusters, want 1: %+v", len(clusters), clusters)
+	}
+	var ids []string
+	for _, m := range clusters[0].memories {
+		ids = append(ids, m.InteractionID)
+	}
+	if clusters[0].topic != "go" || strings.Join(ids, ",") != "go-1,go-2,go-3" {
+		t.Errorf("cluster = %s: %v, want go: go-1,go-2,go-3", clusters[0].topic, ids)
+	}
+
+	// Large clusters are split evenly.
+	var many []storage.EpisodicMemory
+	for i := range 45 {
+		many = append(many, episode(fmt.Sprintf("m-%02d", i), "go", week.Add(time.Duration(i)*time.Minute)))
+	}
+	clusters = clusterEpisodes(many, 7*24*time.Hour, 3)
+	if len(clusters) != 3 {
+		t.Fatalf("got %d clusters for 45 summaries, want 3", len(clusters))
+	}
+	for _, cl := range clusters {
+		if len(cl.memories) != 15 {
+			t.Errorf("cluster size = %d, want 15", len(cl.memories))
+		}
+	}
+}
+
+func TestMemoryConsolidator_Run(t *testing.T) {
+	now := time.Date(2026, 5, 1, 5, 0, 0, 0, time.UTC)
+	old := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	store := &mockConsolidationStore{memories: []storage.EpisodicMemory{
+		episode("ix-1", "go", old),
+		episode("ix-2", "go", old.Add(time.Hour)),
+		episode("ix-3", "go", old.Add(2*time.Hour)),
+		episode("recent", "go", now.Add(-24*time.Hour)),
+	}}
+	chatter := &deepMockChatter{response: `{"title": "Go error handling", "memory": "The user standardised on wrapped errors."}`}
+	c := NewMemoryConsolidator(store, chatter, "test-model", ConsolidationPolicy{Enabled: true})
+	c.now = func() time.Time { return now }
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !store.before.Equal(now.Add(-defaultConsolidationMinAge)) {
+		t.Errorf("candidates listed before %s, want %s", store.before, now.Add(-defaultConsolidationMinAge))
+	}
+	if len(store.saved) != 1 {
+		t.Fatalf("saved %d memories, want 1", len(store.saved))
+	}
+	doc := store.saved[0]
+	if doc.Source != consolidatedMemorySource || !strings.HasPrefix(doc.Title, "Go error handling (2026-03-02") {
+		t.Errorf("doc = %+v", doc)
+	}
+	if !strings.Contains(doc.Content, "wrapped errors") || !strings.Contains(doc.Content, "3 conversations") {
+		t.Errorf("content = %q", doc.Content)
+	}
+	var meta map[string]ConsolidationMetadata
+	if err := json.Unmarshal([]byte(doc.Metadata), &meta); err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	if got := meta["consolidation"].InteractionIDs; strings.Join(got, ",") != "ix-1,ix-2,ix-3" {
+		t.Errorf("metadata interaction IDs = %v", got)
+	}
+	if len(store.sources[doc.ID]) != 3 || store.weights[0] != consolidatedVectorWeight {
+		t.Errorf("sources = %d, weight = %v", len(store.sources[doc.ID]), store.weights[0])
+	}
+	if len(store.enqueued) != 1 || store.enqueued[0].Type != "ingest_enrich" || !strings.Contains(store.enqueued[0].PayloadJSON, doc.ID) {
+		t.Errorf("enqueued = %+v, want an ingest_enrich job for the memory", store.enqueued)
+	}
+}
+
+func TestMemoryConsolidator_RunFailures(t *testing.T) {
+	old := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	store := &mockConsolidationStore{memories: []storage.EpisodicMemory{
+		episode("ix-1", "go", old),
+		episode("ix-2", "go", old.Add(time.Hour)),
+		episode("ix-3", "go", old.Add(2*time.Hour)),
+	}}
+
+	for name, chatter := range map[string]*deepMockChatter{
+		"llm error":    {err: errors.New("model unavailable")},
+		"empty memory": {response: `{"title": "t", "memory": "  "}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewMemoryConsolidator(store, chatter, "test-model", ConsolidationPolicy{Enabled: true})
+			if err := c.Run(context.Background()); err == nil {
+				t.Error("Run() = nil, want an error when every cluster fails")
+			}
+			if len(store.saved) != 0 {
+				t.Errorf("saved %d memories, want 0", len(store.saved))
+			}
+		})
+	}
+
+	// Nothing to consolidate is not an error.
+	c := NewMemoryConsolidator(&mockConsolidationStore{}, &deepMockChatter{}, "test-model", ConsolidationPolicy{Enabled: true})
+	if err := c.Run(context.Background()); err != nil {
+		t.Errorf("Run() with no candidates = %v, want nil", err)
+	}
+}
+
+func TestMemoryConsolidator_Disabled(t *testing.T) {
+	old := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	store := &mockConsolidationStore{memories: []storage.EpisodicMemory{
+		episode("ix-1", "go", old),
+		episode("ix-2", "go", old.Add(time.Hour)),
+		episode("ix-3", "go", old.Add(2*time.Hour)),
+	}}
+	chatter := &deepMockChatter{response: `{"title": "t", "memory": "m"}`}
+
+	c := NewMemoryConsolidator(store, chatter, "test-model", ConsolidationPolicy{})
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if chatter.calls.Load() != 0 || len(store.saved) != 0 {
+		t.Errorf("disabled pass made %d LLM calls and saved %d memories, want none", chatter.calls.Load(), len(store.saved))
+	}
+}
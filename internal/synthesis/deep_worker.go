@@ -24,6 +24,7 @@ type DeepEnrichStore interface {
 	FailJob(id string, errMsg string) error
 	GetContextDoc(id string) (storage.ContextDoc, error)
 	UpdateContextDocTagsAndDeepMetadata(id, tags, deepMetadataJSON string) error
+	ReplaceDocGraph(docID string, g storage.DocGraph) error
 	ResetStaleJobs(jobTypes []string, timeout time.Duration) (int, error)
 	EnqueueJob(ctx context.Context, job storage.Job) error
 }
@@ -250,6 +251,11 @@ func (w *DeepEnrichmentWorker) Run(ctx context.Context) error {
 					continue
 				}
 
+				// The graph is derived data; a failure here does not fail the job.
+				if err := w.store.ReplaceDocGraph(doc.ID, docGraph(enrich)); err != nil {
+					w.logger.Warn("deep_enrich: failed to update knowledge graph", "doc_id", doc.ID, "error", err)
+				}
+
 				_ = w.store.CompleteJob(ref.jobID)
 				delete(refByDocID, doc.ID)
 			}
@@ -265,12 +271,41 @@ func (w *DeepEnrichmentWorker) Run(ctx context.Context) error {
 	return nil
 }
 
-// Schedule polls the idle detector and triggers Run when the system is idle,
-// and also fires at the scheduledHour (UTC) each day.
-//
-// idleCheckInterval controls how often idle state is checked.
-// scheduledHour (0–23) is the hour in UTC when the worker fires unconditionally.
-func (w *DeepEnrichmentWorker) Schedule(ctx context.Context, idleCheckInterval time.Duration, scheduledHour int) {
+// docGraph converts an enrichment into the doc's contribution to the
+// knowledge graph. Enriched entities the model did not also type are added
+// with kind "other".
+func docGraph(e DeepEnrichment) storage.DocGraph {
+	var g storage.DocGraph
+	seen := make(map[string]bool)
+	for _, ge := range e.GraphEntities {
+		key := storage.NormalizeEntityName(ge.Name)
+		if key == "" {
+			continue
+		}
+		seen[key] = true
+		for _, a := range ge.Aliases {
+			seen[storage.NormalizeEntityName(a)] = true
+		}
+		g.Entities = append(g.Entities, storage.DocEntity{Name: ge.Name, Kind: ge.Kind, Aliases: ge.Aliases})
+	}
+	for _, name := range e.EnrichedEntities {
+		key := storage.NormalizeEntityName(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.Entities = append(g.Entities, storage.DocEntity{Name: name, Kind: "other"})
+	}
+	for _, r := range e.Relations {
+		g.Relations = append(g.Relations, storage.DocRelation{Source: r.Source, Relation: r.Relation, Target: r.Target})
+	}
+	return g
+}
+
+// RunWhenIdle polls the idle detector every idleCheckInterval and triggers
+// Run whenever the system is idle, until ctx is cancelled. Scheduled runs
+// at a fixed time are left to the scheduler.
+func (w *DeepEnrichmentWorker) RunWhenIdle(ctx context.Context, idleCheckInterval time.Duration) {
 	if idleCheckInterval <= 0 {
 		idleCheckInterval = 5 * time.Minute
 	}
@@ -278,32 +313,11 @@ func (w *DeepEnrichmentWorker) Schedule(ctx context.Context, idleCheckInterval t
 	ticker := time.NewTicker(idleCheckInterval)
 	defer ticker.Stop()
 
-	// Suppress re-fire only if the server starts during the scheduled hour itself.
-	// If we start before or after the scheduled hour, lastScheduledDay = -1 ensures
-	// the first scheduled-hour tick fires normally.
-	lastScheduledDay := -1
-	if time.Now().UTC().Hour() == scheduledHour {
-		lastScheduledDay = time.Now().UTC().YearDay()
-	}
-
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			now := time.Now().UTC()
-
-			// Fire at the scheduled hour (once per day).
-			if now.Hour() == scheduledHour && now.YearDay() != lastScheduledDay {
-				lastScheduledDay = now.YearDay()
-				w.logger.Info("deep_enrich: scheduled run triggered", "hour", scheduledHour)
-				if err := w.Run(ctx); err != nil && ctx.Err() == nil {
-					w.logger.Error("deep_enrich: scheduled run failed", "error", err)
-				}
-				continue
-			}
-
-			// Also fire when idle.
 			if w.idle.IsIdle() {
 				w.logger.Info("deep_enrich: idle run triggered")
 				if err := w.Run(ctx); err != nil && ctx.Err() == nil {
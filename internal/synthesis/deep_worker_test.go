@@ -19,6 +19,7 @@ type mockDeepStore struct {
 	completedJobs []string
 	failedJobs    []string
 	updatedDocs   map[string]struct{ tags, deepMeta string }
+	graphs        map[string]storage.DocGraph
 	resetCount    int
 	claimErr      error
 }
@@ -27,6 +28,7 @@ func newMockDeepStore() *mockDeepStore {
 	return &mockDeepStore{
 		docs:        make(map[string]storage.ContextDoc),
 		updatedDocs: make(map[string]struct{ tags, deepMeta string }),
+		graphs:      make(map[string]storage.DocGraph),
 	}
 }
 
@@ -93,6 +95,13 @@ func (m *mockDeepStore) UpdateContextDocTagsAndDeepMetadata(id, tags, deepMetada
 	return nil
 }
 
+func (m *mockDeepStore) ReplaceDocGraph(docID string, g storage.DocGraph) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.graphs[docID] = g
+	return nil
+}
+
 func (m *mockDeepStore) ResetStaleJobs(_ []string, _ time.Duration) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -140,7 +149,7 @@ func successfulEnrichResponse(docIDs ...string) string {
 
 func buildWorker(store DeepEnrichStore, enricher *DeepEnricher) *DeepEnrichmentWorker {
 	batcher := NewBatcher(DefaultContextWindowTokens)
-	idle := NewIdleDetector(100, 0) // always idle in tests
+	idle := NewIdleDetector(100, 0, false) // always idle in tests
 	return NewDeepEnrichmentWorker(store, enricher, batcher, idle, 1000)
 }
 
@@ -256,6 +265,60 @@ func TestRun_AdditiveUpdate(t *testing.T) {
 	}
 }
 
+func TestRun_PopulatesGraph(t *testing.T) {
+	store := newMockDeepStore()
+	store.addDoc(storage.ContextDoc{ID: "doc-1", Content: "Alice moved tbyd to SQLite.", Tags: "[]"})
+	store.addJob("doc-1")
+
+	mockResp := `{
+		"enrichments": [{
+			"doc_id": "doc-1",
+			"enriched_entities": ["Alice", "tbyd", "SQLite", "Go"],
+			"enriched_topics": [],
+			"deep_key_points": [],
+			"cross_references": [],
+			"domain_classification": "engineering",
+			"relationship_notes": "",
+			"graph_entities": [
+				{"name": "Alice", "kind": "person", "aliases": []},
+				{"name": "tbyd", "kind": "project", "aliases": ["To Be Your Double"]},
+				{"name": "SQLite", "kind": "technology", "aliases": []}
+			],
+			"relations": [
+				{"source": "Alice", "relation": "works_on", "target": "tbyd"},
+				{"source": "tbyd", "relation": "uses", "target": "SQLite"}
+			]
+		}]
+	}`
+	worker := buildWorker(store, mockDeepEnricher(mockResp, nil))
+	if err := worker.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	g, ok := store.graphs["doc-1"]
+	if !ok {
+		t.Fatal("graph for doc-1 was not replaced")
+	}
+	kinds := make(map[string]string)
+	for _, e := range g.Entities {
+		kinds[e.Name] = e.Kind
+	}
+	want := map[string]string{"Alice": "person", "tbyd": "project", "SQLite": "technology", "Go": "other"}
+	if len(kinds) != len(want) {
+		t.Errorf("graph entities = %v, want %v", kinds, want)
+	}
+	for name, kind := range want {
+		if kinds[name] != kind {
+			t.Errorf("entity %q kind = %q, want %q", name, kinds[name], kind)
+		}
+	}
+	if len(g.Relations) != 2 || g.Relations[0] != (storage.DocRelation{Source: "Alice", Relation: "works_on", Target: "tbyd"}) {
+		t.Errorf("graph relations = %+v", g.Relations)
+	}
+}
+
 func TestDeepRun_ContextCancellation(t *testing.T) {
 	store := newMockDeepStore()
 
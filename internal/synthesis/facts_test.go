@@ -0,0 +1,151 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type mockFactStore struct {
+	interactions []storage.Interaction
+	before       time.Time
+	saved        map[string][]storage.PendingFact
+}
+
+func (m *mockFactStore) ListFactExtractionCandidates(before time.Time, limit int) ([]storage.Interaction, error) {
+	m.before = before
+	var out []storage.Interaction
+	for _, in := range m.interactions {
+		if _, done := m.saved[in.ID]; !done && in.CreatedAt.Before(before) && len(out) < limit {
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockFactStore) SaveExtractedFacts(interactionID string, facts []storage.PendingFact) (int, error) {
+	if m.saved == nil {
+		m.saved = make(map[string][]storage.PendingFact)
+	}
+	if _, done := m.saved[interactionID]; done {
+		return 0, storage.ErrConflict
+	}
+	m.saved[interactionID] = facts
+	return len(facts), nil
+}
+
+func (m *mockFactStore) ClaimNextJob(_ []string) (*storage.Job, error) { return nil, nil }
+func (m *mockFactStore) CompleteJob(_ string) error                    { return nil }
+func (m *mockFactStore) FailJob(_ string, _ string) error              { return nil }
+
+func TestFactExtractor_Run(t *testing.T) {
+	now := time.Date(2026, 5, 1, 4, 30, 0, 0, time.UTC)
+	store := &mockFactStore{interactions: []storage.Interaction{
+		{ID: "ix-1", CreatedAt: now.Add(-2 * time.Hour), UserQuery: "Postgres or Mongo for billing?", Partition: "work",
+			CloudResponse: `{"choices":[{"message":{"content":"Postgres, for transactions."}}]}`},
+		{ID: "in-progress", CreatedAt: now.Add(-time.Minute)},
+	}}
+	chatter := &deepMockChatter{response: `{"facts": [
+		{"kind": "Decision", "statement": "Billing uses Postgres rather than MongoDB for transactions.", "confidence": 0.9},
+		{"kind": "decision", "statement": "billing uses postgres rather than mongodb for transactions.", "confidence": 0.9},
+		{"kind": "fact", "statement": "The user might like Rust.", "confidence": 0.3},
+		{"kind": "opinion", "statement": "Mongo is bad.", "confidence": 0.9},
+		{"kind": "fact", "statement": "  ", "confidence": 0.9}
+	]}`}
+	e := NewFactExtractor(store, chatter, "test-model", 0, true)
+	e.now = func() time.Time { return now }
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !store.before.Equal(now.Add(-factSettleDelay)) {
+		t.Errorf("candidates listed before %s, want %s", store.before, now.Add(-factSettleDelay))
+	}
+	if _, ok := store.saved["in-progress"]; ok {
+		t.Error("interaction younger than the settle delay was processed")
+	}
+	facts := store.saved["ix-1"]
+	if len(facts) != 1 {
+		t.Fatalf("saved %d facts, want 1: %+v", len(facts), facts)
+	}
+	f := facts[0]
+	if f.Kind != FactKindDecision || f.InteractionID != "ix-1" || f.Partition != "work" || f.Confidence != 0.9 || f.ID == "" {
+		t.Errorf("fact = %+v", f)
+	}
+	if chatter.calls.Load() != 1 {
+		t.Errorf("LLM calls = %d, want 1", chatter.calls.Load())
+	}
+
+	// Interactions are processed once, even when they yield nothing.
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if chatter.calls.Load() != 1 {
+		t.Errorf("LLM calls after second pass = %d, want 1", chatter.calls.Load())
+	}
+}
+
+func TestFactExtractor_RunFailures(t *testing.T) {
+	for name, chatter := range map[string]*deepMockChatter{
+		"llm error":      {err: errors.New("model unavailable")},
+		"malformed JSON": {response: `not json`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := &mockFactStore{interactions: []storage.Interaction{{ID: "ix-1", CreatedAt: time.Now().Add(-time.Hour)}}}
+			e := NewFactExtractor(store, chatter, "test-model", 0.6, true)
+			if err := e.Run(context.Background()); err == nil {
+				t.Error("Run() = nil, want an error when every interaction fails")
+			}
+			if _, ok := store.saved["ix-1"]; ok {
+				t.Error("failed interaction was marked as processed")
+			}
+		})
+	}
+
+	// Nothing to extract is not an error.
+	e := NewFactExtractor(&mockFactStore{}, &deepMockChatter{}, "test-model", 0.6, true)
+	if err := e.Run(context.Background()); err != nil {
+		t.Errorf("Run() with no candidates = %v, want nil", err)
+	}
+}
+
+func TestFactExtractor_Disabled(t *testing.T) {
+	store := &mockFactStore{interactions: []storage.Interaction{{ID: "ix-1", CreatedAt: time.Now().Add(-time.Hour)}}}
+	chatter := &deepMockChatter{response: `{"facts": []}`}
+	e := NewFactExtractor(store, chatter, "test-model", 0.6, false)
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if chatter.calls.Load() != 0 || len(store.saved) != 0 {
+		t.Errorf("disabled pass made %d LLM calls and processed %d interactions, want none", chatter.calls.Load(), len(store.saved))
+	}
+}
+
+func TestFactDoc(t *testing.T) {
+	f := storage.PendingFact{
+		ID: "f-1", InteractionID: "ix-1", Kind: FactKindDecision, Confidence: 0.85, Partition: "work",
+		Statement: "Billing uses Postgres rather than MongoDB for transactions.",
+	}
+	doc, err := FactDoc(f, time.Now())
+	if err != nil {
+		t.Fatalf("FactDoc: %v", err)
+	}
+	if doc.Source != ExtractedFactSource || doc.Partition != "work" || !strings.HasPrefix(doc.Title, "Decision: Billing") {
+		t.Errorf("doc = %+v", doc)
+	}
+	if !strings.HasPrefix(doc.Content, f.Statement) || doc.Tags != `["extracted_fact","decision"]` {
+		t.Errorf("content = %q, tags = %s", doc.Content, doc.Tags)
+	}
+	var meta map[string]FactMetadata
+	if err := json.Unmarshal([]byte(doc.Metadata), &meta); err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	if got := meta["fact"]; got.InteractionID != "ix-1" || got.Confidence != 0.85 || got.Kind != FactKindDecision {
+		t.Errorf("metadata = %+v", got)
+	}
+}
@@ -1,8 +1,8 @@
 package synthesis
 
 // SystemStatsProvider abstracts OS-level CPU and memory queries.
-// The default implementation uses macOS command-line tools.
-// Tests may inject a fake implementation.
+// The default implementation uses macOS command-line tools on macOS and
+// procfs on Linux. Tests may inject a fake implementation.
 type SystemStatsProvider interface {
 	// CPUIdlePercent returns the percentage of CPU time that is idle (0–100).
 	// An error means "unknown"; callers treat unknown as idle (fail open).
@@ -13,18 +13,30 @@ type SystemStatsProvider interface {
 	AvailableMemoryGB() (float64, error)
 }
 
+// PowerSource is implemented by stats providers that can tell whether the
+// machine is running on battery.
+type PowerSource interface {
+	// OnBattery reports whether the machine is running on battery.
+	// An error means "unknown"; callers treat unknown as on mains power.
+	OnBattery() (bool, error)
+}
+
 // IdleDetector checks whether the system is idle enough for deep enrichment.
 type IdleDetector struct {
 	cpuMaxPercent int
 	memMinGB      int
+	skipOnBattery bool
 	stats         SystemStatsProvider
 }
 
-// NewIdleDetector creates an IdleDetector using the default macOS stats provider.
-func NewIdleDetector(cpuMaxPercent, memMinGB int) *IdleDetector {
+// NewIdleDetector creates an IdleDetector using the platform's default stats
+// provider. With skipOnBattery, a machine running on battery is never idle
+// where the provider can tell.
+func NewIdleDetector(cpuMaxPercent, memMinGB int, skipOnBattery bool) *IdleDetector {
 	return &IdleDetector{
 		cpuMaxPercent: cpuMaxPercent,
 		memMinGB:      memMinGB,
+		skipOnBattery: skipOnBattery,
 		stats:         defaultStatsProvider{},
 	}
 }
@@ -40,9 +52,18 @@ func newIdleDetectorWithProvider(cpuMaxPercent, memMinGB int, p SystemStatsProvi
 }
 
 // IsIdle returns true if CPU usage is below cpuMaxPercent AND available memory
-// is above memMinGB. Fails open: if either stat is unavailable the system is
-// treated as idle so the deep model eventually runs rather than never running.
+// is above memMinGB, and, when skipOnBattery is set, the machine is not on
+// battery. Fails open: if a stat is unavailable the system is treated as idle
+// so the deep model eventually runs rather than never running.
 func (d *IdleDetector) IsIdle() bool {
+	if d.skipOnBattery {
+		if ps, ok := d.stats.(PowerSource); ok {
+			if onBattery, err := ps.OnBattery(); err == nil && onBattery {
+				return false
+			}
+		}
+	}
+
 	cpuIdle, err := d.stats.CPUIdlePercent()
 	if err == nil {
 		cpuUsed := 100.0 - cpuIdle
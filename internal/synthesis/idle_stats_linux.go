@@ -0,0 +1,240 @@
+//go:build linux
+
+package synthesis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpuSampleInterval is how long CPUIdlePercent waits between the two
+// /proc/stat readings it compares.
+const cpuSampleInterval = time.Second
+
+// defaultStatsProvider is the Linux implementation of SystemStatsProvider.
+// It reads procfs and sysfs directly; the zero value uses /proc and /sys.
+type defaultStatsProvider struct {
+	procDir        string        // "" means /proc
+	sysDir         string        // "" means /sys
+	sampleInterval time.Duration // 0 means cpuSampleInterval
+	numCPU         int           // 0 means runtime.NumCPU()
+}
+
+func (p defaultStatsProvider) proc() string {
+	if p.procDir == "" {
+		return "/proc"
+	}
+	return p.procDir
+}
+
+func (p defaultStatsProvider) sys() string {
+	if p.sysDir == "" {
+		return "/sys"
+	}
+	return p.sysDir
+}
+
+// CPUIdlePercent samples /proc/stat twice, cpuSampleInterval apart, and
+// returns the share of CPU time spent idle in between. A short sample can
+// land in a gap of sustained work, such as between compiler invocations
+// during a build, so the result is capped by the idle share implied by the
+// one-minute load average.
+func (p defaultStatsProvider) CPUIdlePercent() (float64, error) {
+	statPath := filepath.Join(p.proc(), "stat")
+	before, err := readCPUTimes(statPath)
+	if err != nil {
+		return 0, err
+	}
+	interval := p.sampleInterval
+	if interval == 0 {
+		interval = cpuSampleInterval
+	}
+	time.Sleep(interval)
+	after, err := readCPUTimes(statPath)
+	if err != nil {
+		return 0, err
+	}
+	idle := cpuIdleBetween(before, after)
+
+	if load, err := readLoadAverage(filepath.Join(p.proc(), "loadavg")); err == nil {
+		cpus := p.numCPU
+		if cpus <= 0 {
+			cpus = runtime.NumCPU()
+		}
+		loadIdle := 100 * (1 - load/float64(cpus))
+		idle = min(idle, max(loadIdle, 0))
+	}
+	return idle, nil
+}
+
+// AvailableMemoryGB returns MemAvailable from /proc/meminfo in GB. Kernels
+// older than 3.14 lack MemAvailable; free + inactive memory is used instead,
+// as on macOS.
+func (p defaultStatsProvider) AvailableMemoryGB() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(p.proc(), "meminfo"))
+	if err != nil {
+		return 0, fmt.Errorf("reading meminfo: %w", err)
+	}
+	fields, err := parseMeminfo(data)
+	if err != nil {
+		return 0, err
+	}
+
+	kb, ok := fields["MemAvailable"]
+	if !ok {
+		free, hasFree := fields["MemFree"]
+		inactive, hasInactive := fields["Inactive"]
+		if !hasFree && !hasInactive {
+			return 0, fmt.Errorf("meminfo contained no recognised memory fields")
+		}
+		kb = free + inactive
+	}
+	return float64(kb) / (1024 * 1024), nil
+}
+
+// OnBattery reports whether the machine is running on battery: no external
+// supply in /sys/class/power_supply is online and a system battery is
+// discharging. Machines without power supply information, such as desktops
+// and most VMs, report false.
+func (p defaultStatsProvider) OnBattery() (bool, error) {
+	dir := filepath.Join(p.sys(), "class", "power_supply")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading power supplies: %w", err)
+	}
+
+	discharging := false
+	for _, e := range entries {
+		supply := filepath.Join(dir, e.Name())
+		if readSysfsValue(supply, "type") == "Battery" {
+			// Batteries of peripherals such as mice report scope "Device".
+			if readSysfsValue(supply, "scope") != "Device" && readSysfsValue(supply, "status") == "Discharging" {
+				discharging = true
+			}
+			continue
+		}
+		if readSysfsValue(supply, "online") == "1" {
+			return false, nil
+		}
+	}
+	return discharging, nil
+}
+
+// readSysfsValue returns the trimmed contents of a sysfs attribute, or "" if
+// it cannot be read.
+func readSysfsValue(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// cpuTimes holds the aggregate jiffy counters from the "cpu" line of
+// /proc/stat.
+type cpuTimes struct {
+	idle  uint64 // idle + iowait
+	total uint64 // user through steal; guest time is already counted in user
+}
+
+func readCPUTimes(path string) (cpuTimes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cpuTimes{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return parseCPUTimes(data)
+}
+
+// parseCPUTimes parses the aggregate line of /proc/stat, e.g.
+// "cpu  10000 200 3000 80000 500 0 300 0 0 0".
+func parseCPUTimes(data []byte) (cpuTimes, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+		if len(fields) < 5 {
+			return cpuTimes{}, fmt.Errorf("short cpu line in /proc/stat: %q", sc.Text())
+		}
+		var t cpuTimes
+		for i, f := range fields[1:min(len(fields), 9)] {
+			v, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("parsing cpu field %q: %w", f, err)
+			}
+			t.total += v
+			if i == 3 || i == 4 { // idle, iowait
+				t.idle += v
+			}
+		}
+		return t, nil
+	}
+	return cpuTimes{}, fmt.Errorf("cpu line not found in /proc/stat")
+}
+
+// cpuIdleBetween returns the idle percentage between two samples. If no time
+// elapsed between them it falls back to the average since boot.
+func cpuIdleBetween(before, after cpuTimes) float64 {
+	total, idle := after.total, after.idle
+	if after.total > before.total && after.idle >= before.idle {
+		total, idle = after.total-before.total, after.idle-before.idle
+	}
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(idle) / float64(total)
+}
+
+// readLoadAverage returns the one-minute load average from /proc/loadavg,
+// e.g. "0.50 0.40 0.30 1/234 5678".
+func readLoadAverage(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty %s", path)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing load average %q: %w", fields[0], err)
+	}
+	return v, nil
+}
+
+// parseMeminfo parses /proc/meminfo lines such as "MemAvailable: 8388608 kB"
+// into kilobyte values keyed by field name.
+func parseMeminfo(data []byte) (map[string]uint64, error) {
+	out := make(map[string]uint64)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		name, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing meminfo %s: %w", name, err)
+		}
+		out[name] = v
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package synthesis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func fixtureStats(sysDir string, numCPU int) defaultStatsProvider {
+	return defaultStatsProvider{
+		procDir:        "testdata/linux/proc",
+		sysDir:         sysDir,
+		sampleInterval: time.Nanosecond,
+		numCPU:         numCPU,
+	}
+}
+
+func TestLinuxStats_CPUIdlePercent(t *testing.T) {
+	// The fixture does not change between samples, so the idle share since
+	// boot is used: 80500 idle+iowait of 94000 jiffies.
+	got, err := fixtureStats("", 4).CPUIdlePercent()
+	if err != nil {
+		t.Fatalf("CPUIdlePercent: %v", err)
+	}
+	if want := 100 * 80500.0 / 94000; math.Abs(got-want) > 0.01 {
+		t.Errorf("CPUIdlePercent = %.2f, want %.2f", got, want)
+	}
+
+	// On one CPU the 0.50 load average means only 50% idle.
+	got, err = fixtureStats("", 1).CPUIdlePercent()
+	if err != nil {
+		t.Fatalf("CPUIdlePercent: %v", err)
+	}
+	if math.Abs(got-50) > 0.01 {
+		t.Errorf("CPUIdlePercent with load = %.2f, want 50", got)
+	}
+}
+
+func TestCPUIdleBetween(t *testing.T) {
+	before, err := parseCPUTimes([]byte("cpu  10000 200 3000 80000 500 0 300 0 0 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parseCPUTimes([]byte("cpu  10100 200 3050 80800 550 0 300 0 0 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cpuIdleBetween(before, after); math.Abs(got-85) > 0.01 {
+		t.Errorf("cpuIdleBetween = %.2f, want 85", got)
+	}
+
+	if _, err := parseCPUTimes([]byte("intr 1 2 3\n")); err == nil {
+		t.Error("parseCPUTimes without a cpu line = nil error, want error")
+	}
+}
+
+func TestLinuxStats_AvailableMemoryGB(t *testing.T) {
+	got, err := fixtureStats("", 4).AvailableMemoryGB()
+	if err != nil {
+		t.Fatalf("AvailableMemoryGB: %v", err)
+	}
+	if got != 8 {
+		t.Errorf("AvailableMemoryGB = %.2f, want 8 (MemAvailable)", got)
+	}
+
+	// Kernels without MemAvailable fall back to free + inactive.
+	fields, err := parseMeminfo([]byte("MemTotal: 16314040 kB\nMemFree: 1048576 kB\nInactive: 2097152 kB\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["MemAvailable"]; ok {
+		t.Fatal("unexpected MemAvailable")
+	}
+	if fields["MemFree"]+fields["Inactive"] != 3*1024*1024 {
+		t.Errorf("free + inactive = %d kB, want 3 GB", fields["MemFree"]+fields["Inactive"])
+	}
+}
+
+func TestLinuxStats_OnBattery(t *testing.T) {
+	tests := []struct {
+		sysDir string
+		want   bool
+	}{
+		{"testdata/linux/sys-ac", false},
+		{"testdata/linux/sys-battery", true},
+		{"testdata/linux/missing", false}, // desktops and VMs have no power supplies
+	}
+	for _, tt := range tests {
+		got, err := fixtureStats(tt.sysDir, 4).OnBattery()
+		if err != nil {
+			t.Fatalf("OnBattery(%s): %v", tt.sysDir, err)
+		}
+		if got != tt.want {
+			t.Errorf("OnBattery(%s) = %v, want %v", tt.sysDir, got, tt.want)
+		}
+	}
+}
+
+func TestIsIdle_LinuxFixtures(t *testing.T) {
+	d := newIdleDetectorWithProvider(20, 4, fixtureStats("testdata/linux/sys-ac", 4))
+	d.skipOnBattery = true
+	if !d.IsIdle() {
+		t.Error("IsIdle() = false on mains with 14% CPU and 8GB free, want true")
+	}
+
+	d.stats = fixtureStats("testdata/linux/sys-battery", 4)
+	if d.IsIdle() {
+		t.Error("IsIdle() = true on battery, want false")
+	}
+	d.skipOnBattery = false
+	if !d.IsIdle() {
+		t.Error("IsIdle() = false on battery with the check disabled, want true")
+	}
+}
@@ -1,8 +1,8 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package synthesis
 
-// defaultStatsProvider is a stub for non-macOS platforms.
+// defaultStatsProvider is a stub for platforms other than macOS and Linux.
 // It reports the system as NOT idle (high CPU, low memory) so the deep
 // enrichment worker only fires at the scheduled hour, never on idle triggers.
 // This prevents continuous LLM load on platforms where we cannot measure
@@ -89,3 +89,28 @@ func TestIsIdle_BothErrorsFailOpen(t *testing.T) {
 		t.Error("IsIdle() = false, want true (fail open when both stats unavailable)")
 	}
 }
+
+// fakePowerStats adds a battery reading to fakeStats.
+type fakePowerStats struct {
+	fakeStats
+	onBattery bool
+	err       error
+}
+
+func (f fakePowerStats) OnBattery() (bool, error) { return f.onBattery, f.err }
+
+func TestIsIdle_OnBattery(t *testing.T) {
+	stats := fakePowerStats{fakeStats: fakeStats{cpuIdlePct: 95.0, memGB: 8.0}, onBattery: true}
+	detector := newIdleDetectorWithProvider(10, 4, stats)
+	detector.skipOnBattery = true
+
+	if detector.IsIdle() {
+		t.Error("IsIdle() = true, want false (running on battery)")
+	}
+
+	// An unknown power source fails open like the other stats.
+	detector.stats = fakePowerStats{fakeStats: stats.fakeStats, err: errors.New("no power supply info")}
+	if !detector.IsIdle() {
+		t.Error("IsIdle() = false, want true (fail open on power source error)")
+	}
+}
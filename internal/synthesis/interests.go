@@ -0,0 +1,305 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/scheduler"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+const interestReviewJobType = "interest_review"
+
+func init() { scheduler.Register(interestReviewJobType, "30 3 * * *") }
+
+// Defaults for InterestReviewer. An emerging interest is promoted once it
+// recurs across enough interactions on enough distinct days within the
+// window; a primary interest decays once it has not come up at all within
+// the window while the user kept interacting.
+const (
+	defaultInterestWindow       = 30 * 24 * time.Hour
+	defaultPromoteMinMentions   = 5
+	defaultPromoteMinActiveDays = 3
+	defaultDecayMinInteractions = 20
+)
+
+// InterestStore abstracts the storage operations InterestReviewer needs.
+type InterestStore interface {
+	GetTopicActivity(since time.Time) ([]storage.TopicActivity, error)
+	CountTopicInteractionsSince(since time.Time) (int, error)
+	GetSignalCounts() ([]storage.SignalCount, error)
+	SavePendingDelta(delta storage.PendingProfileDelta) error
+	HasPendingDeltaForSource(source string, since time.Time) (bool, error)
+	ClaimNextJob(types []string) (*storage.Job, error)
+	CompleteJob(id string) error
+	FailJob(id string, errMsg string) error
+}
+
+// ProfileReader reads the profile a review pass proposes changes to.
+type ProfileReader interface {
+	GetProfile() (profile.Profile, error)
+}
+
+// InterestEvidence is the evidence recorded with each proposed interest
+// change, so a reviewer can see why it was suggested.
+type InterestEvidence struct {
+	Interest         string     `json:"interest"`
+	Change           string     `json:"change"` // "promote" | "decay"
+	WindowStart      time.Time  `json:"window_start"`
+	WindowEnd        time.Time  `json:"window_end"`
+	Mentions         int        `json:"mentions"`    // interactions mentioning the interest within the window
+	ActiveDays       int        `json:"active_days"` // distinct days with a mention within the window
+	PositiveFeedback int        `json:"positive_feedback"`
+	FirstSeen        *time.Time `json:"first_seen,omitempty"` // earliest mention within the window
+	LastSeen         *time.Time `json:"last_seen,omitempty"`  // latest mention ever recorded
+	Interactions     int        `json:"interactions"`         // interactions with topics within the window
+	PositiveSignals  int        `json:"positive_signals"`     // preference signals mentioning the interest
+	NegativeSignals  int        `json:"negative_signals"`
+}
+
+// InterestReviewer periodically compares the profile's interests with the
+// topics of recent interactions. It proposes promoting emerging interests
+// that keep recurring and decaying primary interests that have gone quiet,
+// as pending profile deltas for human review; it never edits the profile.
+type InterestReviewer struct {
+	store                InterestStore
+	profile              ProfileReader
+	window               time.Duration
+	promoteMinMentions   int
+	promoteMinActiveDays int
+	decayMinInteractions int
+	now                  func() time.Time
+	logger               *slog.Logger
+}
+
+// NewInterestReviewer creates an InterestReviewer with a 30-day window.
+func NewInterestReviewer(store InterestStore, prof ProfileReader) *InterestReviewer {
+	return &InterestReviewer{
+		store:                store,
+		profile:              prof,
+		window:               defaultInterestWindow,
+		promoteMinMentions:   defaultPromoteMinMentions,
+		promoteMinActiveDays: defaultPromoteMinActiveDays,
+		decayMinInteractions: defaultDecayMinInteractions,
+		now:                  time.Now,
+		logger:               slog.Default(),
+	}
+}
+
+// Run performs a single review pass and saves at most one pending delta
+// covering every proposed promotion and decay.
+//
+// Returns nil without writing a delta if there is nothing to propose or if an
+// earlier interest review is still awaiting review.
+func (r *InterestReviewer) Run(ctx context.Context) error {
+	exists, err := r.store.HasPendingDeltaForSource(interestReviewJobType, time.Time{})
+	if err != nil {
+		return fmt.Errorf("checking for existing pending delta: %w", err)
+	}
+	if exists {
+		r.logger.Info("interest_review: unreviewed delta already exists, skipping")
+		return nil
+	}
+
+	p, err := r.profile.GetProfile()
+	if err != nil {
+		return fmt.Errorf("loading profile: %w", err)
+	}
+	if len(p.Interests.Primary) == 0 && len(p.Interests.Emerging) == 0 {
+		r.logger.Info("interest_review: profile has no interests, skipping")
+		return nil
+	}
+
+	now := r.now().UTC()
+	since := now.Add(-r.window)
+
+	recent, err := r.store.GetTopicActivity(since)
+	if err != nil {
+		return fmt.Errorf("loading recent topic activity: %w", err)
+	}
+	allTime, err := r.store.GetTopicActivity(time.Time{})
+	if err != nil {
+		return fmt.Errorf("loading topic activity: %w", err)
+	}
+	interactions, err := r.store.CountTopicInteractionsSince(since)
+	if err != nil {
+		return fmt.Errorf("counting recent interactions: %w", err)
+	}
+	signals, err := r.store.GetSignalCounts()
+	if err != nil {
+		return fmt.Errorf("loading signal counts: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	recentByTopic := indexTopicActivity(recent)
+	allByTopic := indexTopicActivity(allTime)
+
+	var delta profile.ProfileDelta
+	var evidence []InterestEvidence
+	newEvidence := func(interest, change string) InterestEvidence {
+		ev := InterestEvidence{
+			Interest:     interest,
+			Change:       change,
+			WindowStart:  since,
+			WindowEnd:    now,
+			Interactions: interactions,
+		}
+		ev.PositiveSignals, ev.NegativeSignals = interestSignals(signals, interest)
+		if a, ok := recentByTopic[normalizeInterest(interest)]; ok {
+			ev.Mentions = a.Mentions
+			ev.ActiveDays = a.ActiveDays
+			ev.PositiveFeedback = a.PositiveFeedback
+			first := a.FirstSeen
+			ev.FirstSeen = &first
+		}
+		if a, ok := allByTopic[normalizeInterest(interest)]; ok {
+			last := a.LastSeen
+			ev.LastSeen = &last
+		}
+		return ev
+	}
+
+	for _, interest := range p.Interests.Emerging {
+		ev := newEvidence(interest, "promote")
+		if ev.Mentions < r.promoteMinMentions || ev.ActiveDays < r.promoteMinActiveDays {
+			continue
+		}
+		// Explicit negative preference signals outweigh topic recurrence.
+		if ev.NegativeSignals > ev.PositiveSignals {
+			continue
+		}
+		delta.PromoteInterests = append(delta.PromoteInterests, interest)
+		evidence = append(evidence, ev)
+	}
+
+	// Without enough recent interactions, silence says nothing about interest.
+	if interactions >= r.decayMinInteractions {
+		for _, interest := range p.Interests.Primary {
+			ev := newEvidence(interest, "decay")
+			// Only interests that once came up as topics can go quiet;
+			// never-mentioned ones may simply be phrased differently.
+			if ev.Mentions > 0 || ev.LastSeen == nil {
+				continue
+			}
+			delta.DecayInterests = append(delta.DecayInterests, interest)
+			evidence = append(evidence, ev)
+		}
+	}
+
+	if len(evidence) == 0 {
+		r.logger.Info("interest_review: no interest changes to propose")
+		return nil
+	}
+
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		return fmt.Errorf("marshalling profile delta: %w", err)
+	}
+	evidenceJSON, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("marshalling evidence: %w", err)
+	}
+
+	pending := storage.PendingProfileDelta{
+		ID:           uuid.New().String(),
+		DeltaJSON:    string(deltaJSON),
+		Description:  describeInterestDelta(delta),
+		Source:       interestReviewJobType,
+		CreatedAt:    now,
+		EvidenceJSON: string(evidenceJSON),
+	}
+	if err := r.store.SavePendingDelta(pending); err != nil {
+		return fmt.Errorf("saving pending delta: %w", err)
+	}
+
+	r.logger.Info("interest_review: pending delta saved",
+		"id", pending.ID,
+		"promote", len(delta.PromoteInterests),
+		"decay", len(delta.DecayInterests),
+	)
+	return nil
+}
+
+// normalizeInterest returns the form topics are aggregated under.
+func normalizeInterest(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func indexTopicActivity(activity []storage.TopicActivity) map[string]storage.TopicActivity {
+	m := make(map[string]storage.TopicActivity, len(activity))
+	for _, a := range activity {
+		m[a.Topic] = a
+	}
+	return m
+}
+
+// interestSignals sums the preference signal counts whose pattern mentions
+// interest.
+func interestSignals(signals []storage.SignalCount, interest string) (pos, neg int) {
+	needle := normalizeInterest(interest)
+	if needle == "" {
+		return 0, 0
+	}
+	for _, sc := range signals {
+		if strings.Contains(strings.ToLower(sc.PatternDisplay), needle) {
+			pos += sc.PositiveCount
+			neg += sc.NegativeCount
+		}
+	}
+	return pos, neg
+}
+
+// describeInterestDelta summarises the proposal for the review queue.
+func describeInterestDelta(delta profile.ProfileDelta) string {
+	var parts []string
+	if len(delta.PromoteInterests) > 0 {
+		parts = append(parts, "Promote recurring emerging interests to primary: "+strings.Join(delta.PromoteInterests, ", "))
+	}
+	if len(delta.DecayInterests) > 0 {
+		parts = append(parts, "Move quiet primary interests back to emerging: "+strings.Join(delta.DecayInterests, ", "))
+	}
+	return truncateUTF8(strings.Join(parts, ". "), maxDescriptionLen)
+}
+
+// ProcessJobs polls for interest_review jobs and runs a review pass for each
+// one. Exits when ctx is cancelled.
+func (r *InterestReviewer) ProcessJobs(ctx context.Context, pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = 30 * time.Second
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := r.store.ClaimNextJob([]string{interestReviewJobType})
+		if err != nil {
+			r.logger.Error("interest_review: error claiming job", "error", err)
+		} else if job != nil {
+			if runErr := r.Run(ctx); runErr != nil {
+				r.logger.Warn("interest_review: run failed", "job_id", job.ID, "error", runErr)
+				if failErr := r.store.FailJob(job.ID, runErr.Error()); failErr != nil {
+					r.logger.Error("interest_review: failed to mark job as failed", "job_id", job.ID, "error", failErr)
+				}
+			} else if completeErr := r.store.CompleteJob(job.ID); completeErr != nil {
+				r.logger.Error("interest_review: failed to mark job as completed", "job_id", job.ID, "error", completeErr)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type mockInterestStore struct {
+	mockNightlyStore
+	recent       []storage.TopicActivity
+	allTime      []storage.TopicActivity
+	interactions int
+}
+
+func (m *mockInterestStore) GetTopicActivity(since time.Time) ([]storage.TopicActivity, error) {
+	if since.IsZero() {
+		return m.allTime, nil
+	}
+	return m.recent, nil
+}
+
+func (m *mockInterestStore) CountTopicInteractionsSince(_ time.Time) (int, error) {
+	return m.interactions, nil
+}
+
+type staticProfile struct{ p profile.Profile }
+
+func (s staticProfile) GetProfile() (profile.Profile, error) { return s.p, nil }
+
+var reviewNow = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestReviewer(store *mockInterestStore, interests profile.Interests) *InterestReviewer {
+	r := NewInterestReviewer(store, staticProfile{profile.Profile{Interests: interests}})
+	r.now = func() time.Time { return reviewNow }
+	return r
+}
+
+func TestInterestReviewer_PromotesRecurringEmerging(t *testing.T) {
+	rustSeen := reviewNow.Add(-10 * 24 * time.Hour)
+	rust := storage.TopicActivity{Topic: "rust", Mentions: 6, ActiveDays: 4, PositiveFeedback: 2, FirstSeen: rustSeen, LastSeen: reviewNow.Add(-time.Hour)}
+	store := &mockInterestStore{
+		recent: []storage.TopicActivity{
+			rust,
+			{Topic: "zig", Mentions: 8, ActiveDays: 1, FirstSeen: rustSeen, LastSeen: rustSeen},
+		},
+		allTime: []storage.TopicActivity{rust},
+	}
+	r := newTestReviewer(store, profile.Interests{Emerging: []string{"Rust", "Zig"}})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 1 {
+		t.Fatalf("saved %d deltas, want 1", len(store.savedDeltas))
+	}
+	saved := store.savedDeltas[0]
+	if saved.Source != interestReviewJobType {
+		t.Errorf("Source = %q, want %q", saved.Source, interestReviewJobType)
+	}
+
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(saved.DeltaJSON), &delta); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	// Zig was mentioned often but on a single day, so it is not promoted.
+	if !reflect.DeepEqual(delta.PromoteInterests, []string{"Rust"}) || len(delta.DecayInterests) != 0 {
+		t.Errorf("delta = %+v, want only Rust promoted", delta)
+	}
+
+	var evidence []InterestEvidence
+	if err := json.Unmarshal([]byte(saved.EvidenceJSON), &evidence); err != nil {
+		t.Fatalf("unmarshal evidence: %v", err)
+	}
+	if len(evidence) != 1 {
+		t.Fatalf("got %d evidence entries, want 1", len(evidence))
+	}
+	ev := evidence[0]
+	if ev.Change != "promote" || ev.Mentions != 6 || ev.ActiveDays != 4 || ev.PositiveFeedback != 2 {
+		t.Errorf("evidence = %+v", ev)
+	}
+	if ev.FirstSeen == nil || !ev.FirstSeen.Equal(rustSeen) || !ev.WindowEnd.Equal(reviewNow) {
+		t.Errorf("evidence dates = first %v, window end %v", ev.FirstSeen, ev.WindowEnd)
+	}
+}
+
+func TestInterestReviewer_NegativeSignalsBlockPromotion(t *testing.T) {
+	store := &mockInterestStore{
+		recent: []storage.TopicActivity{{Topic: "rust", Mentions: 6, ActiveDays: 4}},
+	}
+	store.signals = []storage.SignalCount{{PatternDisplay: "avoid Rust examples", NegativeCount: 3}}
+	r := newTestReviewer(store, profile.Interests{Emerging: []string{"Rust"}})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 0 {
+		t.Errorf("saved %d deltas, want none", len(store.savedDeltas))
+	}
+}
+
+func TestInterestReviewer_DecaysQuietPrimary(t *testing.T) {
+	lastK8s := reviewNow.Add(-60 * 24 * time.Hour)
+	store := &mockInterestStore{
+		recent: []storage.TopicActivity{{Topic: "go", Mentions: 12, ActiveDays: 9}},
+		allTime: []storage.TopicActivity{
+			{Topic: "go", Mentions: 40, ActiveDays: 30},
+			{Topic: "kubernetes", Mentions: 7, ActiveDays: 5, LastSeen: lastK8s},
+		},
+		interactions: 25,
+	}
+	// "Distributed Systems" never came up as a topic, so it is left alone.
+	r := newTestReviewer(store, profile.Interests{Primary: []string{"Go", "Kubernetes", "Distributed Systems"}})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 1 {
+		t.Fatalf("saved %d deltas, want 1", len(store.savedDeltas))
+	}
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].DeltaJSON), &delta); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	if !reflect.DeepEqual(delta.DecayInterests, []string{"Kubernetes"}) {
+		t.Errorf("DecayInterests = %v, want [Kubernetes]", delta.DecayInterests)
+	}
+	var evidence []InterestEvidence
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].EvidenceJSON), &evidence); err != nil {
+		t.Fatalf("unmarshal evidence: %v", err)
+	}
+	if len(evidence) != 1 || evidence[0].LastSeen == nil || !evidence[0].LastSeen.Equal(lastK8s) || evidence[0].Interactions != 25 {
+		t.Errorf("evidence = %+v", evidence)
+	}
+}
+
+func TestInterestReviewer_NoDecayWithoutRecentActivity(t *testing.T) {
+	store := &mockInterestStore{
+		allTime:      []storage.TopicActivity{{Topic: "kubernetes", Mentions: 7, LastSeen: reviewNow.Add(-60 * 24 * time.Hour)}},
+		interactions: 3,
+	}
+	r := newTestReviewer(store, profile.Interests{Primary: []string{"Kubernetes"}})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 0 {
+		t.Errorf("saved %d deltas, want none while the user was mostly inactive", len(store.savedDeltas))
+	}
+}
+
+func TestInterestReviewer_SkipsWhilePendingReview(t *testing.T) {
+	store := &mockInterestStore{
+		recent: []storage.TopicActivity{{Topic: "rust", Mentions: 6, ActiveDays: 4}},
+	}
+	store.hasPending = true
+	r := newTestReviewer(store, profile.Interests{Emerging: []string{"Rust"}})
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 0 {
+		t.Errorf("saved %d deltas, want none", len(store.savedDeltas))
+	}
+}
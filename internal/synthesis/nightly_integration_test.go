@@ -86,6 +86,9 @@ func (v *e2eVerbosityChatter) Chat(_ context.Context, _ string, _ []ollama.Messa
 		"add_preferences": ["concise responses"],
 		"remove_preferences": ["verbose explanations"],
 		"update_fields": {},
-		"description": "User consistently negatively rated verbose responses; prefer concise answers"
+		"description": "User consistently negatively rated verbose responses; prefer concise answers",
+		"interaction_ids": ["ix-e2e-0", "ix-e2e-1"],
+		"doc_ids": [],
+		"signal_patterns": []
 	}`, nil
 }
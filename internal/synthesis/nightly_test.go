@@ -2,8 +2,10 @@ package synthesis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -113,6 +115,25 @@ func TestRun_NoInteractions(t *testing.T) {
 	}
 }
 
+func TestRun_SkipsDeltaWithoutValidEvidence(t *testing.T) {
+	store := &mockNightlyStore{
+		interactions: []storage.Interaction{{ID: "ix-1", UserQuery: "q", FeedbackScore: 1, CreatedAt: time.Now()}},
+		signals:      []storage.SignalCount{{PatternDisplay: "concise responses", PositiveCount: 3}},
+	}
+	chatter := &nightlyMockChatter{
+		response: `{"add_preferences":["concise responses"],"remove_preferences":[],"description":"brevity",
+			"interaction_ids":["ix-9"],"doc_ids":[],"signal_patterns":["Concise Responses"]}`,
+	}
+
+	s := buildSynthesizer(store, chatter)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if len(store.savedDeltas) != 0 {
+		t.Errorf("saved %d deltas, want 0 when no citation matches the input", len(store.savedDeltas))
+	}
+}
+
 func TestRun_ProducesDeltas(t *testing.T) {
 	interactions := make([]storage.Interaction, 10)
 	for i := range interactions {
@@ -127,7 +148,8 @@ func TestRun_ProducesDeltas(t *testing.T) {
 
 	store := &mockNightlyStore{interactions: interactions}
 	chatter := &nightlyMockChatter{
-		response: `{"add_preferences":["concise responses"],"remove_preferences":["verbose explanations"],"description":"user prefers brevity"}`,
+		response: `{"add_preferences":["concise responses"],"remove_preferences":["verbose explanations"],"description":"user prefers brevity",
+			"interaction_ids":["ix-1","ix-2","ix-404","ix-1"],"doc_ids":["doc-404"],"signal_patterns":[]}`,
 	}
 
 	s := buildSynthesizer(store, chatter)
@@ -148,6 +170,15 @@ func TestRun_ProducesDeltas(t *testing.T) {
 	if delta.ID == "" {
 		t.Error("delta.ID is empty")
 	}
+
+	// Citations of items that were not in the prompt are dropped.
+	var ev NightlyEvidence
+	if err := json.Unmarshal([]byte(delta.EvidenceJSON), &ev); err != nil {
+		t.Fatalf("unmarshal evidence: %v", err)
+	}
+	if !reflect.DeepEqual(ev.InteractionIDs, []string{"ix-1", "ix-2"}) || len(ev.DocIDs) != 0 {
+		t.Errorf("evidence = %+v, want only the cited interactions from the input", ev)
+	}
 }
 
 func TestRun_LLMMalformedResponse(t *testing.T) {
@@ -216,55 +247,6 @@ func (b *blockingChatter) Chat(ctx context.Context, _ string, _ []ollama.Message
 	}
 }
 
-func TestSchedule_FiresOnInterval(t *testing.T) {
-	store := &mockNightlyStore{}
-	chatter := &nightlyMockChatter{}
-
-	s := buildSynthesizer(store, chatter)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-	defer cancel()
-
-	go s.Schedule(ctx, 50*time.Millisecond)
-
-	<-ctx.Done()
-
-	count := store.enqueueCount.Load()
-	if count < 2 {
-		t.Errorf("enqueued %d jobs in 500ms with 50ms interval, want >= 2", count)
-	}
-}
-
-func TestSchedule_StopsOnContextCancel(t *testing.T) {
-	store := &mockNightlyStore{}
-	chatter := &nightlyMockChatter{}
-
-	s := buildSynthesizer(store, chatter)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	done := make(chan struct{})
-	go func() {
-		s.Schedule(ctx, 10*time.Millisecond)
-		close(done)
-	}()
-
-	cancel()
-
-	select {
-	case <-done:
-	case <-time.After(2 * time.Second):
-		t.Fatal("Schedule did not stop after context cancellation within 2s")
-	}
-
-	// No further enqueue calls after cancel.
-	beforeCount := store.enqueueCount.Load()
-	time.Sleep(30 * time.Millisecond)
-	afterCount := store.enqueueCount.Load()
-	if afterCount != beforeCount {
-		t.Errorf("enqueue count changed after cancel: before=%d after=%d", beforeCount, afterCount)
-	}
-}
-
 func TestSanitizePreferences(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -0,0 +1,317 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/scheduler"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+// DeltaPolicyJobType is the job type that triggers a delta policy pass.
+const DeltaPolicyJobType = "delta_policy"
+
+func init() { scheduler.Register(DeltaPolicyJobType, "@hourly") }
+
+// Rules recorded with each automatic decision.
+const (
+	RuleFeedbackAdditions = "feedback_additions"
+	RuleExpireUnreviewed  = "expire_unreviewed"
+)
+
+// Actions recorded with each automatic decision.
+const (
+	DecisionAccepted = "accepted"
+	DecisionExpired  = "expired"
+)
+
+// DeltaPolicy configures which pending deltas are decided without review.
+type DeltaPolicy struct {
+	// AutoAccept enables accepting feedback_aggregation deltas that only add
+	// preferences, once every addition clears MinCount or MinConfidence.
+	AutoAccept    bool
+	MinCount      int     // positive signals behind an addition
+	MinConfidence float64 // share of signals behind an addition that are positive
+
+	// ExpireAfter rejects deltas left unreviewed this long; 0 disables.
+	ExpireAfter time.Duration
+}
+
+// PolicyStore abstracts the storage operations PolicyEngine needs.
+type PolicyStore interface {
+	ListPendingDeltas() ([]storage.PendingProfileDelta, error)
+	ReviewDelta(id string, accept bool) error
+	UnreviewDelta(id string) error
+	SaveDeltaDecision(d storage.DeltaDecision) error
+	ListOverriddenDeltaIDs() (map[string]bool, error)
+	EnqueueJob(ctx context.Context, job storage.Job) error
+	ClaimNextJob(types []string) (*storage.Job, error)
+	CompleteJob(id string) error
+	FailJob(id string, errMsg string) error
+}
+
+// PolicyProfile reads and updates the profile auto-accepted deltas apply to.
+type PolicyProfile interface {
+	ProfileReader
+	ProfileApplier
+}
+
+// PolicyEngine decides low-risk pending deltas without review: it accepts
+// well-supported preference additions learned from feedback and expires
+// deltas nobody reviewed in time. Removals and identity changes are always
+// left to the user. Every decision is recorded with the rule that fired, so
+// it can be undone; deltas with an undone decision are never decided again.
+type PolicyEngine struct {
+	store   PolicyStore
+	profile PolicyProfile
+	policy  DeltaPolicy
+	now     func() time.Time
+	logger  *slog.Logger
+}
+
+// NewPolicyEngine creates a PolicyEngine applying policy.
+func NewPolicyEngine(store PolicyStore, prof PolicyProfile, policy DeltaPolicy) *PolicyEngine {
+	return &PolicyEngine{
+		store:   store,
+		profile: prof,
+		policy:  policy,
+		now:     time.Now,
+		logger:  slog.Default(),
+	}
+}
+
+// Run performs a single policy pass over the pending deltas.
+func (e *PolicyEngine) Run(ctx context.Context) error {
+	pending, err := e.store.ListPendingDeltas()
+	if err != nil {
+		return fmt.Errorf("listing pending deltas: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	overridden, err := e.store.ListOverriddenDeltaIDs()
+	if err != nil {
+		return fmt.Errorf("listing overridden deltas: %w", err)
+	}
+
+	now := e.now().UTC()
+	accepted := 0
+	for _, d := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if overridden[d.ID] {
+			continue
+		}
+
+		if e.policy.ExpireAfter > 0 && now.Sub(d.CreatedAt) >= e.policy.ExpireAfter {
+			if err := e.expire(d, now); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !e.policy.AutoAccept || !e.autoAcceptable(d) {
+			continue
+		}
+		ok, err := e.accept(d, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			accepted++
+		}
+	}
+
+	if accepted > 0 {
+		// Accepted additions may duplicate or contradict existing entries.
+		job := storage.Job{
+			ID:          uuid.New().String(),
+			Type:        PreferenceReconcileJobType,
+			PayloadJSON: "{}",
+		}
+		if err := e.store.EnqueueJob(ctx, job); err != nil {
+			e.logger.Warn("delta_policy: failed to enqueue preference reconciliation", "error", err)
+		}
+	}
+	return nil
+}
+
+// autoAcceptable reports whether d may be accepted without review.
+func (e *PolicyEngine) autoAcceptable(d storage.PendingProfileDelta) bool {
+	if d.Source != feedbackAggregationSource {
+		return false
+	}
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(d.DeltaJSON), &delta); err != nil {
+		return false
+	}
+	if len(delta.AddPreferences) == 0 || riskyChange(delta) {
+		return false
+	}
+	// Anything beyond preference additions needs a human.
+	rest := delta
+	rest.AddPreferences = nil
+	if !rest.IsEmpty() {
+		return false
+	}
+
+	var evidence []SignalEvidence
+	if err := json.Unmarshal([]byte(d.EvidenceJSON), &evidence); err != nil {
+		return false
+	}
+	byPattern := make(map[string]SignalEvidence, len(evidence))
+	for _, ev := range evidence {
+		byPattern[ev.Pattern] = ev
+	}
+	for _, pref := range delta.AddPreferences {
+		ev, ok := byPattern[pref]
+		if !ok {
+			return false
+		}
+		if ev.Positive < e.policy.MinCount && ev.Confidence < e.policy.MinConfidence {
+			return false
+		}
+	}
+	return true
+}
+
+// riskyChange reports whether delta removes anything or touches identity
+// fields; such deltas are never auto-accepted.
+func riskyChange(delta profile.ProfileDelta) bool {
+	if len(delta.RemovePreferences) > 0 || len(delta.RemoveOpinions) > 0 || len(delta.DecayInterests) > 0 {
+		return true
+	}
+	for key := range delta.UpdateFields {
+		if strings.HasPrefix(key, "identity.") || strings.HasPrefix(key, "expertise.") {
+			return true
+		}
+	}
+	return false
+}
+
+// accept marks d accepted, applies the additions not already in the profile
+// and records the decision. Returns false if d was reviewed concurrently.
+func (e *PolicyEngine) accept(d storage.PendingProfileDelta, now time.Time) (bool, error) {
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(d.DeltaJSON), &delta); err != nil {
+		return false, fmt.Errorf("parsing delta %s: %w", d.ID, err)
+	}
+
+	// Mark first so a concurrent manual review wins cleanly.
+	if err := e.store.ReviewDelta(d.ID, true); err != nil {
+		if errors.Is(err, storage.ErrAlreadyReviewed) || errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("marking delta %s accepted: %w", d.ID, err)
+	}
+	rollback := func(opErr error) {
+		if rbErr := e.store.UnreviewDelta(d.ID); rbErr != nil {
+			e.logger.Error("delta_policy: failed to roll back delta review",
+				"delta_id", d.ID, "op_error", opErr, "rollback_error", rbErr)
+		}
+	}
+
+	// Record only what this decision adds, so undoing it never removes a
+	// preference the profile already had.
+	p, err := e.profile.GetProfile()
+	if err != nil {
+		rollback(err)
+		return false, fmt.Errorf("loading profile: %w", err)
+	}
+	current := make(map[string]bool, len(p.Preferences))
+	for _, v := range p.Preferences {
+		current[v] = true
+	}
+	var applied profile.ProfileDelta
+	for _, v := range delta.AddPreferences {
+		if !current[v] {
+			applied.AddPreferences = append(applied.AddPreferences, v)
+		}
+	}
+
+	if err := e.profile.ApplyDelta(profile.SourcePolicy, applied); err != nil {
+		rollback(err)
+		return false, fmt.Errorf("applying delta %s: %w", d.ID, err)
+	}
+
+	appliedJSON, err := json.Marshal(applied)
+	if err != nil {
+		return false, fmt.Errorf("marshalling applied delta: %w", err)
+	}
+	if err := e.record(d.ID, DecisionAccepted, RuleFeedbackAdditions, string(appliedJSON), now); err != nil {
+		return false, err
+	}
+	e.logger.Info("delta_policy: delta auto-accepted", "delta_id", d.ID, "added", len(applied.AddPreferences))
+	return true, nil
+}
+
+// expire rejects d for having gone unreviewed and records the decision.
+func (e *PolicyEngine) expire(d storage.PendingProfileDelta, now time.Time) error {
+	if err := e.store.ReviewDelta(d.ID, false); err != nil {
+		if errors.Is(err, storage.ErrAlreadyReviewed) || errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("expiring delta %s: %w", d.ID, err)
+	}
+	if err := e.record(d.ID, DecisionExpired, RuleExpireUnreviewed, "", now); err != nil {
+		return err
+	}
+	e.logger.Info("delta_policy: unreviewed delta expired", "delta_id", d.ID, "source", d.Source)
+	return nil
+}
+
+func (e *PolicyEngine) record(deltaID, action, rule, appliedJSON string, now time.Time) error {
+	if err := e.store.SaveDeltaDecision(storage.DeltaDecision{
+		ID:          uuid.New().String(),
+		DeltaID:     deltaID,
+		Action:      action,
+		Rule:        rule,
+		AppliedJSON: appliedJSON,
+		CreatedAt:   now,
+	}); err != nil {
+		return fmt.Errorf("recording decision on delta %s: %w", deltaID, err)
+	}
+	return nil
+}
+
+// ProcessJobs polls for delta_policy jobs and runs a policy pass for each
+// one. Exits when ctx is cancelled.
+func (e *PolicyEngine) ProcessJobs(ctx context.Context, pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = 30 * time.Second
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := e.store.ClaimNextJob([]string{DeltaPolicyJobType})
+		if err != nil {
+			e.logger.Error("delta_policy: error claiming job", "error", err)
+		} else if job != nil {
+			if runErr := e.Run(ctx); runErr != nil {
+				e.logger.Warn("delta_policy: run failed", "job_id", job.ID, "error", runErr)
+				if failErr := e.store.FailJob(job.ID, runErr.Error()); failErr != nil {
+					e.logger.Error("delta_policy: failed to mark job as failed", "job_id", job.ID, "error", failErr)
+				}
+			} else if completeErr := e.store.CompleteJob(job.ID); completeErr != nil {
+				e.logger.Error("delta_policy: failed to mark job as completed", "job_id", job.ID, "error", completeErr)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
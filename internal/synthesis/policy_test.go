@@ -0,0 +1,196 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kalambet/tbyd/internal/profile"
+	"github.com/kalambet/tbyd/internal/storage"
+)
+
+type mockPolicyStore struct {
+	mockNightlyStore
+	pending    []storage.PendingProfileDelta
+	reviewed   map[string]bool
+	decisions  []storage.DeltaDecision
+	overridden map[string]bool
+}
+
+func (m *mockPolicyStore) ListPendingDeltas() ([]storage.PendingProfileDelta, error) {
+	return m.pending, nil
+}
+
+func (m *mockPolicyStore) ReviewDelta(id string, accept bool) error {
+	if m.reviewed == nil {
+		m.reviewed = make(map[string]bool)
+	}
+	if _, ok := m.reviewed[id]; ok {
+		return storage.ErrAlreadyReviewed
+	}
+	m.reviewed[id] = accept
+	return nil
+}
+
+func (m *mockPolicyStore) UnreviewDelta(id string) error {
+	delete(m.reviewed, id)
+	return nil
+}
+
+func (m *mockPolicyStore) SaveDeltaDecision(d storage.DeltaDecision) error {
+	m.decisions = append(m.decisions, d)
+	return nil
+}
+
+func (m *mockPolicyStore) ListOverriddenDeltaIDs() (map[string]bool, error) {
+	return m.overridden, nil
+}
+
+// recordingProfile serves a fixed profile and records applied deltas.
+type recordingProfile struct {
+	staticProfile
+	applied []profile.ProfileDelta
+	sources []profile.Source
+}
+
+func (r *recordingProfile) ApplyDelta(source profile.Source, delta profile.ProfileDelta) error {
+	r.sources = append(r.sources, source)
+	r.applied = append(r.applied, delta)
+	return nil
+}
+
+var policyNow = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+var testPolicy = DeltaPolicy{AutoAccept: true, MinCount: 5, MinConfidence: 0.9, ExpireAfter: 30 * 24 * time.Hour}
+
+func newTestPolicyEngine(store *mockPolicyStore, prof *recordingProfile, policy DeltaPolicy) *PolicyEngine {
+	e := NewPolicyEngine(store, prof, policy)
+	e.now = func() time.Time { return policyNow }
+	return e
+}
+
+func feedbackDelta(t *testing.T, id string, delta profile.ProfileDelta, evidence []SignalEvidence) storage.PendingProfileDelta {
+	t.Helper()
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evidenceJSON, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.PendingProfileDelta{
+		ID:           id,
+		DeltaJSON:    string(deltaJSON),
+		Source:       feedbackAggregationSource,
+		CreatedAt:    policyNow.Add(-time.Hour),
+		EvidenceJSON: string(evidenceJSON),
+	}
+}
+
+func TestPolicyEngine_AcceptsConfidentAdditions(t *testing.T) {
+	store := &mockPolicyStore{pending: []storage.PendingProfileDelta{
+		feedbackDelta(t, "d-1",
+			profile.ProfileDelta{AddPreferences: []string{"uses Go", "prefers tables"}},
+			[]SignalEvidence{
+				{Pattern: "uses Go", Positive: 6, Negative: 2, Confidence: 0.75},
+				{Pattern: "prefers tables", Positive: 3, Confidence: 1},
+			}),
+	}}
+	// "uses Go" is already in the profile, so only "prefers tables" is new.
+	prof := &recordingProfile{staticProfile: staticProfile{profile.Profile{Preferences: []string{"uses Go"}}}}
+	e := newTestPolicyEngine(store, prof, testPolicy)
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if accepted, ok := store.reviewed["d-1"]; !ok || !accepted {
+		t.Fatalf("d-1 reviewed = %v, %v; want accepted", accepted, ok)
+	}
+	if len(prof.applied) != 1 || prof.sources[0] != profile.SourcePolicy ||
+		!reflect.DeepEqual(prof.applied[0].AddPreferences, []string{"prefers tables"}) {
+		t.Errorf("applied = %+v from %v", prof.applied, prof.sources)
+	}
+	if len(store.decisions) != 1 {
+		t.Fatalf("recorded %d decisions, want 1", len(store.decisions))
+	}
+	dec := store.decisions[0]
+	if dec.DeltaID != "d-1" || dec.Action != DecisionAccepted || dec.Rule != RuleFeedbackAdditions {
+		t.Errorf("decision = %+v", dec)
+	}
+	var applied profile.ProfileDelta
+	if err := json.Unmarshal([]byte(dec.AppliedJSON), &applied); err != nil {
+		t.Fatalf("unmarshal applied: %v", err)
+	}
+	if !reflect.DeepEqual(applied.AddPreferences, []string{"prefers tables"}) {
+		t.Errorf("recorded applied = %+v, want only the new preference", applied)
+	}
+	if len(store.enqueuedJobs) != 1 || store.enqueuedJobs[0].Type != PreferenceReconcileJobType {
+		t.Errorf("enqueued = %+v, want a preference reconciliation", store.enqueuedJobs)
+	}
+}
+
+func TestPolicyEngine_LeavesRiskyDeltasForReview(t *testing.T) {
+	strong := []SignalEvidence{{Pattern: "uses Go", Positive: 10, Confidence: 1}}
+	identity := feedbackDelta(t, "d-identity",
+		profile.ProfileDelta{AddPreferences: []string{"uses Go"}, UpdateFields: map[string]string{"identity.role": "manager"}}, strong)
+	removal := feedbackDelta(t, "d-removal",
+		profile.ProfileDelta{RemovePreferences: []string{"uses Go"}}, strong)
+	weak := feedbackDelta(t, "d-weak",
+		profile.ProfileDelta{AddPreferences: []string{"uses Go"}},
+		[]SignalEvidence{{Pattern: "uses Go", Positive: 4, Negative: 1, Confidence: 0.8}})
+	nightly := feedbackDelta(t, "d-nightly", profile.ProfileDelta{AddPreferences: []string{"uses Go"}}, strong)
+	nightly.Source = "nightly_synthesis"
+
+	store := &mockPolicyStore{pending: []storage.PendingProfileDelta{identity, removal, weak, nightly}}
+	prof := &recordingProfile{}
+	e := newTestPolicyEngine(store, prof, testPolicy)
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.reviewed) != 0 || len(prof.applied) != 0 || len(store.decisions) != 0 {
+		t.Errorf("reviewed = %v, applied = %v, decisions = %v; want everything left pending",
+			store.reviewed, prof.applied, store.decisions)
+	}
+}
+
+func TestPolicyEngine_ExpiresStaleDeltas(t *testing.T) {
+	stale := storage.PendingProfileDelta{ID: "d-stale", DeltaJSON: `{}`, Source: "nightly_synthesis", CreatedAt: policyNow.Add(-31 * 24 * time.Hour)}
+	overridden := storage.PendingProfileDelta{ID: "d-overridden", DeltaJSON: `{}`, Source: "nightly_synthesis", CreatedAt: policyNow.Add(-40 * 24 * time.Hour)}
+	fresh := storage.PendingProfileDelta{ID: "d-fresh", DeltaJSON: `{}`, Source: "nightly_synthesis", CreatedAt: policyNow.Add(-24 * time.Hour)}
+	store := &mockPolicyStore{
+		pending:    []storage.PendingProfileDelta{stale, overridden, fresh},
+		overridden: map[string]bool{"d-overridden": true},
+	}
+	e := newTestPolicyEngine(store, &recordingProfile{}, testPolicy)
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(store.reviewed, map[string]bool{"d-stale": false}) {
+		t.Errorf("reviewed = %v, want only d-stale rejected", store.reviewed)
+	}
+	if len(store.decisions) != 1 || store.decisions[0].Action != DecisionExpired || store.decisions[0].Rule != RuleExpireUnreviewed {
+		t.Errorf("decisions = %+v", store.decisions)
+	}
+}
+
+func TestPolicyEngine_Disabled(t *testing.T) {
+	old := feedbackDelta(t, "d-1",
+		profile.ProfileDelta{AddPreferences: []string{"uses Go"}},
+		[]SignalEvidence{{Pattern: "uses Go", Positive: 10, Confidence: 1}})
+	old.CreatedAt = policyNow.Add(-365 * 24 * time.Hour)
+	store := &mockPolicyStore{pending: []storage.PendingProfileDelta{old}}
+	prof := &recordingProfile{}
+	e := newTestPolicyEngine(store, prof, DeltaPolicy{})
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.reviewed) != 0 || len(prof.applied) != 0 {
+		t.Errorf("reviewed = %v, applied = %v; want nothing decided", store.reviewed, prof.applied)
+	}
+}
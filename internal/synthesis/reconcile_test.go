@@ -0,0 +1,138 @@
+package synthesis
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kalambet/tbyd/internal/profile"
+)
+
+// mapEmbedder returns a fixed vector per text, defaulting to a unique axis so
+// unlisted texts are dissimilar to everything.
+type mapEmbedder struct {
+	vecs map[string][]float32
+}
+
+func (m *mapEmbedder) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		if v, ok := m.vecs[t]; ok {
+			out[i] = v
+			continue
+		}
+		v := make([]float32, 16)
+		v[(i+4)%16] = 1
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestPreferenceReconciler_MergesNearDuplicates(t *testing.T) {
+	store := &mockNightlyStore{}
+	prof := staticProfile{profile.Profile{
+		Preferences: []string{"prefers short answers", "uses Go", "likes concise responses"},
+		Opinions:    []string{"tabs are better"},
+	}}
+	emb := &mapEmbedder{vecs: map[string][]float32{
+		"prefers short answers":   {1, 0.1, 0},
+		"likes concise responses": {0.95, 0.15, 0},
+		// Same direction as the preferences, but a different field.
+		"tabs are better": {1, 0.1, 0},
+	}}
+	r := NewPreferenceReconciler(store, prof, emb, nil, "")
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 1 {
+		t.Fatalf("saved %d deltas, want 1", len(store.savedDeltas))
+	}
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].DeltaJSON), &delta); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	if !reflect.DeepEqual(delta.RemovePreferences, []string{"likes concise responses"}) || len(delta.RemoveOpinions) != 0 {
+		t.Errorf("delta = %+v, want the later duplicate removed", delta)
+	}
+	var ev ReconcileEvidence
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].EvidenceJSON), &ev); err != nil {
+		t.Fatalf("unmarshal evidence: %v", err)
+	}
+	if len(ev.Duplicates) != 1 || ev.Duplicates[0].Kept != "prefers short answers" || ev.Duplicates[0].Similarity < duplicateSimilarity {
+		t.Errorf("evidence = %+v", ev)
+	}
+}
+
+func TestPreferenceReconciler_ResolvesContradictions(t *testing.T) {
+	store := &mockNightlyStore{}
+	prof := staticProfile{profile.Profile{
+		Preferences: []string{"prefers short answers", "wants exhaustive detail"},
+		Opinions:    []string{"ORMs are fine"},
+	}}
+	chatter := &nightlyMockChatter{response: `{"contradictions":[
+		{"first":1,"second":2,"keep":1,"reason":"length conflict"},
+		{"first":3,"second":9,"keep":0,"reason":"out of range"}
+	]}`}
+	r := NewPreferenceReconciler(store, prof, &mapEmbedder{}, chatter, "fast")
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 1 {
+		t.Fatalf("saved %d deltas, want 1", len(store.savedDeltas))
+	}
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].DeltaJSON), &delta); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	if !reflect.DeepEqual(delta.RemovePreferences, []string{"wants exhaustive detail"}) {
+		t.Errorf("RemovePreferences = %v, want the side the model did not keep", delta.RemovePreferences)
+	}
+	var ev ReconcileEvidence
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].EvidenceJSON), &ev); err != nil {
+		t.Fatalf("unmarshal evidence: %v", err)
+	}
+	if len(ev.Contradictions) != 1 || ev.Contradictions[0].Reason != "length conflict" {
+		t.Errorf("evidence = %+v", ev)
+	}
+}
+
+func TestPreferenceReconciler_KeepsLaterSideWhenUnclear(t *testing.T) {
+	store := &mockNightlyStore{}
+	prof := staticProfile{profile.Profile{
+		Preferences: []string{"likes emoji"},
+		Opinions:    []string{"emoji are unprofessional"},
+	}}
+	chatter := &nightlyMockChatter{response: `{"contradictions":[{"first":2,"second":1,"keep":0,"reason":"emoji"}]}`}
+	r := NewPreferenceReconciler(store, prof, &mapEmbedder{}, chatter, "fast")
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 1 {
+		t.Fatalf("saved %d deltas, want 1", len(store.savedDeltas))
+	}
+	var delta profile.ProfileDelta
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].DeltaJSON), &delta); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	if !reflect.DeepEqual(delta.RemovePreferences, []string{"likes emoji"}) || len(delta.RemoveOpinions) != 0 {
+		t.Errorf("delta = %+v, want the earlier item removed", delta)
+	}
+}
+
+func TestPreferenceReconciler_NothingToReconcile(t *testing.T) {
+	store := &mockNightlyStore{}
+	prof := staticProfile{profile.Profile{Preferences: []string{"uses Go", "likes tests"}}}
+	chatter := &nightlyMockChatter{response: `{"contradictions":[]}`}
+	r := NewPreferenceReconciler(store, prof, &mapEmbedder{}, chatter, "fast")
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.savedDeltas) != 0 {
+		t.Errorf("saved %d deltas, want none", len(store.savedDeltas))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/kalambet/tbyd/internal/profile"
 	"github.com/kalambet/tbyd/internal/storage"
 )
@@ -23,33 +24,50 @@ type FeedbackJobStore interface {
 	UpdateExtractedSignals(id string, signalsJSON string) error
 	PersistSignalsAtomically(interactionID string, signalsJSON string, counts []storage.SignalCountDelta) error
 	GetSignalCounts() ([]storage.SignalCount, error)
+	SavePendingDelta(delta storage.PendingProfileDelta) error
+	ListPendingDeltas() ([]storage.PendingProfileDelta, error)
+	EnqueueJob(ctx context.Context, job storage.Job) error
 }
 
 // ProfileApplier applies a ProfileDelta to the user profile.
 type ProfileApplier interface {
-	ApplyDelta(delta profile.ProfileDelta) error
+	ApplyDelta(source profile.Source, delta profile.ProfileDelta) error
+}
+
+// feedbackAggregationSource is the pending delta source of preference changes
+// learned from feedback.
+const feedbackAggregationSource = "feedback_aggregation"
+
+// SignalEvidence is the feedback behind one proposed preference change.
+type SignalEvidence struct {
+	Pattern    string  `json:"pattern"`
+	Positive   int     `json:"positive"`
+	Negative   int     `json:"negative"`
+	Confidence float64 `json:"confidence"` // share of signals agreeing with the change
 }
 
 // FeedbackWorker polls the job queue for "feedback_extract" jobs and uses the
-// PreferenceExtractor + Aggregate pipeline to update the user profile.
+// PreferenceExtractor + Aggregate pipeline to propose profile updates as
+// pending deltas. Additions and removals are proposed separately so the delta
+// policy can auto-accept the former while leaving the latter for review.
 type FeedbackWorker struct {
 	store     FeedbackJobStore
 	extractor *PreferenceExtractor
-	applier   ProfileApplier
+	profile   ProfileReader
 	poll      time.Duration
 	logger    *slog.Logger
 }
 
 // NewFeedbackWorker creates a FeedbackWorker. If pollInterval is <= 0 it
 // defaults to 500ms.
-func NewFeedbackWorker(store FeedbackJobStore, extractor *PreferenceExtractor, applier ProfileApplier, poll time.Duration) *FeedbackWorker {
+func NewFeedbackWorker(store FeedbackJobStore, extractor *PreferenceExtractor, prof ProfileReader, poll time.Duration) *FeedbackWorker {
 	if poll <= 0 {
 		poll = 500 * time.Millisecond
 	}
 	return &FeedbackWorker{
 		store:     store,
 		extractor: extractor,
-		applier:   applier,
+		profile:   prof,
 		poll:      poll,
 		logger:    slog.Default(),
 	}
@@ -169,23 +187,144 @@ func (w *FeedbackWorker) processJob(ctx context.Context, job *storage.Job) error
 
 	// Aggregate from the summary counts table — O(distinct patterns), not
 	// O(total interactions). Typically < 100 rows for a single user.
-	delta, err := AggregateFromCounts(w.store)
+	counts, err := w.store.GetSignalCounts()
 	if err != nil {
 		return fmt.Errorf("aggregating signal counts: %w", err)
 	}
+	delta := aggregateCounts(counts)
+	if len(delta.AddPreferences) == 0 && len(delta.RemovePreferences) == 0 {
+		return nil
+	}
+
+	adds, removes, err := w.newChanges(delta)
+	if err != nil {
+		return err
+	}
 
-	// Apply the delta when there are changes.
-	if len(delta.AddPreferences) > 0 || len(delta.RemovePreferences) > 0 || len(delta.UpdateFields) > 0 {
-		if err := w.applier.ApplyDelta(delta); err != nil {
-			return fmt.Errorf("applying profile delta: %w", err)
+	byPattern := make(map[string]storage.SignalCount, len(counts))
+	for _, c := range counts {
+		byPattern[c.PatternDisplay] = c
+	}
+	if len(adds) > 0 {
+		if err := w.propose(profile.ProfileDelta{AddPreferences: adds}, byPattern,
+			fmt.Sprintf("Add %d preference(s) learned from feedback", len(adds))); err != nil {
+			return err
 		}
-		w.logger.Info("feedback_extract: profile updated",
-			"added", len(delta.AddPreferences),
-			"removed", len(delta.RemovePreferences),
+	}
+	if len(removes) > 0 {
+		if err := w.propose(profile.ProfileDelta{RemovePreferences: removes}, byPattern,
+			fmt.Sprintf("Remove %d preference(s) contradicted by feedback", len(removes))); err != nil {
+			return err
+		}
+	}
+	if len(adds) > 0 {
+		// Let the delta policy decide the additions without waiting for its
+		// next scheduled pass. Non-fatal: the deltas are already saved.
+		policyJob := storage.Job{
+			ID:          uuid.New().String(),
+			Type:        DeltaPolicyJobType,
+			PayloadJSON: "{}",
+		}
+		if err := w.store.EnqueueJob(ctx, policyJob); err != nil {
+			w.logger.Warn("feedback_extract: failed to enqueue delta policy pass", "error", err)
+		}
+	}
+	if len(adds) > 0 || len(removes) > 0 {
+		w.logger.Info("feedback_extract: profile changes proposed",
+			"added", len(adds),
+			"removed", len(removes),
 			"job_id", job.ID,
 		)
 	}
+	return nil
+}
+
+// newChanges filters delta down to the additions not yet in the profile and
+// the removals still in it, skipping any already awaiting review.
+func (w *FeedbackWorker) newChanges(delta profile.ProfileDelta) (adds, removes []string, err error) {
+	p, err := w.profile.GetProfile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading profile: %w", err)
+	}
+	current := make(map[string]bool, len(p.Preferences))
+	for _, v := range p.Preferences {
+		current[v] = true
+	}
+
+	pending, err := w.store.ListPendingDeltas()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing pending deltas: %w", err)
+	}
+	proposedAdd := make(map[string]bool)
+	proposedRemove := make(map[string]bool)
+	for _, d := range pending {
+		if d.Source != feedbackAggregationSource {
+			continue
+		}
+		var pd profile.ProfileDelta
+		if err := json.Unmarshal([]byte(d.DeltaJSON), &pd); err != nil {
+			continue
+		}
+		for _, v := range pd.AddPreferences {
+			proposedAdd[v] = true
+		}
+		for _, v := range pd.RemovePreferences {
+			proposedRemove[v] = true
+		}
+	}
 
+	for _, v := range delta.AddPreferences {
+		if !current[v] && !proposedAdd[v] {
+			adds = append(adds, v)
+		}
+	}
+	for _, v := range delta.RemovePreferences {
+		if current[v] && !proposedRemove[v] {
+			removes = append(removes, v)
+		}
+	}
+	sort.Strings(adds)
+	sort.Strings(removes)
+	return adds, removes, nil
+}
+
+// propose saves delta as a pending feedback_aggregation delta, with the
+// signal counts behind each pattern as evidence.
+func (w *FeedbackWorker) propose(delta profile.ProfileDelta, counts map[string]storage.SignalCount, description string) error {
+	patterns := append(append([]string(nil), delta.AddPreferences...), delta.RemovePreferences...)
+	adding := len(delta.AddPreferences) > 0
+	evidence := make([]SignalEvidence, 0, len(patterns))
+	for _, pat := range patterns {
+		c := counts[pat]
+		ev := SignalEvidence{Pattern: pat, Positive: c.PositiveCount, Negative: c.NegativeCount}
+		if total := c.PositiveCount + c.NegativeCount; total > 0 {
+			agree := c.NegativeCount
+			if adding {
+				agree = c.PositiveCount
+			}
+			ev.Confidence = float64(agree) / float64(total)
+		}
+		evidence = append(evidence, ev)
+	}
+
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		return fmt.Errorf("marshalling profile delta: %w", err)
+	}
+	evidenceJSON, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("marshalling evidence: %w", err)
+	}
+	if err := w.store.SavePendingDelta(storage.PendingProfileDelta{
+		ID:           uuid.New().String(),
+		DeltaJSON:    string(deltaJSON),
+		Description:  description,
+		Source:       feedbackAggregationSource,
+		CreatedAt:    time.Now().UTC(),
+		EvidenceJSON: string(evidenceJSON),
+	}); err != nil {
+		return fmt.Errorf("saving pending delta: %w", err)
+	}
 	return nil
 }
 
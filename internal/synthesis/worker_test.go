@@ -1,8 +1,12 @@
 package synthesis
 
 import (
+	"context"
+	"encoding/json"
+	"reflect"
 	"testing"
 
+	"github.com/kalambet/tbyd/internal/profile"
 	"github.com/kalambet/tbyd/internal/storage"
 )
 
@@ -113,3 +117,88 @@ func TestBuildSignalCountDeltas_ReturnsStorageType(t *testing.T) {
 		t.Fatalf("expected 1 delta, got %d", len(deltas))
 	}
 }
+
+// mockFeedbackStore serves one feedback_extract job whose signals were
+// already persisted, so the worker goes straight to aggregation.
+type mockFeedbackStore struct {
+	mockNightlyStore
+	job     *storage.Job
+	pending []storage.PendingProfileDelta
+}
+
+func (m *mockFeedbackStore) ClaimNextJob(_ []string) (*storage.Job, error) {
+	job := m.job
+	m.job = nil
+	return job, nil
+}
+
+func (m *mockFeedbackStore) GetInteraction(id string) (storage.Interaction, error) {
+	return storage.Interaction{ID: id}, nil
+}
+
+func (m *mockFeedbackStore) HasExtractedSignals(_ string) (bool, error) { return true, nil }
+
+func (m *mockFeedbackStore) UpdateExtractedSignals(_ string, _ string) error { return nil }
+
+func (m *mockFeedbackStore) PersistSignalsAtomically(_ string, _ string, _ []storage.SignalCountDelta) error {
+	return nil
+}
+
+func (m *mockFeedbackStore) ListPendingDeltas() ([]storage.PendingProfileDelta, error) {
+	return m.pending, nil
+}
+
+func TestFeedbackWorker_ProposesAdditionsAndRemovalsSeparately(t *testing.T) {
+	store := &mockFeedbackStore{
+		job: &storage.Job{ID: "job-1", Type: "feedback_extract", PayloadJSON: `{"interaction_id":"i-1"}`},
+		pending: []storage.PendingProfileDelta{{
+			ID:        "d-old",
+			Source:    feedbackAggregationSource,
+			DeltaJSON: `{"AddPreferences":["likes tables"]}`,
+		}},
+	}
+	store.signals = []storage.SignalCount{
+		{PatternDisplay: "prefers short answers", PositiveCount: 4},
+		{PatternDisplay: "uses Go", PositiveCount: 5},       // already in the profile
+		{PatternDisplay: "likes tables", PositiveCount: 3},  // already proposed
+		{PatternDisplay: "wants emoji", NegativeCount: 3},   // in the profile
+		{PatternDisplay: "wants sarcasm", NegativeCount: 4}, // not in the profile
+	}
+	prof := staticProfile{profile.Profile{Preferences: []string{"uses Go", "wants emoji"}}}
+	w := NewFeedbackWorker(store, NewPreferenceExtractor(nil, "model"), prof, 0)
+
+	if _, err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if store.completeCount != 1 {
+		t.Fatalf("completed %d jobs, want 1 (failed %d)", store.completeCount, store.failCount)
+	}
+	if len(store.savedDeltas) != 2 {
+		t.Fatalf("saved %d deltas, want 2", len(store.savedDeltas))
+	}
+
+	var adds, removes profile.ProfileDelta
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].DeltaJSON), &adds); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(store.savedDeltas[1].DeltaJSON), &removes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(adds.AddPreferences, []string{"prefers short answers"}) || len(adds.RemovePreferences) != 0 {
+		t.Errorf("additions delta = %+v", adds)
+	}
+	if !reflect.DeepEqual(removes.RemovePreferences, []string{"wants emoji"}) || len(removes.AddPreferences) != 0 {
+		t.Errorf("removals delta = %+v", removes)
+	}
+
+	var evidence []SignalEvidence
+	if err := json.Unmarshal([]byte(store.savedDeltas[0].EvidenceJSON), &evidence); err != nil {
+		t.Fatal(err)
+	}
+	if len(evidence) != 1 || evidence[0].Positive != 4 || evidence[0].Confidence != 1 {
+		t.Errorf("evidence = %+v", evidence)
+	}
+	if len(store.enqueuedJobs) != 1 || store.enqueuedJobs[0].Type != DeltaPolicyJobType {
+		t.Errorf("enqueued = %+v, want a delta policy pass", store.enqueuedJobs)
+	}
+}